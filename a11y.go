@@ -0,0 +1,66 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// accessible tells whether cat writes for screen readers, which read
+// the output aloud, rather than show it: without colors, with plain
+// ASCII separators and with the boundaries of inputs in words.
+var accessible bool
+
+// arrow returns the arrow that separates a source from a destination.
+func arrow() string {
+	if accessible {
+		return "->"
+	}
+	return "→"
+}
+
+// announcer tells where inputs start and end, in words.
+type announcer struct {
+	w    io.Writer
+	last byte // the last byte written
+}
+
+func (a *announcer) Write(b []byte) (int, error) {
+	n, err := a.w.Write(b)
+	if n > 0 {
+		a.last = b[n-1]
+	}
+	return n, err
+}
+
+func (a *announcer) start(name string) error {
+	a.last = '\n'
+	_, err := fmt.Fprintf(a.w, "Start of %s.\n", inputWords(name))
+	return err
+}
+
+// end announces the end of the input, or that it failed, on a line of
+// its own also if the input did not end its last line.
+func (a *announcer) end(name string, failed bool) error {
+	sep := ""
+	if a.last != '\n' {
+		sep = "\n"
+	}
+	a.last = '\n'
+	format := "%sEnd of %s.\n"
+	if failed {
+		format = "%sFailed to read %s.\n"
+	}
+	_, err := fmt.Fprintf(a.w, format, sep, inputWords(name))
+	return err
+}
+
+func inputWords(name string) string {
+	if name == "-" {
+		return "standard input"
+	}
+	return "file " + name
+}
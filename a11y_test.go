@@ -0,0 +1,42 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnnouncer(t *testing.T) {
+	var b strings.Builder
+	a := &announcer{w: &b}
+	a.start("-")
+	a.Write([]byte("a\nb"))
+	a.end("-", false)
+	a.start("x.txt")
+	a.Write([]byte("c\n"))
+	a.end("x.txt", true)
+	want := "Start of standard input.\na\nb\nEnd of standard input.\nStart of file x.txt.\nc\nFailed to read file x.txt.\n"
+	if b.String() != want {
+		t.Fatalf("unexpected announcements %q, want %q", b.String(), want)
+	}
+}
+
+func TestMainA11y(t *testing.T) {
+	got := runMain("--a11y", "--color=always", "--group-by-ext", "testdata/b.md", "testdata/b.md")
+	want := "Files ending in .md:\n" +
+		"Start of file testdata/b.md.\nworld\nEnd of file testdata/b.md.\n" +
+		"Start of file testdata/b.md.\nworld\nEnd of file testdata/b.md.\n"
+	if got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+	// Formats other than raw have their own boundaries.
+	if got := runMain("--a11y", "--fence", "testdata/b.md"); strings.Contains(got, "Start of") {
+		t.Fatalf("unexpected announcements in %q", got)
+	}
+	if arrow() != "->" {
+		t.Fatalf("unexpected arrow %q", arrow())
+	}
+}
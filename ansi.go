@@ -0,0 +1,299 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ansiPalette holds the 16 basic colors of ANSI escape codes as xterm
+// renders them.
+var ansiPalette = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// ansiColor returns the CSS color of an entry of the 256 color palette.
+func ansiColor(n int) string {
+	switch {
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + 40*v
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		g := 8 + 10*(n-232)
+		return fmt.Sprintf("#%02x%02x%02x", g, g, g)
+	}
+}
+
+// ansiStyle is the graphic rendition set by SGR escape codes.
+type ansiStyle struct {
+	fg, bg                       string
+	bold, dim, italic, underline bool
+	inverse, strike              bool
+}
+
+// css returns the inline style of s, or "" for the default style.
+func (s ansiStyle) css() string {
+	fg, bg := s.fg, s.bg
+	if s.inverse {
+		fg, bg = bg, fg
+		if fg == "" {
+			fg = "var(--bg)"
+		}
+		if bg == "" {
+			bg = "var(--fg)"
+		}
+	}
+	var b []string
+	if fg != "" {
+		b = append(b, "color:"+fg)
+	}
+	if bg != "" {
+		b = append(b, "background:"+bg)
+	}
+	if s.bold {
+		b = append(b, "font-weight:bold")
+	}
+	if s.dim {
+		b = append(b, "opacity:.6")
+	}
+	if s.italic {
+		b = append(b, "font-style:italic")
+	}
+	switch {
+	case s.underline && s.strike:
+		b = append(b, "text-decoration:underline line-through")
+	case s.underline:
+		b = append(b, "text-decoration:underline")
+	case s.strike:
+		b = append(b, "text-decoration:line-through")
+	}
+	return strings.Join(b, ";")
+}
+
+// apply updates s by the parameters of an SGR escape code.
+func (s *ansiStyle) apply(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*s = ansiStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.dim = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 7:
+			s.inverse = true
+		case p == 9:
+			s.strike = true
+		case p == 22:
+			s.bold, s.dim = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p == 27:
+			s.inverse = false
+		case p == 29:
+			s.strike = false
+		case 30 <= p && p <= 37:
+			s.fg = ansiPalette[p-30]
+		case 90 <= p && p <= 97:
+			s.fg = ansiPalette[p-90+8]
+		case p == 39:
+			s.fg = ""
+		case 40 <= p && p <= 47:
+			s.bg = ansiPalette[p-40]
+		case 100 <= p && p <= 107:
+			s.bg = ansiPalette[p-100+8]
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			// 38;5;n picks from the 256 color palette, 38;2;r;g;b
+			// is a true color, and 48 does the same for the background.
+			var c string
+			switch {
+			case i+2 < len(params) && params[i+1] == 5:
+				c = ansiColor(params[i+2] & 0xff)
+				i += 2
+			case i+4 < len(params) && params[i+1] == 2:
+				c = fmt.Sprintf("#%02x%02x%02x", params[i+2]&0xff, params[i+3]&0xff, params[i+4]&0xff)
+				i += 4
+			default:
+				return
+			}
+			if p == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		}
+	}
+}
+
+// writeANSIHTML writes the content of r, which may be colored with ANSI
+// escape codes, as a heading with the name of the input followed by the
+// content in equivalent HTML styling. Escape codes other than colors and
+// text attributes, e.g. cursor movements, are dropped.
+func writeANSIHTML(w io.Writer, name string, r io.Reader) error {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<h2>%s</h2>\n<pre>", html.EscapeString(name))
+
+	var style ansiStyle
+	open := false
+	setStyle := func(s ansiStyle) {
+		if s == style {
+			return
+		}
+		if open {
+			bw.WriteString("</span>")
+			open = false
+		}
+		style = s
+		if css := s.css(); css != "" {
+			fmt.Fprintf(bw, `<span style="%s">`, css)
+			open = true
+		}
+	}
+	for {
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch c {
+		case '<':
+			bw.WriteString("&lt;")
+		case '>':
+			bw.WriteString("&gt;")
+		case '&':
+			bw.WriteString("&amp;")
+		case 0x1b:
+			s := style
+			if err := readEscape(br, &s); err != nil && err != io.EOF {
+				return err
+			}
+			setStyle(s)
+		default:
+			bw.WriteByte(c)
+		}
+	}
+	if open {
+		bw.WriteString("</span>")
+	}
+	bw.WriteString("</pre>\n")
+	return bw.Flush()
+}
+
+// readEscape reads the escape sequence that follows an ESC, and applies
+// it to s if it is an SGR code.
+func readEscape(r *bufio.Reader, s *ansiStyle) error {
+	seq, err := readEscapeSeq(r)
+	if err != nil {
+		return err
+	}
+	if n := len(seq); n >= 2 && seq[0] == '[' && seq[n-1] == 'm' && seq[1] != '?' {
+		s.apply(sgrParams(string(seq[1 : n-1])))
+	}
+	return nil
+}
+
+// readEscapeSeq reads the escape sequence that follows an ESC, and
+// returns it without the ESC.
+func readEscapeSeq(r *bufio.Reader) ([]byte, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	seq := []byte{c}
+	switch c {
+	case '[': // CSI: parameters, intermediate bytes, a final byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return seq, err
+			}
+			seq = append(seq, c)
+			if 0x40 <= c && c <= 0x7e {
+				return seq, nil
+			}
+		}
+	case ']', 'P', '_', '^': // strings ended by BEL or ST, e.g. titles and links
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return seq, err
+			}
+			seq = append(seq, c)
+			if c == 0x07 {
+				return seq, nil
+			}
+			if c == 0x1b {
+				c, err := r.ReadByte() // the \ of ST
+				if err != nil {
+					return seq, err
+				}
+				return append(seq, c), nil
+			}
+		}
+	}
+	return seq, nil // a two character sequence
+}
+
+// stripANSI removes the escape sequences from s.
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	var b strings.Builder
+	r := bufio.NewReader(strings.NewReader(s))
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return b.String()
+		}
+		if c == 0x1b {
+			readEscapeSeq(r)
+			continue
+		}
+		b.WriteByte(c)
+	}
+}
+
+// sgrParams parses the parameters of an SGR code, which are separated
+// by semicolons or, in the newer form of 38 and 48, by colons.
+func sgrParams(seq string) []int {
+	if seq == "" {
+		return nil
+	}
+	fields := strings.Split(strings.ReplaceAll(seq, ":", ";"), ";")
+	params := make([]int, len(fields))
+	for i, f := range fields {
+		params[i], _ = strconv.Atoi(f) // an empty parameter is 0
+	}
+	return params
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteANSIHTML(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain <b>", "plain &lt;b&gt;"},
+		{"\x1b[31mred\x1b[0m ok", `<span style="color:#cd0000">red</span> ok`},
+		{"\x1b[1;38;5;196mx\x1b[22my\x1b[m", `<span style="color:#ff0000;font-weight:bold">x</span><span style="color:#ff0000">y</span>`},
+		{"\x1b[48;2;1;2;3mbg", `<span style="background:#010203">bg</span>`},
+		{"\x1b[2K\x1b]0;title\x07\x1b[?25lline", "line"},
+		{"\x1b[4", ""},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := writeANSIHTML(&b, "log", strings.NewReader(tt.in)); err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.in, err)
+		}
+		if want := "<h2>log</h2>\n<pre>" + tt.want + "</pre>\n"; b.String() != want {
+			t.Errorf("%q: got %q, want %q", tt.in, b.String(), want)
+		}
+	}
+}
+
+func FuzzWriteANSIHTML(f *testing.F) {
+	f.Add("plain <b> & text")
+	f.Add("\x1b[1;38;5;196mx\x1b[22my\x1b[m")
+	f.Add("\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x07\x1b[")
+	tags := regexp.MustCompile(`<[^>]*>`)
+	f.Fuzz(func(t *testing.T, s string) {
+		var b strings.Builder
+		if err := writeANSIHTML(&b, "x", strings.NewReader(s)); err != nil {
+			t.Fatal(err)
+		}
+		// The text of the HTML is the input without its escape codes.
+		out := strings.TrimPrefix(b.String(), "<h2>x</h2>\n")
+		if got, want := html.UnescapeString(tags.ReplaceAllString(out, "")), stripANSI(s)+"\n"; got != want {
+			t.Fatalf("text %q of %q, want %q", got, s, want)
+		}
+	})
+}
@@ -0,0 +1,410 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() { registerFeature("archives") }
+
+// archiveFormats are the archives that -r walks like directories, by
+// their extension, and the decoder of their compression if any. The
+// formats that the standard library cannot read are converted to tar by
+// bsdtar of libarchive, or sqfs2tar of squashfs-tools-ng.
+var archiveFormats = []struct{ ext, format, decoder string }{
+	{".zip", "zip", ""},
+	{".jar", "zip", ""},
+	{".tar", "tar", ""},
+	{".tar.gz", "tar", "gzip"},
+	{".tgz", "tar", "gzip"},
+	{".tar.bz2", "tar", "bzip2"},
+	{".7z", "bsdtar", ""},
+	{".rar", "bsdtar", ""},
+	{".iso", "bsdtar", ""},
+	{".squashfs", "sqfs2tar", ""},
+	{".sqfs", "sqfs2tar", ""},
+}
+
+// toTar returns the command that converts an archive to tar, it is
+// replaced by tests.
+var toTar = func(name string, args []string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// toTarArgs returns the command and the arguments that convert an
+// archive to tar on the standard output. Windows ships bsdtar as tar.
+func toTarArgs(format, name string) (string, []string) {
+	if format == "sqfs2tar" {
+		return format, []string{name}
+	}
+	args := []string{"-c", "-f", "-", "--format=pax"}
+	if runtime.GOOS == "windows" {
+		format = "tar"
+	}
+	return format, append(args, "@"+name)
+}
+
+// passphrasePrompt is the prompt of bsdtar for the password.
+const passphrasePrompt = "Enter passphrase:"
+
+// passPassword hands the password to bsdtar on its standard input, as
+// its arguments are visible to every user, e.g. in ps. bsdtar reads it
+// from there only without a controlling terminal, and prompts for it on
+// its standard error, where the prompt is dropped.
+func passPassword(cmd *exec.Cmd, password string) error {
+	if !detachTerminal(cmd) {
+		return fmt.Errorf("--archive-password is not supported for these archives on %s", runtime.GOOS)
+	}
+	cmd.Stdin = strings.NewReader(password + "\n")
+	if cmd.Stderr != nil {
+		cmd.Stderr = &promptFilter{w: cmd.Stderr}
+	}
+	return nil
+}
+
+// promptFilter drops the password prompts of bsdtar from what it writes.
+type promptFilter struct {
+	w io.Writer
+}
+
+func (f *promptFilter) Write(p []byte) (int, error) {
+	if s := strings.ReplaceAll(string(p), passphrasePrompt, ""); s != "" {
+		if _, err := io.WriteString(f.w, s); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// archiveFormat returns the format and the decoder of the compression of
+// an archive, or an empty format for other files.
+func archiveFormat(name string) (format, decoder string) {
+	name = strings.ToLower(name)
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(name, f.ext) {
+			return f.format, f.decoder
+		}
+	}
+	return "", ""
+}
+
+// archiveMember splits an input that names a member of an archive, as
+// in logs.zip!/2021/app.log, into the archive and the member.
+func archiveMember(src string) (archive, member string, ok bool) {
+	i := strings.Index(src, "!/")
+	if i < 0 {
+		return "", "", false
+	}
+	if format, _ := archiveFormat(src[:i]); format == "" {
+		return "", "", false
+	}
+	return src[:i], src[i+2:], true
+}
+
+func isArchiveMember(src string) bool {
+	_, _, ok := archiveMember(src)
+	return ok
+}
+
+// openArchive opens an archive as a file system.
+func openArchive(name string) (fs.FS, io.Closer, error) {
+	format, decoder := archiveFormat(name)
+	switch format {
+	case "zip":
+		r, err := zip.OpenReader(longPath(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		return newZipFS(name, &r.Reader), r, nil
+	case "tar":
+		t, err := newTarFS(func() (io.ReadCloser, error) {
+			f, err := os.Open(longPath(name))
+			if err != nil {
+				return nil, err
+			}
+			if decoder == "" {
+				return f, nil
+			}
+			r, err := decoders[decoder](f)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{r, f}, nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, io.NopCloser(nil), nil
+	case "bsdtar", "sqfs2tar":
+		if _, err := os.Stat(longPath(name)); err != nil {
+			return nil, nil, err
+		}
+		t, err := newTarFS(func() (io.ReadCloser, error) {
+			cmd := toTar(toTarArgs(format, name))
+			if opts.archivePassword != "" && format == "bsdtar" {
+				if err := passPassword(cmd, opts.archivePassword); err != nil {
+					return nil, err
+				}
+			}
+			c, err := startCmd(cmd)
+			if errors.Is(err, exec.ErrNotFound) {
+				return nil, fmt.Errorf("reading the archive needs %s", format)
+			}
+			return c, err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, io.NopCloser(nil), nil
+	}
+	return nil, nil, errors.New("not an archive")
+}
+
+// openArchiveMember opens a member of an archive for reading.
+func openArchiveMember(archive, member string) (io.ReadCloser, error) {
+	src := archive + "!/" + member
+	fsys, c, err := openArchive(archive)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", archive, unwrapPathError(err))
+	}
+	f, err := fsys.Open(member)
+	if err != nil {
+		c.Close()
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return nil, fmt.Errorf("%s: No such file or directory", src)
+		}
+		return nil, fmt.Errorf("%s: %v", src, unwrapPathError(err))
+	}
+	if i, err := f.Stat(); err == nil && i.IsDir() {
+		f.Close()
+		c.Close()
+		return nil, fmt.Errorf("%s: Is a directory", src)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{f, closers{f, c}}, nil
+}
+
+// closers closes all of them in order, and returns the first error.
+type closers []io.Closer
+
+func (cs closers) Close() (err error) {
+	for _, c := range cs {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// walkArchive adds the members of an archive to the files like walk
+// does for the files of a directory, as archive!/member inputs.
+func (w *walker) walkArchive(archive string) {
+	fsys, c, err := openArchive(archive)
+	if err != nil {
+		w.errs = append(w.errs, fmt.Errorf("%s: %v", archive, unwrapPathError(err)))
+		return
+	}
+	defer c.Close()
+	w.walkFS(fsys, archive, ".", nil)
+}
+
+func (w *walker) walkFS(fsys fs.FS, archive, dir string, rules []ignoreRule) {
+	name := func(p string) string {
+		if p == "." {
+			return archive + "!"
+		}
+		return archive + "!/" + p
+	}
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		w.errs = append(w.errs, fmt.Errorf("%s: %v", name(dir), unwrapPathError(err)))
+		return
+	}
+	if !opts.noIgnore {
+		rules = rules[:len(rules):len(rules)]
+		for _, n := range ignoreFiles {
+			if f, err := fsys.Open(path.Join(dir, n)); err == nil {
+				rules = append(rules, scanIgnoreFile(name(dir), f)...)
+				f.Close()
+			}
+		}
+	}
+
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		if !opts.hidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		i, err := e.Info()
+		if err != nil {
+			w.errs = append(w.errs, fmt.Errorf("%s: %v", name(p), unwrapPathError(err)))
+			continue
+		}
+		if ignored(name(p), i.IsDir(), rules) {
+			continue
+		}
+		if i.IsDir() {
+			w.walkFS(fsys, archive, p, rules)
+			continue
+		}
+		// Links in archives point to files on the system that made
+		// them, not to other members.
+		if !i.Mode().IsRegular() || skipFile(e.Name()) || !keepFile(i) {
+			continue
+		}
+		w.files = append(w.files, name(p))
+	}
+}
+
+// tarFS is a tar archive as a file system. Only the headers are kept,
+// members are read by reading the archive again up to them, as tar
+// archives, compressed ones in particular, cannot be read at random.
+type tarFS struct {
+	stream   func() (io.ReadCloser, error) // opens the archive
+	infos    map[string]fs.FileInfo
+	index    map[string]int // of the entry of a path in the archive
+	children map[string][]string
+}
+
+func newTarFS(stream func() (io.ReadCloser, error)) (*tarFS, error) {
+	t := &tarFS{
+		stream:   stream,
+		infos:    map[string]fs.FileInfo{".": dirInfo(".")},
+		index:    map[string]int{},
+		children: map[string][]string{},
+	}
+	tr, c, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	for k := 0; ; k++ {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := memberPath(h.Name); ok {
+			t.add(p, h.FileInfo())
+			t.index[p] = k
+		}
+	}
+}
+
+// memberPath returns the path of a member in the file system, if it has
+// one. Archives may name members with a leading / or ./.
+func memberPath(name string) (string, bool) {
+	p := path.Clean(strings.TrimLeft(name, "/"))
+	return p, p != "." && fs.ValidPath(p)
+}
+
+// add adds a member and the directories above it that the archive does
+// not have entries of. Later entries of a path replace earlier ones.
+func (t *tarFS) add(p string, i fs.FileInfo) {
+	if _, ok := t.infos[p]; !ok {
+		dir := path.Dir(p)
+		if _, ok := t.infos[dir]; !ok {
+			t.add(dir, dirInfo(path.Base(dir)))
+		}
+		t.children[dir] = append(t.children[dir], p)
+	}
+	t.infos[p] = i
+}
+
+// open opens the archive as a tar stream.
+func (t *tarFS) open() (*tar.Reader, io.Closer, error) {
+	rc, err := t.stream()
+	if err != nil {
+		return nil, nil, err
+	}
+	return tar.NewReader(rc), rc, nil
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	i, ok := t.infos[name]
+	if !ok || !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if i.IsDir() {
+		return &archiveFile{info: i, Reader: strings.NewReader(""), Closer: io.NopCloser(nil)}, nil
+	}
+	if !i.Mode().IsRegular() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("not a regular file")}
+	}
+	tr, c, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	for k := 0; ; k++ {
+		h, err := tr.Next()
+		if err != nil {
+			c.Close()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if k == t.index[name] {
+			return &archiveFile{info: h.FileInfo(), Reader: tr, Closer: c}, nil
+		}
+	}
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	i, ok := t.infos[name]
+	if !ok || !i.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for _, p := range t.children[name] {
+		entries = append(entries, fs.FileInfoToDirEntry(t.infos[p]))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// archiveFile is an open member or directory of an archive.
+type archiveFile struct {
+	info fs.FileInfo
+	io.Reader
+	io.Closer
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// dirInfo is a directory of an archive without an entry of its own.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
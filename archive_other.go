@@ -0,0 +1,13 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal && !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package main
+
+import "os/exec"
+
+// detachTerminal runs the command without a controlling terminal,
+// which it cannot on this system.
+func detachTerminal(cmd *exec.Cmd) bool { return false }
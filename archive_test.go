@@ -0,0 +1,208 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// archiveFiles are the members of the test archives, with their names
+// as content, in an order that is not lexical.
+var archiveFiles = []string{"b.txt", "a/z.txt", "./a/._z.txt", ".env", "c/d/e.log", ".gitignore"}
+
+func writeZip(t *testing.T, name string) {
+	t.Helper()
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, n := range archiveFiles {
+		w, _ := zw.Create(filepath.ToSlash(filepath.Clean(n)))
+		w.Write(content(n))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+}
+
+func writeTar(t *testing.T, name string, compress bool) {
+	t.Helper()
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w io.WriteCloser = f
+	if compress {
+		w = gzip.NewWriter(f)
+	}
+	tw := tar.NewWriter(w)
+	tw.WriteHeader(&tar.Header{Name: "./a/", Mode: 0755, Typeflag: tar.TypeDir})
+	tw.WriteHeader(&tar.Header{Name: "link", Linkname: "b.txt", Typeflag: tar.TypeSymlink})
+	for i, n := range append([]string{"b.txt"}, archiveFiles...) {
+		c := content(n)
+		if i == 0 {
+			c = []byte("stale") // replaced by the later entry
+		}
+		tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(c)), Typeflag: tar.TypeReg})
+		tw.Write(c)
+	}
+	tw.Close()
+	w.Close()
+	f.Close()
+}
+
+// content is the content of a member, and the .gitignore ignores the
+// logs.
+func content(name string) []byte {
+	if name == ".gitignore" {
+		return []byte("*.log\n")
+	}
+	return []byte(filepath.Base(name))
+}
+
+func TestExpandArchive(t *testing.T) {
+	defer func() { opts = options{} }()
+
+	dir := t.TempDir()
+	zipName := filepath.Join(dir, "x.zip")
+	writeZip(t, zipName)
+
+	tests := []struct {
+		opts options
+		want []string
+	}{
+		{options{}, []string{"a/z.txt", "b.txt"}},
+		{options{noIgnore: true}, []string{"a/z.txt", "b.txt", "c/d/e.log"}},
+		{options{hidden: true, appleDouble: true}, []string{".env", ".gitignore", "a/._z.txt", "a/z.txt", "b.txt"}},
+	}
+	for _, tt := range tests {
+		opts = tt.opts
+		got, errs := expand([]string{zipName})
+		var want []string
+		for _, n := range tt.want {
+			want = append(want, zipName+"!/"+n)
+		}
+		if len(errs) != 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: got %v, %v, want %v", tt.opts, got, errs, want)
+		}
+	}
+}
+
+func TestMainArchive(t *testing.T) {
+	dir := t.TempDir()
+	zipName := filepath.Join(dir, "x.zip")
+	tgzName := filepath.Join(dir, "x.tgz")
+	writeZip(t, zipName)
+	writeTar(t, tgzName, true)
+
+	for _, name := range []string{zipName, tgzName} {
+		if out := runMain("-r", name); out != "z.txtb.txt" {
+			t.Errorf("cat -r %s: %q", name, out)
+		}
+		if out := runMain(name + "!/c/d/e.log"); out != "e.log" {
+			t.Errorf("cat %s!/c/d/e.log: %q", name, out)
+		}
+		if out, want := runMain(name+"!/a"), "cat: "+name+"!/a: Is a directory\n"; out != want {
+			t.Errorf("cat %s!/a: %q, want %q", name, out, want)
+		}
+		if out, want := runMain(name+"!/nope"), "cat: "+name+"!/nope: No such file or directory\n"; out != want {
+			t.Errorf("cat %s!/nope: %q, want %q", name, out, want)
+		}
+	}
+}
+
+func TestMainArchivePolicy(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "data")
+	os.Mkdir(allowed, 0755)
+	writeZip(t, filepath.Join(allowed, "in.zip"))
+	writeZip(t, filepath.Join(dir, "out.zip"))
+	symlink(t, filepath.Join(dir, "out.zip"), filepath.Join(allowed, "link.zip"))
+	policy := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policy, []byte("paths: ["+allowed+"]\n"), 0644)
+
+	// The link in the allowed directory leads to an archive outside.
+	in, link := filepath.Join(allowed, "in.zip")+"!/b.txt", filepath.Join(allowed, "link.zip")+"!/b.txt"
+	want := "b.txtcat: " + link + ": the path is not allowed by the policy\n"
+	if out := runMain("--policy", policy, in, link); out != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
+
+func TestMainArchiveToTar(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake converters are shell scripts")
+	}
+	dir := t.TempDir()
+	tarName := filepath.Join(dir, "x.tar")
+	writeTar(t, tarName, false)
+
+	var got []string
+	defer func(f func(string, []string) *exec.Cmd) { toTar = f }(toTar)
+	toTar = func(name string, args []string) *exec.Cmd {
+		got = append([]string{name}, args...)
+		return exec.Command("sh", "-c", `cat "$0"`, tarName)
+	}
+	tests := []struct{ ext, args string }{
+		{".7z", "bsdtar -c -f - --format=pax @%s"},
+		{".iso", "bsdtar -c -f - --format=pax @%s"},
+		{".sqfs", "sqfs2tar %s"},
+	}
+	for _, tt := range tests {
+		name := filepath.Join(dir, "x"+tt.ext)
+		os.WriteFile(name, nil, 0644)
+		if out := runMain("-r", name); out != "z.txtb.txt" {
+			t.Errorf("cat -r %s: %q", name, out)
+		}
+		if out := runMain(name + "!/c/d/e.log"); out != "e.log" {
+			t.Errorf("cat %s!/c/d/e.log: %q", name, out)
+		}
+		if want := fmt.Sprintf(tt.args, name); strings.Join(got, " ") != want {
+			t.Errorf("unexpected command %q, want %q", got, want)
+		}
+	}
+
+	// The password is handed over on stdin, not in the arguments.
+	toTar = func(name string, args []string) *exec.Cmd {
+		got = append([]string{name}, args...)
+		return exec.Command("sh", "-c", `read p && test "$p" = hunter2 && cat "$0"`, tarName)
+	}
+	name := filepath.Join(dir, "x.7z")
+	if out := runMain("--archive-password=hunter2", name+"!/c/d/e.log"); out != "e.log" {
+		t.Errorf("cat %s!/c/d/e.log: %q", name, out)
+	}
+	if want := "bsdtar -c -f - --format=pax @" + name; strings.Join(got, " ") != want {
+		t.Errorf("unexpected command %q, want %q", got, want)
+	}
+}
+
+func TestMainArchivePasswordBsdtar(t *testing.T) {
+	if _, err := exec.LookPath("bsdtar"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("needs bsdtar")
+	}
+	// bsdtar tells archives by their content, and reads a zip archive
+	// that is named like a 7z one.
+	name := filepath.Join(t.TempDir(), "enc.7z")
+	b, _ := os.ReadFile("testdata/aes.zip")
+	os.WriteFile(name, b, 0644)
+	if out := runMain("--archive-password=hunter2", name+"!/notes.txt"); out != "secret notes\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
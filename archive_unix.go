@@ -0,0 +1,19 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal && (aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachTerminal runs the command in a session of its own, without a
+// controlling terminal.
+func detachTerminal(cmd *exec.Cmd) bool {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return true
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// temps holds the temporary files of atomic mode that have not been
+// moved into place yet, so that they can be removed if cat is killed.
+var temps struct {
+	sync.Mutex
+	files map[string]bool
+	once  sync.Once
+}
+
+// createTemp creates the temporary file that is renamed to path once
+// complete. It lives in the same directory, as a rename is only
+// atomic within a file system, and is created like the file itself
+// would be, so it ends up with the same permissions under the umask.
+func createTemp(path string, mode fs.FileMode) (*os.File, string, error) {
+	dir, base := filepath.Split(path)
+	for i := 0; i < 100; i++ {
+		b := make([]byte, 6)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		name := filepath.Join(dir, "."+base+"."+hex.EncodeToString(b)+".tmp")
+
+		// Track the name before it exists to not miss a signal that
+		// arrives right after creation.
+		trackTemp(name)
+		f, err := os.OpenFile(longPath(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if err == nil {
+			return f, name, nil
+		}
+		untrackTemp(name)
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, "", fmt.Errorf("%s: %v", path, unwrapPathError(err))
+		}
+	}
+	return nil, "", fmt.Errorf("%s: cannot create a temporary file", path)
+}
+
+// commitTemp moves a complete temporary file into place. With
+// --no-clobber, a hard link is used instead of a rename, because it
+// fails rather than replaces if the file was created meanwhile.
+func commitTemp(tmp, path string) error {
+	defer removeTemp(tmp)
+	if opts.clobber == clobberNever {
+		if err := os.Link(longPath(tmp), longPath(path)); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("%s: File exists", path)
+			}
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		return nil
+	}
+	if err := os.Rename(longPath(tmp), longPath(path)); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	untrackTemp(tmp)
+	return nil
+}
+
+// removeTemp removes a temporary file that is no longer needed.
+func removeTemp(tmp string) {
+	temps.Lock()
+	defer temps.Unlock()
+	if temps.files[tmp] {
+		os.Remove(longPath(tmp))
+		delete(temps.files, tmp)
+	}
+}
+
+func trackTemp(name string) {
+	temps.once.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-c
+			removeTemps()
+			// Die from the signal as if it was never caught.
+			signal.Reset()
+			if p, err := os.FindProcess(os.Getpid()); err == nil {
+				p.Signal(sig)
+				time.Sleep(time.Second)
+			}
+			os.Exit(1)
+		}()
+	})
+
+	temps.Lock()
+	defer temps.Unlock()
+	if temps.files == nil {
+		temps.files = map[string]bool{}
+	}
+	temps.files[name] = true
+}
+
+func untrackTemp(name string) {
+	temps.Lock()
+	defer temps.Unlock()
+	delete(temps.files, name)
+}
+
+// removeTemps removes all temporary files, e.g. on an interrupt.
+func removeTemps() {
+	temps.Lock()
+	defer temps.Unlock()
+	for name := range temps.files {
+		os.Remove(longPath(name))
+		delete(temps.files, name)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMainAtomic(t *testing.T) {
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "out.txt")
+	if err := os.WriteFile(dst, []byte("old"), 0640); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	os.Chmod(dst, 0640)
+
+	// A failing input leaves the destination untouched.
+	runMain("--atomic", "-o", dst, "testdata/b.md", "none.txt")
+	if b, _ := os.ReadFile(dst); string(b) != "old" {
+		t.Fatalf("destination changed after failure: %q", b)
+	}
+
+	if got := runMain("--atomic", "--sync", "-o", dst, "testdata/b.md"); got != "" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "world" {
+		t.Fatalf("unexpected content: %q", b)
+	}
+	if i, _ := os.Stat(dst); runtime.GOOS != "windows" && i.Mode().Perm() != 0640 {
+		t.Fatalf("permissions of replaced file not kept: %v", i.Mode())
+	}
+
+	if got := runMain("--atomic", "--no-clobber", "-o", dst, "testdata/a.txt"); got != "cat: "+dst+": File exists\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestAtomicCrash(t *testing.T) {
+	defer func() { opts = options{} }()
+	opts = options{atomic: true}
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "out.txt")
+	os.WriteFile(dst, []byte("old"), 0644)
+
+	// Crash mid-write by a signal, for which removeTemps runs.
+	o, err := createOutput(dst)
+	if err != nil {
+		t.Fatalf("failed to create output: %v", err)
+	}
+	o.WriteString("partial")
+	removeTemps()
+	o.File.Close()
+
+	// Crash mid-write by a panic, for which main aborts the output.
+	o, err = createOutput(dst)
+	if err != nil {
+		t.Fatalf("failed to create output: %v", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				o.Abort()
+			}
+		}()
+		o.WriteString("partial")
+		panic("crash")
+	}()
+
+	if b, _ := os.ReadFile(dst); string(b) != "old" {
+		t.Fatalf("destination changed by crash: %q", b)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
+
+// auditLog is the file of --audit, or nil.
+var auditLog *os.File
+
+// openAuditLog opens the audit log for appending, creating it with
+// permissions for the owner only.
+func openAuditLog(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	auditLog = f
+	return nil
+}
+
+// auditRecord is a line of the audit log.
+type auditRecord struct {
+	Time   string `json:"time"`
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"` // of the bytes read
+	UID    int    `json:"uid"`    // or -1 on Windows
+	Error  string `json:"error,omitempty"`
+}
+
+// auditReader counts and hashes the raw content of an input as it is
+// read, to record it in the audit log.
+type auditReader struct {
+	r    io.Reader
+	name string
+	n    int64
+	h    hash.Hash
+}
+
+// audit wraps the raw content of the named input to record it in the
+// audit log once it was read, if there is one.
+func audit(name string, r io.Reader) (io.Reader, func(err error)) {
+	if auditLog == nil {
+		return r, func(error) {}
+	}
+	a := &auditReader{r: r, name: name, h: sha256.New()}
+	return a, a.record
+}
+
+func (a *auditReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.n += int64(n)
+	a.h.Write(p[:n])
+	return n, err
+}
+
+// record appends the record of the input to the audit log. The record
+// is written at once, so that concurrent writers do not mix lines.
+func (a *auditReader) record(err error) {
+	rec := auditRecord{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Path:   a.name,
+		Bytes:  a.n,
+		SHA256: hex.EncodeToString(a.h.Sum(nil)),
+		UID:    os.Getuid(),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	b, _ := json.Marshal(rec)
+	auditLog.Write(append(b, '\n'))
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMainAudit(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("hello\n"), 0644)
+	missing := filepath.Join(dir, "missing.txt")
+	log := filepath.Join(dir, "audit.log")
+
+	runMain("--audit", log, name, missing)
+	runMain("--audit", log, name)
+
+	f, err := os.Open(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var recs []auditRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var rec auditRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", s.Text(), err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, rec.Time); err != nil {
+			t.Fatalf("invalid time of record %q: %v", s.Text(), err)
+		}
+		if rec.UID != os.Getuid() {
+			t.Fatalf("record %q has uid %d, want %d", s.Text(), rec.UID, os.Getuid())
+		}
+		rec.Time, rec.UID = "", 0
+		recs = append(recs, rec)
+	}
+
+	ok := auditRecord{Path: name, Bytes: 6, SHA256: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"}
+	want := []auditRecord{
+		ok,
+		{Path: missing, SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Error: missing + ": No such file or directory"},
+		ok,
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(recs), len(want), recs)
+	}
+	for i := range want {
+		if recs[i] != want[i] {
+			t.Errorf("record %d: got %+v, want %+v", i, recs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+func init() { registerFeature("avro") }
+
+// avroMagic starts every Avro object container file.
+const avroMagic = "Obj\x01"
+
+func isAvro(head []byte, _ string) bool {
+	return bytes.HasPrefix(head, []byte(avroMagic))
+}
+
+// previewAvro writes the schema of an Avro object container file and
+// its first records, one JSON object per line.
+func previewAvro(w io.Writer, r *bufio.Reader) error {
+	if _, err := r.Discard(len(avroMagic)); err != nil {
+		return err
+	}
+	d := &avroDecoder{named: map[string]interface{}{}}
+	// The metadata is a map of bytes, which are encoded like strings.
+	meta, err := d.read(r, map[string]interface{}{"type": "map", "values": "string"}, "", 0)
+	if err != nil {
+		return err
+	}
+	var schemaJSON, codec string
+	for _, m := range meta.(jsonObject) {
+		switch m.key {
+		case "avro.schema":
+			schemaJSON = m.value.(string)
+		case "avro.codec":
+			codec = m.value.(string)
+		}
+	}
+	var schema interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	if codec != "" && codec != "null" && codec != "deflate" {
+		return fmt.Errorf("unsupported codec %s", codec)
+	}
+	d.register(schema, "")
+	var sync [16]byte
+	if _, err := io.ReadFull(r, sync[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	var pretty bytes.Buffer
+	json.Indent(&pretty, []byte(schemaJSON), "", "  ")
+	fmt.Fprintf(bw, "schema:\n%s\nrecords:\n", pretty.Bytes())
+
+	left := opts.previewRecords
+	if left <= 0 {
+		left = math.MaxInt
+	}
+	for left > 0 {
+		if _, err := r.Peek(1); err == io.EOF {
+			break
+		}
+		count, err := readAvroLong(r)
+		if err != nil {
+			return err
+		}
+		size, err := readAvroLong(r)
+		if err != nil {
+			return err
+		}
+		if count < 0 || size < 0 {
+			return errors.New("invalid block")
+		}
+		data, err := readN(r, uint64(size))
+		if err != nil {
+			return err
+		}
+		var block io.Reader = bytes.NewReader(data)
+		if codec == "deflate" {
+			block = flate.NewReader(block)
+		}
+		br := bufio.NewReader(block)
+		for ; count > 0 && left > 0; count, left = count-1, left-1 {
+			v, err := d.read(br, schema, "", 0)
+			if err != nil {
+				return err
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			bw.Write(append(b, '\n'))
+		}
+		if _, err := readN(r, 16); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// avroDecoder decodes Avro data by a schema in its JSON form.
+type avroDecoder struct {
+	named map[string]interface{} // the named types by their full names
+}
+
+// fullName qualifies the name of a named type by its namespace.
+func fullName(name, ns string) string {
+	if strings.Contains(name, ".") || ns == "" {
+		return name
+	}
+	return ns + "." + name
+}
+
+// register collects the named types of a schema.
+func (d *avroDecoder) register(s interface{}, ns string) {
+	switch s := s.(type) {
+	case []interface{}:
+		for _, u := range s {
+			d.register(u, ns)
+		}
+	case map[string]interface{}:
+		if name, ok := s["name"].(string); ok {
+			if n, ok := s["namespace"].(string); ok {
+				ns = n
+			}
+			name = fullName(name, ns)
+			if i := strings.LastIndexByte(name, '.'); i >= 0 {
+				ns = name[:i]
+			}
+			d.named[name] = s
+		}
+		if fields, ok := s["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if f, ok := f.(map[string]interface{}); ok {
+					d.register(f["type"], ns)
+				}
+			}
+		}
+		d.register(s["items"], ns)
+		d.register(s["values"], ns)
+		if t, ok := s["type"].(map[string]interface{}); ok {
+			d.register(t, ns)
+		}
+	}
+}
+
+// read decodes a value of the given schema.
+func (d *avroDecoder) read(r *bufio.Reader, s interface{}, ns string, depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, errNesting
+	}
+	switch t := s.(type) {
+	case []interface{}: // a union
+		i, err := readAvroLong(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t)) {
+			return nil, fmt.Errorf("invalid union branch %d", i)
+		}
+		return d.read(r, t[i], ns, depth+1)
+	case string:
+		switch t {
+		case "null":
+			return nil, nil
+		case "boolean":
+			b, err := r.ReadByte()
+			return b != 0, unexpectedEOF(err)
+		case "int", "long":
+			return readAvroLong(r)
+		case "float":
+			var b [4]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return jsonFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b[:])))), nil
+		case "double":
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			return jsonFloat(math.Float64frombits(binary.LittleEndian.Uint64(b[:]))), nil
+		case "bytes", "string":
+			n, err := readAvroLong(r)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, errors.New("negative length")
+			}
+			b, err := readN(r, uint64(n))
+			if t == "bytes" {
+				return base64.StdEncoding.EncodeToString(b), err
+			}
+			return string(b), err
+		}
+		named, ok := d.named[fullName(t, ns)]
+		if !ok {
+			if named, ok = d.named[t]; !ok {
+				return nil, fmt.Errorf("unknown type %s", t)
+			}
+		}
+		return d.read(r, named, ns, depth+1)
+	case map[string]interface{}:
+		if n, ok := t["namespace"].(string); ok {
+			ns = n
+		}
+		switch t["type"] {
+		case "record", "error":
+			var rec jsonObject
+			fields, _ := t["fields"].([]interface{})
+			for _, f := range fields {
+				f, _ := f.(map[string]interface{})
+				v, err := d.read(r, f["type"], ns, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				name, _ := f["name"].(string)
+				rec = append(rec, jsonMember{name, v})
+			}
+			if rec == nil {
+				rec = jsonObject{}
+			}
+			return rec, nil
+		case "enum":
+			i, err := readAvroLong(r)
+			if err != nil {
+				return nil, err
+			}
+			symbols, _ := t["symbols"].([]interface{})
+			if i < 0 || i >= int64(len(symbols)) {
+				return nil, fmt.Errorf("invalid enum index %d", i)
+			}
+			return symbols[i], nil
+		case "fixed":
+			size, _ := t["size"].(float64)
+			b, err := readN(r, uint64(size))
+			return base64.StdEncoding.EncodeToString(b), err
+		case "array":
+			a := []interface{}{}
+			err := readAvroBlocks(r, func() error {
+				v, err := d.read(r, t["items"], ns, depth+1)
+				a = append(a, v)
+				return err
+			})
+			return a, err
+		case "map":
+			m := jsonObject{}
+			err := readAvroBlocks(r, func() error {
+				k, err := d.read(r, "string", ns, depth+1)
+				if err != nil {
+					return err
+				}
+				v, err := d.read(r, t["values"], ns, depth+1)
+				m = append(m, jsonMember{k.(string), v})
+				return err
+			})
+			return m, err
+		default:
+			// A primitive type with attributes, e.g. a logical type.
+			return d.read(r, t["type"], ns, depth+1)
+		}
+	}
+	return nil, fmt.Errorf("invalid schema %v", s)
+}
+
+// readAvroBlocks reads the items of an array or map, which come in
+// blocks that start with their number of items.
+func readAvroBlocks(r *bufio.Reader, item func() error) error {
+	for {
+		n, err := readAvroLong(r)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			// The number of items is followed by the size of the block.
+			n = -n
+			if _, err := readAvroLong(r); err != nil {
+				return err
+			}
+		}
+		for ; n > 0; n-- {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readAvroLong reads a zig-zag encoded variable-length integer.
+func readAvroLong(r *bufio.Reader) (int64, error) {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
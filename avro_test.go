@@ -0,0 +1,83 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// avro appends Avro values: an int is written as a long, a string as
+// a string, and a []byte as it is.
+func avro(values ...interface{}) []byte {
+	var b []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case int:
+			var buf [binary.MaxVarintLen64]byte
+			b = append(b, buf[:binary.PutVarint(buf[:], int64(v))]...)
+		case string:
+			b = append(append(b, avro(len(v))...), v...)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+func testAvroFile(codec string) []byte {
+	schema := `{"type":"record","name":"User","namespace":"test","fields":[
+		{"name":"name","type":"string"},
+		{"name":"age","type":["null","int"]},
+		{"name":"tags","type":{"type":"array","items":"string"}},
+		{"name":"kind","type":{"type":"enum","name":"Kind","symbols":["A","B"]}},
+		{"name":"next","type":["null","Kind"]}]}`
+	records := avro(
+		"ann", 1, 30, 2, "x", "y", 0, 0, 1, 1,
+		"bob", 0, 0, 1, 0,
+	)
+	if codec == "deflate" {
+		var z bytes.Buffer
+		zw, _ := flate.NewWriter(&z, flate.BestCompression)
+		zw.Write(records)
+		zw.Close()
+		records = z.Bytes()
+	}
+	sync := []byte("0123456789abcdef")
+	return avro([]byte(avroMagic),
+		2, "avro.schema", schema, "avro.codec", codec, 0,
+		sync,
+		2, len(records), records, sync)
+}
+
+func TestPreviewAvro(t *testing.T) {
+	dir := t.TempDir()
+	for _, codec := range []string{"null", "deflate"} {
+		path := filepath.Join(dir, codec+".avro")
+		os.WriteFile(path, testAvroFile(codec), 0644)
+		got := runMain("--preview", path)
+		want := `{"name":"ann","age":30,"tags":["x","y"],"kind":"A","next":"B"}
+{"name":"bob","age":null,"tags":[],"kind":"B","next":null}
+`
+		if !bytes.HasPrefix([]byte(got), []byte("schema:\n{\n")) || !bytes.HasSuffix([]byte(got), []byte("records:\n"+want)) {
+			t.Fatalf("%s: unexpected preview:\n%s", codec, got)
+		}
+	}
+
+	got := runMain("--preview", "--preview-records", "1", filepath.Join(dir, "deflate.avro"))
+	if !bytes.HasSuffix([]byte(got), []byte("records:\n{\"name\":\"ann\",\"age\":30,\"tags\":[\"x\",\"y\"],\"kind\":\"A\",\"next\":\"B\"}\n")) {
+		t.Fatalf("unexpected preview:\n%s", got)
+	}
+
+	if got, want := runMain("--preview", "testdata/b.md"), "cat: testdata/b.md: no preview available for this format\n"; got != filepath.FromSlash(want) {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
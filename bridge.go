@@ -0,0 +1,58 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// bridge runs a command with its standard input fed from stdin and its
+// standard output relayed to stdout as it comes, like socat between
+// the standard streams and a program. Its standard error is ours.
+//
+// Each direction is copied on its own, so that neither waits for the
+// other. The end of stdin closes the standard input of the command,
+// which tells it that no more is coming, and the end of its standard
+// output ends the bridge.
+func bridge(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// The command may exit before stdin ends, then what is left of
+	// stdin has nowhere to go and the copy is abandoned.
+	go func() {
+		io.Copy(in, stdin)
+		in.Close()
+	}()
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stdout, out)
+		copied <- err
+	}()
+	if err := <-copied; err != nil {
+		// Nothing reads the output of the command anymore.
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
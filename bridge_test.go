@@ -0,0 +1,44 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBridge(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are shell scripts")
+	}
+
+	// The end of the input ends the command, which reads all of it.
+	var out bytes.Buffer
+	err := bridge("sh", []string{"-c", "tr a-z A-Z; echo done"}, strings.NewReader("hello\nworld\n"), &out)
+	if err != nil || out.String() != "HELLO\nWORLD\ndone\n" {
+		t.Fatalf("unexpected output %q, %v", out.String(), err)
+	}
+
+	// The output is relayed before the input ends, and the command can
+	// end before it.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	out.Reset()
+	done := make(chan error)
+	go func() { done <- bridge("sh", []string{"-c", "head -n 1; exit 3"}, pr, &out) }()
+	pw.Write([]byte("first\n"))
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "sh: exit status 3" || out.String() != "first\n" {
+			t.Fatalf("unexpected output %q, %v", out.String(), err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the bridge waits for the end of the input")
+	}
+}
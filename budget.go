@@ -0,0 +1,119 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// errBudget is returned by a budgetWriter once its budget is used up.
+var errBudget = errors.New("output budget reached")
+
+// budgetWriter stops the output once a number of characters or tokens
+// was written, e.g. to keep a prompt assembled from many files within
+// the context window of a language model. It remembers which inputs
+// were cut short or left out, so that they can be reported.
+//
+// Tokens are counted by a simple tokenizer, not by the one of any
+// particular model: every punctuation character is a token, and every
+// word counts as one token per four characters. Whitespace is free.
+// This is close enough to common tokenizers for English text and code
+// to stay within a budget that leaves some margin.
+type budgetWriter struct {
+	w         io.Writer
+	maxChars  int64 // or 0 for no limit
+	maxTokens int64 // or 0 for no limit
+
+	chars  int64
+	tokens int64
+	word   int // characters of the current word so far
+	done   bool
+
+	name      string // of the input being written
+	wrote     bool   // whether anything of the input was written
+	truncated string
+	omitted   []string
+}
+
+// start notes that the named input is written next. It reports false
+// if the budget is used up already, and the input is to be left out.
+func (b *budgetWriter) start(name string) bool {
+	if b.done {
+		b.omitted = append(b.omitted, name)
+		return false
+	}
+	b.name, b.wrote = name, false
+	return true
+}
+
+func (b *budgetWriter) Write(p []byte) (int, error) {
+	if b.done {
+		return 0, errBudget
+	}
+	n := b.fit(p)
+	if n > 0 {
+		b.wrote = true
+	}
+	m, err := b.w.Write(p[:n])
+	if err != nil || n == len(p) {
+		return m, err
+	}
+	b.done = true
+	if b.wrote {
+		b.truncated = b.name
+	} else {
+		b.omitted = append(b.omitted, b.name)
+	}
+	return m, errBudget
+}
+
+// fit counts p against the budget and returns the length of its
+// longest prefix that stays within the budget.
+func (b *budgetWriter) fit(p []byte) int {
+	for i, c := range p {
+		if !utf8.RuneStart(c) {
+			continue // part of the character counted already
+		}
+		token := false
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			b.word = 0
+		case c >= utf8.RuneSelf || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || '0' <= c && c <= '9':
+			token = b.word%4 == 0
+			b.word++
+		default:
+			token = true
+			b.word = 0
+		}
+		if b.maxChars > 0 && b.chars >= b.maxChars {
+			return i
+		}
+		if token && b.maxTokens > 0 && b.tokens >= b.maxTokens {
+			return i
+		}
+		b.chars++
+		if token {
+			b.tokens++
+		}
+	}
+	return len(p)
+}
+
+// report prints what was left out because of the budget, if anything.
+func (b *budgetWriter) report(w io.Writer) {
+	if !b.done {
+		return
+	}
+	fmt.Fprintf(w, "cat: output budget reached after %d characters and %d tokens\n", b.chars, b.tokens)
+	if b.truncated != "" {
+		fmt.Fprintf(w, "cat: truncated %s\n", b.truncated)
+	}
+	for _, name := range b.omitted {
+		fmt.Fprintf(w, "cat: omitted %s\n", name)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBudgetWriter(t *testing.T) {
+	tests := []struct {
+		in        string
+		maxChars  int64
+		maxTokens int64
+		want      string
+	}{
+		{"hello, 世界", 8, 0, "hello, 世"},
+		{"hello, 世界", 0, 3, "hello, "}, // hell o ,
+		{"a+b c", 0, 3, "a+b "},
+		{"abc", 10, 10, "abc"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		b := &budgetWriter{w: &buf, maxChars: tt.maxChars, maxTokens: tt.maxTokens}
+		b.start("in")
+		// One byte at a time, so that characters span writes.
+		var err error
+		for i := 0; i < len(tt.in) && err == nil; i++ {
+			_, err = b.Write([]byte{tt.in[i]})
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%q with %d chars, %d tokens: got %q, want %q", tt.in, tt.maxChars, tt.maxTokens, buf.String(), tt.want)
+		}
+		if full := tt.want == tt.in; full != (err == nil) {
+			t.Errorf("%q: unexpected error %v", tt.in, err)
+		}
+	}
+}
+
+func TestMainBudget(t *testing.T) {
+	got := runMain("--max-chars", "3", "testdata/b.md", "testdata/a.txt", "testdata/x.png")
+	want := "wor" +
+		"cat: output budget reached after 3 characters and 1 tokens\n" +
+		"cat: truncated testdata/b.md\n" +
+		"cat: omitted testdata/a.txt\n" +
+		"cat: omitted testdata/x.png\n"
+	if got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+
+	got = runMain("--max-tokens", "1", "testdata/b.md", "testdata/a.txt")
+	want = "worl" +
+		"cat: output budget reached after 4 characters and 1 tokens\n" +
+		"cat: truncated testdata/b.md\n" +
+		"cat: omitted testdata/a.txt\n"
+	if got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
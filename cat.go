@@ -5,77 +5,1049 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-func main() {
-	flag.CommandLine.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: cat [FILE]...
+// options are the command line flags that tune how the inputs are
+// processed. They are registered on every run of main, so that each
+// parse starts from the defaults.
+type options struct {
+	stripPaste bool
+	sudo       bool
+	fds        fdList
+
+	recursive         bool
+	followDirSymlinks bool
+	hidden            bool
+	noIgnore          bool
+	appleDouble       bool
+	minSize           sizeFlag
+	maxSize           sizeFlag
+	newerThan         timeFlag
+	olderThan         timeFlag
+	groupByExt        bool
+	listStreams       bool
+	xattrs            bool
+	ciPaths           bool
+	archivePassword   string
+
+	format    string
+	fence     bool
+	html      bool
+	ansi2html bool
+	pdf       string
+	table     tableFlag
+	pager     bool
+	full      bool
+	program   string
+	preset    string
+	helpJSON  bool
+	features  bool
+	summary   bool
+	guard     bool
+	summarize sizeFlag
+	maxMemory sizeFlag
+	unchanged string
+	watch     bool
+	peek      peekFlag
+	chop      bool
+	pretty    bool
+	lang      string
+
+	preview        bool
+	previewRecords int
+
+	protoDesc      string
+	protoType      string
+	protoDelimited bool
+	decoders       []string // applied to every input after its annotations
+	mail           bool
+	jwt            bool
+
+	processIncludes bool
+	includePattern  string
+	template        bool
+	data            string
+	applyPatch      string
+	bridge          string
+	pty             bool
+	replay          bool
+	replayTiming    string
+	replaySpeed     float64
+	delayPerLine    time.Duration
+	typewriter      float64
+	envsubst        bool
+	envAllow        []string
+	stripComments   bool
+	stripBlankLines bool
+	number          bool
+	redact          redactFlag
+	redactConfig    string
+
+	expectSHA256 digestList
+	verifyMode   string
+	minisignKey  string
+	sshKey       string
+	sshNamespace string
+	policy       string
+	audit        string
+	profile      string
+	profileHTTP  string
+	frame        string
+	frameSize    sizeFlag
+	unframe      bool
+
+	timeout        time.Duration
+	perFileTimeout time.Duration
+	onTimeout      string
+	ws             string
+	serve          string
+	grpc           string
+	grpcCert       string
+	grpcKey        string
+	publish        string
+	kafka          string
+	kafkaKey       string
+	kafkaPartition int
+	kafkaBatchSize sizeFlag
+	kafkaLinger    time.Duration
+	syslog         syslogFlag
+	syslogFacility string
+	syslogSeverity string
+	syslogTag      string
+
+	htmlTheme string
+	color     string
+	a11y      bool
+	splitDir  string
+	maxChars  int64
+	maxTokens int64
+
+	write   string
+	mode    fileMode
+	clobber clobber
+	atomic  bool
+	sync    bool
+	owner   string
+	group   string
+}
+
+var opts options
+
+// errNotExist is the error of inputs that do not exist.
+var errNotExist = errors.New("No such file or directory")
+
+const usage = `Usage: cat [FILE]...
 Concatenate FILE(s) to standard output.
 
 examples:
 $ cat --help
 $ cat ./cat.go
-`)
+`
+
+// run runs cat as the flags tell, and returns its exit code.
+func run() (code int) {
+	msgLang = localeLang()
+	flag.CommandLine.Usage = func() {
+		fmt.Fprint(os.Stderr, tr(usage))
 		flag.PrintDefaults()
 	}
 	flag.CommandLine.SetOutput(io.Discard)
-	flag.Parse()
+	setupConsole(os.Stdout)
+	setupConsole(os.Stderr)
+	// Another program may have left stdout non-blocking. If one switches
+	// it again while cat runs, retryWriter waits out the EAGAIN errors.
+	setBlocking(os.Stdout)
+
+	opts = options{}
+	fenced, templateData = false, nil
+	flag.StringVar(&opts.program, "for", "", "suit the output to the given `program` that reads it, e.g. grep or jq, whose lines it writes whole and, for jq, without banners, or less, for which it aligns --table as on a terminal; detected on Linux if not given")
+	flag.StringVar(&opts.preset, "preset", "", "use the flags of the preset of the given `name` in the config file, $CAT_CONFIG or cat/config.yaml in the config directory of the user, before those of the command line")
+	flag.BoolVar(&opts.helpJSON, "help-json", false, "print the flags, with their types, defaults and descriptions, as JSON and exit")
+	flag.BoolVar(&opts.features, "features", false, "print the optional features and whether they are compiled in, which the minimal build tag leaves out, and exit")
+	flag.BoolVar(&opts.stripPaste, "strip-paste", false, "strip bracketed paste sequences and CRs when stdin is a terminal")
+	flag.BoolVar(&opts.sudo, "sudo", false, "read files that are not permitted to the current user through sudo")
+	flag.Var(&opts.fds, "fd", "read from the given file descriptor before any FILE, can be repeated")
+	flag.BoolVar(&opts.recursive, "r", false, "read all files under each directory and archive, recursively")
+	flag.BoolVar(&opts.followDirSymlinks, "follow-dir-symlinks", false, "follow symbolic links to directories with -r")
+	flag.BoolVar(&opts.hidden, "hidden", false, "include hidden files and directories with -r")
+	flag.BoolVar(&opts.noIgnore, "no-ignore", false, "do not respect .gitignore and .catignore files with -r")
+	flag.BoolVar(&opts.appleDouble, "apple-double", false, "include AppleDouble ._* files and .DS_Store with -r --hidden")
+	flag.Var(&opts.minSize, "min-size", "only read files of at least the given `size`, e.g. 10K, with -r")
+	flag.Var(&opts.maxSize, "max-size", "only read files of at most the given `size`, e.g. 1M, with -r")
+	flag.Var(&opts.newerThan, "newer-than", "only read files modified after the given `time`, e.g. 24h or 2021-11-07, with -r")
+	flag.Var(&opts.olderThan, "older-than", "only read files modified before the given `time`, e.g. 7d or 2021-11-07, with -r")
+	flag.BoolVar(&opts.groupByExt, "group-by-ext", false, "order the inputs by file extension, with a banner before each group")
+	flag.BoolVar(&opts.listStreams, "list-streams", false, "list the data streams (NTFS streams, macOS resource forks) of each FILE instead of its content")
+	flag.BoolVar(&opts.xattrs, "xattrs", false, "print the extended attributes of each FILE instead of its content")
+	flag.BoolVar(&opts.ciPaths, "ci-paths", false, "fall back to a case-insensitive match if a FILE does not exist")
+	flag.StringVar(&opts.archivePassword, "archive-password", "", "the `password` of encrypted archives, asked for on the terminal if needed and not given")
+	flag.StringVar(&opts.format, "format", "raw", "output format: raw, records to frame each input with its name and length, fence for Markdown code blocks, html, ansi2html, or pdf")
+	flag.BoolVar(&opts.html, "html", false, "write a highlighted, line-numbered HTML document of the inputs, same as --format=html")
+	flag.BoolVar(&opts.ansi2html, "ansi2html", false, "write an HTML document of the inputs that renders their ANSI colors, same as --format=ansi2html")
+	flag.StringVar(&opts.pdf, "pdf", "", "write a paginated, line-numbered PDF listing of the inputs to the given `file`, like --format=pdf -o file")
+	flag.BoolVar(&opts.pretty, "pretty", false, "pretty-print JSON, XML, INI and TOML inputs, as told by their extension or --lang")
+	flag.StringVar(&opts.lang, "lang", "", "treat all inputs as the given `language`, e.g. json, xml, ini or toml, with --pretty or --strip-comments")
+	flag.StringVar(&opts.protoDesc, "proto-desc", "", "decode protobuf inputs with the message types of the given descriptor set `file`, as written by protoc --descriptor_set_out")
+	flag.StringVar(&opts.protoType, "proto-type", "", "decode protobuf inputs as messages of the given `type`, e.g. pkg.Message, as JSON")
+	flag.BoolVar(&opts.protoDelimited, "proto-delimited", false, "read protobuf inputs as streams of length-delimited messages")
+	flag.Func("decode", "decode every input with the named decoder, e.g. msgpack or cbor to JSON, or gzip, as if annotated with `name`:", func(v string) error {
+		name := strings.ToLower(v)
+		if _, ok := decoders[name]; !ok {
+			return fmt.Errorf("unknown decoder %q", v)
+		}
+		opts.decoders = append(opts.decoders, name)
+		return nil
+	})
+	flag.BoolVar(&opts.mail, "mail", false, "render inputs as email messages, single RFC 2822 messages or mbox files, with decoded text and a list of attachments")
+	flag.BoolVar(&opts.jwt, "jwt", false, "decode the header and payload of JSON Web Tokens as JSON, without verifying them")
+	flag.StringVar(&opts.bridge, "bridge", "", "run the given `command`, with the arguments after --, and relay stdin to it and its output to stdout as they come, like a small socat")
+	flag.BoolVar(&opts.pty, "pty", false, "run the command of --bridge under a pseudo-terminal, for interactive programs")
+	flag.BoolVar(&opts.replay, "replay", false, "play inputs that are ttyrec recordings with the timing they were recorded with")
+	flag.StringVar(&opts.replayTiming, "replay-timing", "", "play inputs that are typescripts of script(1) with the timing in the given `file` of script -t or --log-timing, implies --replay")
+	flag.Float64Var(&opts.replaySpeed, "replay-speed", 1, "play --replay and --delay-per-line faster by the given `factor`, or slower below 1")
+	flag.DurationVar(&opts.delayPerLine, "delay-per-line", 0, "wait for the given `duration` before every line after the first, e.g. 200ms")
+	flag.Float64Var(&opts.typewriter, "typewriter", 0, "write the given number of characters per second, `CPS`, like a typewriter for demos and screencasts")
+	flag.StringVar(&opts.applyPatch, "apply-patch", "", "apply the unified diff in the given `file` to the inputs it is about, or to every input if it is about a single file, and write the patched content")
+	flag.BoolVar(&opts.envsubst, "envsubst", false, "replace references to environment variables, $VAR or ${VAR}, by their values like envsubst")
+	flag.Func("env-allow", "only replace the listed environment variables with --envsubst, given as comma separated `names`, can be repeated", func(v string) error {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimPrefix(strings.TrimSpace(name), "$"); name != "" {
+				opts.envAllow = append(opts.envAllow, strings.Trim(name, "{}"))
+			}
+		}
+		return nil
+	})
+	flag.BoolVar(&opts.processIncludes, "process-includes", false, "replace include directives, by default #include \"file\", with the content of the files they name")
+	flag.StringVar(&opts.includePattern, "include-pattern", defaultIncludePattern, "the `regexp` of include directives, whose first group is the name of the included file")
+	flag.BoolVar(&opts.template, "template", false, "render inputs as Go templates, with the data of --data")
+	flag.StringVar(&opts.data, "data", "", "render --template inputs with the JSON data of the given `file`")
+	flag.BoolVar(&opts.stripComments, "strip-comments", false, "remove the comments of inputs in the syntax of their language, as told by their extension or --lang")
+	flag.BoolVar(&opts.stripBlankLines, "strip-blank-lines", false, "remove blank lines")
+	flag.BoolVar(&opts.number, "n", false, "number the output lines, continuing from one input to the next")
+	flag.BoolVar(&opts.number, "number", false, "number the output lines, same as -n")
+	flag.Var(&opts.redact, "redact", "mask secrets such as AWS keys, bearer tokens, passwords and private keys, or what the comma separated `profiles` given as --redact=PROFILES match: secrets, emails, ipv4, ipv6 or credit-cards")
+	flag.StringVar(&opts.redactConfig, "redact-config", "", "also mask the matches of the regexps in the given `file`, one per line, or of their first group")
+	flag.Var(&opts.expectSHA256, "expect-sha256", "fail unless the inputs have the given comma separated SHA-256 `digests`, one per input in order")
+	flag.StringVar(&opts.verifyMode, "verify-mode", "before", "when to check --expect-sha256: before writing an input, or after it, which streams it")
+	flag.StringVar(&opts.minisignKey, "minisign-key", "", "verify each input against its minisign signature in FILE.minisig, or URL.minisig, by the given public `key` or key file before writing it")
+	flag.StringVar(&opts.sshKey, "ssh-key", "", "verify each input against its SSH signature in FILE.sig, or URL.sig, by one of the given public `keys` in authorized_keys format, or in that file, before writing it")
+	flag.StringVar(&opts.sshNamespace, "ssh-namespace", "file", "the `namespace` that --ssh-key signatures are made for")
+	flag.StringVar(&opts.policy, "policy", os.Getenv("CAT_POLICY"), "only read the inputs that the policy in the given `file` allows, by their schemes, hosts and path prefixes, defaults to $CAT_POLICY")
+	flag.StringVar(&opts.audit, "audit", "", "append a JSON record of the path, size, SHA-256 digest and time of each input read, and of the uid that read it, to the given `file`")
+	flag.StringVar(&opts.profile, "profile", "", "write a CPU profile of the run to the given `file`, for go tool pprof")
+	flag.StringVar(&opts.profileHTTP, "profile-http", "", "serve the profiles of the running process at the given `address`, e.g. :6060, under /debug/pprof/")
+	flag.StringVar(&opts.frame, "frame", "", "frame the output in chunks that are each followed by a trailer record with their `checksum`, crc32c, for --unframe to detect corruption")
+	flag.Var(&opts.frameSize, "frame-size", "the `size` of the chunks of --frame, 64K by default")
+	flag.BoolVar(&opts.unframe, "unframe", false, "verify and strip the frames of inputs written with --frame, and fail at the first corrupt one")
+	flag.DurationVar(&opts.timeout, "timeout", 0, "stop after the given `duration`, e.g. 30s, write what was read so far and exit with 124, as timeout(1) does")
+	flag.DurationVar(&opts.perFileTimeout, "per-file-timeout", 0, "give up on an input that takes longer than the given `duration` to open and read, e.g. 30s, such as a file on a hung NFS mount or a stalled URL")
+	flag.StringVar(&opts.onTimeout, "on-timeout", "skip", "what to do when an input times out: skip it and read the next, or abort to read no more inputs")
+	flag.StringVar(&opts.ws, "ws", "", "send each line of the output as a message to the WebSocket server at the given `url`, ws:// or wss://, instead of stdout")
+	flag.StringVar(&opts.serve, "serve", "", "serve the lines of the output at the given `address`, e.g. :8080, instead of stdout, once the first client connected: as Server-Sent Events at /events, to WebSocket clients, and as a page that tails them at /")
+	flag.StringVar(&opts.publish, "publish", "", "publish each line of the output as a message to the given `url` instead of stdout: a NATS subject as nats://[user:pass@]host[:port]/subject, or an MQTT topic as mqtt://[user:pass@]host[:port]/topic")
+	flag.StringVar(&opts.kafka, "kafka", "", "produce each line of the output as a record to a Kafka topic instead of stdout, given as `brokers/topic`, e.g. host1:9092,host2:9092/logs")
+	flag.StringVar(&opts.kafkaKey, "kafka-key", "", "the `key` of the records of --kafka, which chooses their partition as other Kafka clients do, rather than spreading the batches over all partitions")
+	flag.IntVar(&opts.kafkaPartition, "kafka-partition", -1, "produce the records of --kafka to the given `partition`, rather than choosing it by key")
+	flag.Var(&opts.kafkaBatchSize, "kafka-batch-size", "send the records of --kafka in batches of about the given `size`, 16K by default")
+	flag.DurationVar(&opts.kafkaLinger, "kafka-linger", 100*time.Millisecond, "send a batch of --kafka records once it waited for the given `duration`, even if it is not full")
+	flag.Var(&opts.syslog, "syslog", "forward each line of the output to the local syslog daemon instead of stdout, or to the server at the `address` given as --syslog=ADDRESS: udp://host:port, tcp://host:port or the path of a Unix socket")
+	flag.StringVar(&opts.syslogFacility, "syslog-facility", "user", "the `facility` of the messages of --syslog, e.g. user, daemon or local0")
+	flag.StringVar(&opts.syslogSeverity, "syslog-severity", "info", "the `severity` of the messages of --syslog, e.g. info, notice or err")
+	flag.StringVar(&opts.syslogTag, "syslog-tag", "cat", "the `tag` of the messages of --syslog, i.e. their app name")
+	flag.StringVar(&opts.grpc, "grpc", "", "serve the files that the --policy allows to gRPC clients at the given `address`, e.g. :9000, as streams of the method cat.FileService/Read, written as the other flags tell, over TLS with --grpc-cert and --grpc-key")
+	flag.StringVar(&opts.grpcCert, "grpc-cert", "", "the certificate `file` of --grpc, in PEM")
+	flag.StringVar(&opts.grpcKey, "grpc-key", "", "the key `file` of --grpc, in PEM")
+	flag.StringVar(&opts.serve, "serve-ws", "", "serve the lines of the output to WebSocket clients at the given `address`, same as --serve")
+	flag.BoolVar(&opts.preview, "preview", false, "print a preview of each FILE of a binary format, e.g. the schema and first records of an Avro file, the packets of a capture, the libraries and sections of an executable, the cells of a notebook, or the subject and validity of a certificate")
+	flag.IntVar(&opts.previewRecords, "preview-records", 10, "the number of records or packets shown by --preview, or 0 for all")
+	flag.Var(&opts.peek, "peek", "print only the first and the last 10 lines of each input, or `N` lines given as --peek=N, with a line that tells how many were left out in between")
+	flag.BoolVar(&opts.full, "full", false, "print files larger than --summarize-above in full to a terminal rather than a summary of them")
+	flag.BoolVar(&opts.guard, "interactive-guard", true, "ask before printing binary files, and files larger than --summarize-above, to a terminal, or with --interactive-guard=false summarize large files and print binary ones without asking, e.g. in scripts")
+	flag.Var(&opts.summarize, "summarize-above", "write a summary of files larger than the given `size`, 1M by default, to a terminal rather than their content, with their size, type and first and last lines")
+	flag.StringVar(&opts.unchanged, "skip-if-unchanged", "", "skip the inputs whose content is the same as when they were written the last time, as recorded by their hashes in the given state `file`, e.g. for incremental bundles")
+	flag.Var(&opts.maxMemory, "max-memory", "hold at most the given `size` in memory in the modes that buffer inputs or the output, e.g. 64M: --fence spills to a temporary file beyond it, --pager writes the output without paging, and other modes, like --template and --format=html, fail")
+	flag.BoolVar(&opts.pager, "pager", false, "show the output in a built-in pager when writing to a terminal, with / and ? to search, NUMg to go to a line, and mX and 'X to mark a position and come back to it")
+	flag.BoolVar(&opts.chop, "chop-long-lines", false, "chop lines that are longer than the terminal is wide at its edge, marked with >, rather than wrapping them, also in the --pager, which scrolls to the right and left with the arrow keys")
+	flag.Var(&opts.table, "table", "align the columns of lines split at runs of whitespace, or at the `delimiter` given as --table=DELIM, when writing to a terminal")
+	flag.StringVar(&opts.color, "color", "auto", "color the banners and headers that cat writes: auto, always or never; auto colors them on a terminal unless NO_COLOR is set or TERM is dumb, or if CLICOLOR_FORCE is set and not 0")
+	flag.BoolVar(&opts.a11y, "a11y", false, "write for screen readers: no colors, plain ASCII separators, and the start and end of every input and group in words")
+	flag.StringVar(&opts.htmlTheme, "html-theme", "light", "color theme of the HTML output: light or dark")
+	flag.BoolVar(&opts.fence, "fence", false, "wrap each input in a Markdown code block with its language and name, same as --format=fence")
+	flag.Int64Var(&opts.maxChars, "max-chars", 0, "stop the output after `n` characters and report what was left out")
+	flag.Int64Var(&opts.maxTokens, "max-tokens", 0, "stop the output after about `n` tokens, as counted by a simple tokenizer, and report what was left out")
+	flag.StringVar(&opts.splitDir, "split-by-banner", "", "split inputs in the records format back into files below the given `directory`")
+	flag.BoolVar(&opts.summary, "summary", false, "print how many inputs were written and which failed to stderr at the end")
+	flag.BoolVar(&opts.watch, "watch", false, "write the output to the file of -o, and again whenever the input files change, e.g. to bundle assets while editing them, until interrupted")
+	flag.StringVar(&opts.write, "write", "", "write the output to the given `file` instead of stdout")
+	flag.StringVar(&opts.write, "o", "", "write the output to the given `file` instead of stdout, same as --write")
+	flag.Var(&opts.mode, "mode", "set the permissions of the file created by -o, e.g. 0644")
+	flag.StringVar(&opts.owner, "owner", "", "set the owner of the file created by -o, by `name` or id")
+	flag.StringVar(&opts.group, "group", "", "set the group of the file created by -o, by `name` or id")
+	flag.Var(clobberFlag{&opts.clobber, clobberNever}, "no-clobber", "do not overwrite an existing file with -o")
+	flag.Var(clobberFlag{&opts.clobber, clobberForce}, "force", "replace an existing file with -o even if it is not writable")
+	flag.Var(clobberFlag{&opts.clobber, clobberAsk}, "interactive", "ask before overwriting an existing file with -o")
+	flag.BoolVar(&opts.atomic, "atomic", false, "replace the file written with -o only once all output was written successfully")
+	flag.BoolVar(&opts.sync, "sync", false, "flush the file written with -o to stable storage before exiting")
+	if name, ok := presetName(os.Args[1:]); ok {
+		if err := applyPreset(flag.CommandLine, name); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+	}
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		return exitUsage
+	}
+	if opts.helpJSON {
+		if err := writeHelpJSON(os.Stdout, flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitWrite
+		}
+		return exitOK
+	}
+	if opts.features {
+		if err := writeFeatures(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitWrite
+		}
+		return exitOK
+	}
+
+	switch {
+	case opts.fence:
+		opts.format = "fence"
+	case opts.html:
+		opts.format = "html"
+	case opts.ansi2html:
+		opts.format = "ansi2html"
+	case opts.pdf != "":
+		if opts.write != "" {
+			fmt.Fprint(os.Stderr, tr("cat: --pdf cannot be used with -o\n"))
+			return exitUsage
+		}
+		opts.format, opts.write = "pdf", opts.pdf
+	}
+	switch opts.format {
+	case "raw", "records", "fence", "html", "ansi2html", "pdf":
+	default:
+		fmt.Fprintf(os.Stderr, tr("cat: unknown output format %q\n"), opts.format)
+		return exitUsage
+	}
+	if opts.protoDesc != "" || opts.protoType != "" {
+		if opts.protoDesc == "" || opts.protoType == "" {
+			fmt.Fprint(os.Stderr, tr("cat: --proto-desc and --proto-type have to be used together\n"))
+			return exitUsage
+		}
+		if err := setupProto(opts.protoDesc, opts.protoType, opts.protoDelimited); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		opts.decoders = append(opts.decoders, "proto")
+	}
+	if opts.processIncludes {
+		if err := setupIncludes(opts.includePattern); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+	}
+	if opts.profile != "" {
+		stop, err := startCPUProfile(opts.profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		defer stop()
+	}
+	if opts.profileHTTP != "" {
+		stop, err := serveProfiles(opts.profileHTTP)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		defer stop()
+	}
+	auditLog = nil
+	if opts.audit != "" {
+		if err := openAuditLog(opts.audit); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		defer auditLog.Close()
+	}
+	inputPatch = nil
+	if opts.applyPatch != "" {
+		p, err := readPatch(opts.applyPatch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		inputPatch = p
+	}
+	inputPolicy = nil
+	if opts.policy != "" {
+		p, err := loadPolicy(opts.policy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+		inputPolicy = p
+	}
+	if opts.verifyMode != "before" && opts.verifyMode != "after" {
+		fmt.Fprintf(os.Stderr, tr("cat: unknown verify mode %q\n"), opts.verifyMode)
+		return exitUsage
+	}
+	if opts.redactConfig != "" && !opts.redact.set {
+		opts.redact.Set("true")
+	}
+	if opts.redact.set {
+		if err := setupRedact(opts.redact.profiles, opts.redactConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+	}
+	if opts.data != "" {
+		if !opts.template {
+			fmt.Fprint(os.Stderr, tr("cat: --data can only be used with --template\n"))
+			return exitUsage
+		}
+		if err := loadTemplateData(opts.data); err != nil {
+			fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+			return exitUsage
+		}
+	}
+	if opts.replayTiming != "" {
+		opts.replay = true
+	}
+	if opts.replaySpeed <= 0 {
+		fmt.Fprint(os.Stderr, tr("cat: --replay-speed must be positive\n"))
+		return exitUsage
+	}
+	if opts.typewriter < 0 {
+		fmt.Fprint(os.Stderr, tr("cat: --typewriter must not be negative\n"))
+		return exitUsage
+	}
+	if opts.pty && opts.bridge == "" {
+		fmt.Fprint(os.Stderr, tr("cat: --pty can only be used with --bridge\n"))
+		return exitUsage
+	}
+	if opts.frame != "" && opts.frame != "crc32c" {
+		fmt.Fprintf(os.Stderr, tr("cat: unknown frame checksum %q\n"), opts.frame)
+		return exitUsage
+	}
+	if opts.onTimeout != "skip" && opts.onTimeout != "abort" {
+		fmt.Fprintf(os.Stderr, tr("cat: unknown --on-timeout action %q\n"), opts.onTimeout)
+		return exitUsage
+	}
+	sinks := 0
+	for _, sink := range []string{opts.ws, opts.serve, opts.publish, opts.kafka} {
+		if sink != "" {
+			sinks++
+		}
+	}
+	if opts.syslog.set {
+		sinks++
+	}
+	if sinks > 1 {
+		fmt.Fprint(os.Stderr, tr("cat: only one of --ws, --serve, --publish, --kafka and --syslog can be used\n"))
+		return exitUsage
+	}
+	if sinks > 0 && opts.write != "" {
+		fmt.Fprint(os.Stderr, tr("cat: --ws, --serve, --publish, --kafka and --syslog cannot be used with -o\n"))
+		return exitUsage
+	}
+	if opts.grpc != "" {
+		switch {
+		case opts.grpcCert == "" || opts.grpcKey == "":
+			fmt.Fprint(os.Stderr, tr("cat: --grpc needs --grpc-cert and --grpc-key\n"))
+			return exitUsage
+		case inputPolicy == nil || inputPolicy.schemes == nil:
+			fmt.Fprint(os.Stderr, tr("cat: --grpc needs a --policy with the schemes and files that clients may read\n"))
+			return exitUsage
+		case len(flag.Args()) > 0 || sinks > 0 || opts.write != "" || opts.format == "pdf":
+			fmt.Fprint(os.Stderr, tr("cat: --grpc cannot be used with inputs, outputs or PDF\n"))
+			return exitUsage
+		}
+	}
+	if opts.unframe {
+		// Frames are stripped before anything else is decoded.
+		opts.decoders = append([]string{"unframe"}, opts.decoders...)
+	}
+	if opts.mail {
+		opts.decoders = append(opts.decoders, "mail")
+	}
+	if opts.jwt {
+		opts.decoders = append(opts.decoders, "jwt")
+	}
+	if _, ok := htmlThemes[opts.htmlTheme]; !ok {
+		fmt.Fprintf(os.Stderr, tr("cat: unknown HTML theme %q\n"), opts.htmlTheme)
+		return exitUsage
+	}
+	if opts.color != "auto" && opts.color != "always" && opts.color != "never" {
+		fmt.Fprintf(os.Stderr, tr("cat: --color must be auto, always or never, not %q\n"), opts.color)
+		return exitUsage
+	}
+	if opts.timeout < 0 {
+		fmt.Fprint(os.Stderr, tr("cat: --timeout must not be negative\n"))
+		return exitUsage
+	}
+	if opts.format != "raw" && opts.format != "fence" && (opts.maxChars > 0 || opts.maxTokens > 0) {
+		fmt.Fprintf(os.Stderr, tr("cat: --max-chars and --max-tokens cannot be used with the %s format\n"), opts.format)
+		return exitUsage
+	}
+	if opts.watch && !watching {
+		args := flag.Args()
+		if opts.write == "" || len(args) == 0 {
+			fmt.Fprint(os.Stderr, tr("cat: --watch needs -o and input files\n"))
+			return exitUsage
+		}
+		for _, arg := range args {
+			if arg == "-" {
+				fmt.Fprint(os.Stderr, tr("cat: --watch cannot read stdin\n"))
+				return exitUsage
+			}
+		}
+		// Every build is a run of its own, with the flags parsed anew.
+		build := func() int {
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			return run()
+		}
+		return watchInputs(args, opts.write, build, nil)
+	}
 
 	var errs []error
+	var summary inputSummary
+	writeFailed := false
+	// wrote records the errors of opening, writing and closing the
+	// output, which it returns.
+	wrote := func(err error) error {
+		if err != nil {
+			writeFailed = true
+		}
+		return err
+	}
 	defer func() {
+		failed := false
 		for _, err := range errs {
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "cat: %v\n", err)
+				fmt.Fprintf(os.Stderr, "cat: %s\n", trError(err))
+				failed = true
 			}
 		}
+		if opts.summary {
+			summary.report(os.Stderr)
+		}
+		switch {
+		case runTimedOut():
+			code = exitTimeout
+		case writeFailed:
+			code = exitWrite
+		case failed:
+			code = exitFailed
+		}
 	}()
 
-	switch args := flag.Args(); len(args) {
-	case 0:
-		_, err := io.Copy(os.Stdout, os.Stdin)
+	maxMemory = opts.maxMemory.n
+	numbers = &lineNumbers{}
+	runCtx = context.Background()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, opts.timeout)
+		defer cancel()
+	}
+	unchangedState = nil
+	if opts.unchanged != "" {
+		s, err := loadHashState(opts.unchanged)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		unchangedState = s
+		defer func() { errs = append(errs, s.save()) }()
+	}
+
+	if opts.grpc != "" {
+		errs = append(errs, serveGRPC(opts.grpc, opts.grpcCert, opts.grpcKey))
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	if i, err := os.Stdout.Stat(); err == nil && !i.Mode().IsRegular() {
+		// Regular files never block, and keep their fast path of
+		// io.ReaderFrom without the retries.
+		out = &retryWriter{w: os.Stdout}
+	}
+	stdout := out
+	switch {
+	case opts.ws != "":
+		c, err := dialWS(opts.ws)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		w := &messageWriter{send: c.send}
+		defer func() { errs = append(errs, wrote(w.Close()), wrote(c.Close())) }()
+		out = w
+	case opts.serve != "":
+		h, err := serve(opts.serve)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		w := &messageWriter{send: h.send}
+		defer func() { errs = append(errs, wrote(w.Close()), wrote(h.Close())) }()
+		out = w
+	case opts.publish != "":
+		p, err := dialPublisher(opts.publish)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		w := &messageWriter{send: p.send}
+		defer func() { errs = append(errs, wrote(w.Close()), wrote(p.Close())) }()
+		out = w
+	case opts.kafka != "":
+		size := int64(16 << 10)
+		if opts.kafkaBatchSize.set {
+			size = opts.kafkaBatchSize.n
+		}
+		p, err := newKafkaProducer(opts.kafka, opts.kafkaKey, opts.kafkaPartition, size, opts.kafkaLinger)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		w := &messageWriter{send: p.send}
+		defer func() { errs = append(errs, wrote(w.Close()), wrote(p.Close())) }()
+		out = w
+	case opts.syslog.set:
+		s, err := dialSyslog(opts.syslog.addr, opts.syslogFacility, opts.syslogSeverity, opts.syslogTag)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		w := &messageWriter{send: s.send}
+		defer func() { errs = append(errs, wrote(w.Close()), wrote(s.Close())) }()
+		out = w
+	}
+	if opts.write != "" {
+		// An atomic write replaces the file only once the inputs were
+		// read, so it may read the file it writes.
+		if !opts.atomic {
+			if err := checkOutput(opts.write, append(opts.fds.paths(), flag.Args()...)); err != nil {
+				errs = append(errs, err)
+				return
+			}
+		}
+		f, err := createOutput(opts.write)
+		if err != nil {
+			errs = append(errs, wrote(err))
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				f.Abort()
+				panic(r)
+			}
+			done := f.Close
+			for _, err := range errs {
+				if err != nil {
+					done = f.Abort
+				}
+			}
+			if err := done(); err != nil {
+				errs = append(errs, wrote(err))
+			}
+		}()
+		out = f
+	}
+	toStdout := out == stdout
+	summarizeAbove, guardInputs = 0, false
+	if !opts.pager && opts.format == "raw" && toStdout && isTerminal(os.Stdout) {
+		if !opts.full {
+			summarizeAbove = defaultSummarizeAbove
+			if opts.summarize.set {
+				summarizeAbove = opts.summarize.n
+			}
+		}
+		guardInputs = opts.guard
+	}
+	out, output := newOutputWriter(out)
+	defer func() { writeFailed = writeFailed || output.failed }()
+	// The program that reads the output through a pipe tells what
+	// suits it best.
+	program := opts.program
+	if program == "" && toStdout && !isTerminal(os.Stdout) {
+		program = downstream()
+	}
+	reader := readerKind(program)
+	accessible = opts.a11y
+	colors = !accessible && colorOutput(opts.color, toStdout && isTerminal(os.Stdout))
+	if reader == readerFilter || reader == readerJSON {
+		l := &lineWriter{w: out}
+		defer func() { errs = append(errs, l.Close()) }()
+		out = l
+	}
+	if opts.frame != "" {
+		f := newFrameWriter(out, int(opts.frameSize.n))
+		defer func() { errs = append(errs, f.Close()) }()
+		out = f
+	}
+
+	if opts.pager && opts.write == "" && isTerminal(os.Stdout) {
+		p := &pagerWriter{w: out}
+		defer func() { errs = append(errs, p.Close()) }()
+		out = p
+	}
+	if opts.chop && !opts.pager && opts.write == "" && isTerminal(os.Stdout) {
+		c := &chopWriter{w: out, cols: terminalWidth()}
+		defer func() { errs = append(errs, c.Close()) }()
+		out = c
+	}
+	if opts.table.set && opts.format == "raw" && opts.write == "" && (isTerminal(os.Stdout) || reader == readerPager) {
+		t := &tableWriter{w: out, delim: opts.table.delim}
+		defer func() { errs = append(errs, t.Close()) }()
+		out = t
+	}
+
+	var announce *announcer
+	if accessible && opts.format == "raw" {
+		announce = &announcer{w: out}
+		out = announce
+	}
+	var budget *budgetWriter
+	if opts.maxChars > 0 || opts.maxTokens > 0 {
+		budget = &budgetWriter{w: out, maxChars: opts.maxChars, maxTokens: opts.maxTokens}
+		defer budget.report(os.Stderr)
+		out = budget
+	}
+	if opts.redact.set {
+		defer reportRedactions(os.Stderr)
+	}
+	aborted := false
+	input := func(name string, fn func() error) {
+		if aborted || runTimedOut() || budget != nil && !budget.start(name) {
+			return
+		}
+		if announce != nil {
+			errs = append(errs, announce.start(name))
+		}
+		err := fn()
+		if errors.Is(err, errBudget) {
+			err = nil
+		}
+		if announce != nil {
+			errs = append(errs, announce.end(name, err != nil))
+		}
+		if errors.Is(err, errTimeout) && opts.onTimeout == "abort" {
+			aborted = true
+		}
+		summary.add(name, err)
 		errs = append(errs, err)
+	}
+	read := func(arg string) {
+		input(arg, func() error { return process(arg, out) })
+	}
+
+	switch {
+	case isHTML(opts.format):
+		errs = append(errs, writeHTMLHeader(out, opts.htmlTheme))
+		defer func() { errs = append(errs, writeHTMLFooter(out)) }()
+	case opts.format == "pdf":
+		listing = &pdfDoc{}
+		defer func() { errs = append(errs, listing.writeTo(out)) }()
+	}
+
+	if opts.bridge != "" {
+		if opts.pty {
+			errs = append(errs, bridgePTY(opts.bridge, flag.Args(), os.Stdin, out))
+			return
+		}
+		errs = append(errs, bridge(opts.bridge, flag.Args(), os.Stdin, out))
+		return
+	}
+
+	switch args := append(opts.fds.paths(), flag.Args()...); len(args) {
+	case 0:
+		var r io.Reader = os.Stdin
+		if opts.stripPaste && isTerminal(os.Stdin) {
+			r = newPasteStripper(r)
+		}
+		r, record := audit("-", r)
+		r, check, err := verify("-", r)
+		if err != nil {
+			errs = append(errs, err)
+			record(err)
+			summary.add("-", err)
+			break
+		}
+		r, err = decode(r, opts.decoders)
+		if err == nil {
+			r, err = transform("-", r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-: %v", err))
+			record(err)
+			summary.add("-", err)
+			break
+		}
+		r = layout(r)
+		if opts.splitDir != "" {
+			err := splitRecords(r, opts.splitDir)
+			if err == nil {
+				err = check()
+			}
+			errs = append(errs, err)
+			record(err)
+			summary.add("-", err)
+			break
+		}
+		input("-", func() (err error) {
+			defer func() { record(err) }()
+			if err := emit(out, "-", r); err != nil {
+				return err
+			}
+			return check()
+		})
 	default:
+		if opts.recursive {
+			var werrs []error
+			args, werrs = expand(args)
+			errs = append(errs, werrs...)
+		}
+		if opts.groupByExt {
+			for i, g := range groupByExt(args) {
+				if opts.format == "raw" && reader != readerJSON || opts.format == "fence" {
+					if err := writeGroupBanner(out, g.ext, i == 0); !errors.Is(err, errBudget) {
+						errs = append(errs, err)
+					}
+				}
+				for _, arg := range g.files {
+					read(arg)
+				}
+			}
+			break
+		}
 		for _, arg := range args {
-			err := cat(arg, os.Stdout)
-			errs = append(errs, err)
+			read(arg)
 		}
 	}
+	return exitOK
+}
+
+// process handles a single input as requested by the flags.
+func process(arg string, w io.Writer) error {
+	switch {
+	case opts.listStreams:
+		return listStreams(arg, w)
+	case opts.xattrs:
+		return printXattrs(arg, w)
+	case opts.preview:
+		return preview(arg, w)
+	case opts.splitDir != "":
+		return readInput(arg, func(_ string, r io.Reader) error {
+			return splitRecords(r, opts.splitDir)
+		})
+	default:
+		return cat(arg, w)
+	}
 }
 
 // cat catches the content from a given file path and
 // writes everything to the given writer if possible.
+//
+// The path may carry decoder annotations, such as gzip:file.bin
+// or utf16:log.txt, so that every input can be decoded on its own.
 func cat(src string, w io.Writer) error {
+	return readInput(src, func(name string, r io.Reader) error {
+		if unchangedState == nil {
+			return catContent(w, name, r)
+		}
+		buf, sum, err := unchangedState.check(name, r)
+		if buf == nil {
+			return err
+		}
+		defer buf.Close()
+		content, err := buf.Reader()
+		if err != nil {
+			return err
+		}
+		if err := catContent(w, name, content); err != nil {
+			return err
+		}
+		unchangedState.record(name, sum)
+		return nil
+	})
+}
+
+// catContent writes the content of the named input as the flags tell.
+func catContent(w io.Writer, name string, r io.Reader) error {
+	r, err := guard(w, name, r)
+	if r == nil {
+		return err
+	}
+	r, err = transform(name, r)
+	if err != nil {
+		return err
+	}
+	if !opts.pretty {
+		return emit(w, name, layout(r))
+	}
+	r, perr := prettify(name, r)
+	if r == nil {
+		return perr
+	}
+	if err := emit(w, name, layout(r)); err != nil {
+		return err
+	}
+	return perr
+}
+
+// readInput opens the given input, applies its decoder annotations
+// and hands the decoded content over to fn.
+func readInput(src string, fn func(name string, r io.Reader) error) (err error) {
+	decs, src := splitDecoders(src)
+	decs = append(decs, opts.decoders...)
+
+	name := inputName(src)
+	var f io.ReadCloser
+	if opts.perFileTimeout > 0 || opts.timeout > 0 {
+		f, err = openDeadline(src, name, opts.perFileTimeout)
+	} else {
+		f, err = open(src)
+	}
+	if err != nil {
+		// Inputs that cannot be read are recorded as well.
+		_, record := audit(name, nil)
+		record(err)
+		return err
+	}
+	// No need to check error here. As the (*File).Close() says that
+	// only files support cancellation or double close will throw an
+	// error. We are not the case.
+	defer f.Close()
+
+	r, record := audit(name, f)
+	defer func() { record(err) }()
+	r, err = verifySignature(name, src, r)
+	if err != nil {
+		return err
+	}
+	r, check, err := verify(name, r)
+	if err != nil {
+		return err
+	}
+	r, err = decode(r, decs)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if err := fn(name, r); err != nil {
+		return err
+	}
+	return check()
+}
+
+// emit writes the content of the named input to the writer in the
+// requested output format.
+//
+// In the raw format, r and w are handed to copyBuffer as they are, so
+// that their io.WriterTo and io.ReaderFrom implementations are used.
+// Between files, this lets the kernel copy the data without passing
+// it through user space, e.g. via copy_file_range or splice on Linux.
+// Anything that transforms the content, e.g. a decoder annotation,
+// wraps the reader and hence disables these fast paths for the input.
+func emit(w io.Writer, name string, r io.Reader) error {
+	r = runDeadline(name, r)
+	switch opts.format {
+	case "records":
+		return writeRecord(w, name, r)
+	case "fence":
+		return writeFence(w, name, r)
+	case "html":
+		return writeHTML(w, name, r)
+	case "ansi2html":
+		return writeANSIHTML(w, name, r)
+	case "pdf":
+		return listing.add(name, r)
+	}
+	_, err := copyBuffer(w, r)
+	return err
+}
+
+// open opens the given path for reading.
+func open(src string) (io.ReadCloser, error) {
+	if err := inputPolicy.allow(src); err != nil {
+		return nil, err
+	}
+	if fd, ok := fdPath(src); ok {
+		return openFD(fd, src)
+	}
+	if isURL(src) {
+		return openURL(src)
+	}
+	if virtualFS != nil {
+		return openVirtual(src)
+	}
+	if isJournal(src) {
+		return openJournal(src)
+	}
+	if isDocker(src) {
+		return openDocker(src)
+	}
+	if isOCI(src) {
+		return openOCI(src)
+	}
+	if archive, member, ok := archiveMember(src); ok {
+		return openArchiveMember(archive, member)
+	}
 	src = filepath.Clean(src)
+	if opts.ciPaths {
+		if p, ok := findPathFold(src); ok && p != src {
+			// The policy applies to the file that is opened.
+			if err := inputPolicy.allow(p); err != nil {
+				return nil, err
+			}
+			src = p
+		}
+	}
 
-	i, err := os.Lstat(src)
+	i, err := os.Lstat(longPath(src))
 	if err != nil {
-		return fmt.Errorf("%s: No such file or directory", src)
+		if p, ok := suggestPath(src); ok {
+			return nil, fmt.Errorf("%s: %w (did you mean %s?)", src, errNotExist, p)
+		}
+		return nil, fmt.Errorf("%s: %w", src, errNotExist)
 	}
 	if i.IsDir() {
-		return fmt.Errorf("%s: Is a directory", i.Name())
+		return nil, fmt.Errorf("%s: Is a directory", i.Name())
 	}
 	if i.Mode()&os.ModeSymlink != 0 {
 		// According to readlinkat(2), there are only two possible
 		// errors EBADF and ENOTDIR but both are not possible to occur.
 		// Hence, don't mind the error here as the subsequent os.Open
 		// will throw the error, too. See https://linux.die.net/man/2/readlinkat
-		src, _ = os.Readlink(src)
+		target, _ := os.Readlink(longPath(src))
+		if target != "" && !filepath.IsAbs(target) {
+			// A relative link is relative to its directory.
+			target = filepath.Join(filepath.Dir(src), target)
+		}
+		src = target
 	}
 
-	f, err := os.Open(src)
+	var f io.ReadCloser
+	f, err = openRetry(longPath(src))
+	if err != nil && opts.sudo && errors.Is(err, fs.ErrPermission) {
+		f, err = sudoOpen(src)
+	}
 	if err != nil {
-		return fmt.Errorf("cannot open %s", src)
+		return nil, fmt.Errorf("cannot open %s", src)
 	}
-	// No need to check error here. As the (*File).Close() says that
-	// only files support cancellation or double close will throw an
-	// error. We are not the case.
-	defer f.Close()
-
-	_, err = io.Copy(w, f)
-	return err
+	return f, nil
 }
@@ -0,0 +1,207 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// cborBreak is returned for the stop code of indefinite length items.
+var cborBreak = errors.New("unexpected break code")
+
+// newCBORReader decodes a sequence of CBOR data items as JSON.
+func newCBORReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	return &jsonReader{name: "cbor", next: func() (interface{}, error) {
+		if _, err := br.Peek(1); err != nil {
+			return nil, err
+		}
+		return readCBOR(br, 0)
+	}}, nil
+}
+
+// readCBOR reads a single CBOR data item. Byte strings are shown in
+// base64 and bignums as decimal strings; other tags are dropped and
+// leave their content.
+func readCBOR(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, errNesting
+	}
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	major, info := c>>5, c&0x1f
+	if major == 7 {
+		return readCBORSimple(r, info)
+	}
+	indefinite := info == 31 && major >= 2 && major <= 5
+	var n uint64
+	if !indefinite {
+		if n, err = readCBORArg(r, info); err != nil {
+			return nil, err
+		}
+	}
+
+	switch major {
+	case 0:
+		return n, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return new(big.Int).Sub(big.NewInt(-1), new(big.Int).SetUint64(n)).String(), nil
+		}
+		return -1 - int64(n), nil
+	case 2, 3:
+		var b []byte
+		if indefinite {
+			// The chunks are definite strings of the same type.
+			for {
+				c, err := r.ReadByte()
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if c == 0xff {
+					break
+				}
+				if c>>5 != major || c&0x1f == 31 {
+					return nil, errors.New("invalid chunk of an indefinite length string")
+				}
+				n, err := readCBORArg(r, c&0x1f)
+				if err != nil {
+					return nil, err
+				}
+				chunk, err := readN(r, n)
+				if err != nil {
+					return nil, err
+				}
+				b = append(b, chunk...)
+			}
+		} else if b, err = readN(r, n); err != nil {
+			return nil, err
+		}
+		if major == 2 {
+			return base64.StdEncoding.EncodeToString(b), nil
+		}
+		return string(b), nil
+	case 4:
+		a := []interface{}{}
+		for i := uint64(0); indefinite || i < n; i++ {
+			v, err := readCBOR(r, depth+1)
+			if indefinite && err == cborBreak {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case 5:
+		m := jsonObject{}
+		for i := uint64(0); indefinite || i < n; i++ {
+			k, err := readCBOR(r, depth+1)
+			if indefinite && err == cborBreak {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			v, err := readCBOR(r, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, jsonMember{jsonKey(k), v})
+		}
+		return m, nil
+	default: // 6, a tag
+		if next, err := r.Peek(1); err == nil && (n == 2 || n == 3) && next[0]>>5 == 2 && next[0]&0x1f != 31 {
+			// A bignum, whose content is a definite byte string.
+			r.ReadByte()
+			l, err := readCBORArg(r, next[0]&0x1f)
+			if err != nil {
+				return nil, err
+			}
+			b, err := readN(r, l)
+			if err != nil {
+				return nil, err
+			}
+			i := new(big.Int).SetBytes(b)
+			if n == 3 {
+				i.Sub(big.NewInt(-1), i)
+			}
+			return i.String(), nil
+		}
+		return readCBOR(r, depth+1)
+	}
+}
+
+// readCBORArg reads the argument of a data item that follows its
+// initial byte.
+func readCBORArg(r io.Reader, info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		return readUint(r, 1<<(info-24))
+	}
+	return 0, fmt.Errorf("invalid CBOR argument %d", info)
+}
+
+// readCBORSimple reads a simple value or a float.
+func readCBORSimple(r io.Reader, info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null and undefined
+		return nil, nil
+	case 25:
+		v, err := readUint(r, 2)
+		return jsonFloat(halfFloat(uint16(v))), err
+	case 26:
+		v, err := readUint(r, 4)
+		return jsonFloat(float64(math.Float32frombits(uint32(v)))), err
+	case 27:
+		v, err := readUint(r, 8)
+		return jsonFloat(math.Float64frombits(v)), err
+	case 31:
+		return nil, cborBreak
+	}
+	v, err := readCBORArg(r, info)
+	if err != nil {
+		return nil, err
+	}
+	return "simple(" + strconv.FormatUint(v, 10) + ")", nil
+}
+
+// halfFloat converts an IEEE 754 half precision float.
+func halfFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 31:
+		if frac == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestCBORReader(t *testing.T) {
+	// Examples from appendix A of RFC 8949.
+	tests := []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte{0x18, 0x64}, "100"},
+		{[]byte{0x39, 0x03, 0xe7}, "-1000"},
+		{[]byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, `"-18446744073709551616"`},
+		{[]byte{0xc2, 0x49, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}, `"18446744073709551616"`},
+		{[]byte{0xf9, 0x3e, 0x00}, "1.5"},
+		{[]byte{0xf9, 0x7c, 0x00}, `"Infinity"`},
+		{[]byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, "1.1"},
+		{[]byte{0xf6}, "null"},
+		{[]byte{0x44, 0x01, 0x02, 0x03, 0x04}, `"AQIDBA=="`},
+		{[]byte{0x62, 0xc3, 0xbc}, `"ü"`},
+		{[]byte{0x7f, 0x65, 's', 't', 'r', 'e', 'a', 0x64, 'm', 'i', 'n', 'g', 0xff}, `"streaming"`},
+		{[]byte{0x9f, 0x01, 0x82, 0x02, 0x03, 0xff}, "[\n  1,\n  [\n    2,\n    3\n  ]\n]"},
+		{[]byte{0xa2, 0x61, 'a', 0x01, 0x02, 0xf5}, "{\n  \"a\": 1,\n  \"2\": true\n}"},
+		{[]byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, "1363896240"},
+	}
+	for _, tt := range tests {
+		r, _ := newCBORReader(bytes.NewReader(tt.in))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%x: unexpected error: %v", tt.in, err)
+		}
+		if string(got) != tt.want+"\n" {
+			t.Errorf("%x: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range [][]byte{{0x82, 0x01}, {0xff}, {0x1c}, {0x5f, 0x61, 'a', 0xff}} {
+		r, _ := newCBORReader(bytes.NewReader(in))
+		if _, err := io.ReadAll(r); err == nil {
+			t.Errorf("%x: expect an error", in)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+func init() { registerFeature("cert") }
+
+func isPEM(head []byte, _ string) bool {
+	return bytes.Contains(head, []byte("-----BEGIN "))
+}
+
+// previewPEM describes the blocks of a PEM file: the subject, issuer,
+// names and validity of certificates and requests, and the kind and
+// size of keys. Keys are never shown themselves.
+func previewPEM(w io.Writer, r *bufio.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	n := 0
+	for {
+		var b *pem.Block
+		b, data = pem.Decode(data)
+		if b == nil {
+			break
+		}
+		if n > 0 {
+			bw.WriteString("\n")
+		}
+		n++
+		fmt.Fprintf(bw, "%s\n", b.Type)
+		field := func(k, v string) {
+			fmt.Fprintf(bw, "  %-11s %s\n", k+":", v)
+		}
+		if _, ok := b.Headers["DEK-Info"]; ok {
+			field("key", "encrypted")
+			continue
+		}
+		switch b.Type {
+		case "CERTIFICATE":
+			c, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("subject", c.Subject.String())
+			field("issuer", c.Issuer.String())
+			field("serial", c.SerialNumber.String())
+			field("not before", c.NotBefore.UTC().Format(time.RFC3339))
+			field("not after", c.NotAfter.UTC().Format(time.RFC3339))
+			if now := time.Now(); now.After(c.NotAfter) {
+				field("status", "expired")
+			} else if now.Before(c.NotBefore) {
+				field("status", "not yet valid")
+			}
+			if names := altNames(c.DNSNames, c.EmailAddresses, c.IPAddresses, c.URIs); names != "" {
+				field("names", names)
+			}
+			if c.IsCA {
+				field("CA", "yes")
+			}
+			field("key", describeKey(c.PublicKey))
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			c, err := x509.ParseCertificateRequest(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("subject", c.Subject.String())
+			if names := altNames(c.DNSNames, c.EmailAddresses, c.IPAddresses, c.URIs); names != "" {
+				field("names", names)
+			}
+			field("key", describeKey(c.PublicKey))
+		case "PUBLIC KEY":
+			k, err := x509.ParsePKIXPublicKey(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("key", describeKey(k))
+		case "PRIVATE KEY":
+			k, err := x509.ParsePKCS8PrivateKey(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("key", describeKey(k))
+		case "RSA PRIVATE KEY":
+			k, err := x509.ParsePKCS1PrivateKey(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("key", describeKey(k))
+		case "EC PRIVATE KEY":
+			k, err := x509.ParseECPrivateKey(b.Bytes)
+			if err != nil {
+				return err
+			}
+			field("key", describeKey(k))
+		case "ENCRYPTED PRIVATE KEY":
+			field("key", "encrypted")
+		default:
+			field("size", fmt.Sprintf("%d bytes", len(b.Bytes)))
+		}
+	}
+	if n == 0 {
+		return errors.New("no PEM blocks")
+	}
+	return nil
+}
+
+// altNames formats the subject alternative names of a certificate.
+func altNames(dns, emails []string, ips []net.IP, uris []*url.URL) string {
+	var names []string
+	for _, n := range dns {
+		names = append(names, "DNS:"+n)
+	}
+	for _, n := range emails {
+		names = append(names, "email:"+n)
+	}
+	for _, n := range ips {
+		names = append(names, "IP:"+n.String())
+	}
+	for _, n := range uris {
+		names = append(names, "URI:"+n.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// describeKey tells the algorithm and size of a public or private key.
+func describeKey(k interface{}) string {
+	switch k := k.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d bits", k.N.BitLen())
+	case *rsa.PrivateKey:
+		return fmt.Sprintf("RSA %d bits", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case *ecdsa.PrivateKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return "Ed25519"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", k), "*")
+}
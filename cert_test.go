@@ -0,0 +1,68 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPreviewPEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "example.com", Organization: []string{"Example"}},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		DNSNames:     []string{"example.com", "www.example.com"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := []byte("A certificate and its key.\n")
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: priv})...)
+	name := filepath.Join(t.TempDir(), "a.pem")
+	os.WriteFile(name, b, 0644)
+
+	got := runMain("--preview", name)
+	want := `CERTIFICATE
+  subject:    CN=example.com,O=Example
+  issuer:     CN=example.com,O=Example
+  serial:     42
+  not before: 2020-01-01T00:00:00Z
+  not after:  2021-01-01T00:00:00Z
+  status:     expired
+  names:      DNS:example.com, DNS:www.example.com, IP:127.0.0.1
+  key:        ECDSA P-256
+
+EC PRIVATE KEY
+  key:        ECDSA P-256
+`
+	if got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
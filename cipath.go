@@ -0,0 +1,45 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findPathFold looks for an existing path that equals the given one
+// under Unicode case folding, resolving one element at a time, so that
+// e.g. ./Testdata/A.TXT finds ./testdata/a.txt. If a directory holds
+// several matches, the first in lexical order wins.
+func findPathFold(src string) (string, bool) {
+	if _, err := os.Lstat(longPath(src)); err == nil {
+		return src, true
+	}
+
+	dir, name := filepath.Split(src)
+	if name == "" {
+		return "", false // a missing root or volume
+	}
+	if dir == "" {
+		dir = "."
+	} else if dir = filepath.Clean(dir); dir != src {
+		var ok bool
+		if dir, ok = findPathFold(dir); !ok {
+			return "", false
+		}
+	}
+
+	entries, err := os.ReadDir(longPath(dir))
+	if err != nil {
+		return "", false
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name(), name) {
+			return filepath.Join(dir, e.Name()), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindPathFold(t *testing.T) {
+	dir := t.TempDir()
+	mkfiles(t, dir, "Scripts/Build.sh", "readme.md")
+
+	tests := []struct {
+		src  string
+		want string
+		ok   bool
+	}{
+		{"readme.md", "readme.md", true},
+		{"README.MD", "readme.md", true},
+		{"scripts/build.SH", "Scripts/Build.sh", true},
+		{"scripts/missing.sh", "", false},
+		{"missing/build.sh", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := findPathFold(filepath.Join(dir, filepath.FromSlash(tt.src)))
+		want := ""
+		if tt.ok {
+			want = filepath.Join(dir, filepath.FromSlash(tt.want))
+		}
+		if got != want || ok != tt.ok {
+			t.Errorf("findPathFold(%s): got %q %v, want %q %v", tt.src, got, ok, want, tt.ok)
+		}
+	}
+}
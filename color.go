@@ -0,0 +1,48 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "os"
+
+// colors tells whether cat colors what it writes of its own, like the
+// banners of groups and the headers of summaries.
+var colors bool
+
+// colorOutput reports whether to color the output, following the
+// conventions, from the strongest to the weakest:
+//
+//	--color=always or never
+//	NO_COLOR set and not empty, which turns colors off
+//	CLICOLOR_FORCE set and not empty or 0, which turns them on
+//	TERM=dumb, which turns them off
+//
+// and else colors are on if the output is a terminal.
+func colorOutput(mode string, terminal bool) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return terminal
+}
+
+// paint wraps s in the SGR sequence of the attributes, e.g. "1" for
+// bold, if colors are on.
+func paint(s, sgr string) string {
+	if !colors {
+		return s
+	}
+	return "\x1b[" + sgr + "m" + s + "\x1b[m"
+}
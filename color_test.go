@@ -0,0 +1,57 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestColorOutput(t *testing.T) {
+	tests := []struct {
+		mode     string
+		terminal bool
+		noColor  string
+		force    string
+		term     string
+		want     bool
+	}{
+		{"auto", true, "", "", "xterm", true},
+		{"auto", false, "", "", "xterm", false},
+		{"auto", true, "1", "", "xterm", false},
+		{"auto", false, "", "1", "xterm", true},
+		{"auto", false, "", "0", "xterm", false},
+		{"auto", true, "", "", "dumb", false},
+		{"auto", false, "", "1", "dumb", true},
+		{"auto", false, "1", "1", "xterm", false},
+		{"always", false, "1", "", "dumb", true},
+		{"never", true, "", "1", "xterm", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("CLICOLOR_FORCE", tt.force)
+		t.Setenv("TERM", tt.term)
+		if got := colorOutput(tt.mode, tt.terminal); got != tt.want {
+			t.Errorf("colorOutput(%q, %v) with NO_COLOR=%q CLICOLOR_FORCE=%q TERM=%q = %v, want %v",
+				tt.mode, tt.terminal, tt.noColor, tt.force, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestMainColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("CLICOLOR_FORCE", "")
+	if got := runMain("--color=always", "--group-by-ext", "testdata/b.md"); got != "\x1b[1m==> *.md <==\x1b[m\nworld" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	if got := runMain("--group-by-ext", "testdata/b.md"); got != "==> *.md <==\nworld" {
+		t.Fatalf("unexpected output %q with NO_COLOR", got)
+	}
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "1")
+	if got := runMain("--group-by-ext", "testdata/b.md"); got != "\x1b[1m==> *.md <==\x1b[m\nworld" {
+		t.Fatalf("unexpected output %q with CLICOLOR_FORCE", got)
+	}
+	if _, code := runMainCode("--color=sometimes", "testdata/b.md"); code != exitUsage {
+		t.Fatalf("unexpected exit code %d for an invalid --color", code)
+	}
+}
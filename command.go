@@ -0,0 +1,72 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// cmdReader reads the standard output of a running command. The exit
+// status of the command is reported at the end of its output so that
+// a failing command is not mistaken for an empty file.
+type cmdReader struct {
+	r    io.ReadCloser
+	cmd  *exec.Cmd
+	done bool
+}
+
+// startCmd starts the given command and returns its standard output.
+func startCmd(cmd *exec.Cmd) (*cmdReader, error) {
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &cmdReader{r: r, cmd: cmd}, nil
+}
+
+func (c *cmdReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, fmt.Errorf("%s: %v", c.cmd.Args[0], werr)
+		}
+	}
+	return n, err
+}
+
+// Close stops the command if its output was not consumed entirely.
+func (c *cmdReader) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+
+// sudoOpen reads a file that the current user has no permission to
+// read by running cat for just that file again through sudo. Only
+// the raw content is read this way, everything else, e.g. decoding,
+// is still done by the calling process.
+func sudoOpen(src string) (io.ReadCloser, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("sudo", "--", self, "--", src)
+	// sudo prompts on the terminal for a password if necessary,
+	// its messages go to our stderr.
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	return startCmd(cmd)
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestCmdReader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh on Windows")
+	}
+
+	r, err := startCmd(exec.Command("sh", "-c", "printf hello"))
+	if err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil || string(b) != "hello" {
+		t.Fatalf("unexpected output: got %q, %v", b, err)
+	}
+
+	r, err = startCmd(exec.Command("sh", "-c", "printf partial; exit 3"))
+	if err != nil {
+		t.Fatalf("failed to start command: %v", err)
+	}
+	b, err = io.ReadAll(r)
+	if string(b) != "partial" || err == nil {
+		t.Fatalf("expect exit status to be reported, got %q, %v", b, err)
+	}
+	r.Close()
+}
@@ -0,0 +1,13 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package main
+
+import "os"
+
+// setupConsole prepares a console for output. Terminals other than
+// the Windows console need no setup.
+func setupConsole(f *os.File) {}
@@ -0,0 +1,40 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	kernel32           = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+)
+
+const enableVirtualTerminalProcessing = 0x0004
+
+// setupConsole enables the processing of ANSI escape sequences if the
+// given file is a console, so that colored output is rendered rather
+// than printed as garbage.
+//
+// There is nothing to do for non-ASCII text: the os package already
+// writes to consoles through the UTF-16 WriteConsoleW API regardless
+// of the active code page.
+func setupConsole(f *os.File) {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return // not a console, e.g. redirected to a file
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return
+	}
+	// Consoles before Windows 10 do not support the flag and reject
+	// the call, in which case the output stays as it was.
+	procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+}
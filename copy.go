@@ -0,0 +1,39 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// bufPool holds the buffers for copies that have no fast path, so that
+// concatenating thousands of small files does not allocate a new 32KB
+// buffer for each of them.
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 32*1024)
+		return &b
+	},
+}
+
+// copyBuffer copies from r to w like io.Copy, but with a pooled buffer.
+//
+// The io.ReaderFrom of w and the io.WriterTo of r are still preferred.
+// The exception is a *os.File writing to a writer that lacks a fast
+// path: its WriteTo cannot do better than copying through a buffer of
+// its own, which is exactly the allocation the pool avoids.
+func copyBuffer(w io.Writer, r io.Reader) (int64, error) {
+	if _, ok := w.(io.ReaderFrom); ok {
+		return io.Copy(w, r)
+	}
+	if _, ok := r.(*os.File); ok {
+		r = struct{ io.Reader }{r} // hide WriteTo
+	}
+	b := bufPool.Get().(*[]byte)
+	defer bufPool.Put(b)
+	return io.CopyBuffer(w, r, *b)
+}
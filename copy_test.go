@@ -0,0 +1,55 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCopyBuffer(t *testing.T) {
+	want, err := os.ReadFile("testdata/x.png")
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	readers := map[string]func() io.Reader{
+		"file": func() io.Reader {
+			f, err := os.Open("testdata/x.png")
+			if err != nil {
+				t.Fatalf("failed to open file: %v", err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f
+		},
+		"plain": func() io.Reader { return iotest.HalfReader(bytes.NewReader(want)) },
+	}
+	for name, r := range readers {
+		// Hide the io.ReaderFrom of the buffer to use the pool.
+		var buf bytes.Buffer
+		n, err := copyBuffer(struct{ io.Writer }{&buf}, r())
+		if err != nil || n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: unexpected copy: %d bytes, %v", name, n, err)
+		}
+
+		buf.Reset()
+		if _, err := copyBuffer(&buf, r()); err != nil || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s: unexpected copy with io.ReaderFrom: %v", name, err)
+		}
+	}
+}
+
+func BenchmarkCatPooled(b *testing.B) {
+	// Hide the io.ReaderFrom of io.Discard, which brings its own
+	// buffer pool, to copy through the pool of copyBuffer.
+	w := struct{ io.Writer }{io.Discard}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cat("./testdata/a.txt", w)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decoders maps the name of an input annotation, as in gzip:file.bin,
+// to a function that wraps the raw input with the matching decoder.
+var decoders = map[string]func(io.Reader) (io.Reader, error){
+	"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"zlib":    func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+	"bzip2":   func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+	"flate":   func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil },
+	"base64":  func(r io.Reader) (io.Reader, error) { return base64.NewDecoder(base64.StdEncoding, r), nil },
+	"utf16":   func(r io.Reader) (io.Reader, error) { return newUTF16Reader(r, nil), nil },
+	"utf16le": func(r io.Reader) (io.Reader, error) { return newUTF16Reader(r, binary.LittleEndian), nil },
+	"utf16be": func(r io.Reader) (io.Reader, error) { return newUTF16Reader(r, binary.BigEndian), nil },
+	"proto":   newProtoReader,
+	"msgpack": newMsgpackReader,
+	"cbor":    newCBORReader,
+	"mail":    newMailReader,
+	"jwt":     newJWTReader,
+	"unframe": newUnframeReader,
+}
+
+// splitDecoders strips the decoder annotations from an input such as
+// base64:gzip:file.bin and returns them in the order they have to be
+// applied, i.e. the annotation closest to the path comes first.
+//
+// Only names known to decoders are treated as annotations, so that
+// Windows drive letters and other colons stay part of the path. A file
+// whose name happens to start with an annotation can still be read
+// by prefixing it with ./ as in ./gzip:file.bin.
+func splitDecoders(arg string) (names []string, path string) {
+	for {
+		i := strings.IndexByte(arg, ':')
+		if i < 0 {
+			break
+		}
+		name := strings.ToLower(arg[:i])
+		if _, ok := decoders[name]; !ok {
+			break
+		}
+		names = append(names, name)
+		arg = arg[i+1:]
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return names, arg
+}
+
+// decode wraps r with the named decoders in order.
+func decode(r io.Reader, names []string) (io.Reader, error) {
+	for _, name := range names {
+		var err error
+		r, err = decoders[name](r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// utf16Reader converts a UTF-16 stream into UTF-8. If no byte order is
+// given, it is taken from the byte order mark and defaults to little
+// endian, which is what Windows tools usually produce.
+type utf16Reader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	bom   bool   // whether the leading byte order mark was inspected
+	in    []byte // raw bytes that do not form a complete character yet
+	out   []byte // decoded bytes not returned to the caller yet
+	buf   []byte
+	err   error
+}
+
+func newUTF16Reader(r io.Reader, order binary.ByteOrder) *utf16Reader {
+	return &utf16Reader{r: r, order: order, buf: make([]byte, 4096)}
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		if u.err != nil {
+			if u.err == io.EOF && len(u.in) > 0 {
+				// A dangling byte or an unpaired surrogate at the
+				// end of the input is replaced rather than dropped.
+				u.in = u.in[:0]
+				u.out = utf8.AppendRune(u.out, utf8.RuneError)
+				break
+			}
+			return 0, u.err
+		}
+		n, err := u.r.Read(u.buf)
+		u.in = append(u.in, u.buf[:n]...)
+		u.err = err
+		u.convert()
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// convert decodes as many complete characters from u.in as possible.
+func (u *utf16Reader) convert() {
+	if !u.bom {
+		if len(u.in) < 2 && u.err == nil {
+			return
+		}
+		u.bom = true
+		if len(u.in) >= 2 {
+			switch {
+			case u.in[0] == 0xff && u.in[1] == 0xfe && u.order != binary.BigEndian:
+				u.order, u.in = binary.LittleEndian, u.in[2:]
+			case u.in[0] == 0xfe && u.in[1] == 0xff && u.order != binary.LittleEndian:
+				u.order, u.in = binary.BigEndian, u.in[2:]
+			}
+		}
+		if u.order == nil {
+			u.order = binary.LittleEndian
+		}
+	}
+
+	i := 0
+	for ; i+1 < len(u.in); i += 2 {
+		r := rune(u.order.Uint16(u.in[i:]))
+		if utf16.IsSurrogate(r) {
+			if i+3 >= len(u.in) {
+				if u.err == nil {
+					break // wait for the second half of the pair
+				}
+				u.out = utf8.AppendRune(u.out, utf8.RuneError)
+				continue
+			}
+			r2 := rune(u.order.Uint16(u.in[i+2:]))
+			if d := utf16.DecodeRune(r, r2); d != utf8.RuneError {
+				u.out = utf8.AppendRune(u.out, d)
+				i += 2
+				continue
+			}
+			r = utf8.RuneError
+		}
+		u.out = utf8.AppendRune(u.out, r)
+	}
+	u.in = append(u.in[:0], u.in[i:]...)
+}
@@ -0,0 +1,202 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func TestSplitDecoders(t *testing.T) {
+	tests := []struct {
+		arg   string
+		names []string
+		path  string
+	}{
+		{"testdata/a.txt", nil, "testdata/a.txt"},
+		{"gzip:a.gz", []string{"gzip"}, "a.gz"},
+		{"base64:GZIP:a.gz", []string{"gzip", "base64"}, "a.gz"},
+		{`C:\a.txt`, nil, `C:\a.txt`},
+		{"./gzip:a.gz", nil, "./gzip:a.gz"},
+		{"unknown:a.txt", nil, "unknown:a.txt"},
+	}
+	for _, tt := range tests {
+		names, path := splitDecoders(tt.arg)
+		if !reflect.DeepEqual(names, tt.names) || path != tt.path {
+			t.Errorf("splitDecoders(%q): got %v %q, want %v %q", tt.arg, names, path, tt.names, tt.path)
+		}
+	}
+}
+
+func TestCatDecoders(t *testing.T) {
+	dir := t.TempDir()
+	want := []byte("hello, 世界 🌍\n")
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(want)
+	zw.Close()
+	gzPath := filepath.Join(dir, "a.gz")
+	os.WriteFile(gzPath, gz.Bytes(), 0644)
+
+	b64Path := filepath.Join(dir, "a.gz.b64")
+	os.WriteFile(b64Path, []byte(base64.StdEncoding.EncodeToString(gz.Bytes())), 0644)
+
+	// UTF-16 big endian with a byte order mark, including a surrogate pair.
+	u16 := []byte{0xfe, 0xff}
+	for _, r := range []rune(string(want)) {
+		if r > 0xffff {
+			r -= 0x10000
+			u16 = append(u16, byte((0xd800+(r>>10))>>8), byte(0xd800+(r>>10)))
+			u16 = append(u16, byte((0xdc00+(r&0x3ff))>>8), byte(0xdc00+(r&0x3ff)))
+			continue
+		}
+		u16 = append(u16, byte(r>>8), byte(r))
+	}
+	u16Path := filepath.Join(dir, "a.u16")
+	os.WriteFile(u16Path, u16, 0644)
+
+	for _, src := range []string{
+		"gzip:" + gzPath,
+		"gzip:base64:" + b64Path,
+		"utf16:" + u16Path,
+		"utf16be:" + u16Path,
+	} {
+		w := newCompleteWriter()
+		if err := cat(src, w); err != nil {
+			t.Fatalf("failed to cat %s: %v", src, err)
+		}
+		if !bytes.Equal(w.Bytes(), want) {
+			t.Fatalf("cat %s: got %q, want %q", src, w.Bytes(), want)
+		}
+	}
+
+	if err := cat("gzip:testdata/a.txt", io.Discard); err == nil {
+		t.Fatalf("expect gzip decoding of plain text to fail")
+	}
+}
+
+func TestUTF16ReaderSplitInput(t *testing.T) {
+	// Little endian without a byte order mark, read one byte at a time
+	// so that surrogate pairs arrive in separate reads.
+	in := []byte{'h', 0, 'i', 0, 0x3c, 0xd8, 0x0d, 0xdf, 'x'}
+	got, err := io.ReadAll(newUTF16Reader(iotest.OneByteReader(bytes.NewReader(in)), nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "hi🌍\uFFFD"; string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func FuzzUTF16(f *testing.F) {
+	f.Add("hello", 3)
+	f.Add("é\U0001F600x", 1)
+	f.Add("", 0)
+	f.Fuzz(func(t *testing.T, s string, chunk int) {
+		if !utf8.ValidString(s) {
+			return
+		}
+		// UTF-8 round-trips through UTF-16, in chunks of any size.
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			u := utf16.Encode([]rune(s))
+			b := make([]byte, 2*len(u))
+			for i, c := range u {
+				order.PutUint16(b[2*i:], c)
+			}
+			r := newUTF16Reader(chunked(b, chunk), order)
+			got, err := io.ReadAll(r)
+			if err != nil || string(got) != s {
+				t.Fatalf("round trip of %q in %v: got %q, %v", s, order, got, err)
+			}
+		}
+		// Arbitrary bytes become valid UTF-8.
+		got, err := io.ReadAll(newUTF16Reader(strings.NewReader(s), nil))
+		if err != nil || !utf8.Valid(got) {
+			t.Fatalf("decoding %q: got %q, %v", s, got, err)
+		}
+	})
+}
+
+// chunked returns a reader of b that returns at most n bytes at a
+// time, if n is positive.
+func chunked(b []byte, n int) io.Reader {
+	if n <= 0 {
+		return bytes.NewReader(b)
+	}
+	return &chunkReader{b: b, n: n}
+}
+
+type chunkReader struct {
+	b []byte
+	n int
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.b) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > c.n {
+		p = p[:c.n]
+	}
+	n := copy(p, c.b)
+	c.b = c.b[n:]
+	return n, nil
+}
+
+func FuzzDecompress(f *testing.F) {
+	f.Add([]byte("hello, world\n"), false)
+	f.Add([]byte{0x1f, 0x8b, 0x08, 0x00}, true)
+	f.Add([]byte("BZh91AY&SY"), true)
+	f.Fuzz(func(t *testing.T, data []byte, raw bool) {
+		compress := map[string]func(io.Writer) io.WriteCloser{
+			"gzip":  func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+			"zlib":  func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+			"flate": func(w io.Writer) io.WriteCloser { fw, _ := flate.NewWriter(w, flate.DefaultCompression); return fw },
+			"base64": func(w io.Writer) io.WriteCloser {
+				return base64.NewEncoder(base64.StdEncoding, w)
+			},
+		}
+		for name, newWriter := range compress {
+			if raw {
+				// Garbage fails, but must not panic.
+				if r, err := decode(bytes.NewReader(data), []string{name}); err == nil {
+					io.Copy(io.Discard, r)
+				}
+				continue
+			}
+			var b bytes.Buffer
+			w := newWriter(&b)
+			w.Write(data)
+			w.Close()
+			r, err := decode(&b, []string{name})
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("%s: round trip of %q: got %q, %v", name, data, got, err)
+			}
+		}
+		if raw {
+			if r, err := decode(bytes.NewReader(data), []string{"bzip2"}); err == nil {
+				io.Copy(io.Discard, r)
+			}
+		}
+	})
+}
@@ -0,0 +1,200 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+func init() { registerFeature("docker") }
+
+// dockerClient talks to the API of the container engine at $DOCKER_HOST,
+// unix:///path or tcp://host:port, or at /var/run/docker.sock. Podman
+// serves the same API, at a socket that $DOCKER_HOST can point to.
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		path := strings.TrimPrefix(host, "unix://")
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}}, "http://docker", nil
+	case strings.HasPrefix(host, "tcp://"):
+		return http.DefaultClient, "http://" + strings.TrimPrefix(host, "tcp://"), nil
+	}
+	return nil, "", fmt.Errorf("unsupported DOCKER_HOST %s", host)
+}
+
+// dockerGet requests a path of the API, and returns the error message
+// of the engine for failed requests.
+func dockerGet(path string, query url.Values) (*http.Response, error) {
+	c, base, err := dockerClient()
+	if err != nil {
+		return nil, err
+	}
+	u := base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := c.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		var e struct{ Message string }
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&e) == nil && e.Message != "" {
+			return nil, errors.New(e.Message)
+		}
+		return nil, errors.New(resp.Status)
+	}
+	return resp, nil
+}
+
+// openDocker reads a file in a container, or the logs of one.
+func openDocker(src string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	var err error
+	if strings.HasPrefix(src, "docker-logs://") {
+		rc, err = openDockerLogs(strings.TrimPrefix(src, "docker-logs://"))
+	} else {
+		rc, err = openDockerFile(strings.TrimPrefix(src, "docker://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	return rc, nil
+}
+
+// openDockerFile reads the file at container:/path from the archive of
+// it that the engine makes.
+func openDockerFile(ref string) (io.ReadCloser, error) {
+	i := strings.IndexByte(ref, ':')
+	if i <= 0 || i == len(ref)-1 {
+		return nil, errors.New("expected container:/path")
+	}
+	container, path := ref[:i], ref[i+1:]
+	resp, err := dockerGet("/containers/"+url.PathEscape(container)+"/archive", url.Values{"path": {path}})
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(resp.Body)
+	h, err := tr.Next()
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	switch h.Typeflag {
+	case tar.TypeReg:
+	case tar.TypeDir:
+		resp.Body.Close()
+		return nil, errors.New("Is a directory")
+	default:
+		resp.Body.Close()
+		return nil, errors.New("not a regular file")
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{tr, resp.Body}, nil
+}
+
+// dockerLogParams are the parameters of docker-logs:// inputs that are
+// passed on to the engine, as in docker-logs://web?tail=100&follow.
+var dockerLogParams = map[string]bool{"follow": true, "tail": true, "since": true, "until": true, "timestamps": true}
+
+// openDockerLogs reads both the standard output and error of a
+// container in the order they were written.
+func openDockerLogs(ref string) (io.ReadCloser, error) {
+	container, params, _ := strings.Cut(ref, "?")
+	q := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if params != "" {
+		p, err := url.ParseQuery(params)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range p {
+			if !dockerLogParams[k] {
+				return nil, fmt.Errorf("unknown parameter %q", k)
+			}
+			if len(v) == 0 || v[0] == "" {
+				v = []string{"1"}
+			}
+			q[k] = v
+		}
+	}
+
+	// The logs of containers without a TTY come in frames of the
+	// stream they were written to.
+	resp, err := dockerGet("/containers/"+url.PathEscape(container)+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	var info struct{ Config struct{ Tty bool } }
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp, err = dockerGet("/containers/"+url.PathEscape(container)+"/logs", q)
+	if err != nil {
+		return nil, err
+	}
+	if info.Config.Tty {
+		return resp.Body, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{&dockerStreamReader{r: resp.Body}, resp.Body}, nil
+}
+
+// dockerStreamReader reads the payloads of the frames of a multiplexed
+// stream, which each have a header of the stream and the size.
+type dockerStreamReader struct {
+	r    io.Reader
+	left uint32 // of the current frame
+}
+
+func (d *dockerStreamReader) Read(p []byte) (int, error) {
+	for d.left == 0 {
+		var h [8]byte
+		if _, err := io.ReadFull(d.r, h[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return 0, errors.New("truncated log stream")
+			}
+			return 0, err
+		}
+		d.left = binary.BigEndian.Uint32(h[4:])
+	}
+	if uint32(len(p)) > d.left {
+		p = p[:d.left]
+	}
+	n, err := d.r.Read(p)
+	d.left -= uint32(n)
+	if err == io.EOF && d.left > 0 {
+		err = errors.New("truncated log stream")
+	}
+	return n, err
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeDocker serves the API of an engine with a container web without a
+// TTY, and a container tty with one.
+func fakeDocker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/web/archive":
+			tw := tar.NewWriter(w)
+			switch p := r.URL.Query().Get("path"); p {
+			case "/etc/hostname":
+				tw.WriteHeader(&tar.Header{Name: "hostname", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+				tw.Write([]byte("web\n"))
+			case "/etc":
+				tw.WriteHeader(&tar.Header{Name: "etc/", Mode: 0755, Typeflag: tar.TypeDir})
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message":"Could not find the file ` + p + ` in container web"}`))
+				return
+			}
+			tw.Close()
+		case "/containers/web/json":
+			w.Write([]byte(`{"Config":{"Tty":false}}`))
+		case "/containers/tty/json":
+			w.Write([]byte(`{"Config":{"Tty":true}}`))
+		case "/containers/web/logs":
+			if q := r.URL.Query(); q.Get("stdout") != "1" || q.Get("stderr") != "1" || q.Get("tail") != "2" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			for _, f := range []struct {
+				stream byte
+				data   string
+			}{{1, "out\n"}, {2, "err\n"}} {
+				h := make([]byte, 8)
+				h[0] = f.stream
+				binary.BigEndian.PutUint32(h[4:], uint32(len(f.data)))
+				w.Write(h)
+				w.Write([]byte(f.data))
+			}
+		case "/containers/tty/logs":
+			w.Write([]byte("raw\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such container"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("DOCKER_HOST", "tcp://"+strings.TrimPrefix(srv.URL, "http://"))
+}
+
+func TestMainDocker(t *testing.T) {
+	fakeDocker(t)
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"docker://web:/etc/hostname"}, "web\n"},
+		{[]string{"docker-logs://web?tail=2"}, "out\nerr\n"},
+		{[]string{"docker-logs://tty"}, "raw\n"},
+		{[]string{"docker://web:/etc"}, "cat: docker://web:/etc: Is a directory\n"},
+		{[]string{"docker://web:/nope"}, "cat: docker://web:/nope: Could not find the file /nope in container web\n"},
+		{[]string{"docker://web"}, "cat: docker://web: expected container:/path\n"},
+		{[]string{"docker-logs://db"}, "cat: docker-logs://db: No such container\n"},
+		{[]string{"docker-logs://web?color=1"}, "cat: docker-logs://web?color=1: unknown parameter \"color\"\n"},
+	}
+	for _, c := range cases {
+		if out := runMain(c.args...); out != c.want {
+			t.Errorf("cat %s:\n%q, want\n%q", strings.Join(c.args, " "), out, c.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Kinds of programs that read the output of cat through a pipe.
+const (
+	readerOther  = iota
+	readerPager  // shows the output on a terminal, e.g. less
+	readerFilter // reads the output a line at a time, e.g. grep
+	readerJSON   // a filter that reads JSON, e.g. jq
+)
+
+// readerKinds are the kinds of known programs.
+var readerKinds = map[string]int{
+	"less":  readerPager,
+	"more":  readerPager,
+	"most":  readerPager,
+	"grep":  readerFilter,
+	"egrep": readerFilter,
+	"fgrep": readerFilter,
+	"rg":    readerFilter,
+	"ag":    readerFilter,
+	"sed":   readerFilter,
+	"awk":   readerFilter,
+	"gawk":  readerFilter,
+	"mawk":  readerFilter,
+	"cut":   readerFilter,
+	"head":  readerFilter,
+	"tail":  readerFilter,
+	"tee":   readerFilter,
+	"xargs": readerFilter,
+	"jq":    readerJSON,
+	"gron":  readerJSON,
+}
+
+// readerKind returns the kind of the program, given by its name or
+// path.
+func readerKind(program string) int {
+	name := strings.TrimSuffix(filepath.Base(program), ".exe")
+	return readerKinds[name]
+}
+
+// procRoot is where the proc file system is mounted.
+var procRoot = "/proc"
+
+// downstream returns the name of the program that reads the output of
+// cat through a pipe, as in cat log | grep error, or "" if it does not
+// know. Only Linux tells, in the proc file system.
+func downstream() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	fd := strconv.FormatUint(uint64(os.Stdout.Fd()), 10)
+	return pipeReader(procRoot, strconv.Itoa(os.Getpid()), fd)
+}
+
+// pipeReader looks for another process whose stdin is the pipe that is
+// the file descriptor fd of the process pid, and returns its name.
+func pipeReader(root, pid, fd string) string {
+	pipe, err := os.Readlink(filepath.Join(root, pid, "fd", fd))
+	if err != nil || !strings.HasPrefix(pipe, "pipe:") {
+		return ""
+	}
+	dirs, err := os.ReadDir(root)
+	if err != nil {
+		return ""
+	}
+	for _, d := range dirs {
+		if d.Name() == pid || !isNumber(d.Name()) {
+			continue
+		}
+		if in, err := os.Readlink(filepath.Join(root, d.Name(), "fd", "0")); err != nil || in != pipe {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(root, d.Name(), "comm"))
+		if err != nil {
+			return ""
+		}
+		return string(bytes.TrimSpace(comm))
+	}
+	return ""
+}
+
+func isNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// lineWriter writes whole lines, and keeps the beginning of a line
+// until its end is written, or it is longer than the buffer. Writes to
+// a pipe of at most PIPE_BUF bytes are atomic, so that the lines of
+// several writers to the same pipe do not mix, as in
+// (cat a & cat b) | grep x.
+type lineWriter struct {
+	w    io.Writer
+	line []byte
+}
+
+// lineMax is the most that a lineWriter keeps of a line.
+const lineMax = 64 << 10
+
+func (l *lineWriter) Write(b []byte) (int, error) {
+	l.line = append(l.line, b...)
+	end := bytes.LastIndexByte(l.line, '\n') + 1
+	if end == 0 {
+		if len(l.line) < lineMax {
+			return len(b), nil
+		}
+		end = len(l.line)
+	}
+	_, err := l.w.Write(l.line[:end])
+	l.line = append(l.line[:0], l.line[end:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close writes what is left, an unterminated last line.
+func (l *lineWriter) Close() error {
+	if len(l.line) == 0 {
+		return nil
+	}
+	_, err := l.w.Write(l.line)
+	l.line = nil
+	return err
+}
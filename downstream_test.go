@@ -0,0 +1,99 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReaderKind(t *testing.T) {
+	for program, want := range map[string]int{
+		"grep":          readerFilter,
+		"/usr/bin/less": readerPager,
+		"jq.exe":        readerJSON,
+		"vim":           readerOther,
+		"":              readerOther,
+	} {
+		if got := readerKind(program); got != want {
+			t.Errorf("readerKind(%q) = %d, want %d", program, got, want)
+		}
+	}
+}
+
+func TestPipeReader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+	root := t.TempDir()
+	proc := func(pid, fd, target, comm string) {
+		os.MkdirAll(filepath.Join(root, pid, "fd"), 0755)
+		if err := os.Symlink(target, filepath.Join(root, pid, "fd", fd)); err != nil {
+			t.Fatal(err)
+		}
+		if comm != "" {
+			os.WriteFile(filepath.Join(root, pid, "comm"), []byte(comm+"\n"), 0644)
+		}
+	}
+	proc("100", "1", "pipe:[42]", "cat")
+	proc("101", "0", "pipe:[7]", "less")
+	proc("102", "0", "pipe:[42]", "grep")
+	os.MkdirAll(filepath.Join(root, "self"), 0755)
+
+	if got := pipeReader(root, "100", "1"); got != "grep" {
+		t.Fatalf("unexpected reader %q, want grep", got)
+	}
+	if got := pipeReader(root, "101", "0"); got != "" {
+		t.Fatalf("unexpected reader %q of a pipe that no other reads", got)
+	}
+	proc("103", "1", "/dev/pts/0", "")
+	if got := pipeReader(root, "103", "1"); got != "" {
+		t.Fatalf("unexpected reader %q of a terminal", got)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	var writes []string
+	w := &lineWriter{w: writerFunc(func(b []byte) (int, error) {
+		writes = append(writes, string(b))
+		return len(b), nil
+	})}
+	w.Write([]byte("hel"))
+	w.Write([]byte("lo\nwor"))
+	w.Write([]byte("ld\nand\nmore"))
+	if len(writes) != 2 || writes[0] != "hello\n" || writes[1] != "world\nand\n" {
+		t.Fatalf("unexpected writes %q", writes)
+	}
+	w.Close()
+	if len(writes) != 3 || writes[2] != "more" {
+		t.Fatalf("unexpected writes %q after close", writes)
+	}
+
+	writes = nil
+	long := bytes.Repeat([]byte("x"), lineMax)
+	w.Write(long[:lineMax-1])
+	w.Write(long[:2])
+	if len(writes) != 1 || len(writes[0]) != lineMax+1 {
+		t.Fatalf("a line longer than the buffer was kept, %d writes", len(writes))
+	}
+}
+
+func TestMainFor(t *testing.T) {
+	got := runMain("--for=jq", "--group-by-ext", "testdata/b.md", "testdata/b.md")
+	if got != "worldworld" {
+		t.Fatalf("unexpected output %q for jq", got)
+	}
+	got = runMain("--for=/usr/bin/grep", "--group-by-ext", "testdata/b.md")
+	if got != "==> *.md <==\nworld" {
+		t.Fatalf("unexpected output %q for grep", got)
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
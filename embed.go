@@ -0,0 +1,55 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// catMu serializes the inputs that embedders read, e.g. the reads of
+// --grpc, as the pipeline keeps the flags and the state of a run, such
+// as the numbers of the lines, in globals. Each input is written whole
+// before the next one starts, so that inputs written to the same writer
+// are not interleaved.
+var catMu sync.Mutex
+
+// catInput writes an input as a run of its own would, and is safe to
+// call concurrently, also with the same writer: the lines are numbered
+// from 1, and HTML is a document of its own.
+func catInput(src string, w io.Writer) error {
+	catMu.Lock()
+	defer catMu.Unlock()
+	numbers = &lineNumbers{}
+	var err error
+	if isHTML(opts.format) {
+		err = writeHTMLHeader(w, opts.htmlTheme)
+	}
+	if err == nil {
+		err = process(src, w)
+	}
+	if err == nil && isHTML(opts.format) {
+		err = writeHTMLFooter(w)
+	}
+	return err
+}
+
+// catFunc is catInput for embedders that take the output chunk by chunk,
+// e.g. to send each as a message, rather than as an io.Writer. A chunk
+// is only valid during the call of fn, and an error of fn stops the
+// input and is returned.
+func catFunc(src string, fn func(chunk []byte) error) error {
+	return catInput(src, chunkWriter(fn))
+}
+
+// chunkWriter passes each write to a function.
+type chunkWriter func(chunk []byte) error
+
+func (f chunkWriter) Write(p []byte) (int, error) {
+	if err := f(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
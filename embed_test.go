@@ -0,0 +1,67 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCatInputConcurrent(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.txt")
+	os.WriteFile(src, []byte(strings.Repeat("line\n", 100)), 0644)
+	opts.number = true
+	defer func() { opts = options{} }()
+
+	// The inputs are written to the same writer, which is not safe for
+	// concurrent use, whole and numbered from 1.
+	var b bytes.Buffer
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := catInput(src, &b); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	var want strings.Builder
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(&want, "%6d\tline\n", i)
+	}
+	if got := b.String(); got != strings.Repeat(want.String(), 8) {
+		t.Fatalf("unexpected output of %d bytes:\n%s", len(got), got)
+	}
+}
+
+func TestCatFunc(t *testing.T) {
+	var chunks [][]byte
+	err := catFunc("testdata/b.md", func(chunk []byte) error {
+		chunks = append(chunks, append([]byte(nil), chunk...))
+		return nil
+	})
+	if got := string(bytes.Join(chunks, nil)); got != "world" || err != nil {
+		t.Fatalf("unexpected chunks %q: %v", got, err)
+	}
+
+	// An error of the function stops the input.
+	stop := errors.New("stop")
+	calls := 0
+	err = catFunc("testdata/b.md", func(chunk []byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("unexpected error after %d calls: %v", calls, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+)
+
+// expandEnv replaces the references to environment variables of a line,
+// $VAR or ${VAR}, by their values like envsubst(1). Unset variables
+// expand to nothing. If --env-allow lists variables, the references to
+// others are left as they are.
+func expandEnv(line []byte) []byte {
+	var b bytes.Buffer
+	for {
+		i := bytes.IndexByte(line, '$')
+		if i < 0 || i+1 == len(line) {
+			b.Write(line)
+			return b.Bytes()
+		}
+		b.Write(line[:i])
+		ref, name := line[i:i+1], []byte(nil)
+		if line[i+1] == '{' {
+			if j := bytes.IndexByte(line[i:], '}'); j > 0 {
+				ref, name = line[i:i+j+1], line[i+2:i+j]
+			}
+		} else {
+			j := i + 1
+			for j < len(line) && isNameByte(line[j], j == i+1) {
+				j++
+			}
+			ref, name = line[i:j], line[i+1:j]
+		}
+		if isEnvName(name) && envAllowed(string(name)) {
+			b.WriteString(os.Getenv(string(name)))
+		} else {
+			b.Write(ref)
+		}
+		line = line[i+len(ref):]
+	}
+}
+
+// envAllowed reports whether a variable may be expanded.
+func envAllowed(name string) bool {
+	if len(opts.envAllow) == 0 {
+		return true
+	}
+	for _, n := range opts.envAllow {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isEnvName(name []byte) bool {
+	for i, c := range name {
+		if !isNameByte(c, i == 0) {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainEnvsubst(t *testing.T) {
+	t.Setenv("CAT_HOST", "example.com")
+	t.Setenv("CAT_PORT", "8080")
+	name := filepath.Join(t.TempDir(), "a.conf")
+	os.WriteFile(name, []byte("url=http://${CAT_HOST}:$CAT_PORT/\nunset=[$CAT_UNSET] args=$1 price=$ 5 ${CAT_HOST\n"), 0644)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"--envsubst", name},
+			"url=http://example.com:8080/\nunset=[] args=$1 price=$ 5 ${CAT_HOST\n",
+		},
+		{
+			[]string{"--envsubst", "--env-allow", "CAT_HOST,$CAT_UNSET", name},
+			"url=http://example.com:$CAT_PORT/\nunset=[] args=$1 price=$ 5 ${CAT_HOST\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := runMain(tt.args...); got != tt.want {
+			t.Errorf("cat %v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func init() { registerFeature("exec") }
+
+func isELF(head []byte, _ string) bool {
+	return bytes.HasPrefix(head, []byte(elf.ELFMAG))
+}
+
+func isMachO(head []byte, _ string) bool {
+	if len(head) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(head) {
+	case macho.Magic32, macho.Magic64, 0xcefaedfe, 0xcffaedfe:
+		return true
+	case macho.MagicFat:
+		// Java class files share the magic of universal binaries, and
+		// follow it by their version, which is larger than any number
+		// of architectures.
+		return binary.BigEndian.Uint32(head[4:]) < 45
+	}
+	return false
+}
+
+func isPE(head []byte, _ string) bool {
+	if len(head) < 0x40 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	off := int(binary.LittleEndian.Uint32(head[0x3c:]))
+	return off+4 <= len(head) && string(head[off:off+4]) == "PE\x00\x00"
+}
+
+// section is a section of an executable and its size.
+type section struct {
+	name string
+	size uint64
+}
+
+// execSummary is what the previews of executables show.
+type execSummary struct {
+	format   string
+	arch     string
+	libs     []string
+	sections []section
+}
+
+func (s *execSummary) writeTo(w io.Writer) {
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	fmt.Fprintf(bw, "format: %s\narch: %s\n", s.format, s.arch)
+	bw.WriteString("libraries:\n")
+	if len(s.libs) == 0 {
+		bw.WriteString("  (none)\n")
+	}
+	for _, l := range s.libs {
+		fmt.Fprintf(bw, "  %s\n", l)
+	}
+	bw.WriteString("sections:\n")
+	width := 0
+	for _, sec := range s.sections {
+		if len(sec.name) > width {
+			width = len(sec.name)
+		}
+	}
+	for _, sec := range s.sections {
+		fmt.Fprintf(bw, "  %-*s %d\n", width, sec.name, sec.size)
+	}
+}
+
+// readExec reads a whole executable for the debug packages, which
+// need to seek in it.
+func readExec(r io.Reader) (*bytes.Reader, error) {
+	b, err := io.ReadAll(r)
+	return bytes.NewReader(b), err
+}
+
+// elfMachines names the common machines of ELF files like Go does.
+var elfMachines = map[elf.Machine]string{
+	elf.EM_386:     "386",
+	elf.EM_X86_64:  "amd64",
+	elf.EM_ARM:     "arm",
+	elf.EM_AARCH64: "arm64",
+}
+
+// previewELF writes the class, type and machine of an ELF file, the
+// shared libraries it needs and the sizes of its sections.
+func previewELF(w io.Writer, r *bufio.Reader) error {
+	br, err := readExec(r)
+	if err != nil {
+		return err
+	}
+	f, err := elf.NewFile(br)
+	if err != nil {
+		return err
+	}
+	kind := map[elf.Type]string{
+		elf.ET_REL:  "relocatable",
+		elf.ET_EXEC: "executable",
+		elf.ET_DYN:  "shared object",
+		elf.ET_CORE: "core",
+	}[f.Type]
+	if kind == "" {
+		kind = f.Type.String()
+	}
+	bits := "32-bit"
+	if f.Class == elf.ELFCLASS64 {
+		bits = "64-bit"
+	}
+	s := execSummary{
+		format: fmt.Sprintf("ELF %s %s", bits, kind),
+		arch:   elfMachines[f.Machine],
+	}
+	if s.arch == "" {
+		s.arch = strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+	}
+	// Static executables have no dynamic section to find libraries in.
+	s.libs, _ = f.ImportedLibraries()
+	for _, sec := range f.Sections {
+		if sec.Name != "" {
+			s.sections = append(s.sections, section{sec.Name, sec.Size})
+		}
+	}
+	s.writeTo(w)
+	return nil
+}
+
+// previewMachO writes the type and architecture of a Mach-O file, the
+// libraries it links and the sizes of its sections. Universal binaries
+// are shown an architecture at a time.
+func previewMachO(w io.Writer, r *bufio.Reader) error {
+	br, err := readExec(r)
+	if err != nil {
+		return err
+	}
+	var files []*macho.File
+	if fat, err := macho.NewFatFile(br); err == nil {
+		for _, a := range fat.Arches {
+			files = append(files, a.File)
+		}
+	} else {
+		f, err := macho.NewFile(br)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	for i, f := range files {
+		if i > 0 {
+			io.WriteString(w, "\n")
+		}
+		format := "Mach-O"
+		if len(files) > 1 {
+			format = "Mach-O universal"
+		}
+		bits := "32-bit"
+		if f.Magic == macho.Magic64 {
+			bits = "64-bit"
+		}
+		kind := map[macho.Type]string{
+			macho.TypeObj:    "object",
+			macho.TypeExec:   "executable",
+			macho.TypeDylib:  "dynamic library",
+			macho.TypeBundle: "bundle",
+		}[f.Type]
+		if kind == "" {
+			kind = f.Type.String()
+		}
+		s := execSummary{
+			format: fmt.Sprintf("%s %s %s", format, bits, kind),
+			arch:   strings.ToLower(strings.TrimPrefix(f.Cpu.String(), "Cpu")),
+		}
+		s.libs, _ = f.ImportedLibraries()
+		for _, sec := range f.Sections {
+			s.sections = append(s.sections, section{sec.Seg + "," + sec.Name, sec.Size})
+		}
+		s.writeTo(w)
+	}
+	return nil
+}
+
+// peMachines names the common machines of PE files like Go does.
+var peMachines = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARM:   "arm",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+// previewPE writes the kind and machine of a PE file, the DLLs it
+// imports from and the sizes of its sections.
+func previewPE(w io.Writer, r *bufio.Reader) error {
+	br, err := readExec(r)
+	if err != nil {
+		return err
+	}
+	f, err := pe.NewFile(br)
+	if err != nil {
+		return err
+	}
+	kind := "executable"
+	if f.Characteristics&pe.IMAGE_FILE_DLL != 0 {
+		kind = "DLL"
+	}
+	bits := "32-bit"
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		bits = "64-bit"
+	}
+	arch, ok := peMachines[f.Machine]
+	if !ok {
+		arch = fmt.Sprintf("0x%04x", f.Machine)
+	}
+	s := execSummary{format: fmt.Sprintf("PE %s %s", bits, kind), arch: arch}
+	// The imported symbols are named symbol:dll, which tells the DLLs.
+	syms, _ := f.ImportedSymbols()
+	seen := map[string]bool{}
+	for _, sym := range syms {
+		if i := strings.LastIndexByte(sym, ':'); i >= 0 && !seen[sym[i+1:]] {
+			seen[sym[i+1:]] = true
+			s.libs = append(s.libs, sym[i+1:])
+		}
+	}
+	sort.Strings(s.libs)
+	for _, sec := range f.Sections {
+		s.sections = append(s.sections, section{sec.Name, uint64(sec.VirtualSize)})
+	}
+	s.writeTo(w)
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPreviewExec(t *testing.T) {
+	// The test binary is an executable of the format of the platform.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	want := map[string]string{
+		"linux":   "format: ELF",
+		"darwin":  "format: Mach-O",
+		"windows": "format: PE",
+	}[runtime.GOOS]
+	if want == "" {
+		t.Skipf("no executable format known for %s", runtime.GOOS)
+	}
+
+	got := runMain("--preview", exe)
+	if !strings.HasPrefix(got, want) {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+		if !strings.Contains(got, "\narch: "+runtime.GOARCH+"\n") {
+			t.Fatalf("missing architecture %s:\n%s", runtime.GOARCH, got)
+		}
+	}
+	if !strings.Contains(got, "\nsections:\n") {
+		t.Fatalf("missing sections:\n%s", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// The exit codes of cat, for scripts and tools that run it to tell why
+// it failed.
+const (
+	exitOK     = 0 // all inputs were written
+	exitFailed = 1 // some inputs could not be read
+	exitUsage  = 2 // the flags are invalid
+	exitWrite  = 3 // the output could not be written
+
+	exitTimeout = 124 // the --timeout was over, as of timeout(1)
+)
+
+// outputWriter is the writer of the output at the bottom of all others,
+// which records whether writing to it failed.
+type outputWriter struct {
+	w      io.Writer
+	failed bool
+}
+
+// outputReaderFrom is an outputWriter that keeps the io.ReaderFrom of
+// the writer it wraps, e.g. of a file.
+type outputReaderFrom struct {
+	*outputWriter
+}
+
+func newOutputWriter(w io.Writer) (io.Writer, *outputWriter) {
+	o := &outputWriter{w: w}
+	if _, ok := w.(io.ReaderFrom); ok {
+		return outputReaderFrom{o}, o
+	}
+	return o, o
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	if err != nil {
+		o.failed = true
+	}
+	return n, err
+}
+
+func (o outputReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	n, err := o.w.(io.ReaderFrom).ReadFrom(r)
+	if err != nil && isWriteError(err) {
+		o.failed = true
+	}
+	return n, err
+}
+
+// isWriteError reports whether an error of copying to a file came from
+// writing to it rather than from reading.
+func isWriteError(err error) bool {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return pe.Op == "write"
+	}
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ENOSPC)
+}
+
+// inputSummary counts the inputs that were read, and remembers those
+// that failed, for --summary.
+type inputSummary struct {
+	inputs int
+	failed []string
+}
+
+func (s *inputSummary) add(name string, err error) {
+	s.inputs++
+	if err != nil {
+		s.failed = append(s.failed, name)
+	}
+}
+
+func (s *inputSummary) report(w io.Writer) {
+	fmt.Fprintf(w, tr("cat: %d inputs, %d written, %d failed\n"), s.inputs, s.inputs-len(s.failed), len(s.failed))
+	for _, name := range s.failed {
+		fmt.Fprintf(w, tr("cat: failed: %s\n"), name)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"testdata/a.txt"}, exitOK},
+		{[]string{"testdata/a.txt", "testdata/none.txt"}, exitFailed},
+		{[]string{"--no-such-flag", "testdata/a.txt"}, exitUsage},
+		{[]string{"--replay-speed=0", "testdata/a.txt"}, exitUsage},
+		{[]string{"-o", filepath.Join(dir, "none", "out"), "testdata/a.txt"}, exitWrite},
+	}
+	for _, tt := range tests {
+		if _, code := runMainCode(tt.args...); code != tt.code {
+			t.Errorf("%q: exit code %d, want %d", tt.args, code, tt.code)
+		}
+	}
+}
+
+func TestMainSummary(t *testing.T) {
+	out, code := runMainCode("--summary", "testdata/a.txt", "testdata/none.txt", "testdata/b.md", "testdata/nothing.txt")
+	if code != exitFailed {
+		t.Fatalf("exit code %d", code)
+	}
+	want := "cat: 4 inputs, 2 written, 2 failed\ncat: failed: testdata/none.txt\ncat: failed: testdata/nothing.txt\n"
+	if !strings.HasSuffix(out, want) {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestOutputWriter(t *testing.T) {
+	w, o := newOutputWriter(&faultyWriter{})
+	if _, ok := w.(io.ReaderFrom); ok {
+		t.Fatal("the writer has a ReadFrom that the one it wraps lacks")
+	}
+	io.WriteString(w, "x")
+	if !o.failed {
+		t.Fatal("the write error is not recorded")
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, o = newOutputWriter(f)
+	if _, ok := w.(io.ReaderFrom); !ok {
+		t.Fatal("the writer lost the ReadFrom of the file")
+	}
+	// A read error is no write error.
+	if _, err := io.Copy(w, io.MultiReader(strings.NewReader("x"), iotest.ErrReader(&os.PathError{Op: "read", Path: "in", Err: errors.New("bad sector")}))); err == nil {
+		t.Fatal("no error")
+	}
+	if o.failed {
+		t.Fatal("a read error is recorded as a write error")
+	}
+	f.Close()
+	io.WriteString(w, "x")
+	if !o.failed {
+		t.Fatal("the write error is not recorded")
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// faults decides which operations of a faultFS or a faultWriter fail.
+// The decisions follow from the seed, so that a test sees the same
+// faults in every run.
+type faults struct {
+	seed int64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// fail reports whether the next operation fails, as the given share of
+// them do.
+func (f *faults) fail(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(f.seed))
+	}
+	return f.rand.Float64() < rate
+}
+
+// faultFS is a slow and faulty file system for tests, which wraps
+// another one. Set as the virtualFS, it tests how cat copes with the
+// timeouts, retries and partial reads of inputs without relying on the
+// behavior of a particular system.
+type faultFS struct {
+	fs fs.FS
+
+	latency     time.Duration // before each open and read
+	openErrRate float64       // the share of opens that fail
+	openErr     error         // of the opens that fail, EIO if nil
+	readErrRate float64       // the share of reads that fail
+	readErr     error         // of the reads that fail, EIO if nil
+	maxRead     int           // the most bytes a read returns, if not 0
+
+	// stall, if not nil, blocks the reads of a file that read stallAfter
+	// bytes until it is closed, like a hung mount.
+	stall      <-chan struct{}
+	stallAfter int
+
+	faults
+}
+
+func (f *faultFS) Open(name string) (fs.File, error) {
+	if f.latency > 0 {
+		sleep(f.latency)
+	}
+	if f.fail(f.openErrRate) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errOr(f.openErr, syscall.EIO)}
+	}
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{File: file, fs: f, name: name}, nil
+}
+
+// faultFile is a file of a faultFS.
+type faultFile struct {
+	fs.File
+	fs   *faultFS
+	name string
+	n    int // the number of bytes read
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	if f.fs.stall != nil && f.n >= f.fs.stallAfter {
+		<-f.fs.stall
+	}
+	if f.fs.latency > 0 {
+		sleep(f.fs.latency)
+	}
+	if f.fs.fail(f.fs.readErrRate) {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: errOr(f.fs.readErr, syscall.EIO)}
+	}
+	if f.fs.maxRead > 0 && len(p) > f.fs.maxRead {
+		p = p[:f.fs.maxRead]
+	}
+	if f.fs.stall != nil && f.n < f.fs.stallAfter && len(p) > f.fs.stallAfter-f.n {
+		p = p[:f.fs.stallAfter-f.n]
+	}
+	n, err := f.File.Read(p)
+	f.n += n
+	return n, err
+}
+
+// faultWriter is a faulty writer for tests: it writes at most maxWrite
+// bytes at a time, if not 0, and reports the writes it cut short with
+// io.ErrShortWrite. It fails the given share of writes with err, EIO if
+// nil, before it writes anything.
+type faultWriter struct {
+	w        io.Writer
+	maxWrite int
+	errRate  float64
+	err      error
+
+	faults
+}
+
+func (w *faultWriter) Write(p []byte) (int, error) {
+	if w.fail(w.errRate) {
+		return 0, errOr(w.err, syscall.EIO)
+	}
+	if w.maxWrite > 0 && len(p) > w.maxWrite {
+		n, err := w.w.Write(p[:w.maxWrite])
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+	return w.w.Write(p)
+}
+
+func errOr(err, def error) error {
+	if err == nil {
+		return def
+	}
+	return err
+}
+
+// useFS makes fsys the virtual file system of the test.
+func useFS(t *testing.T, fsys fs.FS) {
+	t.Cleanup(func() { virtualFS = nil })
+	virtualFS = fsys
+}
+
+func TestFaults(t *testing.T) {
+	count := func(seed int64) (n int) {
+		f := &faults{seed: seed}
+		for i := 0; i < 1000; i++ {
+			if f.fail(0.3) {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(1); n != count(1) || n < 250 || n > 350 {
+		t.Fatalf("unexpected %d faults of 1000 at a rate of 0.3", n)
+	}
+	if f := (&faults{}); f.fail(0) || !f.fail(1) {
+		t.Fatal("unexpected faults at the rates 0 and 1")
+	}
+}
+
+func TestFaultWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := &faultWriter{w: &b, maxWrite: 2}
+	if n, err := w.Write([]byte("abc")); n != 2 || err != io.ErrShortWrite {
+		t.Fatalf("unexpected short write: %d, %v", n, err)
+	}
+	if n, err := w.Write([]byte("c")); n != 1 || err != nil || b.String() != "abc" {
+		t.Fatalf("unexpected write: %d, %v, %q", n, err, b.String())
+	}
+}
+
+func TestMainFaultFSTimeout(t *testing.T) {
+	// The first input hangs after its first 7 bytes.
+	stall := make(chan struct{})
+	defer close(stall)
+	useFS(t, &faultFS{
+		fs:         memFS{"a.txt": []byte("hello, world\n"), "b.txt": []byte("world")},
+		stall:      stall,
+		stallAfter: 7,
+	})
+
+	got := runMain("--per-file-timeout=50ms", "a.txt", "b.txt")
+	if want := "hello, world" + "cat: a.txt: timed out after 50ms\n"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+	got, code := runMainCode("--timeout=50ms", "b.txt", "a.txt", "b.txt")
+	if want := "world" + "hello, " + "cat: a.txt: timed out after 50ms\n"; got != want || code != exitTimeout {
+		t.Fatalf("unexpected output %q and exit code %d, want %q", got, code, want)
+	}
+}
+
+func TestMainFaultFSRetry(t *testing.T) {
+	defer func() { openBackoff = time.Second }()
+	openBackoff = 100 * time.Millisecond
+
+	// Half of the opens run out of file descriptors, and are retried.
+	files := memFS{}
+	var args []string
+	for _, name := range strings.Fields("a b c d e f g h") {
+		files[name] = []byte(name)
+		args = append(args, name)
+	}
+	useFS(t, &faultFS{fs: files, openErrRate: 0.5, openErr: syscall.EMFILE, faults: faults{seed: 1}})
+	if got := runMain(args...); got != "abcdefgh" {
+		t.Fatalf("unexpected output %q", got)
+	}
+
+	useFS(t, &faultFS{fs: files, openErrRate: 1, openErr: syscall.ENFILE})
+	want := "cat: " + (&fs.PathError{Op: "open", Path: "a", Err: syscall.ENFILE}).Error() + "\n"
+	if got := runMain("a"); got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+	// Other errors are not retried.
+	f := &faultFS{fs: files, openErrRate: 1, latency: time.Millisecond}
+	useFS(t, f)
+	delays := recordSleeps(t)
+	want = "cat: " + (&fs.PathError{Op: "open", Path: "a", Err: syscall.EIO}).Error() + "\n"
+	if got := runMain("a"); got != want || len(*delays) != 1 {
+		t.Fatalf("unexpected output %q after %d opens, want %q", got, len(*delays), want)
+	}
+}
+
+func TestMainFaultFSReads(t *testing.T) {
+	files := memFS{"a.go": []byte("package a\n\nfunc A() {}\n"), "b.md": []byte("# B\n")}
+	useFS(t, files)
+	want := runMain("--fence", "--strip-blank-lines", "-n", "a.go", "b.md")
+
+	// Reads of a byte at a time make no difference.
+	useFS(t, &faultFS{fs: files, maxRead: 1})
+	if got := runMain("--fence", "--strip-blank-lines", "-n", "a.go", "b.md"); got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+
+	// What was read before a read failed is written.
+	useFS(t, &faultFS{fs: files, maxRead: 4, readErrRate: 0.5, faults: faults{seed: 1}})
+	want = "package a\n\nf" + "cat: " + (&fs.PathError{Op: "read", Path: "a.go", Err: syscall.EIO}).Error() + "\n"
+	if got := runMain("a.go"); got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+}
+
+func TestRetryWriterFaults(t *testing.T) {
+	defer func() { writeBackoff = 100 * time.Millisecond }()
+	writeBackoff = time.Millisecond
+
+	// Writes that would block or are interrupted are retried until all
+	// is written. The input is read a byte at a time for many writes.
+	data := bytes.Repeat([]byte("hello, world\n"), 20)
+	for _, err := range []error{syscall.EAGAIN, syscall.EINTR} {
+		var b bytes.Buffer
+		f := &faultWriter{w: &b, errRate: 0.3, err: err, faults: faults{seed: 3}}
+		n, werr := copyBuffer(&retryWriter{w: f}, iotest.OneByteReader(bytes.NewReader(data)))
+		if n != int64(len(data)) || werr != nil || !bytes.Equal(b.Bytes(), data) {
+			t.Fatalf("%v: wrote %d bytes of %d: %v", err, n, len(data), werr)
+		}
+	}
+
+	var b bytes.Buffer
+	f := &faultWriter{w: &b, errRate: 0.3, faults: faults{seed: 3}}
+	n, err := io.Copy(&retryWriter{w: f}, iotest.OneByteReader(bytes.NewReader(data)))
+	if !errors.Is(err, syscall.EIO) || n != int64(b.Len()) {
+		t.Fatalf("expect EIO after the %d bytes written, got %d bytes: %v", b.Len(), n, err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// fdPath reports the file descriptor behind paths such as /dev/fd/63,
+// which shells pass for process substitutions like <(...).
+//
+// Such paths have to be read through the descriptor directly: on Linux
+// they are symbolic links to names like pipe:[1234] that cannot be
+// opened as a file, and on other systems the path may not exist at all.
+func fdPath(src string) (int, bool) {
+	for _, dir := range []string{"/dev/fd/", "/proc/self/fd/"} {
+		if !strings.HasPrefix(src, dir) {
+			continue
+		}
+		fd, err := strconv.Atoi(src[len(dir):])
+		if err != nil || fd < 0 {
+			return 0, false
+		}
+		return fd, true
+	}
+	return 0, false
+}
+
+// openFD opens a file descriptor that cat inherited for reading. It
+// reads a copy of the descriptor and closes only the copy, so that the
+// same descriptor can be read more than once, as in cat /dev/fd/3
+// /dev/fd/3. Descriptors that cat opened itself cannot be read, nor
+// closed, this way.
+func openFD(fd int, src string) (io.ReadCloser, error) {
+	r, err := dupFD(fd, src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	return r, nil
+}
+
+var (
+	errBadFD        = errors.New("Bad file descriptor")
+	errNotInherited = errors.New("the descriptor was not inherited")
+)
+
+// fdList collects the descriptors given by repeated --fd flags.
+type fdList []int
+
+func (l *fdList) String() string { return fmt.Sprint(*l) }
+
+func (l *fdList) Set(s string) error {
+	fd, err := strconv.Atoi(s)
+	if err != nil || fd < 0 {
+		return fmt.Errorf("invalid file descriptor %q", s)
+	}
+	*l = append(*l, fd)
+	return nil
+}
+
+// paths turns the descriptors into inputs that are read like files.
+func (l fdList) paths() []string {
+	paths := make([]string, 0, len(l))
+	for _, fd := range l {
+		paths = append(paths, "/dev/fd/"+strconv.Itoa(fd))
+	}
+	return paths
+}
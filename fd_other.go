@@ -0,0 +1,22 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// dupFD returns the standard streams only, which are left open after
+// reading, as the system does not tell which other descriptors were
+// inherited.
+func dupFD(fd int, src string) (io.ReadCloser, error) {
+	if fd > 2 {
+		return nil, errBadFD
+	}
+	return io.NopCloser([]*os.File{os.Stdin, os.Stdout, os.Stderr}[fd]), nil
+}
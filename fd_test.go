@@ -0,0 +1,27 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestFDPath(t *testing.T) {
+	tests := []struct {
+		src string
+		fd  int
+		ok  bool
+	}{
+		{"/dev/fd/63", 63, true},
+		{"/proc/self/fd/3", 3, true},
+		{"/dev/fd/x", 0, false},
+		{"/dev/fd/-1", 0, false},
+		{"testdata/a.txt", 0, false},
+	}
+	for _, tt := range tests {
+		fd, ok := fdPath(tt.src)
+		if fd != tt.fd || ok != tt.ok {
+			t.Errorf("fdPath(%q): got %d %v, want %d %v", tt.src, fd, ok, tt.fd, tt.ok)
+		}
+	}
+}
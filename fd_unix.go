@@ -0,0 +1,40 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// dupFD returns a copy of an inherited descriptor, which is closed on
+// exec like the files that Go opens.
+//
+// Go opens all files with close-on-exec, and an exec closes the ones
+// that have it, so a descriptor without it was inherited.
+func dupFD(fd int, src string) (io.ReadCloser, error) {
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_GETFD, 0)
+	if errno != 0 {
+		return nil, errBadFD
+	}
+	if flags&syscall.FD_CLOEXEC != 0 {
+		return nil, errNotInherited
+	}
+	// The lock keeps a concurrent exec from inheriting the copy before
+	// it is marked.
+	syscall.ForkLock.RLock()
+	dup, err := syscall.Dup(fd)
+	if err == nil {
+		syscall.CloseOnExec(dup)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(dup), src), nil
+}
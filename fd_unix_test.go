@@ -0,0 +1,71 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// inheritedPipe returns the read end of a pipe with the given content.
+// Unlike those of os.Pipe, its ends are not closed on exec, as those of
+// a descriptor that cat inherited.
+func inheritedPipe(t *testing.T, content string) *os.File {
+	t.Helper()
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	r, w := os.NewFile(uintptr(p[0]), "r"), os.NewFile(uintptr(p[1]), "w")
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		w.WriteString(content)
+		w.Close()
+	}()
+	return r
+}
+
+func TestCatFD(t *testing.T) {
+	r := inheritedPipe(t, "from a pipe")
+	w := newCompleteWriter()
+	if err := cat(fmt.Sprintf("/dev/fd/%d", r.Fd()), w); err != nil {
+		t.Fatalf("failed to cat pipe: %v", err)
+	}
+	if w.String() != "from a pipe" {
+		t.Fatalf("unexpected content: %q", w.String())
+	}
+	// Only the copy was closed.
+	if _, err := r.Stat(); err != nil {
+		t.Fatalf("the descriptor was closed: %v", err)
+	}
+
+	r = inheritedPipe(t, "hello ")
+	src := fmt.Sprintf("/dev/fd/%d", r.Fd())
+	if got := runMain("--fd", fmt.Sprint(r.Fd()), "testdata/b.md", src); got != "hello world" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	if err := cat("/dev/fd/12345", newCompleteWriter()); err == nil {
+		t.Fatalf("expect closed descriptor to fail")
+	}
+
+	// A file that cat opened itself is not read, nor closed.
+	f, err := os.Open("testdata/b.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	src = fmt.Sprintf("/dev/fd/%d", f.Fd())
+	if got, want := runMain(src), "cat: "+src+": the descriptor was not inherited\n"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+	if _, err := f.Stat(); err != nil {
+		t.Fatalf("the file was closed: %v", err)
+	}
+}
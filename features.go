@@ -0,0 +1,67 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// optionalFeatures describes the features that the minimal build tag
+// leaves out, for a small binary, by their names.
+var optionalFeatures = map[string]string{
+	"archives":  "members of tar, zip and other archives as inputs, and archives with -r",
+	"avro":      "previews of Avro files with --preview",
+	"cert":      "previews of PEM certificates and keys with --preview",
+	"docker":    "files and logs of containers as inputs, docker:// and docker-logs://",
+	"exec":      "previews of ELF, Mach-O and PE executables with --preview",
+	"grpc":      "a gRPC service of the files that the policy allows, --grpc",
+	"highlight": "highlighting of source code in --format=html, and --strip-comments",
+	"http":      "URLs as inputs, --serve, --ws and --profile-http",
+	"journal":   "queries of the systemd journal as inputs, journal://",
+	"kafka":     "the output as records of a Kafka topic, --kafka",
+	"mail":      "e-mail messages as text, the mail: decoder",
+	"oci":       "files in images of container registries as inputs, oci://",
+	"pcap":      "previews of packet captures with --preview",
+	"profile":   "CPU profiles of the run, --profile",
+	"proto":     "protocol buffers as JSON, --proto-desc and --proto-type",
+	"publish":   "the output as NATS and MQTT messages, --publish",
+	"signature": "checks of minisign and SSH signatures, --minisign-key and --ssh-key",
+	"syslog":    "the output as syslog messages, --syslog",
+	"template":  "inputs as Go templates, --template and --data",
+}
+
+// features are the optional features that are compiled in. The files
+// of a feature register it.
+var features = map[string]bool{}
+
+func registerFeature(name string) { features[name] = true }
+
+// featureError is the error of an input that needs a feature that is
+// not compiled in.
+func featureError(src, name string) error {
+	return fmt.Errorf("%s: %s are not compiled in, build cat without the minimal tag", src, name)
+}
+
+// writeFeatures writes the optional features, and whether they are
+// compiled in.
+func writeFeatures(w io.Writer) error {
+	names := make([]string, 0, len(optionalFeatures))
+	for name := range optionalFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		state := "no "
+		if features[name] {
+			state = "yes"
+		}
+		if _, err := fmt.Fprintf(w, "%-10s %s %s\n", name, state, optionalFeatures[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requireFeature skips the test if the optional feature is not compiled
+// in, as in the minimal build.
+func requireFeature(t testing.TB, name string) {
+	t.Helper()
+	if !features[name] {
+		t.Skipf("%s is not compiled in", name)
+	}
+}
+
+func TestMainFeatures(t *testing.T) {
+	got := runMain("--features")
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != len(optionalFeatures) {
+		t.Fatalf("unexpected features:\n%s", got)
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || optionalFeatures[fields[0]] == "" || (fields[1] == "yes") != features[fields[0]] {
+			t.Fatalf("unexpected feature %q", line)
+		}
+	}
+}
+
+// minimalSize is the most bytes that the stripped minimal build may
+// take, which stays far below the full build.
+const minimalSize = 6 << 20
+
+func TestMinimalSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds cat")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	out := filepath.Join(t.TempDir(), "cat")
+	cmd := exec.Command(goTool, "build", "-tags", "minimal", "-trimpath", "-ldflags=-s -w", "-o", out, ".")
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, b)
+	}
+	fi, err := os.Stat(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > minimalSize {
+		t.Fatalf("the minimal build takes %d bytes, more than %d", fi.Size(), minimalSize)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// fenced reports whether a code block was written already.
+var fenced bool
+
+// writeFence writes the content of r as a Markdown code block that is
+// annotated with the language and the name of the input, e.g.
+//
+//	```go cat.go
+//	package main
+//	```
+//
+// The fence is made longer than any run of backticks in the content,
+// so that the content cannot end the block early. Blocks are separated
+// by an empty line.
+func writeFence(w io.Writer, name string, r io.Reader) error {
+	// The fence depends on all of the content, which is kept in a
+	// temporary file beyond --max-memory.
+	var buf spillBuffer
+	defer buf.Close()
+	runs := &runCounter{c: '`'}
+	if _, err := io.Copy(io.MultiWriter(&buf, runs), r); err != nil {
+		return err
+	}
+	n := runs.longest + 1
+	if n < 3 {
+		n = 3
+	}
+	fence := strings.Repeat("`", n)
+
+	var b strings.Builder
+	if fenced {
+		b.WriteByte('\n')
+	}
+	fenced = true
+	b.WriteString(fence)
+	if lang := language(name); lang != "" {
+		b.WriteString(lang)
+		b.WriteByte(' ')
+	}
+	b.WriteString(name)
+	b.WriteByte('\n')
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	content, err := buf.Reader()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		return err
+	}
+	end := fence + "\n"
+	if buf.Len() > 0 && runs.last != '\n' {
+		end = "\n" + end
+	}
+	_, err = io.WriteString(w, end)
+	return err
+}
+
+// runCounter finds the longest run of c in what is written to it.
+type runCounter struct {
+	c       byte
+	n       int
+	longest int
+	last    byte // the last byte written
+}
+
+func (r *runCounter) Write(b []byte) (int, error) {
+	for _, x := range b {
+		if x != r.c {
+			r.n = 0
+			continue
+		}
+		r.n++
+		if r.n > r.longest {
+			r.longest = r.n
+		}
+	}
+	if len(b) > 0 {
+		r.last = b[len(b)-1]
+	}
+	return len(b), nil
+}
+
+// longestRun returns the length of the longest run of c in b.
+func longestRun(b []byte, c byte) int {
+	longest, n := 0, 0
+	for _, x := range b {
+		if x != c {
+			n = 0
+			continue
+		}
+		n++
+		if n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
@@ -0,0 +1,31 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteFence(t *testing.T) {
+	defer func() { fenced = false }()
+	var buf bytes.Buffer
+	for _, in := range []struct{ name, content string }{
+		{"main.go", "package main\n"},
+		{"README.md", "```sh\n$ cat\n```"},
+		{"LICENSE", ""},
+	} {
+		if err := writeFence(&buf, in.name, strings.NewReader(in.content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	want := "```go main.go\npackage main\n```\n" +
+		"\n````markdown README.md\n```sh\n$ cat\n```\n````\n" +
+		"\n```LICENSE\n```\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package main
+
+import "os"
+
+// fileKey identifies a file independent of the path it is reached by.
+type fileKey struct{}
+
+// fileKeyOf returns false as the system does not expose file ids in
+// the file info, hence hard links cannot be recognized.
+func fileKeyOf(i os.FileInfo) (fileKey, bool) { return fileKey{}, false }
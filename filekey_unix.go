@@ -0,0 +1,24 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file independent of the path it is reached by.
+type fileKey struct{ dev, ino uint64 }
+
+// fileKeyOf returns the device and inode of a file.
+func fileKeyOf(i os.FileInfo) (fileKey, bool) {
+	st, ok := i.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{uint64(st.Dev), uint64(st.Ino)}, true
+}
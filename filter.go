@@ -0,0 +1,127 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepFile reports whether a file found with -r passes the size and
+// modification time filters. Files named on the command line are not
+// filtered, they were asked for explicitly.
+func keepFile(i os.FileInfo) bool {
+	if opts.minSize.set && i.Size() < opts.minSize.n {
+		return false
+	}
+	if opts.maxSize.set && i.Size() > opts.maxSize.n {
+		return false
+	}
+	if opts.newerThan.set && !i.ModTime().After(opts.newerThan.t) {
+		return false
+	}
+	if opts.olderThan.set && !i.ModTime().Before(opts.olderThan.t) {
+		return false
+	}
+	return true
+}
+
+// sizeFlag is a size such as 512, 10K or 1.5M, in powers of 1024.
+type sizeFlag struct {
+	n   int64
+	set bool
+}
+
+func (s *sizeFlag) String() string {
+	if !s.set {
+		return ""
+	}
+	return strconv.FormatInt(s.n, 10)
+}
+
+func (s *sizeFlag) Set(v string) error {
+	n, err := parseSize(v)
+	if err != nil {
+		return err
+	}
+	s.n, s.set = n, true
+	return nil
+}
+
+// parseSize parses a size with an optional K, M, G or T suffix, which
+// may be followed by B or iB as in 10KB or 10KiB.
+func parseSize(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	unit := int64(1)
+	if s != "" {
+		if i := strings.IndexByte("KMGT", s[len(s)-1]); i >= 0 {
+			unit = 1 << (10 * (i + 1))
+			s = s[:len(s)-1]
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	return int64(f * float64(unit)), nil
+}
+
+// formatSize formats a size like parseSize parses it, e.g. 1.5M.
+func formatSize(n int64) string {
+	if n < 1<<10 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f, unit := float64(n), 0
+	for f >= 1<<10 && unit < 4 {
+		f /= 1 << 10
+		unit++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", f), ".0") + "KMGT"[unit-1:unit]
+}
+
+// timeFlag is a point in time, given either as a duration before now,
+// e.g. 24h, 30m or 7d, or as a date, e.g. 2021-11-07 or an RFC 3339
+// timestamp.
+type timeFlag struct {
+	t   time.Time
+	set bool
+}
+
+func (t *timeFlag) String() string {
+	if !t.set {
+		return ""
+	}
+	return t.t.Format(time.RFC3339)
+}
+
+func (t *timeFlag) Set(v string) error {
+	tt, err := parseTime(v, time.Now())
+	if err != nil {
+		return err
+	}
+	t.t, t.set = tt, true
+	return nil
+}
+
+func parseTime(v string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(v, "d") {
+		if days, err := strconv.ParseFloat(strings.TrimSuffix(v, "d"), 64); err == nil && days >= 0 {
+			return now.Add(-time.Duration(days * float64(24*time.Hour))), nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want a duration like 24h or a date like 2006-01-02", v)
+}
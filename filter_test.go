@@ -0,0 +1,83 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"512", 512, true},
+		{"10K", 10 << 10, true},
+		{"10kb", 10 << 10, true},
+		{"1.5MiB", 3 << 19, true},
+		{"2G", 2 << 30, true},
+		{"-1", 0, false},
+		{"ten", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseSize(%q): got %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2021, 11, 7, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"24h", now.Add(-24 * time.Hour), true},
+		{"7d", now.Add(-7 * 24 * time.Hour), true},
+		{"2021-11-01", time.Date(2021, 11, 1, 0, 0, 0, 0, time.Local), true},
+		{"2021-11-01T10:00:00Z", time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC), true},
+		{"yesterday", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTime(tt.in, now)
+		if !got.Equal(tt.want) || (err == nil) != tt.ok {
+			t.Errorf("parseTime(%q): got %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandFilters(t *testing.T) {
+	defer func() { opts = options{} }()
+
+	dir := t.TempDir()
+	mkfiles(t, dir, "old.log", "new.log", "big.log")
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(dir, "old.log"), old, old)
+	os.WriteFile(filepath.Join(dir, "big.log"), make([]byte, 4096), 0644)
+
+	opts = options{}
+	opts.newerThan.Set("24h")
+	opts.maxSize.Set("1K")
+	got, _ := expand([]string{dir, "testdata/x.png"})
+	want := []string{filepath.Join(dir, "new.log"), "testdata/x.png"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files: got %v, want %v", got, want)
+	}
+
+	opts = options{}
+	opts.olderThan.Set("1d")
+	opts.minSize.Set("1")
+	got, _ = expand([]string{dir})
+	if want := []string{filepath.Join(dir, "old.log")}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A stream framed by --frame=crc32c is a sequence of frames, each of
+// which holds a chunk of the output followed by its trailer record:
+//
+//	<length of the chunk in hex>\n<chunk><CRC-32C of the chunk in hex>\n
+//
+// It ends with a frame of length 0, whose trailer is the CRC-32C of
+// the whole stream, so that a truncated stream is detected as well.
+// The frames are told apart by --unframe, which writes the chunks only
+// once their trailers are verified.
+
+// defaultFrameSize is the size of the chunks of --frame by default.
+const defaultFrameSize = 64 << 10
+
+// maxFrameSize limits the chunks that --unframe buffers.
+const maxFrameSize = 16 << 20
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// frameWriter frames what is written to it. It writes every chunk
+// right away, so that streams are framed without delay.
+type frameWriter struct {
+	w     io.Writer
+	size  int
+	total hash.Hash32
+}
+
+func newFrameWriter(w io.Writer, size int) *frameWriter {
+	if size <= 0 {
+		size = defaultFrameSize
+	}
+	if size > maxFrameSize {
+		size = maxFrameSize
+	}
+	return &frameWriter{w: w, size: size, total: crc32.New(castagnoli)}
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > f.size {
+			chunk = chunk[:f.size]
+		}
+		if err := f.frame(chunk, crc32.Checksum(chunk, castagnoli)); err != nil {
+			return n, err
+		}
+		f.total.Write(chunk)
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+func (f *frameWriter) frame(chunk []byte, sum uint32) error {
+	b := make([]byte, 0, len(chunk)+20)
+	b = strconv.AppendInt(b, int64(len(chunk)), 16)
+	b = append(b, '\n')
+	b = append(b, chunk...)
+	b = append(b, fmt.Sprintf("%08x\n", sum)...)
+	_, err := f.w.Write(b)
+	return err
+}
+
+// Close writes the end of the stream.
+func (f *frameWriter) Close() error {
+	return f.frame(nil, f.total.Sum32())
+}
+
+// newUnframeReader verifies and strips the frames of a stream framed by
+// --frame. Streams may follow each other, as when framed outputs are
+// concatenated.
+func newUnframeReader(r io.Reader) (io.Reader, error) {
+	return &unframeReader{r: bufio.NewReader(r), total: crc32.New(castagnoli)}, nil
+}
+
+type unframeReader struct {
+	r     *bufio.Reader
+	buf   []byte
+	n     int  // the number of the next frame, from 1
+	ended bool // whether the last frame read ended a stream
+	total hash.Hash32
+	err   error
+}
+
+func (u *unframeReader) Read(p []byte) (int, error) {
+	for len(u.buf) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.err = u.next()
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+// next reads and verifies the next frame.
+func (u *unframeReader) next() error {
+	line, err := u.r.ReadString('\n')
+	if err == io.EOF && line == "" && u.ended {
+		return io.EOF
+	}
+	u.n++
+	if err != nil {
+		return u.truncated(err)
+	}
+	size, err := strconv.ParseUint(strings.TrimSuffix(line, "\n"), 16, 32)
+	if err != nil || size > maxFrameSize {
+		return fmt.Errorf("frame %d: invalid frame header %q", u.n, strings.TrimSuffix(line, "\n"))
+	}
+	// The chunk grows as it is read, a header alone does not allocate it.
+	var b bytes.Buffer
+	if n, err := io.CopyN(&b, u.r, int64(size)); n < int64(size) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return u.truncated(err)
+	}
+	chunk := b.Bytes()
+	trailer, err := u.r.ReadString('\n')
+	if err != nil {
+		return u.truncated(err)
+	}
+	want, err := strconv.ParseUint(strings.TrimSuffix(trailer, "\n"), 16, 32)
+	if err != nil || len(trailer) != 9 {
+		return fmt.Errorf("frame %d: invalid trailer record %q", u.n, strings.TrimSuffix(trailer, "\n"))
+	}
+	if size == 0 {
+		if got := u.total.Sum32(); got != uint32(want) {
+			return fmt.Errorf("frame %d: CRC-32C mismatch of the stream: got %08x, want %08x", u.n, got, want)
+		}
+		u.total.Reset()
+		u.ended = true
+		return nil
+	}
+	if got := crc32.Checksum(chunk, castagnoli); got != uint32(want) {
+		return fmt.Errorf("frame %d: CRC-32C mismatch: got %08x, want %08x", u.n, got, want)
+	}
+	u.total.Write(chunk)
+	u.buf, u.ended = chunk, false
+	return nil
+}
+
+func (u *unframeReader) truncated(err error) error {
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("frame %d: truncated stream", u.n)
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFrame(t *testing.T) {
+	var b bytes.Buffer
+	f := newFrameWriter(&b, 4)
+	io.WriteString(f, "hello, ")
+	io.WriteString(f, "world\n")
+	f.Close()
+	want := "4\nhella099f534\n3\no, 864c4da3\n4\nworl1a9990e6\n2\nd\n54951b30\n0\n77bb1986\n"
+	if b.String() != want {
+		t.Fatalf("unexpected frames:\n%q", b.String())
+	}
+
+	framed := b.String()
+	got, err := io.ReadAll(mustUnframe(t, framed+framed))
+	if err != nil || string(got) != "hello, world\nhello, world\n" {
+		t.Fatalf("unframe: got %q, %v", got, err)
+	}
+
+	// A flipped bit fails the frame it is in, and only the frames before
+	// it are read.
+	corrupt := []byte(framed)
+	corrupt[strings.Index(framed, "worl")] ^= 1
+	got, err = io.ReadAll(mustUnframe(t, string(corrupt)))
+	if err == nil || !strings.HasPrefix(err.Error(), "frame 3: CRC-32C mismatch") || string(got) != "hello, " {
+		t.Fatalf("corrupt: got %q, %v", got, err)
+	}
+
+	// Dropping a whole frame, or the end, is noticed as well.
+	lines := strings.SplitAfter(framed, "\n")
+	dropped := strings.Join(append(lines[:2:2], lines[4:]...), "")
+	if _, err = io.ReadAll(mustUnframe(t, dropped)); err == nil || !strings.Contains(err.Error(), "CRC-32C mismatch of the stream") {
+		t.Fatalf("dropped frame: %v", err)
+	}
+	for _, s := range []string{"", framed[:len(framed)-11], framed[:len(framed)-1]} {
+		if _, err = io.ReadAll(mustUnframe(t, s)); err == nil || !strings.HasSuffix(err.Error(), "truncated stream") {
+			t.Fatalf("truncated to %q: %v", s, err)
+		}
+	}
+}
+
+func mustUnframe(t *testing.T, s string) io.Reader {
+	r, err := newUnframeReader(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMainFrame(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte(strings.Repeat("lorem ipsum\n", 100)), 0644)
+	framed := filepath.Join(dir, "a.framed")
+
+	if got := runMain("--frame=crc32c", "--frame-size=100", "-o", framed, name); got != "" {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	b, _ := os.ReadFile(framed)
+	if !strings.HasPrefix(string(b), "64\n") || strings.Count(string(b), "\n64\n") != 11 {
+		t.Fatalf("unexpected frames:\n%s", b)
+	}
+	if got := runMain("--unframe", framed); got != strings.Repeat("lorem ipsum\n", 100) {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if got := runMain("--frame=md5", name); got != "cat: unknown frame checksum \"md5\"\n" {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func FuzzFrame(f *testing.F) {
+	f.Add([]byte("hello, world\n"), 4)
+	f.Add([]byte{}, 1)
+	f.Add([]byte("#frame 00000000 0\n"), 64)
+	f.Fuzz(func(t *testing.T, data []byte, size int) {
+		if size <= 0 || size > 1<<16 {
+			return
+		}
+		var b bytes.Buffer
+		w := newFrameWriter(&b, size)
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := newUnframeReader(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("round trip of %q in frames of %d: got %q, %v", data, size, got, err)
+		}
+		// Arbitrary input fails, but must not panic.
+		if r, err := newUnframeReader(bytes.NewReader(data)); err == nil {
+			io.Copy(io.Discard, r)
+		}
+	})
+}
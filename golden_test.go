@@ -0,0 +1,96 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden with the output")
+
+// goldenTests are the output modes, alone and combined, of the inputs in
+// testdata/golden/in, whose output is testdata/golden/NAME.golden.
+var goldenTests = []struct {
+	name    string
+	args    []string
+	feature string // that the test needs
+}{
+	{name: "raw", args: []string{"in/main.go", "in/notes.md"}},
+	{name: "records", args: []string{"--format=records", "in/notes.md", "testdata/x.png"}},
+	{name: "fence", args: []string{"--fence", "in/main.go", "in/notes.md", "in/term.log"}},
+	{name: "fence-strip-blank-lines", args: []string{"--fence", "--strip-blank-lines", "in/notes.md", "in/main.go"}},
+	{name: "strip-comments", args: []string{"--strip-comments", "--strip-blank-lines", "in/main.go"}, feature: "highlight"},
+	{name: "redact", args: []string{"--redact=secrets,emails,ipv4", "in/notes.md"}},
+	{name: "html", args: []string{"--format=html", "in/main.go", "in/notes.md"}, feature: "highlight"},
+	{name: "html-dark", args: []string{"--html", "--html-theme=dark", "in/notes.md"}},
+	{name: "ansi2html", args: []string{"--format=ansi2html", "in/term.log"}},
+	{name: "group-by-ext", args: []string{"--group-by-ext", "in/notes.md", "in/main.go", "in/notes.md", "in/cols.txt"}},
+	{name: "group-by-ext-color", args: []string{"--group-by-ext", "--color=always", "in/notes.md", "in/main.go", "in/cols.txt"}},
+	{name: "group-by-ext-a11y", args: []string{"--group-by-ext", "--a11y", "in/notes.md", "in/main.go", "in/cols.txt"}},
+	{name: "number", args: []string{"-n", "in/notes.md", "in/main.go"}},
+	{name: "number-strip-blank-lines-group-by-ext", args: []string{"-n", "--strip-blank-lines", "--group-by-ext", "in/notes.md", "in/main.go", "in/cols.txt"}},
+	{name: "number-peek-fence", args: []string{"-n", "--peek=2", "--fence", "in/main.go"}},
+	{name: "peek", args: []string{"--peek=2", "in/notes.md", "in/main.go"}},
+	{name: "peek-group-by-ext-color", args: []string{"--peek=2", "--group-by-ext", "--color=always", "in/notes.md", "in/main.go"}},
+	{name: "summary", args: []string{"--summary", "in/main.go", "in/notes.md"}},
+	{name: "max-chars", args: []string{"--max-chars=30", "in/notes.md", "in/main.go"}},
+	{name: "table", args: []string{"--table", "--for=less", "in/cols.txt"}},
+	{name: "frame", args: []string{"--frame=crc32c", "--frame-size=32", "in/notes.md"}},
+}
+
+func TestGolden(t *testing.T) {
+	// The output is the same in every environment.
+	t.Setenv("LC_ALL", "C")
+	t.Setenv("NO_COLOR", "1")
+	for _, tt := range goldenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.feature != "" {
+				requireFeature(t, tt.feature)
+			}
+			args := make([]string, len(tt.args))
+			for i, arg := range tt.args {
+				if strings.HasPrefix(arg, "in/") {
+					arg = "testdata/golden/" + arg
+				}
+				args[i] = arg
+			}
+			golden(t, tt.name, runMain(args...), args...)
+		})
+	}
+}
+
+// golden compares the output with testdata/golden/NAME.golden, or writes
+// it there with -update. The names of the inputs are written with slashes
+// in the golden files, on Windows too.
+func golden(t *testing.T, name, got string, inputs ...string) {
+	t.Helper()
+	var names []string
+	for _, in := range inputs {
+		if p := filepath.FromSlash(in); p != in {
+			names = append(names, strconv.Quote(p), strconv.Quote(in), p, in)
+		}
+	}
+	got = strings.NewReplacer(names...).Replace(got)
+
+	file := filepath.Join("testdata", "golden", name+".golden")
+	if *update {
+		if err := os.WriteFile(file, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to write it", err)
+	}
+	if got != string(want) {
+		t.Fatalf("output differs from %s, run go test -update if it should not:\ngot  %q\nwant %q", file, got, want)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// extGroup is a group of inputs that share a file extension.
+type extGroup struct {
+	ext   string
+	files []string
+}
+
+// groupByExt groups the inputs by their lower case file extension, in
+// the order of the extensions. Within a group, the inputs keep their
+// order, e.g. the lexical order of -r.
+func groupByExt(args []string) []extGroup {
+	index := map[string]int{}
+	var groups []extGroup
+	for _, arg := range args {
+		_, path := splitDecoders(arg)
+		ext := strings.ToLower(filepath.Ext(path))
+		i, ok := index[ext]
+		if !ok {
+			i = len(groups)
+			index[ext] = i
+			groups = append(groups, extGroup{ext: ext})
+		}
+		groups[i].files = append(groups[i].files, arg)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].ext < groups[j].ext })
+	return groups
+}
+
+// writeGroupBanner writes the banner that starts a group of inputs, in
+// the style of the file headers of head and tail.
+func writeGroupBanner(w io.Writer, ext string, first bool) error {
+	name := "*" + ext
+	if ext == "" {
+		name = "(no extension)"
+	}
+	if accessible {
+		if ext == "" {
+			name = "without an extension"
+		} else {
+			name = "ending in " + ext
+		}
+		_, err := fmt.Fprintf(w, "Files %s:\n", name)
+		return err
+	}
+	sep := "\n"
+	if first {
+		sep = ""
+	}
+	_, err := fmt.Fprintf(w, "%s%s\n", sep, paint("==> "+name+" <==", "1"))
+	return err
+}
@@ -0,0 +1,22 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupByExt(t *testing.T) {
+	got := groupByExt([]string{"b.go", "README", "a.md", "gzip:c.GO", "a.go", "LICENSE"})
+	want := []extGroup{
+		{"", []string{"README", "LICENSE"}},
+		{".go", []string{"b.go", "gzip:c.GO", "a.go"}},
+		{".md", []string{"a.md"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected groups: got %v, want %v", got, want)
+	}
+}
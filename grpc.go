@@ -0,0 +1,182 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() { registerFeature("grpc") }
+
+// grpcRead is the method that --grpc serves, of the service
+//
+//	service FileService {
+//		rpc Read(ReadRequest) returns (stream Chunk);
+//	}
+//	message ReadRequest { string path = 1; }
+//	message Chunk { bytes data = 1; }
+//
+// in the package cat. Each read writes the file as the flags tell, as
+// if it was the input of a run.
+const grpcRead = "/cat.FileService/Read"
+
+// The status codes of gRPC, see https://grpc.github.io/grpc/core/md_doc_statuscodes.html.
+const (
+	grpcOK               = 0
+	grpcUnknown          = 2
+	grpcInvalidArgument  = 3
+	grpcNotFound         = 5
+	grpcPermissionDenied = 7
+	grpcUnimplemented    = 12
+)
+
+// grpcChunkSize is the most bytes of a chunk, far below the 4 MiB that
+// clients accept in a message by default.
+const grpcChunkSize = 64 << 10
+
+// serveGRPC serves the reads of files to gRPC clients at the given
+// address. gRPC runs over HTTP/2, which the standard library serves
+// over TLS only, with the certificate and key in the given files.
+func serveGRPC(addr, certFile, keyFile string) error {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("%s: %v", certFile, unwrapPathError(err))
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("%s: %v", keyFile, unwrapPathError(err))
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("--grpc: %v", err)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:   &grpcServer{},
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	fmt.Fprintf(os.Stderr, "cat: serving %s at %s\n", grpcRead, l.Addr())
+	return srv.ServeTLS(l, "", "")
+}
+
+// grpcServer serves the reads of clients, one at a time, as catInput
+// reads them.
+type grpcServer struct{}
+
+func (s *grpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "cat serves gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	code, err := grpcUnimplemented, fmt.Errorf("unknown method %s", r.URL.Path)
+	if r.URL.Path == grpcRead {
+		code, err = s.read(w, r.Body)
+	}
+	// The status is sent in the trailers, after the stream.
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if err != nil {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcEscape(err.Error()))
+	}
+}
+
+// read streams the file of a ReadRequest as chunks.
+func (s *grpcServer) read(w http.ResponseWriter, body io.Reader) (int, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(body, head[:]); err != nil {
+		return grpcInvalidArgument, fmt.Errorf("invalid request: %v", err)
+	}
+	if head[0] != 0 {
+		return grpcUnimplemented, errors.New("compressed requests are not supported")
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if size > 64<<10 {
+		return grpcInvalidArgument, fmt.Errorf("request of %d bytes", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return grpcInvalidArgument, fmt.Errorf("invalid request: %v", err)
+	}
+	var path string
+	err := protoWalk(msg, func(num uint64, wt int, v uint64, b []byte) error {
+		if num == 1 && wt == wireBytes {
+			path = string(b)
+		}
+		return nil
+	})
+	if err != nil || path == "" {
+		return grpcInvalidArgument, fmt.Errorf("invalid request: no path")
+	}
+	// Whatever the policy allows, a client must not close the
+	// descriptors of the server, or have it fetch URLs of its network.
+	if _, ok := fdPath(path); ok || isURL(path) {
+		return grpcPermissionDenied, fmt.Errorf("%s: descriptors and URLs cannot be read remotely", path)
+	}
+
+	err = catFunc(path, func(chunk []byte) error { return grpcSend(w, chunk) })
+	switch {
+	case err == nil:
+		return grpcOK, nil
+	case errors.Is(err, errNotExist):
+		return grpcNotFound, err
+	case errors.Is(err, errNotAllowed), errors.Is(err, os.ErrPermission):
+		return grpcPermissionDenied, err
+	}
+	return grpcUnknown, err
+}
+
+// grpcSend sends the output of a read as Chunk messages, as it is
+// written.
+func grpcSend(w http.ResponseWriter, data []byte) error {
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > grpcChunkSize {
+			chunk = chunk[:grpcChunkSize]
+		}
+		var msg [binary.MaxVarintLen64 + 1]byte
+		msg[0] = 1<<3 | wireBytes
+		m := 1 + binary.PutUvarint(msg[1:], uint64(len(chunk)))
+		var head [5]byte
+		binary.BigEndian.PutUint32(head[1:], uint32(m+len(chunk)))
+		for _, b := range [][]byte{head[:], msg[:m], chunk} {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		}
+		data = data[len(chunk):]
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// grpcEscape percent-encodes a status message, as gRPC requires.
+func grpcEscape(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// grpcCall reads a file from the server as a client of FileService
+// does, and returns the chunks, and the status and message of the
+// trailers. It fails the test with t.Error, so that clients can call it
+// at the same time.
+func grpcCall(t *testing.T, srv *httptest.Server, method, path string) (chunks []string, status, msg string) {
+	t.Helper()
+	req := append([]byte{1<<3 | wireBytes, byte(len(path))}, path...)
+	body := append([]byte{0, 0, 0, 0, byte(len(req))}, req...)
+	r, err := http.NewRequest(http.MethodPost, srv.URL+method, bytes.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.Header.Get("Content-Type") != "application/grpc" {
+		t.Errorf("unexpected response %s, %s", resp.Proto, resp.Header.Get("Content-Type"))
+		return
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for len(b) > 0 {
+		if len(b) < 5 || b[0] != 0 || 5+int(binary.BigEndian.Uint32(b[1:])) > len(b) {
+			t.Errorf("invalid frame %q", b)
+			return
+		}
+		m := b[5 : 5+binary.BigEndian.Uint32(b[1:])]
+		b = b[5+len(m):]
+		err := protoWalk(m, func(num uint64, wt int, v uint64, data []byte) error {
+			chunks = append(chunks, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	return chunks, resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+}
+
+func TestGRPC(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "data")
+	os.Mkdir(allowed, 0755)
+	os.WriteFile(filepath.Join(allowed, "a.txt"), []byte("a\nb\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret\n"), 0644)
+	policy := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policy, []byte("schemes: [file, fd, https]\npaths: ["+allowed+"]\n"), 0644)
+	p, err := loadPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputPolicy, opts.format, opts.number = p, "raw", true
+	defer func() { inputPolicy, opts = nil, options{} }()
+
+	srv := httptest.NewUnstartedServer(&grpcServer{})
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Every read numbers its lines from 1, also of clients that read
+	// at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunks, status, msg := grpcCall(t, srv, grpcRead, filepath.Join(allowed, "a.txt"))
+			if got := strings.Join(chunks, ""); got != "     1\ta\n     2\tb\n" || status != "0" || msg != "" {
+				t.Errorf("unexpected read %q, status %s %q", got, status, msg)
+			}
+		}()
+	}
+	wg.Wait()
+	tests := []struct {
+		method, path string
+		status       string
+		msg          string
+	}{
+		{grpcRead, filepath.Join(dir, "secret.txt"), "7", "the path is not allowed by the policy"},
+		{grpcRead, filepath.Join(allowed, "b.txt"), "5", "No such file or directory"},
+		{grpcRead, "", "3", "invalid request: no path"},
+		{grpcRead, "/dev/fd/0", "7", "descriptors and URLs cannot be read remotely"},
+		{grpcRead, "https://example.com/a", "7", "descriptors and URLs cannot be read remotely"},
+		{"/cat.FileService/Write", "a.txt", "12", "unknown method /cat.FileService/Write"},
+	}
+	for _, tt := range tests {
+		chunks, status, msg := grpcCall(t, srv, tt.method, tt.path)
+		if len(chunks) != 0 || status != tt.status || !strings.Contains(msg, tt.msg) {
+			t.Errorf("%s %s: unexpected chunks %q, status %s %q", tt.method, tt.path, chunks, status, msg)
+		}
+	}
+}
+
+func TestGRPCSend(t *testing.T) {
+	rec := httptest.NewRecorder()
+	data := bytes.Repeat([]byte("x"), grpcChunkSize+1)
+	if err := grpcSend(rec, data); err != nil {
+		t.Fatalf("unexpected send: %v", err)
+	}
+	b := rec.Body.Bytes()
+	var sizes []int
+	for len(b) > 0 {
+		n := int(binary.BigEndian.Uint32(b[1:]))
+		protoWalk(b[5:5+n], func(num uint64, wt int, v uint64, data []byte) error {
+			sizes = append(sizes, len(data))
+			return nil
+		})
+		b = b[5+n:]
+	}
+	if len(sizes) != 2 || sizes[0] != grpcChunkSize || sizes[1] != 1 || !rec.Flushed {
+		t.Fatalf("unexpected chunks of %v bytes, flushed %v", sizes, rec.Flushed)
+	}
+}
+
+func TestGRPCEscape(t *testing.T) {
+	if got := grpcEscape("a: 100% 没有\n"); got != "a: 100%25 %E6%B2%A1%E6%9C%89%0A" {
+		t.Fatalf("unexpected escape %q", got)
+	}
+}
+
+func TestMainGRPC(t *testing.T) {
+	dir := t.TempDir()
+	policy, paths := filepath.Join(dir, "policy.yaml"), filepath.Join(dir, "paths.yaml")
+	os.WriteFile(policy, []byte("schemes: [file]\npaths: [testdata]\n"), 0644)
+	os.WriteFile(paths, []byte("paths: [testdata]\n"), 0644)
+	defer func() { inputPolicy = nil }()
+	tests := []struct {
+		args []string
+		want string
+		code int
+	}{
+		{[]string{"--grpc=:0", "--policy", policy}, "cat: --grpc needs --grpc-cert and --grpc-key\n", exitUsage},
+		{[]string{"--grpc=:0", "--grpc-cert=c.pem", "--grpc-key=k.pem"}, "cat: --grpc needs a --policy with the schemes and files that clients may read\n", exitUsage},
+		{[]string{"--grpc=:0", "--grpc-cert=c.pem", "--grpc-key=k.pem", "--policy", paths}, "cat: --grpc needs a --policy with the schemes and files that clients may read\n", exitUsage},
+		{[]string{"--grpc=:0", "--grpc-cert=c.pem", "--grpc-key=k.pem", "--policy", policy, "testdata/a.txt"}, "cat: --grpc cannot be used with inputs, outputs or PDF\n", exitUsage},
+		{[]string{"--grpc=:0", "--grpc-cert=testdata/none.pem", "--grpc-key=testdata/none.pem", "--policy", policy}, "cat: testdata/none.pem: no such file or directory\n", exitFailed},
+	}
+	for _, tt := range tests {
+		out, code := runMainCode(tt.args...)
+		if out != filepath.FromSlash(tt.want) || code != tt.code {
+			t.Errorf("%v: unexpected output %q and exit code %d", tt.args, out, code)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// flagTypes are the types of the values of flags in --help-json, by
+// the Go types of the values. Values of other types are strings.
+var flagTypes = map[string]string{
+	"*flag.boolValue":     "bool",
+	"main.clobberFlag":    "bool",
+	"*flag.intValue":      "int",
+	"*flag.int64Value":    "int",
+	"*flag.uintValue":     "uint",
+	"*flag.uint64Value":   "uint",
+	"*flag.float64Value":  "float",
+	"*flag.durationValue": "duration",
+	"*main.sizeFlag":      "size",
+	"*main.timeFlag":      "time",
+	"*main.peekFlag":      "int",
+}
+
+// flagSchema describes a flag in --help-json.
+type flagSchema struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Placeholder is the name of the value in the description.
+	Placeholder string `json:"placeholder,omitempty"`
+	// OptionalValue is whether the flag may be given without a value,
+	// e.g. --peek or --peek=N.
+	OptionalValue bool        `json:"optional_value,omitempty"`
+	Default       interface{} `json:"default"`
+	Description   string      `json:"description"`
+}
+
+// writeHelpJSON writes the flags of a flag set as JSON, for programs
+// that wrap cat or complete its command lines.
+func writeHelpJSON(w io.Writer, fs *flag.FlagSet) error {
+	var flags []flagSchema
+	fs.VisitAll(func(f *flag.Flag) {
+		placeholder, desc := flag.UnquoteUsage(f)
+		s := flagSchema{
+			Name:        f.Name,
+			Type:        flagTypes[fmt.Sprintf("%T", f.Value)],
+			Default:     f.DefValue,
+			Description: desc,
+		}
+		if s.Type == "" {
+			s.Type = "string"
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() && s.Type != "bool" {
+			s.OptionalValue = true
+		}
+		if s.Type != "bool" {
+			s.Placeholder = placeholder
+		}
+		switch s.Type {
+		case "bool":
+			s.Default = f.DefValue == "true"
+		case "int", "uint", "float":
+			if n, err := strconv.ParseFloat(f.DefValue, 64); err == nil {
+				s.Default = n
+			}
+		}
+		flags = append(flags, s)
+	})
+	b, err := json.MarshalIndent(struct {
+		Name  string       `json:"name"`
+		Usage string       `json:"usage"`
+		Flags []flagSchema `json:"flags"`
+	}{"cat", "cat [FLAG]... [FILE]...", flags}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
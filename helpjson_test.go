@@ -0,0 +1,46 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMainHelpJSON(t *testing.T) {
+	out, code := runMainCode("--help-json", "testdata/a.txt")
+	if code != exitOK {
+		t.Fatalf("exit code %d", code)
+	}
+	var help struct {
+		Name  string
+		Flags []map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(out), &help); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	flags := map[string]map[string]interface{}{}
+	for _, f := range help.Flags {
+		flags[f["name"].(string)] = f
+	}
+	tests := map[string]map[string]interface{}{
+		"r":                 {"name": "r", "type": "bool", "default": false, "description": "read all files under each directory and archive, recursively"},
+		"interactive-guard": {"name": "interactive-guard", "type": "bool", "default": true, "description": flags["interactive-guard"]["description"]},
+		"o":                 {"name": "o", "type": "string", "placeholder": "file", "default": "", "description": "write the output to the given file instead of stdout, same as --write"},
+		"replay-speed":      {"name": "replay-speed", "type": "float", "placeholder": "factor", "default": 1.0, "description": flags["replay-speed"]["description"]},
+		"peek":              {"name": "peek", "type": "int", "placeholder": "N", "optional_value": true, "default": "", "description": flags["peek"]["description"]},
+		"min-size":          {"name": "min-size", "type": "size", "placeholder": "size", "default": "", "description": flags["min-size"]["description"]},
+		"kafka-linger":      {"name": "kafka-linger", "type": "duration", "placeholder": "duration", "default": "100ms", "description": flags["kafka-linger"]["description"]},
+	}
+	for name, want := range tests {
+		if !reflect.DeepEqual(flags[name], want) {
+			t.Errorf("%s: got %v, want %v", name, flags[name], want)
+		}
+	}
+	if help.Name != "cat" || len(help.Flags) < 100 {
+		t.Fatalf("unexpected help %s with %d flags", help.Name, len(help.Flags))
+	}
+}
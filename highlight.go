@@ -0,0 +1,220 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import "strings"
+
+// syntax describes a language well enough to highlight its comments,
+// strings, numbers and keywords. It is no parser, but it gets most of
+// the everyday source code right.
+type syntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	quotes        string // characters that start a string
+	rawQuotes     string // quotes of strings without escapes, which may span lines
+	keywords      []string
+}
+
+var (
+	cSyntax = &syntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		keywords: []string{"auto", "break", "case", "char", "const", "continue", "default", "do", "double",
+			"else", "enum", "extern", "float", "for", "goto", "if", "inline", "int", "long", "register",
+			"return", "short", "signed", "sizeof", "static", "struct", "switch", "typedef", "union",
+			"unsigned", "void", "volatile", "while", "#include", "#define", "#if", "#ifdef", "#ifndef",
+			"#else", "#endif", "class", "namespace", "template", "public", "private", "protected",
+			"virtual", "new", "delete", "this", "true", "false", "nullptr"},
+	}
+	shSyntax = &syntax{
+		lineComments: []string{"#"},
+		quotes:       `"`,
+		rawQuotes:    `'`,
+		keywords: []string{"if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done",
+			"case", "esac", "in", "function", "return", "local", "export", "set", "unset", "echo"},
+	}
+)
+
+func init() { registerFeature("highlight") }
+
+// syntaxes maps the languages of the languages table to their syntax.
+var syntaxes = map[string]*syntax{
+	"go": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		rawQuotes:     "`",
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else",
+			"fallthrough", "for", "func", "go", "goto", "if", "import", "interface", "map", "package",
+			"range", "return", "select", "struct", "switch", "type", "var", "nil", "true", "false"},
+	},
+	"c":    cSyntax,
+	"cpp":  cSyntax,
+	"java": cSyntax,
+	"javascript": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"'`,
+		rawQuotes:     "`",
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue",
+			"default", "delete", "do", "else", "export", "extends", "false", "finally", "for",
+			"function", "if", "import", "in", "instanceof", "let", "new", "null", "return", "switch",
+			"this", "throw", "true", "try", "typeof", "undefined", "var", "void", "while", "yield",
+			"interface", "type", "enum"},
+	},
+	"python": {
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue",
+			"def", "del", "elif", "else", "except", "False", "finally", "for", "from", "global", "if",
+			"import", "in", "is", "lambda", "None", "nonlocal", "not", "or", "pass", "raise",
+			"return", "True", "try", "while", "with", "yield"},
+	},
+	"rust": {
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `"`,
+		keywords: []string{"as", "break", "const", "continue", "crate", "else", "enum", "extern",
+			"false", "fn", "for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut",
+			"pub", "ref", "return", "self", "Self", "static", "struct", "super", "trait", "true",
+			"type", "unsafe", "use", "where", "while"},
+	},
+	"sh":   shSyntax,
+	"bash": shSyntax,
+	"zsh":  shSyntax,
+	"yaml": {lineComments: []string{"#"}, quotes: `"`, rawQuotes: `'`, keywords: []string{"true", "false", "null"}},
+	"toml": {lineComments: []string{"#"}, quotes: `"`, rawQuotes: `'`, keywords: []string{"true", "false"}},
+	"json": {quotes: `"`, keywords: []string{"true", "false", "null"}},
+	"ini":  {lineComments: []string{";", "#"}, quotes: `"`},
+	"sql": {
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        `'"`,
+	},
+	"dockerfile": {lineComments: []string{"#"}, quotes: `"'`},
+	"makefile":   {lineComments: []string{"#"}},
+}
+
+func init() {
+	syntaxes["typescript"] = syntaxes["javascript"]
+	syntaxes["tsx"] = syntaxes["javascript"]
+	syntaxes["jsx"] = syntaxes["javascript"]
+}
+
+// highlight splits src into spans. Without a syntax, the whole source
+// is a single plain span.
+func highlight(src string, syn *syntax) []span {
+	if syn == nil {
+		return []span{{"", src}}
+	}
+	var spans []span
+	add := func(class string, i, n int) {
+		if k := len(spans); k > 0 && spans[k-1].class == class {
+			// The spans are contiguous in src, so the last one can be
+			// extended without copying.
+			spans[k-1].text = src[i-len(spans[k-1].text) : i+n]
+			return
+		}
+		spans = append(spans, span{class, src[i : i+n]})
+	}
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		c := rest[0]
+		n := 0
+		class := ""
+		switch {
+		case hasAnyPrefix(rest, syn.lineComments):
+			n, class = strings.IndexByte(rest, '\n'), "c"
+			if n < 0 {
+				n = len(rest)
+			}
+		case blockComment(rest, syn) != nil:
+			b := blockComment(rest, syn)
+			n, class = strings.Index(rest[len(b[0]):], b[1]), "c"
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += len(b[0]) + len(b[1])
+			}
+		case strings.IndexByte(syn.rawQuotes, c) >= 0:
+			n, class = strings.IndexByte(rest[1:], c), "s"
+			if n < 0 {
+				n = len(rest)
+			} else {
+				n += 2
+			}
+		case strings.IndexByte(syn.quotes, c) >= 0:
+			n, class = quoted(rest), "s"
+		case isDigit(c) && (i == 0 || !isIdent(src[i-1])):
+			for n = 1; n < len(rest) && (isIdent(rest[n]) || rest[n] == '.'); n++ {
+			}
+			class = "n"
+		case isIdent(c) || c == '#':
+			for n = 1; n < len(rest) && isIdent(rest[n]); n++ {
+			}
+			if isKeyword(rest[:n], syn) {
+				class = "k"
+			}
+		default:
+			n = 1
+		}
+		add(class, i, n)
+		i += n
+	}
+	return spans
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockComment(s string, syn *syntax) *[2]string {
+	for i, b := range syn.blockComments {
+		if strings.HasPrefix(s, b[0]) {
+			return &syn.blockComments[i]
+		}
+	}
+	return nil
+}
+
+// quoted returns the length of the string at the start of s, up to
+// and including its closing quote. An unterminated string ends with
+// the line.
+func quoted(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n':
+			return i
+		case s[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isKeyword(word string, syn *syntax) bool {
+	for _, k := range syn.keywords {
+		if k == word {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isIdent(c byte) bool {
+	return isDigit(c) || c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z' || c >= 0x80
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlThemes holds the style sheets of the html output format. They
+// are inlined into the document, so that it can be shared as a single
+// file.
+var htmlThemes = map[string]string{
+	"light": `:root{--fg:#24292f;--bg:#fff}
+body{margin:2em;background:var(--bg);color:var(--fg);font-family:sans-serif}
+h2{font-size:1em;font-family:monospace}
+pre{padding:1em;background:#f6f8fa;border-radius:6px;overflow:auto}
+.ln{display:inline-block;min-width:3em;margin-right:1em;text-align:right;color:#8c959f;user-select:none}
+.k{color:#cf222e}.s{color:#0a3069}.c{color:#6e7781;font-style:italic}.n{color:#0550ae}
+`,
+	"dark": `:root{--fg:#c9d1d9;--bg:#0d1117}
+body{margin:2em;background:var(--bg);color:var(--fg);font-family:sans-serif}
+h2{font-size:1em;font-family:monospace}
+pre{padding:1em;background:#161b22;border-radius:6px;overflow:auto}
+.ln{display:inline-block;min-width:3em;margin-right:1em;text-align:right;color:#6e7681;user-select:none}
+.k{color:#ff7b72}.s{color:#a5d6ff}.c{color:#8b949e;font-style:italic}.n{color:#79c0ff}
+`,
+}
+
+// isHTML reports whether the output format is an HTML document.
+func isHTML(format string) bool {
+	return format == "html" || format == "ansi2html"
+}
+
+// writeHTMLHeader starts an HTML document in the given theme.
+func writeHTMLHeader(w io.Writer, theme string) error {
+	_, err := fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>cat</title>\n<style>\n%s</style>\n</head>\n<body>\n", htmlThemes[theme])
+	return err
+}
+
+// writeHTMLFooter ends the document started by writeHTMLHeader.
+func writeHTMLFooter(w io.Writer) error {
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+// writeHTML writes the content of r as a heading with the name of the
+// input followed by its highlighted and numbered lines.
+func writeHTML(w io.Writer, name string, r io.Reader) error {
+	src, err := readAll(name, "--format=html", r)
+	if err != nil {
+		return err
+	}
+	text := strings.ToValidUTF8(string(src), "�")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>%s</h2>\n<pre>", html.EscapeString(name))
+	if text != "" {
+		for i, line := range lines(highlight(text, syntaxes[language(name)])) {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			fmt.Fprintf(&b, `<span class="ln">%d</span>`, i+1)
+			for _, s := range line {
+				if s.class == "" {
+					b.WriteString(html.EscapeString(s.text))
+					continue
+				}
+				fmt.Fprintf(&b, `<span class="%s">%s</span>`, s.class, html.EscapeString(s.text))
+			}
+		}
+	}
+	b.WriteString("</pre>\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// span is a piece of highlighted source code. Its class is one of
+// "k" for keywords, "s" for strings, "c" for comments, "n" for numbers,
+// or "" for anything else.
+type span struct {
+	class string
+	text  string
+}
+
+// lines splits spans at line feeds, so that every line can be rendered
+// on its own. The line feeds themselves are dropped.
+func lines(spans []span) [][]span {
+	out := [][]span{nil}
+	for _, s := range spans {
+		for {
+			i := strings.IndexByte(s.text, '\n')
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				out[len(out)-1] = append(out[len(out)-1], span{s.class, s.text[:i]})
+			}
+			out = append(out, nil)
+			s.text = s.text[i+1:]
+		}
+		if s.text != "" {
+			out[len(out)-1] = append(out[len(out)-1], s)
+		}
+	}
+	if len(out) > 1 && out[len(out)-1] == nil {
+		out = out[:len(out)-1] // the final line feed ends the last line
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	requireFeature(t, "highlight")
+	src := "func f() { // hi\n\treturn \"a\\\"b\" + `x\ny` + 42 /* c */\n}\n"
+	got := highlight(src, syntaxes["go"])
+	want := []span{
+		{"k", "func"}, {"", " f() { "}, {"c", "// hi"}, {"", "\n\t"},
+		{"k", "return"}, {"", " "}, {"s", `"a\"b"`}, {"", " + "}, {"s", "`x\ny`"},
+		{"", " + "}, {"n", "42"}, {"", " "}, {"c", "/* c */"}, {"", "\n}\n"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected spans:\ngot  %q\nwant %q", got, want)
+	}
+	if n := len(lines(got)); n != 4 {
+		t.Fatalf("unexpected number of lines: got %d, want 4", n)
+	}
+}
+
+func TestMainHTML(t *testing.T) {
+	got := runMain("--html", "--html-theme", "dark", "testdata/b.md")
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		"--bg:#0d1117",
+		`<pre><span class="ln">1</span>world</pre>`,
+		"</html>\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+
+	got = runMain("--html-theme", "blue", "testdata/b.md")
+	if want := "cat: unknown HTML theme \"blue\"\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
+
+func FuzzHighlight(f *testing.F) {
+	requireFeature(f, "highlight")
+	f.Add("func f() { // hi\n\treturn \"a\\\"b\" + `x\ny` + 42 /* c */\n}\n")
+	f.Add("echo 'unterminated\n# comment")
+	f.Add("/* open")
+	f.Fuzz(func(t *testing.T, src string) {
+		for lang, syn := range syntaxes {
+			// The spans, and the lines of them, are the source.
+			var b strings.Builder
+			for _, s := range highlight(src, syn) {
+				b.WriteString(s.text)
+			}
+			if b.String() != src {
+				t.Fatalf("%s: spans %q of %q", lang, b.String(), src)
+			}
+			var ls []string
+			for _, line := range lines(highlight(src, syn)) {
+				var b strings.Builder
+				for _, s := range line {
+					b.WriteString(s.text)
+				}
+				ls = append(ls, b.String())
+			}
+			if got := strings.Join(ls, "\n"); got != strings.TrimSuffix(src, "\n") {
+				t.Fatalf("%s: lines %q of %q", lang, got, src)
+			}
+		}
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+func init() { registerFeature("http") }
+
+// httpClient fetches URL inputs. The policy applies to every redirect
+// as to the URL itself, so that none leads to a host or a scheme that
+// it does not allow.
+var httpClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if err := inputPolicy.allow(req.URL.String()); err != nil {
+			return fmt.Errorf("redirected to %v", err)
+		}
+		return nil
+	},
+}
+
+// openURL fetches the content of an URL.
+func openURL(src string) (io.ReadCloser, error) {
+	resp, err := httpClient.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", src, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// translations holds the messages of cat in other languages than
+// English, keyed by the English message like gettext does. Messages
+// that have no translation are printed in English.
+var translations = map[string]map[string]string{
+	"zh-CN": {
+		usage: `用法：cat [文件]...
+将文件连接起来并写到标准输出。
+
+示例：
+$ cat --help
+$ cat ./cat.go
+`,
+		"cat: --pdf cannot be used with -o\n":                                              "cat: --pdf 不能与 -o 一起使用\n",
+		"cat: unknown output format %q\n":                                                  "cat: 未知的输出格式 %q\n",
+		"cat: --proto-desc and --proto-type have to be used together\n":                    "cat: --proto-desc 和 --proto-type 必须一起使用\n",
+		"cat: unknown verify mode %q\n":                                                    "cat: 未知的校验模式 %q\n",
+		"cat: --data can only be used with --template\n":                                   "cat: --data 只能与 --template 一起使用\n",
+		"cat: --replay-speed must be positive\n":                                           "cat: --replay-speed 必须是正数\n",
+		"cat: --timeout must not be negative\n":                                            "cat: --timeout 不能是负数\n",
+		"cat: --typewriter must not be negative\n":                                         "cat: --typewriter 不能是负数\n",
+		"cat: --pty can only be used with --bridge\n":                                      "cat: --pty 只能与 --bridge 一起使用\n",
+		"cat: unknown frame checksum %q\n":                                                 "cat: 未知的分帧校验和 %q\n",
+		"cat: unknown --on-timeout action %q\n":                                            "cat: 未知的 --on-timeout 操作 %q\n",
+		"cat: only one of --ws, --serve, --publish, --kafka and --syslog can be used\n":    "cat: --ws、--serve、--publish、--kafka 和 --syslog 只能使用其中一个\n",
+		"cat: --ws, --serve, --publish, --kafka and --syslog cannot be used with -o\n":     "cat: --ws、--serve、--publish、--kafka 和 --syslog 不能与 -o 一起使用\n",
+		"cat: --grpc needs --grpc-cert and --grpc-key\n":                                   "cat: --grpc 需要 --grpc-cert 和 --grpc-key\n",
+		"cat: --grpc needs a --policy with the schemes and files that clients may read\n":  "cat: --grpc 需要一个 --policy 来规定客户端可以读取的 scheme 和文件\n",
+		"cat: --grpc cannot be used with inputs, outputs or PDF\n":                         "cat: --grpc 不能与输入文件、输出或 PDF 一起使用\n",
+		"cat: unknown HTML theme %q\n":                                                     "cat: 未知的 HTML 主题 %q\n",
+		"cat: --color must be auto, always or never, not %q\n":                             "cat: --color 必须是 auto、always 或 never，而不是 %q\n",
+		"cat: --max-chars and --max-tokens cannot be used with the %s format\n":            "cat: --max-chars 和 --max-tokens 不能用于 %s 格式\n",
+		"cat: the output is larger than the --max-memory of %s, wrote it without paging\n": "cat: 输出超过了 --max-memory 的 %s，未分页直接写出\n",
+		"cat: --watch needs -o and input files\n":                                          "cat: --watch 需要 -o 和输入文件\n",
+		"cat: --watch cannot read stdin\n":                                                 "cat: --watch 不能读取标准输入\n",
+		"cat: wrote %s, waiting for changes\n":                                             "cat: 已写入 %s，等待更改\n",
+		"cat: %d inputs, %d written, %d failed\n":                                          "cat: 共 %d 个输入，%d 个已写出，%d 个失败\n",
+		"cat: failed: %s\n":                           "cat: 失败：%s\n",
+		"%s is %s, print all of it?":                  "%s 大小为 %s，要全部输出吗？",
+		"%s looks like binary data, print it anyway?": "%s 看起来是二进制数据，仍要输出吗？",
+		"cat: skipped %s, use --interactive-guard=false to print binary data without asking\n": "cat: 已跳过 %s，使用 --interactive-guard=false 可不经询问直接输出二进制数据\n",
+		"cat: %s is larger than %s, summarized it, use --full to print all of it\n":            "cat: %s 大于 %s，只输出了摘要，使用 --full 可输出全部内容\n",
+		"overwrite '%s'?":  "要覆盖 '%s' 吗？",
+		"password for %s:": "%s 的密码：",
+	},
+}
+
+// errorPhrases are the translations of the phrases that error messages
+// are made of, e.g. "a.txt: No such file or directory", whose English
+// is replaced wherever it appears.
+var errorPhrases = map[string][][2]string{
+	"zh-CN": {
+		{"No such file or directory", "没有那个文件或目录"},
+		{"no such file or directory", "没有那个文件或目录"},
+		{"Is a directory", "是一个目录"},
+		{"is a directory", "是一个目录"},
+		{"permission denied", "权限不够"},
+		{"cannot open", "无法打开"},
+		{"not overwritten", "未覆盖"},
+		{"incorrect password", "密码错误"},
+		{"connection refused", "连接被拒绝"},
+	},
+}
+
+// msgLang is the language of the messages, which run sets from the
+// environment.
+var msgLang string
+
+// localeLang returns the language of messages that the locale of the
+// environment asks for, as in LC_ALL, LC_MESSAGES or LANG, e.g. zh-CN
+// for zh_CN.UTF-8, or "" for English.
+func localeLang() string {
+	var locale string
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale = os.Getenv(v); locale != "" {
+			break
+		}
+	}
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i] // the encoding and the modifier
+	}
+	locale = strings.ReplaceAll(locale, "_", "-")
+	tag := strings.ToLower(locale)
+	switch {
+	case tag == "zh" || tag == "zh-cn" || tag == "zh-sg" || strings.HasPrefix(tag, "zh-hans"):
+		return "zh-CN"
+	case strings.HasPrefix(tag, "zh-"):
+		return "" // traditional Chinese has no translation
+	}
+	if _, ok := translations[locale]; ok {
+		return locale
+	}
+	return ""
+}
+
+// tr returns the translation of a message to msgLang.
+func tr(msg string) string {
+	if s, ok := translations[msgLang][msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// trError returns the message of an error with its phrases translated
+// to msgLang.
+func trError(err error) string {
+	msg := err.Error()
+	for _, p := range errorPhrases[msgLang] {
+		msg = strings.ReplaceAll(msg, p[0], p[1])
+	}
+	return msg
+}
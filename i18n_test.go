@@ -0,0 +1,66 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLocaleLang(t *testing.T) {
+	tests := []struct {
+		lcAll, lcMessages, lang string
+		want                    string
+	}{
+		{"", "", "", ""},
+		{"", "", "C", ""},
+		{"", "", "en_US.UTF-8", ""},
+		{"", "", "zh_CN.UTF-8", "zh-CN"},
+		{"", "", "zh_CN", "zh-CN"},
+		{"", "", "zh_SG.GB2312", "zh-CN"},
+		{"", "", "zh_TW.UTF-8", ""},
+		{"", "zh_CN.UTF-8", "en_US.UTF-8", "zh-CN"},
+		{"C", "zh_CN.UTF-8", "zh_CN.UTF-8", ""},
+		{"zh_CN.UTF-8@pinyin", "", "en_US.UTF-8", "zh-CN"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.lcAll)
+		t.Setenv("LC_MESSAGES", tt.lcMessages)
+		t.Setenv("LANG", tt.lang)
+		if got := localeLang(); got != tt.want {
+			t.Errorf("LC_ALL=%q LC_MESSAGES=%q LANG=%q: %q, want %q", tt.lcAll, tt.lcMessages, tt.lang, got, tt.want)
+		}
+	}
+}
+
+// Translations must take the same arguments as the messages.
+func TestTranslations(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for lang, msgs := range translations {
+		for msg, s := range msgs {
+			if a, b := verbs.FindAllString(msg, -1), verbs.FindAllString(s, -1); strings.Join(a, "") != strings.Join(b, "") {
+				t.Errorf("%s: %q has the verbs %v, but %q has %v", lang, msg, a, s, b)
+			}
+			if strings.HasSuffix(msg, "\n") != strings.HasSuffix(s, "\n") {
+				t.Errorf("%s: %q and %q end differently", lang, msg, s)
+			}
+		}
+	}
+}
+
+func TestMainChinese(t *testing.T) {
+	t.Setenv("LC_ALL", "zh_CN.UTF-8")
+	defer func() { msgLang = "" }()
+	if out := runMain("--replay-speed=0"); out != "cat: --replay-speed 必须是正数\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := runMain("testdata/none.txt"); out != "cat: testdata/none.txt: 没有那个文件或目录\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if out := runMain("--no-such-flag"); !strings.HasPrefix(out, "用法：cat [文件]...\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFiles are read in every directory walked with -r, in order.
+// The rules of .catignore come last and hence take precedence.
+var ignoreFiles = []string{".gitignore", ".catignore"}
+
+// ignoreRule is a pattern of an ignore file in the syntax of gitignore.
+type ignoreRule struct {
+	base     string   // the directory of the ignore file
+	segments []string // the pattern split at slashes
+	negate   bool     // a pattern starting with ! re-includes
+	dirOnly  bool     // a pattern ending with / only matches directories
+	anchored bool     // a pattern with a slash is relative to base
+}
+
+// readIgnoreFiles reads the ignore rules of a directory.
+func readIgnoreFiles(dir string) (rules []ignoreRule) {
+	for _, name := range ignoreFiles {
+		f, err := os.Open(longPath(filepath.Join(dir, name)))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, scanIgnoreFile(dir, f)...)
+		f.Close()
+	}
+	return rules
+}
+
+// scanIgnoreFile reads the rules of an ignore file in the directory base.
+func scanIgnoreFile(base string, r io.Reader) (rules []ignoreRule) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if r, ok := parseIgnoreRule(base, s.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreRule parses a line of an ignore file, see gitignore(5).
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	r := ignoreRule{base: base}
+	// Trailing spaces are ignored unless escaped.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return r, false
+	}
+	if line[0] == '!' {
+		r.negate, line = true, line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '!' || line[1] == '#') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored, line = true, strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return r, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// match reports whether the rule matches a path below its base.
+func (r ignoreRule) match(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, p)
+	if err != nil {
+		return false
+	}
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	if !r.anchored {
+		return matchSegments(r.segments, segments[len(segments)-1:])
+	}
+	return matchSegments(r.segments, segments)
+}
+
+// matchSegments matches path segments against pattern segments, where
+// a ** segment matches any number of path segments.
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// ignored reports whether a path is excluded by the rules, of which the
+// last matching one decides.
+func ignored(p string, isDir bool, rules []ignoreRule) bool {
+	ignore := false
+	for _, r := range rules {
+		if r.match(p, isDir) {
+			ignore = !r.negate
+		}
+	}
+	return ignore
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultIncludePattern matches the include directives of C, e.g.
+// #include "other.sql".
+const defaultIncludePattern = `^\s*#include\s+"([^"]+)"\s*$`
+
+// includePattern matches the lines that --process-includes replaces by
+// the file named by its first group.
+var includePattern *regexp.Regexp
+
+// setupIncludes compiles the pattern of include directives.
+func setupIncludes(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid include pattern: %v", err)
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("include pattern %q has no group for the file name", pattern)
+	}
+	includePattern = re
+	return nil
+}
+
+// processIncludes replaces the include directives of the named input
+// with the content of the files they name, which are processed the
+// same way. Relative names are relative to the directory of the file
+// that includes them.
+func processIncludes(name string, r io.Reader) (io.Reader, error) {
+	var buf bytes.Buffer
+	if err := include(&buf, name, r, nil); err != nil {
+		return nil, err
+	}
+	if maxMemory > 0 && int64(buf.Len()) > maxMemory {
+		return nil, memoryError(name, "--process-includes")
+	}
+	return &buf, nil
+}
+
+// includer is a file that is being included, by its name and its
+// absolute path.
+type includer struct{ name, abs string }
+
+// include writes r to w with its directives resolved. The stack holds
+// the files that are being included, to detect cycles.
+func include(w *bytes.Buffer, name string, r io.Reader, stack []includer) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		abs = name
+	}
+	for i, s := range stack {
+		if s.abs == abs {
+			var cycle []string
+			for _, s := range stack[i:] {
+				cycle = append(cycle, s.name)
+			}
+			return fmt.Errorf("include cycle: %s %s %s", strings.Join(cycle, " "+arrow()+" "), arrow(), name)
+		}
+	}
+	stack = append(stack, includer{name, abs})
+
+	dir := filepath.Dir(name)
+	if name == "-" {
+		dir = "."
+	}
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if m := includePattern.FindSubmatch(bytes.TrimRight(line, "\r\n")); m != nil {
+			path := string(m[1])
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if ierr := includeFile(w, path, stack); ierr != nil {
+				return fmt.Errorf("%s:%d: %v", name, n, ierr)
+			}
+		} else {
+			w.Write(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// includeFile writes an included file, ending it with a newline so that
+// the line after the directive stays a line of its own.
+func includeFile(w *bytes.Buffer, path string, stack []includer) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := include(w, path, f, stack); err != nil {
+		return err
+	}
+	if b := w.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		w.WriteByte('\n')
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte(content), 0644)
+		return p
+	}
+	top := write("main.sql", "BEGIN;\n#include \"tables/users.sql\"\nCOMMIT;\n")
+	write("tables/users.sql", "CREATE TABLE users;\n  #include \"index.sql\"")
+	write("tables/index.sql", "CREATE INDEX users_name;")
+	cycle := write("a.sql", "a\n#include \"b.sql\"\n")
+	write("b.sql", "b\n#include \"a.sql\"\n")
+	custom := write("custom.sql", "-- @import tables/index.sql\n")
+
+	got := runMain("--process-includes", top)
+	want := "BEGIN;\nCREATE TABLE users;\nCREATE INDEX users_name;\nCOMMIT;\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+
+	got = runMain("--process-includes", cycle)
+	b := filepath.Join(dir, "b.sql")
+	want = "cat: " + cycle + ":2: " + b + ":2: include cycle: " + cycle + " → " + b + " → " + cycle + "\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+
+	got = runMain("--process-includes", "--include-pattern", `^-- @import (\S+)$`, custom)
+	want = "CREATE INDEX users_name;\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+}
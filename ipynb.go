@@ -0,0 +1,141 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func isNotebook(_ []byte, name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".ipynb")
+}
+
+// notebookText is the text of a notebook, which is either a string or
+// a list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(b []byte) error {
+	var lines []string
+	if err := json.Unmarshal(b, &lines); err == nil {
+		*t = notebookText(strings.Join(lines, ""))
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*t = notebookText(s)
+	return nil
+}
+
+// notebook is the part of a Jupyter notebook that is rendered.
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string           `json:"cell_type"`
+		Source   notebookText     `json:"source"`
+		Outputs  []notebookOutput `json:"outputs"`
+	} `json:"cells"`
+}
+
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       notebookText               `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"` // by MIME type
+	Ename      string                     `json:"ename"`
+	Evalue     string                     `json:"evalue"`
+}
+
+// previewNotebook renders a Jupyter notebook as Markdown: markdown cells
+// as they are, and code cells and their text outputs as code blocks.
+// Images and other binary outputs are only named, since their base64
+// data is not readable.
+func previewNotebook(w io.Writer, r *bufio.Reader) error {
+	var nb notebook
+	if err := json.NewDecoder(r).Decode(&nb); err != nil {
+		return err
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.Kernelspec.Language
+	}
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	// Blocks are separated by an empty line.
+	first := true
+	separate := func() {
+		if !first {
+			bw.WriteString("\n")
+		}
+		first = false
+	}
+	block := func(info string, text notebookText) {
+		separate()
+		n := longestRun([]byte(text), '`') + 1
+		if n < 3 {
+			n = 3
+		}
+		fence := strings.Repeat("`", n)
+		bw.WriteString(fence + info + "\n" + string(text))
+		if !strings.HasSuffix(string(text), "\n") {
+			bw.WriteString("\n")
+		}
+		bw.WriteString(fence + "\n")
+	}
+	for _, c := range nb.Cells {
+		switch c.CellType {
+		case "markdown":
+			separate()
+			bw.WriteString(strings.TrimRight(string(c.Source), "\n") + "\n")
+			continue
+		case "code":
+			block(lang, c.Source)
+		default:
+			block("", c.Source)
+		}
+		for _, o := range c.Outputs {
+			switch o.OutputType {
+			case "stream":
+				block("", o.Text)
+			case "error":
+				block("", notebookText(o.Ename+": "+o.Evalue))
+			case "execute_result", "display_data":
+				if raw, ok := o.Data["text/plain"]; ok {
+					var text notebookText
+					if err := json.Unmarshal(raw, &text); err != nil {
+						return err
+					}
+					block("", text)
+				}
+				var types []string
+				for typ := range o.Data {
+					if typ != "text/plain" {
+						types = append(types, typ)
+					}
+				}
+				sort.Strings(types)
+				for _, typ := range types {
+					separate()
+					fmt.Fprintf(bw, "[%s output omitted]\n", typ)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviewNotebook(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.ipynb")
+	os.WriteFile(name, []byte(`{"metadata":{"language_info":{"name":"python"}},"cells":[
+{"cell_type":"markdown","source":["# Title\n","Some text\n"]},
+{"cell_type":"code","source":"print(1)\n1+1","outputs":[{"output_type":"stream","name":"stdout","text":["1\n"]},{"output_type":"execute_result","data":{"text/plain":["2"],"image/png":"iVBOR"}}]},
+{"cell_type":"code","source":"1/0","outputs":[{"output_type":"error","ename":"ZeroDivisionError","evalue":"division by zero","traceback":[]}]}
+]}
+`), 0644)
+
+	got := runMain("--preview", name)
+	want := `# Title
+Some text
+
+` + "```" + `python
+print(1)
+1+1
+` + "```" + `
+
+` + "```" + `
+1
+` + "```" + `
+
+` + "```" + `
+2
+` + "```" + `
+
+[image/png output omitted]
+
+` + "```" + `python
+1/0
+` + "```" + `
+
+` + "```" + `
+ZeroDivisionError: division by zero
+` + "```" + `
+`
+	if got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
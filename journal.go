@@ -0,0 +1,190 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() { registerFeature("journal") }
+
+// journalOptions are the parameters of journal:// inputs, and the
+// options of journalctl they stand for. Parameters in upper case, such
+// as _PID=1, match the fields of entries.
+var journalOptions = map[string]string{
+	"unit":       "--unit",
+	"user-unit":  "--user-unit",
+	"identifier": "--identifier",
+	"priority":   "--priority",
+	"since":      "--since",
+	"until":      "--until",
+	"boot":       "--boot",
+	"lines":      "--lines",
+	"grep":       "--grep",
+}
+
+// journalArgs returns the arguments of journalctl for a journal:// input,
+// whose parameters are separated by & as in the query of a URL.
+func journalArgs(src string) ([]string, error) {
+	q, err := url.ParseQuery(strings.TrimPrefix(src, "journal://"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	args := []string{"--no-pager", "--output=export"}
+	var matches []string
+	for k, vs := range q {
+		for _, v := range vs {
+			switch {
+			case k == "follow":
+				args = append(args, "--follow")
+			case journalOptions[k] != "" && v == "":
+				args = append(args, journalOptions[k])
+			case journalOptions[k] != "":
+				args = append(args, journalOptions[k]+"="+v)
+			case k != "" && strings.ToUpper(k) == k:
+				matches = append(matches, k+"="+v)
+			default:
+				return nil, fmt.Errorf("%s: unknown parameter %q", src, k)
+			}
+		}
+	}
+	// The order of a map is random, but that of the arguments should
+	// not be.
+	sort.Strings(args[2:])
+	sort.Strings(matches)
+	return append(args, matches...), nil
+}
+
+// journalctl returns the command that reads the journal, it is replaced
+// by tests.
+var journalctl = func(args []string) *exec.Cmd {
+	cmd := exec.Command("journalctl", args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// openJournal reads the entries of the journal that match a journal://
+// input through journalctl, in its export format, and renders them like
+// journalctl --output=short-iso.
+func openJournal(src string) (io.ReadCloser, error) {
+	args, err := journalArgs(src)
+	if err != nil {
+		return nil, err
+	}
+	c, err := startCmd(journalctl(args))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	return &journalReader{r: bufio.NewReader(c), c: c}, nil
+}
+
+// journalReader renders the entries of the export format of the journal,
+// see https://systemd.io/JOURNAL_EXPORT_FORMATS/.
+type journalReader struct {
+	r   *bufio.Reader
+	c   io.Closer
+	buf bytes.Buffer
+	err error
+}
+
+func (j *journalReader) Read(p []byte) (int, error) {
+	for j.buf.Len() == 0 {
+		if j.err != nil {
+			return 0, j.err
+		}
+		var fields map[string][]byte
+		fields, j.err = readJournalEntry(j.r)
+		if fields != nil {
+			renderJournalEntry(&j.buf, fields)
+		}
+	}
+	return j.buf.Read(p)
+}
+
+func (j *journalReader) Close() error { return j.c.Close() }
+
+// readJournalEntry reads the fields of an entry up to the empty line
+// that ends it. Fields are either KEY=value lines, or a KEY line
+// followed by the little endian 64-bit size of the binary value, the
+// value and a newline.
+func readJournalEntry(r *bufio.Reader) (map[string][]byte, error) {
+	var fields map[string][]byte
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return fields, err
+		}
+		line = line[:len(line)-1]
+		if len(line) == 0 {
+			if fields == nil {
+				continue
+			}
+			return fields, nil
+		}
+		if fields == nil {
+			fields = map[string][]byte{}
+		}
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = line[i+1:]
+			continue
+		}
+		var size [8]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return fields, io.ErrUnexpectedEOF
+		}
+		n := binary.LittleEndian.Uint64(size[:])
+		if n > 64<<20 {
+			return fields, fmt.Errorf("journal: field %s of %d bytes is too large", line, n)
+		}
+		v := make([]byte, n+1)
+		if _, err := io.ReadFull(r, v); err != nil {
+			return fields, io.ErrUnexpectedEOF
+		}
+		fields[string(line)] = v[:n]
+	}
+}
+
+// renderJournalEntry writes an entry as a line of its time, host,
+// identifier, PID and message.
+func renderJournalEntry(w *bytes.Buffer, fields map[string][]byte) {
+	if usec, err := strconv.ParseInt(string(fields["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		w.WriteString(time.Unix(0, usec*int64(time.Microsecond)).Format("2006-01-02T15:04:05-0700"))
+		w.WriteByte(' ')
+	}
+	if host := fields["_HOSTNAME"]; len(host) > 0 {
+		w.Write(host)
+		w.WriteByte(' ')
+	}
+	ident := fields["SYSLOG_IDENTIFIER"]
+	if len(ident) == 0 {
+		ident = fields["_COMM"]
+	}
+	if len(ident) == 0 {
+		ident = []byte("unknown")
+	}
+	w.Write(ident)
+	if pid := fields["_PID"]; len(pid) > 0 {
+		fmt.Fprintf(w, "[%s]", pid)
+	}
+	w.WriteString(": ")
+	w.Write(bytes.TrimRight(fields["MESSAGE"], "\n"))
+	w.WriteByte('\n')
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalArgs(t *testing.T) {
+	args, err := journalArgs("journal://unit=nginx.service&since=-1h&boot&_PID=42&follow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--no-pager --output=export --boot --follow --since=-1h --unit=nginx.service _PID=42"
+	if got := strings.Join(args, " "); got != want {
+		t.Fatalf("unexpected arguments: %s", got)
+	}
+	if _, err := journalArgs("journal://color=red"); err == nil || err.Error() != `journal://color=red: unknown parameter "color"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMainJournal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no sh on Windows")
+	}
+	// Two entries in the export format, the second with a binary
+	// message of two lines.
+	ts := time.Date(2021, 11, 7, 10, 0, 0, 0, time.Local)
+	b := []byte("__CURSOR=s=1\n__REALTIME_TIMESTAMP=" + strconv.FormatInt(ts.UnixNano()/1000, 10) + "\n")
+	b = append(b, "_HOSTNAME=box\nSYSLOG_IDENTIFIER=nginx\n_PID=42\nMESSAGE=started\n\n"...)
+	b = append(b, "_COMM=sh\nMESSAGE\n"...)
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], 10)
+	b = append(b, size[:]...)
+	b = append(b, "two\nlines\n\n\n"...)
+
+	fixture := filepath.Join(t.TempDir(), "export")
+	os.WriteFile(fixture, b, 0644)
+	var got []string
+	defer func(f func([]string) *exec.Cmd) { journalctl = f }(journalctl)
+	journalctl = func(args []string) *exec.Cmd {
+		got = args
+		return exec.Command("sh", "-c", `cat "$0"`, fixture)
+	}
+
+	out := runMain("journal://unit=nginx.service")
+	want := ts.Format("2006-01-02T15:04:05-0700") + " box nginx[42]: started\nsh: two\nlines\n"
+	if out != want {
+		t.Fatalf("unexpected output:\n%q", out)
+	}
+	if strings.Join(got, " ") != "--no-pager --output=export --unit=nginx.service" {
+		t.Fatalf("unexpected arguments %q", got)
+	}
+}
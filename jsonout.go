@@ -0,0 +1,111 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// jsonObject is a JSON object that keeps the order of its members,
+// which decoders of binary formats use to show the data as it is.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// jsonReader reads a stream of values as indented JSON, one value
+// after the other. The values are decoded by next only as the output
+// is read, so that long streams are converted as they arrive.
+type jsonReader struct {
+	name string                      // of the format, for errors
+	next func() (interface{}, error) // returns io.EOF after the last value
+	buf  []byte
+	err  error
+}
+
+func (j *jsonReader) Read(p []byte) (int, error) {
+	for len(j.buf) == 0 {
+		if j.err != nil {
+			return 0, j.err
+		}
+		v, err := j.next()
+		if err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("%s: %v", j.name, err)
+			}
+			j.err = err
+			continue
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			j.err = err
+			continue
+		}
+		j.buf = append(b, '\n')
+	}
+	n := copy(p, j.buf)
+	j.buf = j.buf[n:]
+	return n, nil
+}
+
+// jsonFloat keeps the values that JSON has no numbers for as strings.
+func jsonFloat(f float64) interface{} {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return f
+}
+
+// readOnce returns a next function for a jsonReader that decodes all
+// of r as a single value.
+func readOnce(r io.Reader, decode func([]byte) (interface{}, error)) func() (interface{}, error) {
+	done := false
+	return func() (interface{}, error) {
+		if done {
+			return nil, io.EOF
+		}
+		done = true
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decode(b)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// newJWTReader decodes the header and payload of the JSON Web Tokens of
+// its input, which are separated by whitespace, as JSON. The signatures
+// are not verified, and only shown as they are.
+func newJWTReader(r io.Reader) (io.Reader, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	s.Split(bufio.ScanWords)
+	return &jsonReader{name: "jwt", next: func() (interface{}, error) {
+		for s.Scan() {
+			// Tokens copied from an Authorization header come with
+			// their scheme.
+			if strings.EqualFold(s.Text(), "Bearer") {
+				continue
+			}
+			return decodeJWT(s.Text())
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}}, nil
+}
+
+// decodeJWT splits a token into its header, payload and signature.
+func decodeJWT(token string) (interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("not a JSON Web Token, which has three parts separated by dots")
+	}
+	var t jsonObject
+	for i, k := range []string{"header", "payload"} {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[i], "="))
+		if err != nil {
+			return nil, errors.New("invalid " + k + ": " + err.Error())
+		}
+		if !json.Valid(b) {
+			return nil, errors.New("invalid " + k + ": not JSON")
+		}
+		t = append(t, jsonMember{k, json.RawMessage(b)})
+	}
+	return append(t, jsonMember{"signature", parts[2]}), nil
+}
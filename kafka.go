@@ -0,0 +1,576 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() { registerFeature("kafka") }
+
+// kafkaProducer produces the lines of the output as records to a topic
+// of a Kafka cluster, for --kafka. It speaks version 3 of the Produce
+// API, which every broker from Kafka 0.11 to 4 knows, without
+// compression, and version 4 of the Metadata API, or version 1 with
+// brokers before Kafka 1.0, as the brokers tell by their ApiVersions.
+//
+// Records are batched until a batch holds --kafka-batch-size bytes, or
+// is --kafka-linger old. Each batch waits for the acknowledgment of all
+// in-sync replicas before the next is sent, so that a slow cluster
+// slows down the output rather than letting it pile up.
+type kafkaProducer struct {
+	seeds     []string
+	topic     string
+	key       []byte // or nil to spread the batches over all partitions
+	partition int32  // or -1 to choose by key
+	batchSize int
+	linger    time.Duration
+
+	mu         sync.Mutex
+	brokers    map[int32]string // the addresses by node id
+	leaders    []int32          // the leader node by partition
+	conns      map[int32]*kafkaConn
+	next       int32 // the partition of the next batch without a key
+	batch      [][]byte
+	batchBytes int
+	batchStart time.Time
+	err        error // of a batch sent in the background
+	done       chan struct{}
+}
+
+// Error codes of Kafka, see https://kafka.apache.org/protocol#protocol_error_codes.
+var kafkaErrors = map[int16]string{
+	2:  "corrupt message",
+	3:  "unknown topic or partition",
+	5:  "leader not available",
+	6:  "not leader for partition",
+	7:  "request timed out",
+	10: "message too large",
+	17: "invalid topic",
+	19: "not enough replicas",
+	20: "not enough replicas after append",
+	29: "topic authorization failed",
+	31: "cluster authorization failed",
+}
+
+// kafkaRetriable are the errors after which the metadata is refreshed
+// and a batch is sent again.
+var kafkaRetriable = map[int16]bool{5: true, 6: true}
+
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	if s, ok := kafkaErrors[int16(e)]; ok {
+		return "kafka: " + s
+	}
+	return fmt.Sprintf("kafka: error code %d", int16(e))
+}
+
+// parseKafkaTarget parses the brokers and topic of --kafka, as in
+// host1:9092,host2:9092/topic.
+func parseKafkaTarget(target string) (seeds []string, topic string, err error) {
+	i := strings.LastIndexByte(target, '/')
+	if i <= 0 || i == len(target)-1 {
+		return nil, "", fmt.Errorf("kafka: expected brokers/topic, got %q", target)
+	}
+	for _, b := range strings.Split(target[:i], ",") {
+		if b = strings.TrimSpace(b); b == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			b = net.JoinHostPort(b, "9092")
+		}
+		seeds = append(seeds, b)
+	}
+	return seeds, target[i+1:], nil
+}
+
+// newKafkaProducer connects to the cluster and looks up the partitions
+// of the topic.
+func newKafkaProducer(target, key string, partition int, batchSize int64, linger time.Duration) (*kafkaProducer, error) {
+	seeds, topic, err := parseKafkaTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	p := &kafkaProducer{
+		seeds:     seeds,
+		topic:     topic,
+		partition: int32(partition),
+		batchSize: int(batchSize),
+		linger:    linger,
+		conns:     map[int32]*kafkaConn{},
+		done:      make(chan struct{}),
+	}
+	if key != "" {
+		p.key = []byte(key)
+	}
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	if p.partition >= int32(len(p.leaders)) {
+		p.closeConns()
+		return nil, fmt.Errorf("kafka: topic %s has no partition %d", topic, p.partition)
+	}
+	if p.linger > 0 {
+		go p.lingerLoop()
+	}
+	return p, nil
+}
+
+// refresh fetches the brokers and the leaders of the partitions of the
+// topic from the first seed broker that answers.
+func (p *kafkaProducer) refresh() error {
+	p.closeConns()
+	var err error
+	for _, seed := range p.seeds {
+		var c *kafkaConn
+		if c, err = dialKafka(seed); err != nil {
+			continue
+		}
+		err = p.metadata(c)
+		c.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (p *kafkaProducer) metadata(c *kafkaConn) error {
+	versions, err := c.apiVersions()
+	if err != nil {
+		return err
+	}
+	if _, ok := versions.pick(0, 3); !ok {
+		return errors.New("kafka: the broker does not know version 3 of the Produce API")
+	}
+	version, ok := versions.pick(3, 4, 1)
+	if !ok {
+		return errors.New("kafka: the broker knows neither version 4 nor 1 of the Metadata API")
+	}
+
+	req := appendInt32(nil, 1)
+	req = appendKafkaString(req, p.topic)
+	if version >= 4 {
+		req = append(req, 1) // allow the creation of the topic, as v1 does
+	}
+	resp, err := c.roundTrip(3, version, req)
+	if err != nil {
+		return err
+	}
+	d := &kafkaDecoder{b: resp}
+	if version >= 3 {
+		d.int32() // throttle time
+	}
+	brokers := map[int32]string{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id, host, port := d.int32(), d.string(), d.int32()
+		d.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	if version >= 2 {
+		d.string() // cluster id
+	}
+	d.int32() // controller
+	var leaders []int32
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code, name := d.int16(), d.string()
+		d.int8() // internal
+		if name != p.topic {
+			continue
+		}
+		if code != 0 {
+			return fmt.Errorf("%w: %s", kafkaError(code), name)
+		}
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int16() // error code of the partition
+			index, leader := d.int32(), d.int32()
+			d.int32s() // replicas
+			d.int32s() // in-sync replicas
+			for int(index) >= len(leaders) {
+				leaders = append(leaders, -1)
+			}
+			leaders[index] = leader
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("kafka: invalid metadata: %v", d.err)
+	}
+	if len(leaders) == 0 {
+		return fmt.Errorf("%w: %s", kafkaError(3), p.topic)
+	}
+	p.brokers, p.leaders = brokers, leaders
+	return nil
+}
+
+func (p *kafkaProducer) closeConns() {
+	for id, c := range p.conns {
+		c.Close()
+		delete(p.conns, id)
+	}
+}
+
+// send adds a line to the batch, and sends the batch once it is full.
+func (p *kafkaProducer) send(msg []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	if len(p.batch) == 0 {
+		p.batchStart = time.Now()
+	}
+	p.batch = append(p.batch, append([]byte(nil), msg...))
+	p.batchBytes += len(msg)
+	if p.batchBytes >= p.batchSize {
+		return p.flush()
+	}
+	return nil
+}
+
+// lingerLoop sends batches that waited for longer than the linger time.
+func (p *kafkaProducer) lingerLoop() {
+	t := time.NewTicker(p.linger / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+		}
+		p.mu.Lock()
+		if len(p.batch) > 0 && time.Since(p.batchStart) >= p.linger && p.err == nil {
+			p.err = p.flush()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// flush sends the batch to the leader of its partition, and retries it
+// once with fresh metadata if the leader moved.
+func (p *kafkaProducer) flush() error {
+	if len(p.batch) == 0 {
+		return nil
+	}
+	partition := p.partition
+	switch {
+	case partition >= 0:
+	case p.key != nil:
+		partition = int32(murmur2(p.key)&0x7fffffff) % int32(len(p.leaders))
+	default:
+		partition = p.next
+		p.next = (p.next + 1) % int32(len(p.leaders))
+	}
+	records := encodeRecordBatch(p.key, p.batch, time.Now())
+	err := p.produce(partition, records)
+	// Leaders move, and brokers go away.
+	var code kafkaError
+	if err != nil && (!errors.As(err, &code) || kafkaRetriable[int16(code)]) {
+		if err = p.refresh(); err == nil {
+			err = p.produce(partition, records)
+		}
+	}
+	p.batch, p.batchBytes = p.batch[:0], 0
+	return err
+}
+
+func (p *kafkaProducer) produce(partition int32, records []byte) error {
+	if int(partition) >= len(p.leaders) || p.leaders[partition] < 0 {
+		return kafkaError(5)
+	}
+	leader := p.leaders[partition]
+	c, ok := p.conns[leader]
+	if !ok {
+		var err error
+		if c, err = dialKafka(p.brokers[leader]); err != nil {
+			return err
+		}
+		p.conns[leader] = c
+	}
+
+	req := appendInt16(nil, -1)   // no transactional id
+	req = appendInt16(req, -1)    // acks of all in-sync replicas
+	req = appendInt32(req, 30000) // timeout in ms
+	req = appendInt32(req, 1)     // topics
+	req = appendKafkaString(req, p.topic)
+	req = appendInt32(req, 1) // partitions
+	req = appendInt32(req, partition)
+	req = appendInt32(req, int32(len(records)))
+	req = append(req, records...)
+	resp, err := c.roundTrip(0, 3, req)
+	if err != nil {
+		c.Close()
+		delete(p.conns, leader)
+		return err
+	}
+	d := &kafkaDecoder{b: resp}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string() // topic
+		for m := d.int32(); m > 0 && d.err == nil; m-- {
+			d.int32() // partition
+			code := d.int16()
+			d.int64() // base offset
+			d.int64() // log append time
+			if code != 0 && d.err == nil {
+				return kafkaError(code)
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("kafka: invalid produce response: %v", d.err)
+	}
+	return nil
+}
+
+// Close sends the last batch and closes the connections.
+func (p *kafkaProducer) Close() error {
+	close(p.done)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.err
+	if err == nil {
+		err = p.flush()
+	}
+	p.closeConns()
+	return err
+}
+
+// encodeRecordBatch encodes records in the record batch format of
+// version 2, see https://kafka.apache.org/documentation/#recordbatch.
+func encodeRecordBatch(key []byte, values [][]byte, now time.Time) []byte {
+	ts := now.UnixNano() / int64(time.Millisecond)
+	var records []byte
+	for i, v := range values {
+		var r []byte
+		r = append(r, 0)              // attributes
+		r = appendVarint(r, 0)        // timestamp delta
+		r = appendVarint(r, int64(i)) // offset delta
+		if key == nil {
+			r = appendVarint(r, -1)
+		} else {
+			r = appendVarint(r, int64(len(key)))
+			r = append(r, key...)
+		}
+		r = appendVarint(r, int64(len(v)))
+		r = append(r, v...)
+		r = appendVarint(r, 0) // headers
+		records = appendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// The CRC covers all that follows it.
+	var body []byte
+	body = appendInt16(body, 0)                    // attributes
+	body = appendInt32(body, int32(len(values)-1)) // last offset delta
+	body = appendInt64(body, ts)                   // first timestamp
+	body = appendInt64(body, ts)                   // max timestamp
+	body = appendInt64(body, -1)                   // producer id
+	body = appendInt16(body, -1)                   // producer epoch
+	body = appendInt32(body, -1)                   // base sequence
+	body = appendInt32(body, int32(len(values)))   // records
+	body = append(body, records...)
+
+	var b []byte
+	b = appendInt64(b, 0)                  // base offset
+	b = appendInt32(b, int32(9+len(body))) // batch length, from the leader epoch on
+	b = appendInt32(b, -1)                 // partition leader epoch
+	b = append(b, 2)                       // magic
+	b = appendInt32(b, int32(crc32.Checksum(body, castagnoli)))
+	return append(b, body...)
+}
+
+// murmur2 is the hash that Kafka clients choose the partitions of keys
+// by, so that cat writes the records of a key where they do.
+func murmur2(data []byte) int32 {
+	const m, r = 0x5bd1e995, 24
+	h := uint32(0x9747b28c) ^ uint32(len(data))
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(data) % 4 {
+	case 3:
+		h ^= uint32(data[n+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[n+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[n])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// kafkaConn is a connection to a broker.
+type kafkaConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	id   int32 // of the last request
+}
+
+func dialKafka(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("kafka: %v", err)
+	}
+	return &kafkaConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// roundTrip sends a request and returns the body of its response.
+func (c *kafkaConn) roundTrip(api, version int16, body []byte) ([]byte, error) {
+	c.id++
+	var req []byte
+	req = appendInt32(req, 0) // the size, set below
+	req = appendInt16(req, api)
+	req = appendInt16(req, version)
+	req = appendInt32(req, c.id)
+	req = appendKafkaString(req, "cat")
+	req = append(req, body...)
+	binary.BigEndian.PutUint32(req, uint32(len(req)-4))
+	c.conn.SetDeadline(time.Now().Add(time.Minute))
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+	var head [8]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(head[:])
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("kafka: invalid response size %d", size)
+	}
+	if id := int32(binary.BigEndian.Uint32(head[4:])); id != c.id {
+		return nil, fmt.Errorf("kafka: response %d to request %d", id, c.id)
+	}
+	resp := make([]byte, size-4)
+	_, err := io.ReadFull(c.r, resp)
+	return resp, err
+}
+
+func (c *kafkaConn) Close() error { return c.conn.Close() }
+
+// kafkaVersions are the lowest and highest versions of the APIs that a
+// broker knows, by their keys.
+type kafkaVersions map[int16][2]int16
+
+// apiVersions asks the broker for the versions of the APIs it knows,
+// with version 0 of the ApiVersions API, which every broker since Kafka
+// 0.10 knows.
+func (c *kafkaConn) apiVersions() (kafkaVersions, error) {
+	resp, err := c.roundTrip(18, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := &kafkaDecoder{b: resp}
+	if code := d.int16(); code != 0 {
+		return nil, kafkaError(code)
+	}
+	versions := kafkaVersions{}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		api, low, high := d.int16(), d.int16(), d.int16()
+		versions[api] = [2]int16{low, high}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("kafka: invalid api versions: %v", d.err)
+	}
+	return versions, nil
+}
+
+// pick returns the first of the given versions of an API that the
+// broker knows.
+func (v kafkaVersions) pick(api int16, versions ...int16) (int16, bool) {
+	r, ok := v[api]
+	if !ok {
+		return 0, false
+	}
+	for _, version := range versions {
+		if r[0] <= version && version <= r[1] {
+			return version, true
+		}
+	}
+	return 0, false
+}
+
+// kafkaDecoder reads the fields of Kafka responses. After the first
+// error, all fields are zero.
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.b) {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, 8)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8   { return int8(d.next(1)[0]) }
+func (d *kafkaDecoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.next(2))) }
+func (d *kafkaDecoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.next(4))) }
+func (d *kafkaDecoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.next(8))) }
+
+// string reads a nullable string, which is prefixed by its length.
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) int32s() []int32 {
+	var s []int32
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		s = append(s, d.int32())
+	}
+	return s
+}
+
+func appendInt16(b []byte, v int16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendInt32(b []byte, v int32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendInt64(b []byte, v int64) []byte {
+	return appendInt32(appendInt32(b, int32(v>>32)), int32(v))
+}
+
+func appendKafkaString(b []byte, s string) []byte {
+	return append(appendInt16(b, int16(len(s))), s...)
+}
+
+// appendVarint appends a zig-zag encoded varint.
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
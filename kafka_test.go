@@ -0,0 +1,235 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMurmur2(t *testing.T) {
+	// The cases of the tests of the Java client of Kafka.
+	cases := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for s, want := range cases {
+		if got := murmur2([]byte(s)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+// fakeKafka is a broker of a topic with two partitions, which records
+// the batches produced to it. It knows the given versions of the
+// Metadata API.
+type fakeKafka struct {
+	l        net.Listener
+	metadata [2]int16
+	mu       sync.Mutex
+	batches  []string
+}
+
+func newFakeKafka(t *testing.T, metadata [2]int16) *fakeKafka {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &fakeKafka{l: l, metadata: metadata}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go k.serve(t, conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		d := &kafkaDecoder{b: req}
+		api, version, id, client := d.int16(), d.int16(), d.int32(), d.string()
+		if client != "cat" {
+			t.Errorf("unexpected client id %q", client)
+		}
+		resp := appendInt32(nil, id)
+		switch {
+		case api == 18 && version == 0:
+			resp = appendInt16(resp, 0)
+			resp = appendInt32(resp, 3)
+			resp = appendInt16(appendInt16(appendInt16(resp, 0), 3), 11) // produce
+			resp = appendInt16(appendInt16(appendInt16(resp, 3), k.metadata[0]), k.metadata[1])
+			resp = appendInt16(appendInt16(appendInt16(resp, 18), 0), 4)
+		case api == 3 && (version == 1 || version == 4) && k.metadata[0] <= version && version <= k.metadata[1]:
+			d.int32()
+			topic := d.string()
+			if version == 4 {
+				if auto := d.int8(); auto != 1 {
+					t.Errorf("unexpected creation of topics %d", auto)
+				}
+				resp = appendInt32(resp, 0) // throttle time
+			}
+			host, port, _ := net.SplitHostPort(k.l.Addr().String())
+			p, _ := strconv.Atoi(port)
+			resp = appendInt32(resp, 1) // brokers
+			resp = appendInt32(resp, 7)
+			resp = appendKafkaString(resp, host)
+			resp = appendInt32(resp, int32(p))
+			resp = appendInt16(resp, -1) // rack
+			if version == 4 {
+				resp = appendKafkaString(resp, "cluster")
+			}
+			resp = appendInt32(resp, 7) // controller
+			resp = appendInt32(resp, 1) // topics
+			resp = appendInt16(resp, 0)
+			resp = appendKafkaString(resp, topic)
+			resp = append(resp, 0)
+			resp = appendInt32(resp, 2) // partitions
+			for i := int32(0); i < 2; i++ {
+				resp = appendInt16(resp, 0)
+				resp = appendInt32(resp, i)
+				resp = appendInt32(resp, 7)
+				resp = appendInt32(appendInt32(resp, 1), 7)
+				resp = appendInt32(appendInt32(resp, 1), 7)
+			}
+		case api == 0 && version == 3:
+			d.string() // transactional id
+			if acks := d.int16(); acks != -1 {
+				t.Errorf("unexpected acks %d", acks)
+			}
+			d.int32()
+			d.int32()
+			topic := d.string()
+			d.int32()
+			partition := d.int32()
+			batch := d.next(int(d.int32()))
+			k.mu.Lock()
+			k.batches = append(k.batches, fmt.Sprintf("%d: %s", partition, decodeRecordBatch(t, batch)))
+			k.mu.Unlock()
+			resp = appendInt32(resp, 1)
+			resp = appendKafkaString(resp, topic)
+			resp = appendInt32(resp, 1)
+			resp = appendInt32(resp, partition)
+			resp = appendInt16(resp, 0)
+			resp = appendInt64(resp, 0)
+			resp = appendInt64(resp, -1)
+			resp = appendInt32(resp, 0) // throttle time
+		default:
+			t.Errorf("unexpected request %d version %d", api, version)
+			return
+		}
+		conn.Write(appendInt32(nil, int32(len(resp))))
+		conn.Write(resp)
+	}
+}
+
+// decodeRecordBatch checks a record batch and returns its records as
+// key=value pairs.
+func decodeRecordBatch(t *testing.T, b []byte) string {
+	d := &kafkaDecoder{b: b}
+	d.int64()
+	if n := d.int32(); int(n) != len(d.b) {
+		t.Errorf("batch length %d of %d bytes", n, len(d.b))
+	}
+	d.int32()
+	if magic := d.int8(); magic != 2 {
+		t.Errorf("unexpected magic %d", magic)
+	}
+	if sum := uint32(d.int32()); sum != crc32.Checksum(d.b, castagnoli) {
+		t.Errorf("CRC mismatch")
+	}
+	d.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	var records []string
+	varint := func() int64 {
+		v, n := binary.Varint(d.b)
+		d.next(n)
+		return v
+	}
+	for n := d.int32(); n > 0; n-- {
+		varint() // length
+		d.int8()
+		varint()
+		varint()
+		key := "null"
+		if n := varint(); n >= 0 {
+			key = string(d.next(int(n)))
+		}
+		value := string(d.next(int(varint())))
+		varint()
+		records = append(records, key+"="+value)
+	}
+	if d.err != nil || len(d.b) != 0 {
+		t.Errorf("invalid record batch: %v", d.err)
+	}
+	return strings.Join(records, ",")
+}
+
+func TestMainKafka(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "log")
+	os.WriteFile(name, []byte("a\nbb\nccc\ndddd\n"), 0644)
+
+	// Kafka 4 dropped the versions of the Metadata API before 4, which
+	// brokers before Kafka 1.0 do not know yet.
+	for _, metadata := range [][2]int16{{4, 12}, {0, 2}} {
+		t.Run(fmt.Sprint(metadata), func(t *testing.T) {
+			k := newFakeKafka(t, metadata)
+			// The batches of 3 bytes are spread over the partitions.
+			target := k.l.Addr().String() + "/logs"
+			if out := runMain("--kafka", target, "--kafka-batch-size=3", name); out != "" {
+				t.Fatalf("unexpected output:\n%s", out)
+			}
+			// Records of a key are written to the partition of the key.
+			if out := runMain("--kafka", target, "--kafka-key=abc", name); out != "" {
+				t.Fatalf("unexpected output:\n%s", out)
+			}
+			want := []string{
+				"0: null=a,null=bb",
+				"1: null=ccc",
+				"0: null=dddd",
+				fmt.Sprintf("%d: abc=a,abc=bb,abc=ccc,abc=dddd", (479470107&0x7fffffff)%2),
+			}
+			k.mu.Lock()
+			defer k.mu.Unlock()
+			if fmt.Sprint(k.batches) != fmt.Sprint(want) {
+				t.Fatalf("unexpected batches:\n%q", k.batches)
+			}
+		})
+	}
+
+	// A broker that knows neither version is refused.
+	k := newFakeKafka(t, [2]int16{2, 3})
+	out, code := runMainCode("--kafka", k.l.Addr().String()+"/logs", name)
+	if code != exitWrite || !strings.Contains(out, "knows neither version 4 nor 1 of the Metadata API") {
+		t.Fatalf("unexpected output %q and exit code %d", out, code)
+	}
+}
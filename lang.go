@@ -0,0 +1,71 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languages maps file extensions and well known file names to the
+// names that Markdown renderers use for syntax highlighting.
+var languages = map[string]string{
+	".c":          "c",
+	".h":          "c",
+	".cc":         "cpp",
+	".cpp":        "cpp",
+	".hpp":        "cpp",
+	".cs":         "csharp",
+	".css":        "css",
+	".diff":       "diff",
+	".patch":      "diff",
+	".go":         "go",
+	".mod":        "go",
+	".html":       "html",
+	".htm":        "html",
+	".ini":        "ini",
+	".cfg":        "ini",
+	".java":       "java",
+	".js":         "javascript",
+	".mjs":        "javascript",
+	".json":       "json",
+	".kt":         "kotlin",
+	".lua":        "lua",
+	".md":         "markdown",
+	".php":        "php",
+	".pl":         "perl",
+	".proto":      "protobuf",
+	".py":         "python",
+	".rb":         "ruby",
+	".rs":         "rust",
+	".scala":      "scala",
+	".sh":         "sh",
+	".bash":       "bash",
+	".zsh":        "zsh",
+	".sql":        "sql",
+	".swift":      "swift",
+	".toml":       "toml",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".jsx":        "jsx",
+	".xml":        "xml",
+	".svg":        "xml",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	"Dockerfile":  "dockerfile",
+	"Makefile":    "makefile",
+	"GNUmakefile": "makefile",
+}
+
+// language returns the language of the named file for syntax
+// highlighting, or "" if it is not known.
+func language(name string) string {
+	_, name = splitDecoders(name)
+	base := filepath.Base(name)
+	if l, ok := languages[base]; ok {
+		return l
+	}
+	return languages[strings.ToLower(filepath.Ext(base))]
+}
@@ -0,0 +1,255 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+func init() { registerFeature("mail") }
+
+// mailHeaders are the headers shown of a message, in order.
+var mailHeaders = []string{"From", "To", "Cc", "Date", "Subject"}
+
+// newMailReader renders email messages as text: a single RFC 2822
+// message, or the messages of an mbox file one after the other.
+func newMailReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(5)
+	return &mailReader{r: br, mbox: string(head) == "From "}, nil
+}
+
+// mailReader renders the messages of its input only as the output is
+// read, so that large mailboxes are not held in memory.
+type mailReader struct {
+	r    *bufio.Reader
+	mbox bool
+	n    int // the number of messages rendered
+	buf  bytes.Buffer
+	err  error
+}
+
+func (m *mailReader) Read(p []byte) (int, error) {
+	for m.buf.Len() == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		raw, err := m.next()
+		m.err = err
+		if len(raw) == 0 {
+			continue
+		}
+		if m.n > 0 {
+			m.buf.WriteByte('\n')
+		}
+		m.n++
+		if err := renderMail(&m.buf, raw); err != nil {
+			m.err = fmt.Errorf("mail: %v", err)
+		}
+	}
+	return m.buf.Read(p)
+}
+
+// next returns the next raw message, and io.EOF with the last one.
+func (m *mailReader) next() ([]byte, error) {
+	if !m.mbox {
+		b, err := io.ReadAll(m.r)
+		if err == nil {
+			err = io.EOF
+		}
+		return b, err
+	}
+	// Messages of an mbox file start with a From line, which follows an
+	// empty line. From lines in messages are escaped as >From, which is
+	// undone here.
+	var msg []byte
+	blank := true
+	for {
+		line, err := m.r.ReadBytes('\n')
+		if blank && bytes.HasPrefix(line, []byte("From ")) {
+			if len(msg) > 0 {
+				return bytes.TrimSuffix(msg, []byte("\n")), err
+			}
+		} else {
+			if unescaped := bytes.TrimLeft(line, ">"); len(unescaped) < len(line) && bytes.HasPrefix(unescaped, []byte("From ")) {
+				line = line[1:]
+			}
+			msg = append(msg, line...)
+		}
+		blank = len(bytes.TrimRight(line, "\r\n")) == 0
+		if err != nil {
+			return msg, err
+		}
+	}
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// decodeHeader decodes the encoded words of a header such as
+// =?utf-8?q?caf=C3=A9?=, or leaves it as it is if it cannot.
+func decodeHeader(v string) string {
+	if d, err := wordDecoder.DecodeHeader(v); err == nil {
+		return d
+	}
+	return v
+}
+
+// renderMail writes the main headers of a message, its text and a list
+// of its attachments.
+func renderMail(w *bytes.Buffer, raw []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	for _, k := range mailHeaders {
+		if v := msg.Header.Get(k); v != "" {
+			fmt.Fprintf(w, "%s: %s\n", k, decodeHeader(v))
+		}
+	}
+	p := &mailPart{w: w}
+	if err := p.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return err
+	}
+	if len(p.attachments) > 0 {
+		w.WriteString("\nAttachments:\n")
+		for _, a := range p.attachments {
+			fmt.Fprintf(w, "  %s\n", a)
+		}
+	}
+	return nil
+}
+
+// mailPart walks the MIME parts of a message.
+type mailPart struct {
+	w           *bytes.Buffer
+	attachments []string
+}
+
+// walk writes the text parts and collects the attachments of a part.
+// Of alternative parts, only the plain text one is written.
+func (p *mailPart) walk(h textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxNesting {
+		return errNesting
+	}
+	typ, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		typ, params = "text/plain", nil
+	}
+	switch strings.ToLower(h.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	if strings.HasPrefix(typ, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var parts []*multipart.Part
+		var alternatives [][]byte
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if typ != "multipart/alternative" {
+				if err := p.walk(part.Header, part, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			b, err := io.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			parts, alternatives = append(parts, part), append(alternatives, b)
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+		// The alternatives are ordered by preference for the sender,
+		// from plain to rich, but plain text is what reads best here.
+		i := len(parts) - 1
+		for j, part := range parts {
+			if t, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); t == "text/plain" {
+				i = j
+				break
+			}
+		}
+		return p.walk(parts[i].Header, bytes.NewReader(alternatives[i]), depth+1)
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if disposition == "attachment" || !strings.HasPrefix(typ, "text/") {
+		n, err := io.Copy(io.Discard, body)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			name = "(unnamed)"
+		}
+		p.attachments = append(p.attachments, fmt.Sprintf("%s (%s, %d bytes)", decodeHeader(name), typ, n))
+		return nil
+	}
+
+	if r, err := charsetReader(params["charset"], body); err == nil {
+		body = r
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	p.w.WriteByte('\n')
+	p.w.Write(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n")))
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		p.w.WriteByte('\n')
+	}
+	return nil
+}
+
+// charsetReader converts text of the given charset to UTF-8. Only the
+// charsets that need no tables are known.
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return r, nil
+	case "iso-8859-1", "latin1":
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		s := make([]rune, len(b))
+		for i, c := range b {
+			s[i] = rune(c)
+		}
+		return strings.NewReader(string(s)), nil
+	case "utf-16":
+		return newUTF16Reader(r, nil), nil
+	case "utf-16le":
+		return newUTF16Reader(r, binary.LittleEndian), nil
+	case "utf-16be":
+		return newUTF16Reader(r, binary.BigEndian), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %s", charset)
+}
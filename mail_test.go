@@ -0,0 +1,72 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainMail(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.mbox")
+	os.WriteFile(name, []byte(`From alice Mon Jan  1 00:00:00 2024
+From: =?utf-8?q?Ren=C3=A9?= <rene@example.com>
+To: bob@example.com
+Subject: =?iso-8859-1?q?caf=E9?=
+Content-Type: multipart/mixed; boundary=X
+
+--X
+Content-Type: multipart/alternative; boundary=Y
+
+--Y
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Caf=E9 is open=
+ today.
+>From here on.
+--Y
+Content-Type: text/html
+
+<p>Cafe</p>
+--Y--
+--X
+Content-Type: application/pdf; name=menu.pdf
+Content-Disposition: attachment; filename=menu.pdf
+Content-Transfer-Encoding: base64
+
+aGVsbG8=
+--X--
+
+From bob Mon Jan  1 00:00:00 2024
+From: bob@example.com
+Subject: Re
+
+Thanks
+`), 0644)
+
+	got := runMain("--mail", name)
+	want := `From: René <rene@example.com>
+To: bob@example.com
+Subject: café
+
+Café is open today.
+From here on.
+
+Attachments:
+  menu.pdf (application/pdf, 5 bytes)
+
+From: bob@example.com
+Subject: Re
+
+Thanks
+`
+	if got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
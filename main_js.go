@@ -0,0 +1,109 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build js && wasm
+
+package main
+
+import (
+	"flag"
+	"os"
+	"sync"
+	"syscall/js"
+)
+
+// main exports cat to JavaScript as a function of the arguments and of
+// the input files by their names, strings or Uint8Arrays, which returns
+// a promise of what cat wrote to stdout and stderr, and its exit code:
+//
+//	const {stdout, stderr, code} = await cat(["--fence", "a.go"], {"a.go": "package a\n"});
+//
+// Inputs that are URLs are fetched.
+func main() {
+	js.Global().Set("cat", js.FuncOf(catJS))
+	select {}
+}
+
+func catJS(this js.Value, args []js.Value) interface{} {
+	var argv []string
+	if len(args) > 0 && args[0].Truthy() {
+		for i := 0; i < args[0].Length(); i++ {
+			argv = append(argv, args[0].Index(i).String())
+		}
+	}
+	files := memFS{}
+	if len(args) > 1 && args[1].Truthy() {
+		names := js.Global().Get("Object").Call("keys", args[1])
+		for i := 0; i < names.Length(); i++ {
+			name := names.Index(i).String()
+			files[name] = jsBytes(args[1].Get(name))
+		}
+	}
+	executor := js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+		resolve := p[0]
+		// Runs may block, e.g. on fetches, which must not happen in
+		// a callback of JavaScript.
+		go func() {
+			stdout, stderr, code := runJS(argv, files)
+			resolve.Invoke(map[string]interface{}{"stdout": stdout, "stderr": stderr, "code": code})
+		}()
+		return nil
+	})
+	defer executor.Release()
+	return js.Global().Get("Promise").New(executor)
+}
+
+// jsBytes returns the bytes of a string or an Uint8Array.
+func jsBytes(v js.Value) []byte {
+	if v.Type() == js.TypeString {
+		return []byte(v.String())
+	}
+	b := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(b, v)
+	return b
+}
+
+// jsMu serializes the runs, which share the flags and the hook of the
+// writes.
+var jsMu sync.Mutex
+
+// runJS runs cat on the files, and returns what it wrote to stdout and
+// stderr, which the runtime writes with fs.write of JavaScript.
+func runJS(argv []string, files memFS) (stdout, stderr string, code int) {
+	jsMu.Lock()
+	defer jsMu.Unlock()
+
+	var out [3][]byte
+	fsys := js.Global().Get("fs")
+	write := fsys.Get("write")
+	hook := js.FuncOf(func(this js.Value, a []js.Value) interface{} {
+		// fs.write(fd, buffer, offset, length, position, callback)
+		fd := a[0].Int()
+		if fd != 1 && fd != 2 {
+			args := make([]interface{}, len(a))
+			for i := range a {
+				args[i] = a[i]
+			}
+			return write.Call("apply", this, args)
+		}
+		offset, length := a[2].Int(), a[3].Int()
+		b := make([]byte, length)
+		js.CopyBytesToGo(b, a[1].Call("subarray", offset, offset+length))
+		out[fd] = append(out[fd], b...)
+		a[5].Invoke(js.Null(), length)
+		return nil
+	})
+	fsys.Set("write", hook)
+	defer func() {
+		fsys.Set("write", write)
+		hook.Release()
+	}()
+
+	virtualFS = files
+	defer func() { virtualFS = nil }()
+	os.Args = append([]string{"cat"}, argv...)
+	flag.CommandLine = flag.NewFlagSet("cat", flag.ContinueOnError)
+	code = run()
+	return string(out[1]), string(out[2]), code
+}
@@ -0,0 +1,13 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !js
+
+package main
+
+import "os"
+
+func main() {
+	os.Exit(run())
+}
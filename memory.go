@@ -0,0 +1,92 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxMemory is the most that a mode that buffers its input may hold in
+// memory, or 0 for no limit, as --max-memory tells. Beyond it, modes
+// spill to a temporary file if they can, and fail if they cannot.
+var maxMemory int64
+
+// memoryError is the error of a mode that needs more memory for the
+// named input than it may use.
+func memoryError(name, mode string) error {
+	return fmt.Errorf("%s: %s needs more than the --max-memory of %s", name, mode, formatSize(maxMemory))
+}
+
+// readAll reads r to its end like io.ReadAll, for the mode that needs
+// the whole named input, but fails rather than read more than
+// --max-memory.
+func readAll(name, mode string, r io.Reader) ([]byte, error) {
+	if maxMemory <= 0 {
+		return io.ReadAll(r)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, maxMemory+1))
+	if err == nil && int64(len(b)) > maxMemory {
+		return nil, memoryError(name, mode)
+	}
+	return b, err
+}
+
+// spillBuffer buffers what is written to it in memory, and in a
+// temporary file once that is more than --max-memory. Close removes
+// the file.
+type spillBuffer struct {
+	mem  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func (s *spillBuffer) Write(b []byte) (int, error) {
+	if s.file == nil && maxMemory > 0 && int64(s.mem.Len()+len(b)) > maxMemory {
+		f, err := os.CreateTemp("", "cat-spill-")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.mem.WriteTo(f); err != nil {
+			return 0, err
+		}
+		s.mem = bytes.Buffer{}
+	}
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(b)
+	} else {
+		n, err = s.mem.Write(b)
+	}
+	s.size += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written.
+func (s *spillBuffer) Len() int64 { return s.size }
+
+// Reader returns a reader of what was written.
+func (s *spillBuffer) Reader() (io.Reader, error) {
+	if s.file == nil {
+		return &s.mem, nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// Close removes the temporary file, if any.
+func (s *spillBuffer) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
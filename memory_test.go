@@ -0,0 +1,79 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// limitMemory sets --max-memory for the test.
+func limitMemory(t *testing.T, n int64) {
+	old := maxMemory
+	t.Cleanup(func() { maxMemory = old })
+	maxMemory = n
+}
+
+func TestSpillBuffer(t *testing.T) {
+	limitMemory(t, 4)
+	var s spillBuffer
+	s.Write([]byte("ab"))
+	if s.file != nil {
+		t.Fatal("spilled below the limit")
+	}
+	s.Write([]byte("cdef"))
+	if s.file == nil {
+		t.Fatal("did not spill above the limit")
+	}
+	r, err := s.Reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(r); string(b) != "abcdef" || s.Len() != 6 {
+		t.Fatalf("unexpected content %q of length %d", b, s.Len())
+	}
+	name := s.file.Name()
+	s.Close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatalf("the temporary file was not removed: %v", err)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	limitMemory(t, 4)
+	if b, err := readAll("x", "--template", strings.NewReader("abcd")); err != nil || string(b) != "abcd" {
+		t.Fatalf("unexpected %q, %v", b, err)
+	}
+	_, err := readAll("x", "--template", strings.NewReader("abcde"))
+	if err == nil || err.Error() != "x: --template needs more than the --max-memory of 4B" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestPagerMaxMemory(t *testing.T) {
+	limitMemory(t, 4)
+	var b strings.Builder
+	p := &pagerWriter{w: &b}
+	p.Write([]byte("ab"))
+	out := captureOutput(func() { p.Write([]byte("cdef")) })
+	p.Write([]byte("g"))
+	if b.String() != "abcdefg" || !strings.Contains(out, "without paging") {
+		t.Fatalf("unexpected output %q, and %q", b.String(), out)
+	}
+}
+
+func TestMainMaxMemory(t *testing.T) {
+	want := runMain("--fence", "testdata/a.txt")
+	if got := runMain("--max-memory=16", "--fence", "testdata/a.txt"); got != want {
+		t.Fatalf("unexpected fence after a spill:\n%s\nwant:\n%s", got, want)
+	}
+	requireFeature(t, "template")
+	got, code := runMainCode("--max-memory=4", "--template", "testdata/a.txt")
+	if code != exitFailed || got != "cat: testdata/a.txt: --template needs more than the --max-memory of 4B\n" {
+		t.Fatalf("unexpected output %q and exit code %d", got, code)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "bytes"
+
+// messageWriter sends each line written to it as a message, without
+// its line ending.
+type messageWriter struct {
+	send func(msg []byte) error
+	buf  []byte
+}
+
+func (m *messageWriter) Write(p []byte) (int, error) {
+	m.buf = append(m.buf, p...)
+	for {
+		i := bytes.IndexByte(m.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimSuffix(m.buf[:i], []byte("\r"))
+		if err := m.send(line); err != nil {
+			return 0, err
+		}
+		m.buf = m.buf[i+1:]
+	}
+}
+
+// Close sends the last line if it has no line ending.
+func (m *messageWriter) Close() error {
+	if len(m.buf) == 0 {
+		return nil
+	}
+	err := m.send(m.buf)
+	m.buf = nil
+	return err
+}
@@ -0,0 +1,162 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build minimal
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+// The minimal build leaves out the optional features, which fail, or
+// do nothing, in their place.
+
+func openURL(src string) (io.ReadCloser, error) {
+	return nil, featureError(src, "URLs")
+}
+
+// hub and wsConn are the sinks of --serve and --ws, which cannot be
+// created.
+type hub struct{}
+
+func serve(addr string) (*hub, error) { return nil, featureError("--serve", "HTTP servers") }
+func (h *hub) send(msg []byte) error  { return nil }
+func (h *hub) Close() error           { return nil }
+
+type wsConn struct{}
+
+func dialWS(rawurl string) (*wsConn, error) { return nil, featureError("--ws", "WebSockets") }
+func (c *wsConn) send(msg []byte) error     { return nil }
+func (c *wsConn) Close() error              { return nil }
+
+func serveProfiles(addr string) (stop func(), err error) {
+	return nil, featureError("--profile-http", "HTTP servers")
+}
+
+func serveGRPC(addr, certFile, keyFile string) error {
+	return featureError("--grpc", "gRPC servers")
+}
+
+func openDocker(src string) (io.ReadCloser, error) {
+	return nil, featureError(src, "containers")
+}
+
+func openOCI(src string) (io.ReadCloser, error) {
+	return nil, featureError(src, "container images")
+}
+
+func archiveFormat(name string) (format, decoder string) { return "", "" }
+
+// archiveMember still recognizes members of archives, to tell that
+// they cannot be read.
+func archiveMember(src string) (archive, member string, ok bool) {
+	i := strings.Index(src, "!/")
+	if i < 0 {
+		return "", "", false
+	}
+	return src[:i], src[i+2:], true
+}
+
+func isArchiveMember(src string) bool {
+	_, _, ok := archiveMember(src)
+	return ok
+}
+
+func openArchiveMember(archive, member string) (io.ReadCloser, error) {
+	return nil, featureError(archive, "archives")
+}
+
+func (w *walker) walkArchive(archive string) {}
+
+// syntax is no syntax, as none is known.
+type syntax struct{}
+
+var syntaxes = map[string]*syntax{}
+
+func highlight(src string, syn *syntax) []span {
+	return []span{{text: src}}
+}
+
+func openJournal(src string) (io.ReadCloser, error) {
+	return nil, featureError(src, "journal queries")
+}
+
+// The previews of these formats are left out, and their inputs are not
+// detected, as no preview is available for them.
+
+func isAvro(head []byte, _ string) bool               { return false }
+func previewAvro(w io.Writer, r *bufio.Reader) error  { return nil }
+func isPcap(head []byte, _ string) bool               { return false }
+func previewPcap(w io.Writer, r *bufio.Reader) error  { return nil }
+func isELF(head []byte, _ string) bool                { return false }
+func previewELF(w io.Writer, r *bufio.Reader) error   { return nil }
+func isMachO(head []byte, _ string) bool              { return false }
+func previewMachO(w io.Writer, r *bufio.Reader) error { return nil }
+func isPE(head []byte, _ string) bool                 { return false }
+func previewPE(w io.Writer, r *bufio.Reader) error    { return nil }
+func isPEM(head []byte, _ string) bool                { return false }
+func previewPEM(w io.Writer, r *bufio.Reader) error   { return nil }
+
+func setupProto(desc, typ string, delimited bool) error {
+	return featureError("--proto-desc", "protocol buffers")
+}
+
+func newProtoReader(r io.Reader) (io.Reader, error) {
+	return nil, featureError("proto", "protocol buffers")
+}
+
+func newMailReader(r io.Reader) (io.Reader, error) {
+	return nil, featureError("mail", "e-mail messages")
+}
+
+// verifySignature still passes inputs through if no key is given.
+func verifySignature(name, src string, r io.Reader) (io.Reader, error) {
+	if opts.minisignKey == "" && opts.sshKey == "" {
+		return r, nil
+	}
+	return nil, featureError(name, "signature checks")
+}
+
+var templateData interface{}
+
+func loadTemplateData(path string) error { return featureError("--data", "templates") }
+
+func renderTemplate(name string, r io.Reader) (io.Reader, error) {
+	return nil, featureError(name, "templates")
+}
+
+func startCPUProfile(path string) (stop func(), err error) {
+	return nil, featureError("--profile", "CPU profiles")
+}
+
+// publisher, kafkaProducer and syslogWriter are the sinks of
+// --publish, --kafka and --syslog, which cannot be created.
+type publisher interface {
+	send(msg []byte) error
+	Close() error
+}
+
+func dialPublisher(rawurl string) (publisher, error) {
+	return nil, featureError("--publish", "NATS and MQTT clients")
+}
+
+type kafkaProducer struct{}
+
+func newKafkaProducer(target, key string, partition int, batchSize int64, linger time.Duration) (*kafkaProducer, error) {
+	return nil, featureError("--kafka", "Kafka producers")
+}
+func (p *kafkaProducer) send(msg []byte) error { return nil }
+func (p *kafkaProducer) Close() error          { return nil }
+
+type syslogWriter struct{}
+
+func dialSyslog(addr, facility, severity, tag string) (*syslogWriter, error) {
+	return nil, featureError("--syslog", "syslog clients")
+}
+func (w *syslogWriter) send(line []byte) error { return nil }
+func (w *syslogWriter) Close() error           { return nil }
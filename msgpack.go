@@ -0,0 +1,221 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// maxNesting limits the depth of the arrays and maps of binary inputs,
+// so that a malicious input cannot exhaust the stack.
+const maxNesting = 1000
+
+var errNesting = errors.New("too deeply nested")
+
+// newMsgpackReader decodes a stream of MessagePack values as JSON.
+func newMsgpackReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	return &jsonReader{name: "msgpack", next: func() (interface{}, error) {
+		if _, err := br.Peek(1); err != nil {
+			return nil, err
+		}
+		return readMsgpack(br, 0)
+	}}, nil
+}
+
+// readMsgpack reads a single MessagePack value. Binary data is shown
+// in base64, and extension types other than timestamps as an object
+// of their type and data.
+func readMsgpack(r *bufio.Reader, depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, errNesting
+	}
+	c, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return readMsgpackMap(r, uint64(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return readMsgpackArray(r, uint64(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		b, err := readN(r, uint64(c&0x1f))
+		return string(b), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readUint(r, 1<<(c-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := readN(r, n)
+		return base64.StdEncoding.EncodeToString(b), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readUint(r, 1<<(c-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackExt(r, n)
+	case 0xca:
+		v, err := readUint(r, 4)
+		return jsonFloat(float64(math.Float32frombits(uint32(v)))), err
+	case 0xcb:
+		v, err := readUint(r, 8)
+		return jsonFloat(math.Float64frombits(v)), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readUint(r, 1<<(c-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := readUint(r, size)
+		shift := 64 - 8*size
+		return int64(v<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgpackExt(r, 1<<(c-0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := readUint(r, 1<<(c-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		b, err := readN(r, n)
+		return string(b), err
+	case 0xdc, 0xdd:
+		n, err := readUint(r, 2<<(c-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackArray(r, n, depth)
+	case 0xde, 0xdf:
+		n, err := readUint(r, 2<<(c-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgpackMap(r, n, depth)
+	}
+	return nil, fmt.Errorf("invalid MessagePack type 0x%02x", c)
+}
+
+func readMsgpackArray(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	a := []interface{}{}
+	for i := uint64(0); i < n; i++ {
+		v, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func readMsgpackMap(r *bufio.Reader, n uint64, depth int) (interface{}, error) {
+	m := jsonObject{}
+	for i := uint64(0); i < n; i++ {
+		k, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := readMsgpack(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, jsonMember{jsonKey(k), v})
+	}
+	return m, nil
+}
+
+// readMsgpackExt reads the type and data of an extension value.
+func readMsgpackExt(r *bufio.Reader, n uint64) (interface{}, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	b, err := readN(r, n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) == -1 { // timestamp
+		var t time.Time
+		switch len(b) {
+		case 4:
+			t = time.Unix(int64(binary.BigEndian.Uint32(b)), 0)
+		case 8:
+			v := binary.BigEndian.Uint64(b)
+			t = time.Unix(int64(v&(1<<34-1)), int64(v>>34))
+		case 12:
+			t = time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b)))
+		}
+		if !t.IsZero() {
+			return t.UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+	return jsonObject{
+		{"type", int8(typ)},
+		{"data", base64.StdEncoding.EncodeToString(b)},
+	}, nil
+}
+
+// readUint reads a big endian unsigned integer of the given size.
+func readUint(r io.Reader, size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// readN reads n bytes, without trusting n for the size of the buffer.
+func readN(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)) != n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+// unexpectedEOF turns io.EOF in the middle of a value into an error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// jsonKey returns the key of a JSON object for a map key of a binary
+// format, which does not need to be a string.
+func jsonKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(k)
+	if err != nil {
+		return fmt.Sprint(k)
+	}
+	return string(b)
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMsgpackReader(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte{0x2a}, "42"},
+		{[]byte{0xff}, "-1"},
+		{[]byte{0xd1, 0xfc, 0x18}, "-1000"},
+		{[]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "18446744073709551615"},
+		{[]byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, "1.5"},
+		{[]byte{0xc0}, "null"},
+		{[]byte{0xc4, 0x02, 0x01, 0x02}, `"AQI="`},
+		{[]byte{0x92, 0xc3, 0xa1, 'x'}, "[\n  true,\n  \"x\"\n]"},
+		{[]byte{0x82, 0xa1, 'b', 0x01, 0x07, 0xc2}, "{\n  \"b\": 1,\n  \"7\": false\n}"},
+		{[]byte{0xd6, 0xff, 0, 0, 0, 1}, `"1970-01-01T00:00:01Z"`},
+		{[]byte{0xd4, 0x05, 0x09}, "{\n  \"type\": 5,\n  \"data\": \"CQ==\"\n}"},
+	}
+	for _, tt := range tests {
+		r, _ := newMsgpackReader(bytes.NewReader(tt.in))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%x: unexpected error: %v", tt.in, err)
+		}
+		if string(got) != tt.want+"\n" {
+			t.Errorf("%x: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range [][]byte{{0x92, 0x01}, {0xc1}, {0xdb, 0xff, 0xff, 0xff, 0xff}} {
+		r, _ := newMsgpackReader(bytes.NewReader(in))
+		if _, err := io.ReadAll(r); err == nil {
+			t.Errorf("%x: expect an error", in)
+		}
+	}
+}
+
+func TestMainDecode(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "values")
+	os.WriteFile(in, []byte{0x01, 0x91, 0x02}, 0644)
+	if got, want := runMain("--decode=msgpack", in), "1\n[\n  2\n]\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+	os.WriteFile(in, []byte{0x01, 0x20, 0x82}, 0644)
+	if got, want := runMain("cbor:"+in), "1\n-1\ncat: cbor: unexpected EOF\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// setBlocking does nothing, as the system either has no non-blocking
+// mode of files that is inherited, or does not expose it.
+func setBlocking(f *os.File) bool { return false }
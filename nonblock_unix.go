@@ -0,0 +1,36 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// setBlocking switches a file that was inherited in non-blocking mode
+// back to blocking mode, and reports whether it had to.
+//
+// The mode belongs to the open file that is shared with the parent
+// process and its other children, so a tool that exits without undoing
+// it leaves the terminal or pipe non-blocking for everyone after it.
+// Writes to it then fail with EAGAIN when its buffer is full, which
+// only shows with large outputs.
+func setBlocking(f *os.File) bool {
+	c, err := f.SyscallConn()
+	if err != nil {
+		return false
+	}
+	changed := false
+	c.Control(func(fd uintptr) {
+		flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+		if errno != 0 || flags&syscall.O_NONBLOCK == 0 {
+			return
+		}
+		changed = syscall.SetNonblock(int(fd), false) == nil
+	})
+	return changed
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestSetBlocking(t *testing.T) {
+	// Unlike those of os.Pipe, the ends of the pipe start blocking.
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	r, w := os.NewFile(uintptr(p[0]), "r"), os.NewFile(uintptr(p[1]), "w")
+	defer r.Close()
+	defer w.Close()
+
+	if setBlocking(w) {
+		t.Fatalf("expect a new pipe to be blocking")
+	}
+	c, _ := w.SyscallConn()
+	c.Control(func(fd uintptr) { syscall.SetNonblock(int(fd), true) })
+	if !setBlocking(w) {
+		t.Fatalf("expect a non-blocking pipe to be switched")
+	}
+	if setBlocking(w) {
+		t.Fatalf("expect the pipe to stay blocking")
+	}
+}
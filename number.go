@@ -0,0 +1,35 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "strconv"
+
+// lineNumbers numbers the output lines of -n as GNU cat does, with the
+// number right-aligned in six columns and a tab before each line. The
+// numbers continue from one input to the next, and so does a last line
+// without a newline, which is not numbered again.
+type lineNumbers struct {
+	n   int  // the number of the last line
+	mid bool // whether the last line is yet to end
+}
+
+// numbers are the line numbers of the run, which run resets.
+var numbers *lineNumbers
+
+func (l *lineNumbers) number(line []byte) []byte {
+	if l.mid {
+		l.mid = line[len(line)-1] != '\n'
+		return line
+	}
+	l.n++
+	l.mid = line[len(line)-1] != '\n'
+	num := strconv.Itoa(l.n)
+	b := make([]byte, 0, 7+len(num)+len(line))
+	for i := len(num); i < 6; i++ {
+		b = append(b, ' ')
+	}
+	b = append(append(b, num...), '\t')
+	return append(b, line...)
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLineNumbers(t *testing.T) {
+	l := &lineNumbers{}
+	var b strings.Builder
+	for _, in := range []string{"a\nb", "c\n\n", "", "d"} {
+		out, _ := io.ReadAll(newLineTransformer(strings.NewReader(in), l.number))
+		b.Write(out)
+	}
+	if want := "     1\ta\n     2\tbc\n     3\t\n     4\td"; b.String() != want {
+		t.Fatalf("unexpected output: got %q, want %q", b.String(), want)
+	}
+
+	l = &lineNumbers{n: 999999}
+	if got := string(l.number([]byte("x\n"))); got != "1000000\tx\n" {
+		t.Fatalf("unexpected line %q", got)
+	}
+}
+
+func TestMainNumber(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("one\ntwo"), 0644)
+	os.WriteFile(b, []byte("three\n\nfour\n"), 0644)
+
+	want := "     1\tone\n     2\ttwothree\n     3\t\n     4\tfour\n"
+	for _, flag := range []string{"-n", "--number"} {
+		if got := runMain(flag, a, b); got != want {
+			t.Fatalf("%s: unexpected output: got %q, want %q", flag, got, want)
+		}
+	}
+	// Every run starts from one.
+	if got := runMain("-n", b); got != "     1\tthree\n     2\t\n     3\tfour\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	// The lines that are written are numbered.
+	if got := runMain("-n", "--strip-blank-lines", b); got != "     1\tthree\n     2\tfour\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	// The pretty-printed lines are numbered.
+	j := filepath.Join(dir, "c.json")
+	os.WriteFile(j, []byte(`{"a":1}`), 0644)
+	if got := runMain("-n", "--pretty", j); got != "     1\t{\n     2\t  \"a\": 1\n     3\t}\n" {
+		t.Fatalf("unexpected output %q", got)
+	}
+	// HTML and PDF number their lines themselves.
+	for _, format := range []string{"html", "pdf"} {
+		if got, want := runMain("-n", "--format="+format, b), runMain("--format="+format, b); got != want {
+			t.Fatalf("--format=%s: lines are numbered twice", format)
+		}
+	}
+}
+
+func FuzzLineNumbers(f *testing.F) {
+	f.Add("a\nb", "\n\nc\n")
+	f.Add("", "\r\n")
+	prefix := regexp.MustCompile(`(?m)^ *[0-9]+\t`)
+	f.Fuzz(func(t *testing.T, a, b string) {
+		l := &lineNumbers{}
+		var out []byte
+		for _, in := range []string{a, b} {
+			o, _ := io.ReadAll(newLineTransformer(strings.NewReader(in), l.number))
+			out = append(out, o...)
+		}
+		// Without the numbers, the output is the input, with as many
+		// numbers as it has lines.
+		in := a + b
+		if got := prefix.ReplaceAllString(string(out), ""); got != in {
+			t.Fatalf("output %q of %q, %q", out, a, b)
+		}
+		lines := strings.Count(in, "\n")
+		if !strings.HasSuffix(in, "\n") && in != "" {
+			lines++
+		}
+		if l.n != lines {
+			t.Fatalf("%d numbers for %d lines of %q", l.n, lines, in)
+		}
+	})
+}
@@ -0,0 +1,402 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+)
+
+func init() { registerFeature("oci") }
+
+// ociRef is a file in an image of a registry.
+type ociRef struct {
+	registry string // the host of the API
+	repo     string
+	ref      string // a tag or a digest
+	path     string // of the file, without the leading /
+}
+
+// parseOCIRef parses [registry/]repository[:tag|@digest]:/path, where
+// images without a registry are on Docker Hub, like docker pull.
+func parseOCIRef(src string) (ociRef, error) {
+	s := strings.TrimPrefix(src, "oci://")
+	i := strings.Index(s, ":/")
+	if i <= 0 {
+		return ociRef{}, errors.New("expected image:/path")
+	}
+	image, p := s[:i], s[i+1:]
+	r := ociRef{registry: "registry-1.docker.io", ref: "latest"}
+	if p, ok := memberPath(p); ok {
+		r.path = p
+	} else {
+		return ociRef{}, errors.New("Is a directory")
+	}
+
+	if j := strings.IndexByte(image, '/'); j > 0 && (strings.ContainsAny(image[:j], ".:") || image[:j] == "localhost") {
+		r.registry, image = image[:j], image[j+1:]
+	} else if !strings.Contains(image, "/") {
+		image = "library/" + image
+	}
+	if j := strings.IndexByte(image, '@'); j >= 0 {
+		image, r.ref = image[:j], image[j+1:]
+	} else if j := strings.LastIndexByte(image, ':'); j > strings.LastIndexByte(image, '/') {
+		image, r.ref = image[:j], image[j+1:]
+	}
+	if image == "" || r.ref == "" {
+		return ociRef{}, errors.New("invalid image reference")
+	}
+	r.repo = image
+	return r, nil
+}
+
+// ociClient is a client of the distribution API of a registry for a
+// repository, which gets an anonymous token when the registry asks
+// for one.
+type ociClient struct {
+	base  string
+	repo  string
+	token string
+}
+
+func newOCIClient(r ociRef) *ociClient {
+	scheme := "https://"
+	host := r.registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		scheme = "http://" // like docker, which trusts local registries
+	}
+	return &ociClient{base: scheme + r.registry, repo: r.repo}
+}
+
+func (c *ociClient) get(kind, ref string, accept ...string) (*http.Response, error) {
+	u := c.base + "/v2/" + c.repo + "/" + kind + "/" + ref
+	for retried := false; ; retried = true {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accept {
+			req.Header.Add("Accept", a)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && !retried {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if c.token, err = ociToken(challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s %s: %s", kind, ref, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// ociToken gets an anonymous token as asked for by the challenge of a
+// registry, see https://distribution.github.io/distribution/spec/auth/token/.
+func ociToken(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", errors.New("the registry asks for credentials")
+	}
+	params := map[string]string{}
+	for _, kv := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			params[strings.TrimSpace(kv[:i])] = strings.Trim(kv[i+1:], `"`)
+		}
+	}
+	u, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", errors.New("invalid authentication challenge of the registry")
+	}
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			q.Set(k, params[k])
+		}
+	}
+	u.RawQuery = q.Encode()
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token: %s", resp.Status)
+	}
+	var t struct{ Token, AccessToken string }
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	return t.Token, nil
+}
+
+var ociManifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+type ociManifest struct {
+	Manifests []struct {
+		Digest   string
+		Platform struct{ Architecture, OS string }
+	}
+	Layers []ociLayer
+}
+
+type ociLayer struct {
+	MediaType string
+	Digest    string
+}
+
+// layers returns the layers of the image for Linux on the architecture
+// of the machine, from the bottom up.
+func (c *ociClient) layers(ref string) ([]ociLayer, error) {
+	for i := 0; i < 2; i++ {
+		resp, err := c.get("manifests", ref, ociManifestTypes...)
+		if err != nil {
+			return nil, err
+		}
+		// Manifests of a digest are checked against it, those of a tag
+		// can be anything.
+		var r io.Reader = resp.Body
+		if strings.Contains(ref, ":") {
+			if r, err = newDigestReader(r, ref); err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("manifest %s: %v", ref, err)
+			}
+		}
+		b, err := io.ReadAll(r)
+		resp.Body.Close()
+		var m ociManifest
+		if err == nil {
+			err = json.Unmarshal(b, &m)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("manifest %s: %v", ref, err)
+		}
+		if len(m.Manifests) == 0 {
+			return m.Layers, nil
+		}
+		found := false
+		for _, d := range m.Manifests {
+			if d.Platform.OS == "linux" && d.Platform.Architecture == runtime.GOARCH {
+				ref, found = d.Digest, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no image for linux/%s", runtime.GOARCH)
+		}
+	}
+	return nil, errors.New("nested image indexes")
+}
+
+// openOCI reads a file of an image. Layers are read from the top down,
+// up to the one that has the file, so the layers below it are never
+// downloaded.
+func openOCI(src string) (io.ReadCloser, error) {
+	r, err := parseOCIRef(src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	c := newOCIClient(r)
+	layers, err := c.layers(r.ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", src, err)
+	}
+	p := r.path
+	for links := 0; links < 40; links++ {
+		var rc io.ReadCloser
+		rc, p, err = c.find(layers, p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", src, err)
+		}
+		if rc != nil {
+			return rc, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: Too many levels of symbolic links", src)
+}
+
+// find looks for a file in the layers. It returns the file, or the path
+// to look for instead if the file or a directory above it is a link.
+func (c *ociClient) find(layers []ociLayer, p string) (io.ReadCloser, string, error) {
+	for i := len(layers) - 1; i >= 0; i-- {
+		rc, err := c.openLayer(layers[i])
+		if err != nil {
+			return nil, "", err
+		}
+		tr := tar.NewReader(rc)
+		opaque := false // a directory above is replaced by this layer
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rc.Close()
+				return nil, "", fmt.Errorf("layer %s: %v", layers[i].Digest, err)
+			}
+			name, ok := memberPath(h.Name)
+			if !ok {
+				continue
+			}
+			dir, base := path.Dir(name), path.Base(name)
+			switch {
+			case base == ".wh..wh..opq" && strings.HasPrefix(p, dir+"/"):
+				opaque = true
+			case strings.HasPrefix(base, ".wh.") && ociCovers(path.Join(dir, base[4:]), p):
+				return nil, "", ociDone(rc, errors.New("No such file or directory"))
+			case name == p && h.Typeflag == tar.TypeReg:
+				return &ociFile{tr: tr, layer: rc}, "", nil
+			case name == p && h.Typeflag == tar.TypeDir:
+				return nil, "", ociDone(rc, errors.New("Is a directory"))
+			case name == p && h.Typeflag == tar.TypeLink:
+				target, _ := memberPath(h.Linkname)
+				return nil, target, ociDone(rc, nil)
+			case ociCovers(name, p) && h.Typeflag == tar.TypeSymlink:
+				target := h.Linkname
+				if !path.IsAbs(target) {
+					target = path.Join(dir, target)
+				}
+				target, _ = memberPath(target)
+				return nil, target + p[len(name):], ociDone(rc, nil)
+			}
+		}
+		if err := ociDone(rc, nil); err != nil {
+			return nil, "", err
+		}
+		if opaque {
+			break
+		}
+	}
+	return nil, "", errors.New("No such file or directory")
+}
+
+// ociCovers reports whether a path is the path p or a directory above it.
+func ociCovers(name, p string) bool {
+	return name == p || strings.HasPrefix(p, name+"/")
+}
+
+// ociDone reads the rest of a layer, so that its digest is checked
+// before anything that was found in it is trusted, and closes it.
+func ociDone(layer io.ReadCloser, err error) error {
+	_, rerr := io.Copy(io.Discard, layer)
+	layer.Close()
+	if rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// ociFile is a file of a layer, which reads the rest of the layer at
+// its end, and fails if the layer does not match its digest.
+type ociFile struct {
+	tr    *tar.Reader
+	layer io.ReadCloser
+}
+
+func (f *ociFile) Read(p []byte) (int, error) {
+	n, err := f.tr.Read(p)
+	if err == io.EOF {
+		if _, rerr := io.Copy(io.Discard, f.layer); rerr != nil {
+			err = rerr
+		}
+	}
+	return n, err
+}
+
+func (f *ociFile) Close() error { return f.layer.Close() }
+
+// openLayer opens a layer as a tar, which fails at its end if the blob
+// does not match its digest.
+func (c *ociClient) openLayer(l ociLayer) (io.ReadCloser, error) {
+	resp, err := c.get("blobs", l.Digest)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := newDigestReader(resp.Body, l.Digest)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("layer %s: %v", l.Digest, err)
+	}
+	switch {
+	case strings.HasSuffix(l.MediaType, "gzip"):
+		zr, err := gzip.NewReader(blob)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("layer %s: %v", l.Digest, err)
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{zr, resp.Body}, nil
+	case strings.HasSuffix(l.MediaType, ".tar"):
+		return struct {
+			io.Reader
+			io.Closer
+		}{blob, resp.Body}, nil
+	}
+	resp.Body.Close()
+	return nil, fmt.Errorf("layer %s: unsupported media type %s", l.Digest, l.MediaType)
+}
+
+// digestReader reads a blob, and fails at its end if the blob does not
+// match its digest, as a registry or a proxy may serve anything.
+type digestReader struct {
+	r      io.Reader
+	h      hash.Hash
+	digest string
+}
+
+func newDigestReader(r io.Reader, digest string) (*digestReader, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("unsupported digest %s", digest)
+	}
+	return &digestReader{r: r, h: sha256.New(), digest: digest}, nil
+}
+
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	d.h.Write(p[:n])
+	if err == io.EOF {
+		if sum := "sha256:" + hex.EncodeToString(d.h.Sum(nil)); sum != d.digest {
+			return n, fmt.Errorf("blob %s: the content has the digest %s", d.digest, sum)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseOCIRef(t *testing.T) {
+	tests := []struct {
+		src  string
+		want ociRef
+	}{
+		{"oci://alpine:/etc/os-release", ociRef{"registry-1.docker.io", "library/alpine", "latest", "etc/os-release"}},
+		{"oci://nginx/nginx:1.25:/etc/nginx/nginx.conf", ociRef{"registry-1.docker.io", "nginx/nginx", "1.25", "etc/nginx/nginx.conf"}},
+		{"oci://ghcr.io/o/app@sha256:abc:/app/config.yml", ociRef{"ghcr.io", "o/app", "sha256:abc", "app/config.yml"}},
+		{"oci://localhost:5000/app:v1:/x", ociRef{"localhost:5000", "app", "v1", "x"}},
+	}
+	for _, tt := range tests {
+		got, err := parseOCIRef(tt.src)
+		if err != nil || got != tt.want {
+			t.Errorf("parseOCIRef(%q) = %+v, %v, want %+v", tt.src, got, err, tt.want)
+		}
+	}
+}
+
+// layer returns a gzipped tar of the given entries, where a name ending
+// in / is a directory and content starting with -> is a symbolic link.
+func layer(t *testing.T, entries ...string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for i := 0; i < len(entries); i += 2 {
+		name, content := entries[i], entries[i+1]
+		h := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		switch {
+		case strings.HasSuffix(name, "/"):
+			h.Typeflag, h.Size = tar.TypeDir, 0
+		case strings.HasPrefix(content, "->"):
+			h.Typeflag, h.Size, h.Linkname = tar.TypeSymlink, 0, content[2:]
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+// digest returns the digest of a blob.
+func digest(b []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+}
+
+func TestMainOCI(t *testing.T) {
+	blobs := map[string][]byte{
+		"base": layer(t,
+			"etc/", "",
+			"etc/os-release", "->../usr/lib/os-release",
+			"usr/lib/os-release", "NAME=Base\n",
+			"etc/motd", "hello\n",
+			"etc/issue", "base issue\n",
+			"var/log/old.log", "old\n",
+			"lib", "->usr/lib",
+		),
+		"top": layer(t,
+			"etc/.wh.motd", "",
+			"etc/issue", "top issue\n",
+			"var/log/.wh..wh..opq", "",
+		),
+	}
+	// The blobs are served by their digest, and pulled by their name.
+	names := map[string]string{}
+	for name, b := range blobs {
+		names[digest(b)] = name
+	}
+	// The digest of the bad blob is that of another.
+	blobs["bad"] = layer(t, "etc/issue", "bad issue\n")
+	names["sha256:bad"] = "bad"
+	manifest := func(layers ...string) []byte {
+		var l []map[string]string
+		for _, name := range layers {
+			d := digest(blobs[name])
+			if name == "bad" {
+				d = "sha256:bad"
+			}
+			l = append(l, map[string]string{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": d})
+		}
+		b, _ := json.Marshal(map[string]interface{}{"layers": l})
+		return b
+	}
+	image := manifest("base", "top")
+	index, _ := json.Marshal(map[string]interface{}{
+		"manifests": []map[string]interface{}{
+			{"digest": "sha256:other", "platform": map[string]string{"os": "windows", "architecture": runtime.GOARCH}},
+			{"digest": digest(image), "platform": map[string]string{"os": "linux", "architecture": runtime.GOARCH}},
+		},
+	})
+	bad := manifest("base", "bad")
+
+	var mu sync.Mutex
+	var pulled []string
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:app:pull" {
+				t.Errorf("unexpected token request %s", r.URL)
+			}
+			w.Write([]byte(`{"token":"t0k"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:app:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch p := r.URL.Path; {
+		case p == "/v2/app/manifests/v1":
+			w.Write(index)
+		case p == "/v2/app/manifests/bad":
+			w.Write(bad)
+		case p == "/v2/app/manifests/"+digest(image):
+			w.Write(image)
+		case p == "/v2/app/manifests/"+digest(index):
+			w.Write(bad) // not the manifest of the digest
+		case strings.HasPrefix(p, "/v2/app/blobs/") && names[strings.TrimPrefix(p, "/v2/app/blobs/")] != "":
+			name := names[strings.TrimPrefix(p, "/v2/app/blobs/")]
+			mu.Lock()
+			pulled = append(pulled, name)
+			mu.Unlock()
+			w.Write(blobs[name])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo := "oci://" + strings.TrimPrefix(srv.URL, "http://") + "/app"
+	v1 := repo + ":v1:"
+	tests := []struct {
+		src, want string
+		pulled    string
+	}{
+		{v1 + "/etc/issue", "top issue\n", "[top]"},
+		{v1 + "/etc/os-release", "NAME=Base\n", "[top base top base]"},
+		{v1 + "/lib/os-release", "NAME=Base\n", "[top base top base]"},
+		{v1 + "/etc/motd", "cat: " + v1 + "/etc/motd: No such file or directory\n", "[top]"},
+		{v1 + "/var/log/old.log", "cat: " + v1 + "/var/log/old.log: No such file or directory\n", "[top]"},
+		{v1 + "/etc", "cat: " + v1 + "/etc: Is a directory\n", "[top base]"},
+		{repo + "@" + digest(image) + ":/etc/issue", "top issue\n", "[top]"},
+		// Blobs and manifests that do not match their digest fail, after
+		// what was read of them.
+		{repo + ":bad:/etc/issue", "bad issue\ncat: blob sha256:bad: the content has the digest " + digest(blobs["bad"]) + "\n", "[bad]"},
+		{repo + ":bad:/etc/motd", "cat: " + repo + ":bad:/etc/motd: blob sha256:bad: the content has the digest " + digest(blobs["bad"]) + "\n", "[bad]"},
+		{repo + "@" + digest(index) + ":/etc/issue", "cat: " + repo + "@" + digest(index) + ":/etc/issue: manifest " + digest(index) + ": blob " + digest(index) + ": the content has the digest " + digest(bad) + "\n", "[]"},
+	}
+	for _, tt := range tests {
+		pulled = nil
+		if out := runMain(tt.src); out != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.src, out, tt.want)
+		}
+		if fmt.Sprint(pulled) != tt.pulled {
+			t.Errorf("%s: pulled %v, want %v", tt.src, pulled, tt.pulled)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// clobber decides what happens if the output file exists already.
+type clobber int
+
+const (
+	clobberOverwrite clobber = iota // truncate the file, the default
+	clobberNever                    // fail, as --no-clobber
+	clobberForce                    // replace it even if it is not writable, as --force
+	clobberAsk                      // ask on the terminal, as --interactive
+)
+
+// clobberFlag is one of the flags that set the clobber mode. As with
+// cp, the one given last wins.
+type clobberFlag struct {
+	mode  *clobber
+	value clobber
+}
+
+func (f clobberFlag) IsBoolFlag() bool { return true }
+func (f clobberFlag) String() string   { return "false" }
+
+func (f clobberFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on {
+		*f.mode = f.value
+	} else if *f.mode == f.value {
+		*f.mode = clobberOverwrite
+	}
+	return nil
+}
+
+// syslogFlag is the address of --syslog, which may be given without a
+// value for the local syslog daemon.
+type syslogFlag struct {
+	addr string
+	set  bool
+}
+
+func (f *syslogFlag) String() string { return f.addr }
+
+func (f *syslogFlag) IsBoolFlag() bool { return true }
+
+func (f *syslogFlag) Set(v string) error {
+	switch v {
+	case "true":
+		f.addr, f.set = "", true
+	case "false":
+		f.addr, f.set = "", false
+	default:
+		f.addr, f.set = v, true
+	}
+	return nil
+}
+
+// outputFile is the file the output is written to instead of stdout.
+// In atomic mode, the output goes to a temporary file next to it that
+// replaces the file only once all output was written successfully.
+type outputFile struct {
+	*os.File
+	path string
+	tmp  string // the temporary file in atomic mode
+}
+
+// Close closes the output file and, in atomic mode, moves it into
+// place. With --sync, the content and the directory entry are flushed
+// to stable storage first, so that the file survives a crash right
+// after cat exits.
+func (o *outputFile) Close() error {
+	if opts.sync {
+		if err := o.Sync(); err != nil {
+			o.discard()
+			return fmt.Errorf("%s: %v", o.path, unwrapPathError(err))
+		}
+	}
+	if err := o.File.Close(); err != nil {
+		o.discard()
+		return fmt.Errorf("%s: %v", o.path, unwrapPathError(err))
+	}
+	if o.tmp != "" {
+		if err := commitTemp(o.tmp, o.path); err != nil {
+			return err
+		}
+	}
+	if opts.sync {
+		return syncDir(filepath.Dir(o.path))
+	}
+	return nil
+}
+
+// Abort closes the output file after a failure. The partial output is
+// kept, unless in atomic mode where the destination stays untouched.
+func (o *outputFile) Abort() error {
+	if o.tmp == "" {
+		return o.Close()
+	}
+	o.discard()
+	return nil
+}
+
+// discard closes the output file and removes the temporary file.
+func (o *outputFile) discard() {
+	o.File.Close()
+	if o.tmp != "" {
+		removeTemp(o.tmp)
+	}
+}
+
+// syncDir flushes the entries of a directory to stable storage. This
+// is needed for a new file to be durable, the file's own sync is not
+// enough.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil // directories cannot be opened for writing on Windows
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("%s: %v", dir, unwrapPathError(err))
+	}
+	return nil
+}
+
+// checkOutput fails like GNU cat if an input is the file that -o
+// writes, as truncating it would lose the input before it is read. The
+// standard input counts if no inputs are given, or for "-".
+func checkOutput(path string, inputs []string) error {
+	out, err := os.Stat(longPath(path))
+	if err != nil {
+		return nil
+	}
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	for _, in := range inputs {
+		var i fs.FileInfo
+		if in == "-" {
+			i, err = os.Stdin.Stat()
+		} else {
+			i, err = os.Stat(longPath(in))
+		}
+		if err == nil && i.Mode().IsRegular() && os.SameFile(i, out) {
+			return fmt.Errorf("%s: input file is output file", in)
+		}
+	}
+	return nil
+}
+
+// createOutput creates or truncates the file the output is written to
+// instead of stdout, so that files can be created without any shell
+// redirection, e.g. in minimal containers.
+func createOutput(path string) (*outputFile, error) {
+	if opts.clobber == clobberAsk {
+		if _, err := os.Lstat(longPath(path)); err == nil && !confirm("overwrite '%s'?", path) {
+			return nil, fmt.Errorf("%s: not overwritten", path)
+		}
+	}
+	mode := fs.FileMode(0666)
+	if opts.mode.set {
+		mode = opts.mode.perm
+	}
+
+	o := &outputFile{path: path}
+	var err error
+	if opts.atomic {
+		if _, serr := os.Lstat(longPath(path)); serr == nil && opts.clobber == clobberNever {
+			return nil, fmt.Errorf("%s: File exists", path)
+		}
+		o.File, o.tmp, err = createTemp(path, mode)
+	} else {
+		o.File, err = openOutput(path, mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.owner != "" || opts.group != "" {
+		if err := chown(o.File, opts.owner, opts.group); err != nil {
+			o.Abort()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	// The mode given to open is reduced by the umask and ignored for
+	// existing files, but an explicit mode is meant exactly. It is set
+	// after the owner as changing the owner may clear setuid bits. A
+	// file that is replaced atomically keeps its permissions, as it
+	// does when it is overwritten in place.
+	perm, chmod := opts.mode.perm, opts.mode.set
+	if !chmod && o.tmp != "" {
+		if i, err := os.Stat(longPath(path)); err == nil {
+			perm, chmod = i.Mode().Perm(), true
+		}
+	}
+	if chmod {
+		if err := o.Chmod(perm); err != nil {
+			o.Abort()
+			return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+		}
+	}
+	return o, nil
+}
+
+// openOutput opens the output file for writing in place.
+func openOutput(path string, mode fs.FileMode) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if opts.clobber == clobberNever {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(longPath(path), flags, mode)
+	if err != nil && opts.clobber == clobberForce && errors.Is(err, fs.ErrPermission) {
+		// Like cp -f, remove a file that cannot be opened for writing
+		// and try again.
+		if os.Remove(longPath(path)) == nil {
+			f, err = os.OpenFile(longPath(path), flags, mode)
+		}
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%s: File exists", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	return f, nil
+}
+
+// chown changes the owner and group of a file, each given by name or
+// by numeric id. An empty owner or group is left as it is.
+func chown(f *os.File, owner, group string) error {
+	uid, gid := -1, -1
+	if owner != "" {
+		id, err := lookupID(owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return err
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := lookupID(group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return err
+		}
+		gid = id
+	}
+	return unwrapPathError(f.Chown(uid, gid))
+}
+
+// lookupID resolves a user or group name to its numeric id, using the
+// given lookup function unless the name is numeric already.
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return id, nil
+	}
+	s, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		// e.g. a security identifier on Windows
+		return 0, fmt.Errorf("%s: numeric id required, got %s", name, s)
+	}
+	return id, nil
+}
+
+// fileMode is an octal permission flag such as --mode=0644.
+type fileMode struct {
+	perm fs.FileMode
+	set  bool
+}
+
+func (m *fileMode) String() string {
+	if !m.set {
+		return ""
+	}
+	return fmt.Sprintf("%#o", m.perm)
+}
+
+func (m *fileMode) Set(s string) error {
+	perm, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || perm > 0777 {
+		return fmt.Errorf("invalid mode %q", s)
+	}
+	m.perm, m.set = fs.FileMode(perm), true
+	return nil
+}
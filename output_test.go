@@ -0,0 +1,151 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMainWrite(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "out.txt")
+
+	if got := runMain("--write", dst, "--mode=0600", "testdata/b.md", "testdata/b.md"); got != "" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil || string(b) != "worldworld" {
+		t.Fatalf("unexpected content: %q, %v", b, err)
+	}
+	if i, _ := os.Stat(dst); runtime.GOOS != "windows" && i.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected mode: %v", i.Mode())
+	}
+
+	if got := runMain("-o", dst, "--sync", "testdata/b.md"); got != "" {
+		t.Fatalf("unexpected output with --sync: %q", got)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "world" {
+		t.Fatalf("unexpected content: %q", b)
+	}
+	runMain("-o", dst, "testdata/b.md", "testdata/b.md")
+
+	want := "cat: " + dst + ": File exists\n"
+	if got := runMain("--write", dst, "--no-clobber", "testdata/a.txt"); got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "worldworld" {
+		t.Fatalf("file was clobbered: %q", b)
+	}
+}
+
+func TestMainWriteInput(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(dst, []byte("keep me\n"), 0644)
+
+	want := "cat: " + dst + ": input file is output file\n"
+	if out, code := runMainCode("-o", dst, "testdata/b.md", dst); out != want || code != exitFailed {
+		t.Fatalf("unexpected output %q and exit code %d", out, code)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "keep me\n" {
+		t.Fatalf("input was truncated: %q", b)
+	}
+	if got := runMain("-o", dst, "--atomic", dst, dst); got != "" {
+		t.Fatalf("unexpected output with --atomic: %q", got)
+	}
+	if b, _ := os.ReadFile(dst); string(b) != "keep me\nkeep me\n" {
+		t.Fatalf("unexpected content with --atomic: %q", b)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	var m fileMode
+	for _, s := range []string{"999", "1777", "rw"} {
+		if err := m.Set(s); err == nil {
+			t.Errorf("expect mode %q to be invalid", s)
+		}
+	}
+	if err := m.Set("0640"); err != nil || m.perm != 0640 || m.String() != "0640" {
+		t.Fatalf("unexpected mode: %v, %v", m.String(), err)
+	}
+}
+
+func TestClobber(t *testing.T) {
+	defer func() { opts = options{} }()
+	oldTTY := openTTY
+	defer func() { openTTY = oldTTY }()
+
+	// The flag given last wins.
+	fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+	fs.Var(clobberFlag{&opts.clobber, clobberNever}, "no-clobber", "")
+	fs.Var(clobberFlag{&opts.clobber, clobberForce}, "force", "")
+	fs.Var(clobberFlag{&opts.clobber, clobberAsk}, "interactive", "")
+	if err := fs.Parse([]string{"--interactive", "--no-clobber", "--force"}); err != nil {
+		t.Fatalf("failed to parse flags: %v", err)
+	}
+	if opts.clobber != clobberForce {
+		t.Fatalf("unexpected clobber mode: %v", opts.clobber)
+	}
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	os.WriteFile(dst, []byte("old"), 0644)
+
+	opts.clobber = clobberAsk
+	openTTY = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("n\n")), nil }
+	var err error
+	prompt := captureOutput(func() { _, err = createOutput(dst) })
+	if err == nil {
+		t.Fatalf("expect declined overwrite to fail")
+	}
+	if want := "cat: overwrite '" + dst + "'? [y/N] "; prompt != want {
+		t.Fatalf("unexpected prompt: got %q, want %q", prompt, want)
+	}
+	openTTY = func() (io.ReadCloser, error) { return nil, errors.New("no terminal") }
+	if _, err := createOutput(dst); err == nil {
+		t.Fatalf("expect overwrite without a terminal to fail")
+	}
+	openTTY = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("yes\n")), nil }
+	var f *outputFile
+	captureOutput(func() { f, err = createOutput(dst) })
+	if err != nil {
+		t.Fatalf("failed to overwrite after confirmation: %v", err)
+	}
+	f.Close()
+
+	opts.clobber = clobberForce
+	os.Chmod(dst, 0444)
+	f, err = createOutput(dst)
+	if err != nil {
+		t.Fatalf("failed to force overwrite: %v", err)
+	}
+	f.Close()
+}
+
+func TestOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ownership is not supported on Windows")
+	}
+	defer func() { opts = options{} }()
+
+	dst := filepath.Join(t.TempDir(), "out.txt")
+	opts.owner = strconv.Itoa(os.Getuid())
+	opts.group = strconv.Itoa(os.Getgid())
+	f, err := createOutput(dst)
+	if err != nil {
+		t.Fatalf("failed to set owner to self: %v", err)
+	}
+	f.Close()
+
+	opts.owner = "no-such-user-for-cat"
+	if _, err := createOutput(dst); err == nil {
+		t.Fatalf("expect unknown owner to fail")
+	}
+}
@@ -0,0 +1,576 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// pagerWriter collects the output for --pager, and shows it in the
+// pager when it is closed. Output of more than --max-memory is written
+// as it is, without paging.
+type pagerWriter struct {
+	w       io.Writer
+	buf     bytes.Buffer
+	unpaged bool
+}
+
+func (p *pagerWriter) Write(b []byte) (int, error) {
+	if p.unpaged {
+		return p.w.Write(b)
+	}
+	if maxMemory > 0 && int64(p.buf.Len()+len(b)) > maxMemory {
+		fmt.Fprintf(os.Stderr, tr("cat: the output is larger than the --max-memory of %s, wrote it without paging\n"), formatSize(maxMemory))
+		p.unpaged = true
+		if _, err := p.buf.WriteTo(p.w); err != nil {
+			return 0, err
+		}
+		return p.w.Write(b)
+	}
+	return p.buf.Write(b)
+}
+
+// Close shows the output in the pager, or writes it as it is where
+// there is no terminal to page it in.
+func (p *pagerWriter) Close() error {
+	s := p.buf.String()
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if runtime.GOOS != "windows" {
+		if tty, err := openTTY(); err == nil {
+			defer tty.Close()
+			if f, ok := tty.(*os.File); ok {
+				if done, err := page(f, p.w, lines); done || err != nil {
+					return err
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(p.w, s)
+	return err
+}
+
+// page shows the lines in a pager on the screen of the terminal tty,
+// and reports whether it did so. Output that fits on the screen is
+// written as it is.
+func page(tty *os.File, w io.Writer, lines []string) (bool, error) {
+	rows, cols, err := ttySize(tty)
+	if err != nil || rows < 2 {
+		return false, nil
+	}
+	p := &pager{lines: lines, rows: rows, cols: cols, chop: opts.chop}
+	if p.maxTop() == 0 {
+		return false, nil
+	}
+	mode, err := stty(tty, "-g")
+	if err != nil {
+		return false, nil
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return false, nil
+	}
+	defer stty(tty, mode)
+
+	// The pager draws on the alternate screen, which the terminal
+	// restores when it is done.
+	io.WriteString(w, "\x1b[?1049h")
+	defer io.WriteString(w, "\x1b[?1049l")
+	return true, p.run(bufio.NewReader(tty), w)
+}
+
+// ttySize returns the number of rows and columns of the terminal tty.
+func ttySize(tty *os.File) (rows, cols int, err error) {
+	size, err := stty(tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := fmt.Sscan(size, &rows, &cols); err != nil || rows < 1 || cols < 1 {
+		return 0, 0, fmt.Errorf("unknown terminal size %q", size)
+	}
+	return rows, cols, nil
+}
+
+// terminalWidth returns the number of columns of the terminal, which
+// COLUMNS may give, or 80 if it is unknown.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if runtime.GOOS != "windows" {
+		if tty, err := openTTY(); err == nil {
+			defer tty.Close()
+			if f, ok := tty.(*os.File); ok {
+				if _, cols, err := ttySize(f); err == nil {
+					return cols
+				}
+			}
+		}
+	}
+	return 80
+}
+
+// stty runs stty(1) with the given arguments on the terminal tty, and
+// returns what it prints.
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// pager shows lines on a screen a page at a time, like less. Its
+// commands are those of less:
+//
+//	space, f, PgDn    next page
+//	b, PgUp           previous page
+//	j, Enter, Down    next line
+//	k, Up             previous line
+//	Ng, NG            line N, or the first and the last line
+//	/RE, ?RE          the next and the previous line that matches RE
+//	n, N              the next match in the same and the other direction
+//	mX                mark the position as X
+//	'X                the position marked as X, or '' the one before
+//	                  the last jump
+//	Right, Left       half a screen to the right and to the left
+//	q                 quit
+//
+// Commands that move take a count before them, e.g. 5j. Long lines
+// wrap, unless they are chopped at the edge of the screen, which they
+// are while the screen is scrolled to the right.
+type pager struct {
+	lines      []string
+	rows, cols int // of the screen, the last row is the status line
+
+	chop     bool         // whether long lines are chopped rather than wrapped
+	top      int          // the first line on the screen
+	left     int          // the first column on the screen
+	last     int          // the top before the last jump
+	marks    map[byte]int // the tops marked by m
+	pattern  *regexp.Regexp
+	backward bool   // whether the last search went backward
+	msg      string // shown in the status line until the next command
+}
+
+// run reads commands from in and draws the screen to w after each,
+// until the command q or the end of in.
+func (p *pager) run(in *bufio.Reader, w io.Writer) error {
+	count := 0
+	for {
+		if err := p.draw(w); err != nil {
+			return err
+		}
+		key, err := readKeyPress(in)
+		if err != nil {
+			return nil
+		}
+		p.msg = ""
+		if len(key) == 1 && '0' <= key[0] && key[0] <= '9' {
+			count = count*10 + int(key[0]-'0')
+			p.msg = ":" + strconv.Itoa(count)
+			continue
+		}
+		n := count
+		if n == 0 {
+			n = 1
+		}
+		switch key {
+		case "q", "Q", "\x03":
+			return nil
+		case " ", "f", "pgdn", "\x06":
+			p.scroll(n * (p.rows - 1))
+		case "b", "pgup", "\x02":
+			p.scroll(-n * (p.rows - 1))
+		case "j", "\r", "\n", "down", "\x0e":
+			p.scroll(n)
+		case "k", "up", "\x10":
+			p.scroll(-n)
+		case "g", "<", "home":
+			p.jump(n - 1)
+		case "right", "\x1b)":
+			p.left += n * (p.cols / 2)
+		case "left", "\x1b(":
+			if p.left -= n * (p.cols / 2); p.left < 0 {
+				p.left = 0
+			}
+			p.scroll(0)
+		case "G", ">", "end":
+			if count == 0 {
+				p.jump(p.maxTop())
+			} else {
+				p.jump(count - 1)
+			}
+		case "/", "?":
+			if s, ok := p.prompt(in, w, key); ok {
+				p.search(s, key == "?", n)
+			}
+		case "n":
+			p.repeat(p.backward, n)
+		case "N":
+			p.repeat(!p.backward, n)
+		case "m":
+			if c, err := in.ReadByte(); err == nil && isMarkName(c) {
+				if p.marks == nil {
+					p.marks = make(map[byte]int)
+				}
+				p.marks[c] = p.top
+				p.msg = fmt.Sprintf("Marked as %c", c)
+			}
+		case "'":
+			c, err := in.ReadByte()
+			if err != nil {
+				break
+			}
+			if c == '\'' {
+				p.jump(p.last)
+			} else if top, ok := p.marks[c]; ok {
+				p.jump(top)
+			} else {
+				p.msg = "Mark not set"
+			}
+		case "r", "\x0c":
+		default:
+			p.msg = "Unknown command, q to quit"
+		}
+		count = 0
+	}
+}
+
+func isMarkName(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// readKeyPress reads a key press, which is a byte or the name of a key that
+// terminals send as an escape sequence.
+func readKeyPress(in *bufio.Reader) (string, error) {
+	c, err := in.ReadByte()
+	if err != nil || c != 0x1b {
+		return string(c), err
+	}
+	seq, err := readEscapeSeq(in)
+	if err != nil {
+		return "", err
+	}
+	switch string(seq) {
+	case "[A", "OA":
+		return "up", nil
+	case "[B", "OB":
+		return "down", nil
+	case "[5~":
+		return "pgup", nil
+	case "[6~":
+		return "pgdn", nil
+	case "[H", "OH", "[1~":
+		return "home", nil
+	case "[F", "OF", "[4~":
+		return "end", nil
+	case "[C", "OC":
+		return "right", nil
+	case "[D", "OD":
+		return "left", nil
+	}
+	return "\x1b" + string(seq), nil
+}
+
+// maxTop returns the last top line, the one with which the last line
+// is at the bottom of the screen.
+func (p *pager) maxTop() int {
+	rows := p.rows - 1
+	top := len(p.lines)
+	for top > 0 {
+		rows -= len(p.rowsOf(p.lines[top-1]))
+		if rows < 0 {
+			break
+		}
+		top--
+	}
+	return top
+}
+
+func (p *pager) scroll(n int) {
+	p.top += n
+	if max := p.maxTop(); p.top > max {
+		p.top = max
+	}
+	if p.top < 0 {
+		p.top = 0
+	}
+}
+
+// rowsOf returns the rows of the screen that a line takes.
+func (p *pager) rowsOf(line string) []string {
+	if p.chop || p.left > 0 {
+		return []string{chopLine(line, p.left, p.cols)}
+	}
+	return wrapLine(line, p.cols)
+}
+
+// jump scrolls to the given line, and remembers where it was to come
+// back to with two quotes.
+func (p *pager) jump(line int) {
+	last := p.top
+	p.top = 0
+	p.scroll(line)
+	p.last = last
+}
+
+// search jumps to the nth line after, or before, the top that matches
+// the pattern. An empty pattern repeats the last one.
+func (p *pager) search(pattern string, backward bool, n int) {
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.msg = "Invalid pattern"
+			return
+		}
+		p.pattern = re
+	}
+	p.backward = backward
+	p.repeat(backward, n)
+}
+
+// repeat jumps to the nth next match of the last search.
+func (p *pager) repeat(backward bool, n int) {
+	if p.pattern == nil {
+		p.msg = "No previous pattern"
+		return
+	}
+	step := 1
+	if backward {
+		step = -1
+	}
+	for i := p.top + step; 0 <= i && i < len(p.lines); i += step {
+		if p.pattern.MatchString(stripANSI(p.lines[i])) {
+			if n--; n == 0 {
+				p.jump(i)
+				return
+			}
+		}
+	}
+	p.msg = "Pattern not found"
+}
+
+// prompt reads a line on the status line after the given prompt, and
+// reports whether it was entered rather than cancelled.
+func (p *pager) prompt(in *bufio.Reader, w io.Writer, prompt string) (string, bool) {
+	var s []rune
+	for {
+		fmt.Fprintf(w, "\x1b[%dH\x1b[K%s%s", p.rows, prompt, string(s))
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return "", false
+		}
+		switch r {
+		case '\r', '\n':
+			return string(s), true
+		case 0x1b, 0x03:
+			return "", false
+		case 0x7f, 0x08:
+			if len(s) == 0 {
+				return "", false
+			}
+			s = s[:len(s)-1]
+		default:
+			if r >= ' ' {
+				s = append(s, r)
+			}
+		}
+	}
+}
+
+// draw draws the screen from the top line, and the status line.
+func (p *pager) draw(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x1b[H")
+	row, line := 0, p.top
+	for ; row < p.rows-1 && line < len(p.lines); line++ {
+		s := p.lines[line]
+		if p.pattern != nil && !strings.Contains(s, "\x1b") {
+			s = p.pattern.ReplaceAllStringFunc(s, func(m string) string {
+				return "\x1b[7m" + m + "\x1b[27m"
+			})
+		}
+		for _, seg := range p.rowsOf(s) {
+			if row == p.rows-1 {
+				break
+			}
+			bw.WriteString(seg)
+			if strings.Contains(seg, "\x1b") {
+				bw.WriteString("\x1b[m")
+			}
+			bw.WriteString("\x1b[K\r\n")
+			row++
+		}
+	}
+	for ; row < p.rows-1; row++ {
+		bw.WriteString("~\x1b[K\r\n")
+	}
+	status := p.msg
+	if status == "" {
+		if line == len(p.lines) {
+			status = fmt.Sprintf("lines %d-%d of %d (END)", p.top+1, line, len(p.lines))
+		} else {
+			status = fmt.Sprintf("lines %d-%d of %d %d%%", p.top+1, line, len(p.lines), line*100/len(p.lines))
+		}
+	}
+	fmt.Fprintf(bw, "\x1b[7m%s\x1b[m\x1b[K", status)
+	return bw.Flush()
+}
+
+// wrapLine splits a line into the rows that it takes on a screen of the
+// given width. Tabs are expanded, and escape sequences are kept but
+// take no room.
+func wrapLine(line string, cols int) []string {
+	line = strings.TrimSuffix(line, "\r")
+	var rows []string
+	var b strings.Builder
+	width := 0
+	r := bufio.NewReader(strings.NewReader(line))
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			break
+		}
+		if c == 0x1b {
+			seq, _ := readEscapeSeq(r)
+			b.WriteByte(0x1b)
+			b.Write(seq)
+			continue
+		}
+		s, w := string(c), runeWidth(c)
+		switch {
+		case c == '\t':
+			// Up to the next tab stop, or the end of the row.
+			w = 8 - width%8
+			if width+w > cols {
+				w = cols - width
+			}
+			s = strings.Repeat(" ", w)
+		case c < ' ' || c == 0x7f:
+			s, w = "", 0
+		}
+		if width+w > cols && width > 0 {
+			rows = append(rows, b.String())
+			b.Reset()
+			width = 0
+		}
+		b.WriteString(s)
+		width += w
+	}
+	return append(rows, b.String())
+}
+
+// chopLine returns the part of a line that shows on a row of the given
+// width from the column left. Where the line goes on beyond the edges
+// of the row, the first or the last column shows < or >. Tabs are
+// expanded, and escape sequences are all kept but take no room, so that
+// the styles that they set carry over to the part that shows.
+func chopLine(line string, left, cols int) string {
+	type cell struct {
+		s string
+		w int
+	}
+	var cells []cell
+	width := 0
+	r := bufio.NewReader(strings.NewReader(strings.TrimSuffix(line, "\r")))
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			break
+		}
+		switch {
+		case c == 0x1b:
+			seq, _ := readEscapeSeq(r)
+			cells = append(cells, cell{"\x1b" + string(seq), 0})
+		case c == '\t':
+			for n := 8 - width%8; n > 0; n-- {
+				cells = append(cells, cell{" ", 1})
+				width++
+			}
+		case c < ' ' || c == 0x7f:
+		default:
+			cells = append(cells, cell{string(c), runeWidth(c)})
+			width += runeWidth(c)
+		}
+	}
+
+	// The columns from lo to hi show the line.
+	lo, hi := left, left+cols
+	var b strings.Builder
+	if left > 0 && width > left {
+		b.WriteByte('<')
+		lo++
+	}
+	more := width > hi
+	if more {
+		hi--
+	}
+	x := 0
+	for _, c := range cells {
+		switch {
+		case c.w == 0:
+			b.WriteString(c.s)
+		case lo <= x && x+c.w <= hi:
+			b.WriteString(c.s)
+		case x < hi && x+c.w > lo:
+			// A wide character cut by an edge.
+			for i := x; i < x+c.w; i++ {
+				if lo <= i && i < hi {
+					b.WriteByte(' ')
+				}
+			}
+		}
+		x += c.w
+	}
+	if more {
+		b.WriteByte('>')
+	}
+	return b.String()
+}
+
+// chopWriter chops the lines of its output at the edge of a terminal,
+// see chopLine.
+type chopWriter struct {
+	w    io.Writer
+	cols int
+	line []byte // the beginning of a line that is not complete yet
+}
+
+func (c *chopWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			c.line = append(c.line, b...)
+			break
+		}
+		c.line = append(c.line, b[:i]...)
+		b = b[i+1:]
+		if _, err := io.WriteString(c.w, chopLine(string(c.line), 0, c.cols)+"\n"); err != nil {
+			return 0, err
+		}
+		c.line = c.line[:0]
+	}
+	return n, nil
+}
+
+// Close writes what is left, an unterminated last line.
+func (c *chopWriter) Close() error {
+	if len(c.line) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(c.w, chopLine(string(c.line), 0, c.cols))
+	return err
+}
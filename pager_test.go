@@ -0,0 +1,173 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	lines[41] = "needle"
+	lines[76] = "\x1b[31mneedle\x1b[m"
+
+	tests := []struct {
+		keys string
+		top  int
+		msg  string
+	}{
+		{"", 0, ""},
+		{" ", 10, ""},
+		{"  b", 10, ""},
+		{"jjj5jk", 7, ""},
+		{"G", 90, ""},
+		{"\x1b[F\x1b[A", 89, ""},
+		{"50g", 49, ""},
+		{"1000G", 90, ""},
+		{"/needle\r", 41, ""},
+		{"/needle\rn", 76, ""},
+		{"/needle\rnN", 41, ""},
+		{"G?needle\r", 76, ""},
+		{"/nothing\r", 0, "Pattern not found"},
+		{"/needle\x1b", 0, ""},
+		{"/neex\x7fdle\r", 41, ""},
+		{"n", 0, "No previous pattern"},
+		{"20gma60g'a", 19, ""},
+		{"20g60g''", 19, ""},
+		{"20g60g''''", 59, ""},
+		{"'b", 0, "Mark not set"},
+		{"30gmz", 29, "Marked as z"},
+		{"Z", 0, "Unknown command, q to quit"},
+		{"Gq j", 90, ""},
+	}
+	for _, tt := range tests {
+		p := &pager{lines: lines, rows: 11, cols: 20}
+		if err := p.run(bufio.NewReader(strings.NewReader(tt.keys)), io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if p.top != tt.top || p.msg != tt.msg {
+			t.Errorf("%q: top %d, message %q, want %d, %q", tt.keys, p.top, p.msg, tt.top, tt.msg)
+		}
+	}
+}
+
+func TestPagerDraw(t *testing.T) {
+	p := &pager{lines: []string{"a\tb", "0123456789abc", "x", "y", "z"}, rows: 4, cols: 10}
+	p.pattern = regexp.MustCompile("b")
+	var b strings.Builder
+	p.draw(&b)
+	want := "\x1b[H" +
+		"a       \x1b[7mb\x1b[27m\x1b[m\x1b[K\r\n" +
+		"0123456789\x1b[K\r\n" +
+		"a\x1b[7mb\x1b[27mc\x1b[m\x1b[K\r\n" +
+		"\x1b[7mlines 1-2 of 5 40%\x1b[m\x1b[K"
+	if b.String() != want {
+		t.Fatalf("unexpected screen\n%q\nwant\n%q", b.String(), want)
+	}
+
+	p.scroll(10)
+	b.Reset()
+	p.draw(&b)
+	if want := "\x1b[H" +
+		"x\x1b[K\r\ny\x1b[K\r\nz\x1b[K\r\n" +
+		"\x1b[7mlines 3-5 of 5 (END)\x1b[m\x1b[K"; b.String() != want {
+		t.Fatalf("unexpected screen\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", []string{""}},
+		{"abcdef", []string{"abcd", "ef"}},
+		{"abcd\r", []string{"abcd"}},
+		{"你好世界", []string{"你好", "世界"}},
+		{"a你好", []string{"a你", "好"}},
+		{"\x1b[1mabcd\x1b[mef", []string{"\x1b[1mabcd\x1b[m", "ef"}},
+		{"ab\tc", []string{"ab  ", "c"}},
+	}
+	for _, tt := range tests {
+		if got := wrapLine(tt.line, 4); fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("wrapLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMainPager(t *testing.T) {
+	// Output that is not to a terminal is never paged.
+	want, _ := os.ReadFile("testdata/a.txt")
+	if out := runMain("--pager", "testdata/a.txt"); out != string(want) {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestChopLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		left, cols int
+		want       string
+	}{
+		{"abc", 0, 4, "abc"},
+		{"abcd", 0, 4, "abcd"},
+		{"abcdef", 0, 4, "abc>"},
+		{"abcdef", 2, 4, "<def"},
+		{"abcdefghij", 2, 4, "<de>"},
+		{"abc", 4, 4, ""},
+		{"你好世界", 0, 4, "你 >"},
+		{"a你好", 2, 4, "<好"},
+		{"\x1b[1mabcdef\x1b[m", 0, 4, "\x1b[1mabc\x1b[m>"},
+		{"\x1b[1mabcdef\x1b[m", 4, 4, "<\x1b[1mf\x1b[m"},
+		{"a\tb", 0, 10, "a       b"},
+		{"a\tb\r", 6, 4, "< b"},
+	}
+	for _, tt := range tests {
+		if got := chopLine(tt.line, tt.left, tt.cols); got != tt.want {
+			t.Errorf("chopLine(%q, %d, %d) = %q, want %q", tt.line, tt.left, tt.cols, got, tt.want)
+		}
+	}
+}
+
+func TestPagerScrollRight(t *testing.T) {
+	lines := []string{strings.Repeat("0123456789", 5), "a", "b"}
+	p := &pager{lines: lines, rows: 4, cols: 20}
+	if p.maxTop() != 1 {
+		t.Fatalf("wrapped lines: max top %d", p.maxTop())
+	}
+	p.run(bufio.NewReader(strings.NewReader("\x1b[C")), io.Discard)
+	if p.left != 10 || p.maxTop() != 0 {
+		t.Fatalf("left %d, max top %d", p.left, p.maxTop())
+	}
+	var b strings.Builder
+	p.draw(&b)
+	if want := "\x1b[H<123456789012345678>\x1b[K\r\n\x1b[K\r\n"; !strings.HasPrefix(b.String(), want) {
+		t.Fatalf("unexpected screen %q", b.String())
+	}
+	p.run(bufio.NewReader(strings.NewReader("5\x1b[D")), io.Discard)
+	if p.left != 0 || p.maxTop() != 1 {
+		t.Fatalf("left %d, max top %d", p.left, p.maxTop())
+	}
+}
+
+func TestChopWriter(t *testing.T) {
+	var b strings.Builder
+	c := &chopWriter{w: &b, cols: 5}
+	io.WriteString(c, "abc\n0123")
+	io.WriteString(c, "456789\nxyz")
+	c.Close()
+	if want := "abc\n0123>\nxyz"; b.String() != want {
+		t.Fatalf("got %q, want %q", b.String(), want)
+	}
+}
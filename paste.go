@@ -0,0 +1,91 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// isTerminal reports whether the given file is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	i, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return i.Mode()&os.ModeCharDevice != 0
+}
+
+// Terminals wrap pasted text with these sequences when bracketed paste
+// mode is on, and shells sometimes leave the mode on after they exit.
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// pasteStripper removes bracketed paste sequences from what is typed
+// into a terminal and turns carriage returns into line feeds, because
+// terminals send a pasted line break as CR.
+type pasteStripper struct {
+	r   io.Reader
+	in  []byte // bytes that may be the beginning of a paste sequence
+	out []byte
+	buf []byte
+	cr  bool // whether the last byte seen was a CR
+	err error
+}
+
+func newPasteStripper(r io.Reader) *pasteStripper {
+	return &pasteStripper{r: r, buf: make([]byte, 4096)}
+}
+
+func (s *pasteStripper) Read(p []byte) (int, error) {
+	for len(s.out) == 0 {
+		if s.err != nil {
+			if len(s.in) > 0 {
+				// An incomplete sequence at the end is kept as is.
+				s.out, s.in = append(s.out, s.in...), s.in[:0]
+				break
+			}
+			return 0, s.err
+		}
+		n, err := s.r.Read(s.buf)
+		s.in = append(s.in, s.buf[:n]...)
+		s.err = err
+		s.strip()
+	}
+	n := copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+func (s *pasteStripper) strip() {
+	i := 0
+	for i < len(s.in) {
+		c := s.in[i]
+		if c == 0x1b {
+			rest := s.in[i:]
+			if bytes.HasPrefix(rest, pasteStart) || bytes.HasPrefix(rest, pasteEnd) {
+				i += len(pasteStart)
+				continue
+			}
+			if s.err == nil && (bytes.HasPrefix(pasteStart, rest) || bytes.HasPrefix(pasteEnd, rest)) {
+				break // wait for the rest of the sequence
+			}
+		}
+		switch {
+		case c == '\r':
+			s.out = append(s.out, '\n')
+		case c == '\n' && s.cr:
+			// The line feed of a CRLF pair was already emitted.
+		default:
+			s.out = append(s.out, c)
+		}
+		s.cr = c == '\r'
+		i++
+	}
+	s.in = append(s.in[:0], s.in[i:]...)
+}
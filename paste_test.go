@@ -0,0 +1,36 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPasteStripper(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello\n", "hello\n"},
+		{"\x1b[200~line1\rline2\x1b[201~\n", "line1\nline2\n"},
+		{"a\r\nb\r\n", "a\nb\n"},
+		{"\x1b[1mbold\x1b[0m", "\x1b[1mbold\x1b[0m"},
+		{"tail\x1b[20", "tail\x1b[20"},
+	}
+	for _, tt := range tests {
+		// Read byte by byte so that sequences are split across reads.
+		r := newPasteStripper(iotest.OneByteReader(strings.NewReader(tt.in)))
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("strip %q: got %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// inputPatch is the patch of --apply-patch, or nil.
+var inputPatch []filePatch
+
+// filePatch is the part of a unified diff about one file.
+type filePatch struct {
+	oldName, newName string
+	hunks            []hunk
+}
+
+// hunk is a change to consecutive lines of a file.
+type hunk struct {
+	oldStart, oldLines int
+	lines              []patchLine
+}
+
+// patchLine is a line of a hunk, with its line ending unless the file
+// lacks one at its end.
+type patchLine struct {
+	op   byte // ' ', '-' or '+'
+	text []byte
+}
+
+// readPatch reads a unified diff, as made by diff -u or git diff, of one
+// or more files.
+func readPatch(name string) ([]filePatch, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("--apply-patch: %v", unwrapPathError(err))
+	}
+	var patches []filePatch
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case bytes.HasPrefix(line, []byte("--- ")) && i+1 < len(lines) && bytes.HasPrefix(lines[i+1], []byte("+++ ")):
+			patches = append(patches, filePatch{oldName: patchName(line[4:]), newName: patchName(lines[i+1][4:])})
+			i++
+		case bytes.HasPrefix(line, []byte("@@ ")):
+			if len(patches) == 0 {
+				return nil, fmt.Errorf("%s:%d: hunk without file names", name, i+1)
+			}
+			h, oldLines, newLines, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, i+1, err)
+			}
+			// The counts of lines tell where the hunk ends, as its
+			// lines may look like anything.
+			for oldLines > 0 || newLines > 0 {
+				i++
+				if i >= len(lines) || len(lines[i]) == 0 {
+					return nil, fmt.Errorf("%s: truncated hunk", name)
+				}
+				l := patchLine{op: lines[i][0], text: lines[i][1:]}
+				switch l.op {
+				case ' ':
+					oldLines--
+					newLines--
+				case '-':
+					oldLines--
+				case '+':
+					newLines--
+				case '\n':
+					// Some editors strip the space of empty context lines.
+					l = patchLine{op: ' ', text: lines[i]}
+					oldLines--
+					newLines--
+				case '\\':
+					// "\ No newline at end of file" of the line before.
+					noNewline(&h)
+					continue
+				default:
+					return nil, fmt.Errorf("%s:%d: invalid hunk line", name, i+1)
+				}
+				h.lines = append(h.lines, l)
+			}
+			if i+1 < len(lines) && bytes.HasPrefix(lines[i+1], []byte(`\`)) {
+				i++
+				noNewline(&h)
+			}
+			p := &patches[len(patches)-1]
+			p.hunks = append(p.hunks, h)
+		}
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("%s: not a unified diff", name)
+	}
+	return patches, nil
+}
+
+func noNewline(h *hunk) {
+	if n := len(h.lines); n > 0 {
+		h.lines[n-1].text = bytes.TrimSuffix(h.lines[n-1].text, []byte("\n"))
+	}
+}
+
+// patchName returns the name of a file in a ---/+++ line, without the
+// time that diff adds and the a/ and b/ prefixes of git.
+func patchName(b []byte) string {
+	s := strings.TrimRight(string(b), "\r\n")
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// parseHunkHeader parses @@ -start[,count] +start[,count] @@.
+func parseHunkHeader(line []byte) (h hunk, oldLines, newLines int, err error) {
+	f := strings.Fields(string(line))
+	if len(f) < 4 || f[3] != "@@" || !strings.HasPrefix(f[1], "-") || !strings.HasPrefix(f[2], "+") {
+		return h, 0, 0, fmt.Errorf("invalid hunk header %q", bytes.TrimSpace(line))
+	}
+	rng := func(s string) (start, n int, err error) {
+		n = 1
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			if n, err = strconv.Atoi(s[i+1:]); err != nil {
+				return 0, 0, err
+			}
+			s = s[:i]
+		}
+		start, err = strconv.Atoi(s)
+		return start, n, err
+	}
+	if h.oldStart, h.oldLines, err = rng(f[1][1:]); err != nil {
+		return h, 0, 0, fmt.Errorf("invalid hunk header %q", bytes.TrimSpace(line))
+	}
+	if _, newLines, err = rng(f[2][1:]); err != nil {
+		return h, 0, 0, fmt.Errorf("invalid hunk header %q", bytes.TrimSpace(line))
+	}
+	return h, h.oldLines, newLines, nil
+}
+
+// patchFor returns the patch of an input: the only one of a diff of a
+// single file, or the one of the file whose path the name ends with.
+func patchFor(name string) *filePatch {
+	if len(inputPatch) == 1 {
+		return &inputPatch[0]
+	}
+	name = filepath.ToSlash(name)
+	for i, p := range inputPatch {
+		for _, n := range []string{p.newName, p.oldName} {
+			if n != "/dev/null" && (name == n || strings.HasSuffix(name, "/"+n)) {
+				return &inputPatch[i]
+			}
+		}
+	}
+	return nil
+}
+
+// patcher applies the hunks of a patch to its input as it is read. The
+// lines of hunks must match the input exactly where the patch says.
+type patcher struct {
+	name  string
+	r     *bufio.Reader
+	hunks []hunk
+	n     int // the hunks applied so far
+	line  int // the lines of the input read so far
+	buf   bytes.Buffer
+	err   error
+}
+
+func applyPatch(name string, r io.Reader, p *filePatch) io.Reader {
+	return &patcher{name: name, r: bufio.NewReader(r), hunks: p.hunks}
+}
+
+func (p *patcher) Read(b []byte) (int, error) {
+	for p.buf.Len() == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		if len(p.hunks) == 0 {
+			return p.r.Read(b)
+		}
+		h := p.hunks[0]
+		// A hunk that only adds lines adds them after its line.
+		start := h.oldStart - 1
+		if h.oldLines == 0 {
+			start = h.oldStart
+		}
+		if p.line < start {
+			line, err := p.r.ReadBytes('\n')
+			if err != nil && (err != io.EOF || len(line) == 0) {
+				p.err = p.fail()
+				continue
+			}
+			p.line++
+			p.buf.Write(line)
+			continue
+		}
+		p.err = p.apply(h)
+		p.hunks = p.hunks[1:]
+		p.n++
+	}
+	return p.buf.Read(b)
+}
+
+// apply applies a hunk at the current line.
+func (p *patcher) apply(h hunk) error {
+	for _, l := range h.lines {
+		if l.op == '+' {
+			p.buf.Write(l.text)
+			continue
+		}
+		line, err := p.r.ReadBytes('\n')
+		if !bytes.Equal(line, l.text) || err != nil && err != io.EOF {
+			return p.fail()
+		}
+		p.line++
+		if l.op == ' ' {
+			p.buf.Write(line)
+		}
+	}
+	return nil
+}
+
+func (p *patcher) fail() error {
+	return fmt.Errorf("%s: hunk #%d of the patch does not apply at line %d", p.name, p.n+1, p.line+1)
+}
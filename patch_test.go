@@ -0,0 +1,71 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainApplyPatch(t *testing.T) {
+	dir := t.TempDir()
+	numbers := filepath.Join(dir, "numbers.txt")
+	os.WriteFile(numbers, []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"), 0644)
+	letters := filepath.Join(dir, "src", "letters.txt")
+	os.MkdirAll(filepath.Dir(letters), 0755)
+	os.WriteFile(letters, []byte("x\n"), 0644)
+	other := filepath.Join(dir, "other.txt")
+	os.WriteFile(other, []byte("two\n"), 0644)
+
+	// Made by diff -u and git diff.
+	patch := filepath.Join(dir, "fix.patch")
+	os.WriteFile(patch, []byte(`--- numbers.txt	2021-01-01 00:00:00.000000000 +0000
++++ numbers.txt	2021-01-02 00:00:00.000000000 +0000
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+\ No newline at end of file
+diff --git a/src/letters.txt b/src/letters.txt
+index 587be6b..b77b4eb 100644
+--- a/src/letters.txt
++++ b/src/letters.txt
+@@ -0,0 +1 @@
++w
+@@ -1 +2,2 @@
+ x
++y
+`), 0644)
+
+	want := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven" + "w\nx\ny\n" + "two\n"
+	if out := runMain("--apply-patch", patch, numbers, letters, other); out != want {
+		t.Errorf("unexpected output:\n%q, want\n%q", out, want)
+	}
+
+	// A patch of a single file applies to any input, whatever its name,
+	// and fails where the input does not match it.
+	single := filepath.Join(dir, "single.patch")
+	os.WriteFile(single, []byte("--- a\n+++ b\n@@ -2,2 +2,2 @@\n two\n-three\n+3\n"), 0644)
+	if out := runMain("--apply-patch", single, numbers); out != "one\ntwo\n3\nfour\nfive\nsix\nseven\neight\nnine\nten\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	want = "two\ncat: " + other + ": hunk #1 of the patch does not apply at line 2\n"
+	if out := runMain("--apply-patch", single, other); out != want {
+		t.Errorf("unexpected output %q, want %q", out, want)
+	}
+	os.WriteFile(single, []byte("not a patch\n"), 0644)
+	if out, want := runMain("--apply-patch", single, other), "cat: "+single+": not a unified diff\n"; out != want {
+		t.Errorf("unexpected output %q, want %q", out, want)
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+
+package main
+
+// longPath returns the path that is passed to the system. Only Windows
+// limits the length of paths.
+func longPath(p string) string { return p }
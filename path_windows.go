@@ -0,0 +1,34 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the length from which Windows APIs reject a path unless
+// it is in the extended \\?\ form. It is MAX_PATH minus the room for
+// an 8.3 file name, which is what CreateDirectory enforces.
+const maxPath = 248
+
+// longPath returns the extended-length form of a path that would
+// exceed MAX_PATH, e.g. deep in a node_modules tree. Go releases
+// before 1.20 only did so for absolute paths on their own.
+func longPath(p string) string {
+	if strings.HasPrefix(p, `\\?\`) || strings.HasPrefix(p, `\\.\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil || len(abs) < maxPath {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
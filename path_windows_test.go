@@ -0,0 +1,51 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	if got := longPath(`C:\a.txt`); got != `C:\a.txt` {
+		t.Fatalf("short path changed: %s", got)
+	}
+	if got := longPath(`\\?\C:\a.txt`); got != `\\?\C:\a.txt` {
+		t.Fatalf("extended path changed: %s", got)
+	}
+	long := `C:\` + strings.Repeat(`node_modules\`, 30) + "a.txt"
+	if got := longPath(long); got != `\\?\`+long {
+		t.Fatalf("long path not extended: %s", got)
+	}
+	unc := `\\server\share\` + strings.Repeat(`node_modules\`, 30) + "a.txt"
+	if got := longPath(unc); got != `\\?\UNC\server\share\`+strings.Repeat(`node_modules\`, 30)+"a.txt" {
+		t.Fatalf("long UNC path not extended: %s", got)
+	}
+}
+
+func TestCatLongPath(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join(dir, strings.Repeat("node_modules"+string(filepath.Separator), 25))
+	if err := os.MkdirAll(longPath(deep), 0755); err != nil {
+		t.Fatalf("failed to create deep directory: %v", err)
+	}
+	src := filepath.Join(deep, "a.txt")
+	if err := os.WriteFile(longPath(src), []byte("deep"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	w := newCompleteWriter()
+	if err := cat(src, w); err != nil {
+		t.Fatalf("failed to cat long path: %v", err)
+	}
+	if w.String() != "deep" {
+		t.Fatalf("unexpected content: %q", w.String())
+	}
+}
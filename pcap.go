@@ -0,0 +1,362 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() { registerFeature("pcap") }
+
+// The link types of captures, see https://www.tcpdump.org/linktypes.html.
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+func isPcap(head []byte, _ string) bool {
+	if len(head) < 4 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(head) {
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1, 0x0a0d0d0a:
+		return true
+	}
+	return false
+}
+
+// packet is a captured packet.
+type packet struct {
+	time   time.Time
+	link   int
+	data   []byte
+	length int // on the wire, which may exceed the captured data
+}
+
+// previewPcap writes a line for each packet of a pcap or pcapng file.
+func previewPcap(w io.Writer, r *bufio.Reader) error {
+	head, err := r.Peek(4)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	next := readPcap(r)
+	if binary.BigEndian.Uint32(head) == 0x0a0d0d0a {
+		next = readPcapng(r)
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	for n := 0; opts.previewRecords <= 0 || n < opts.previewRecords; n++ {
+		p, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, "%s %s\n", p.time.UTC().Format("2006-01-02T15:04:05.000000Z"), summarize(p))
+	}
+	return nil
+}
+
+// readPcap returns a function that reads the packets of a pcap file
+// one at a time.
+func readPcap(r *bufio.Reader) func() (packet, error) {
+	var order binary.ByteOrder
+	var nano bool
+	var link int
+	return func() (packet, error) {
+		if order == nil {
+			var h [24]byte
+			if _, err := io.ReadFull(r, h[:]); err != nil {
+				return packet{}, unexpectedEOF(err)
+			}
+			order = binary.LittleEndian
+			if h[0] == 0xa1 {
+				order = binary.BigEndian
+			}
+			nano = order.Uint32(h[:]) == 0xa1b23c4d
+			link = int(order.Uint32(h[20:]) & 0xffff)
+		}
+		var h [16]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			if err == io.EOF {
+				return packet{}, io.EOF
+			}
+			return packet{}, io.ErrUnexpectedEOF
+		}
+		sec, frac := int64(order.Uint32(h[:])), int64(order.Uint32(h[4:]))
+		if !nano {
+			frac *= 1000
+		}
+		data, err := readN(r, uint64(order.Uint32(h[8:])))
+		if err != nil {
+			return packet{}, err
+		}
+		return packet{time.Unix(sec, frac), link, data, int(order.Uint32(h[12:]))}, nil
+	}
+}
+
+// readPcapng returns a function that reads the packets of a pcapng
+// file one at a time.
+func readPcapng(r *bufio.Reader) func() (packet, error) {
+	type iface struct {
+		link  int
+		units int64 // of the timestamps per second
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	var ifaces []iface
+	return func() (packet, error) {
+		for {
+			var h [8]byte
+			if _, err := io.ReadFull(r, h[:]); err != nil {
+				if err == io.EOF {
+					return packet{}, io.EOF
+				}
+				return packet{}, io.ErrUnexpectedEOF
+			}
+			typ := order.Uint32(h[:])
+			if typ == 0x0a0d0d0a {
+				// A section header block sets the byte order and the
+				// interfaces of the blocks that follow.
+				magic, err := r.Peek(4)
+				if err != nil {
+					return packet{}, unexpectedEOF(err)
+				}
+				order = binary.LittleEndian
+				if binary.BigEndian.Uint32(magic) == 0x1a2b3c4d {
+					order = binary.BigEndian
+				}
+				ifaces = nil
+			}
+			size := order.Uint32(h[4:])
+			if size < 12 || size%4 != 0 {
+				return packet{}, errors.New("invalid block length")
+			}
+			body, err := readN(r, uint64(size-8))
+			if err != nil {
+				return packet{}, err
+			}
+			body = body[:len(body)-4] // the repeated block length
+			switch typ {
+			case 1: // interface description
+				if len(body) < 8 {
+					return packet{}, errors.New("invalid interface description")
+				}
+				i := iface{link: int(order.Uint16(body)), units: 1e6}
+				walkPcapngOptions(body[8:], order, func(code uint16, v []byte) {
+					if code == 9 && len(v) == 1 { // if_tsresol
+						if v[0]&0x80 != 0 {
+							i.units = 1 << (v[0] & 0x7f)
+						} else {
+							i.units = 1
+							for n := byte(0); n < v[0]; n++ {
+								i.units *= 10
+							}
+						}
+					}
+				})
+				ifaces = append(ifaces, i)
+			case 6: // enhanced packet
+				if len(body) < 20 {
+					return packet{}, errors.New("invalid packet block")
+				}
+				id := order.Uint32(body)
+				if int(id) >= len(ifaces) {
+					return packet{}, fmt.Errorf("packet of unknown interface %d", id)
+				}
+				i := ifaces[id]
+				ts := int64(order.Uint32(body[4:]))<<32 | int64(order.Uint32(body[8:]))
+				n := order.Uint32(body[12:])
+				if uint64(n) > uint64(len(body)-20) {
+					return packet{}, errors.New("invalid packet block")
+				}
+				t := time.Unix(ts/i.units, ts%i.units*int64(time.Second)/i.units)
+				return packet{t, i.link, body[20 : 20+n], int(order.Uint32(body[16:]))}, nil
+			case 3: // simple packet, without a timestamp
+				if len(body) < 4 || len(ifaces) == 0 {
+					return packet{}, errors.New("invalid packet block")
+				}
+				n := int(order.Uint32(body))
+				data := body[4:]
+				if n < len(data) {
+					data = data[:n]
+				}
+				return packet{time.Time{}, ifaces[0].link, data, n}, nil
+			}
+		}
+	}
+}
+
+// walkPcapngOptions calls fn for each option of a block.
+func walkPcapngOptions(b []byte, order binary.ByteOrder, fn func(code uint16, v []byte)) {
+	for len(b) >= 4 {
+		code, n := order.Uint16(b), int(order.Uint16(b[2:]))
+		if code == 0 || 4+n > len(b) {
+			return
+		}
+		fn(code, b[4:4+n])
+		b = b[4+(n+3)/4*4:]
+	}
+}
+
+// summarize describes a packet like tcpdump in a line: its protocols,
+// where it came from and went to, and its length.
+func summarize(p packet) string {
+	var protos []string
+	var src, dst string
+	data, link := p.data, p.link
+
+	// The link layer tells the type of the network layer, as an
+	// EtherType.
+	var etherType uint16
+	switch link {
+	case linkEthernet:
+		if len(data) < 14 {
+			break
+		}
+		protos = append(protos, "Ethernet")
+		src, dst = net.HardwareAddr(data[6:12]).String(), net.HardwareAddr(data[0:6]).String()
+		etherType, data = binary.BigEndian.Uint16(data[12:]), data[14:]
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			protos = append(protos, "VLAN")
+			etherType, data = binary.BigEndian.Uint16(data[2:]), data[4:]
+		}
+	case linkNull, linkLoop:
+		if len(data) < 4 {
+			break
+		}
+		protos = append(protos, "Loopback")
+		// The address family, in the byte order of the host that
+		// captured, where 2 is IPv4 and 24, 28 or 30 are IPv6.
+		family := binary.LittleEndian.Uint32(data)
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data)
+		}
+		etherType, data = 0x86dd, data[4:]
+		if family == 2 {
+			etherType = 0x0800
+		}
+	case linkSLL:
+		if len(data) < 16 {
+			break
+		}
+		protos = append(protos, "SLL")
+		etherType, data = binary.BigEndian.Uint16(data[14:]), data[16:]
+	case linkSLL2:
+		if len(data) < 20 {
+			break
+		}
+		protos = append(protos, "SLL2")
+		etherType, data = binary.BigEndian.Uint16(data), data[20:]
+	case linkRaw, linkIPv4, linkIPv6:
+		if len(data) > 0 {
+			etherType = 0x0800
+			if data[0]>>4 == 6 {
+				etherType = 0x86dd
+			}
+		}
+	default:
+		protos = append(protos, "link type "+strconv.Itoa(link))
+	}
+
+	// The network layer tells the protocol of the transport layer.
+	proto := -1
+	switch etherType {
+	case 0x0800:
+		if len(data) < 20 || data[0]>>4 != 4 {
+			break
+		}
+		protos = append(protos, "IPv4")
+		ihl := int(data[0]&0x0f) * 4
+		src, dst = net.IP(data[12:16]).String(), net.IP(data[16:20]).String()
+		if ihl >= 20 && ihl <= len(data) {
+			proto, data = int(data[9]), data[ihl:]
+		}
+	case 0x86dd:
+		if len(data) < 40 {
+			break
+		}
+		protos = append(protos, "IPv6")
+		src, dst = net.IP(data[8:24]).String(), net.IP(data[24:40]).String()
+		proto, data = int(data[6]), data[40:]
+		// Skip the common extension headers.
+		for (proto == 0 || proto == 43 || proto == 60) && len(data) >= 8 {
+			n := (int(data[1]) + 1) * 8
+			if n > len(data) {
+				break
+			}
+			proto, data = int(data[0]), data[n:]
+		}
+	case 0x0806:
+		protos = append(protos, "ARP")
+		if len(data) >= 28 && data[4] == 6 && data[5] == 4 {
+			src, dst = net.IP(data[14:18]).String(), net.IP(data[24:28]).String()
+		}
+	case 0:
+	default:
+		protos = append(protos, fmt.Sprintf("EtherType 0x%04x", etherType))
+	}
+
+	switch proto {
+	case 6, 17:
+		name := "TCP"
+		if proto == 17 {
+			name = "UDP"
+		}
+		protos = append(protos, name)
+		if len(data) >= 4 {
+			src = net.JoinHostPort(src, strconv.Itoa(int(binary.BigEndian.Uint16(data))))
+			dst = net.JoinHostPort(dst, strconv.Itoa(int(binary.BigEndian.Uint16(data[2:]))))
+		}
+		if proto == 6 && len(data) >= 14 {
+			protos[len(protos)-1] += " " + tcpFlags(data[13])
+		}
+	case 1:
+		protos = append(protos, "ICMP")
+	case 58:
+		protos = append(protos, "ICMPv6")
+	case -1:
+	default:
+		protos = append(protos, "IP protocol "+strconv.Itoa(proto))
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(protos, "/"))
+	if src != "" {
+		b.WriteString(" " + src + " " + arrow() + " " + dst)
+	}
+	fmt.Fprintf(&b, " length %d", p.length)
+	return b.String()
+}
+
+// tcpFlags formats the flags of a TCP segment like tcpdump.
+func tcpFlags(f byte) string {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, c := range "FSRP.UEW" {
+		if f&(1<<i) != 0 {
+			b.WriteRune(c)
+		}
+	}
+	b.WriteByte(']')
+	return b.String()
+}
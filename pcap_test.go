@@ -0,0 +1,88 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrames are an Ethernet frame with a TCP SYN from 10.0.0.1:443 to
+// 10.0.0.2:80, and a raw IPv6 packet with UDP from ::1:53 to ::2:5353.
+func testFrames() (eth, ip6 []byte) {
+	eth = []byte{
+		0x02, 0, 0, 0, 0, 0x02, 0x02, 0, 0, 0, 0, 0x01, 0x08, 0x00, // Ethernet
+		0x45, 0, 0, 40, 0, 0, 0, 0, 64, 6, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2, // IPv4
+		0x01, 0xbb, 0, 80, 0, 0, 0, 0, 0, 0, 0, 0, 0x50, 0x02, 0, 0, 0, 0, 0, 0, // TCP
+	}
+	ip6 = make([]byte, 48)
+	ip6[0], ip6[6] = 0x60, 17
+	ip6[23], ip6[39] = 1, 2
+	binary.BigEndian.PutUint16(ip6[40:], 53)
+	binary.BigEndian.PutUint16(ip6[42:], 5353)
+	return eth, ip6
+}
+
+func TestPreviewPcap(t *testing.T) {
+	eth, ip6 := testFrames()
+	dir := t.TempDir()
+
+	// A little endian pcap file with microsecond timestamps.
+	pcap := appendUint32(nil, 0xa1b2c3d4)
+	pcap = append(pcap, 2, 0, 4, 0)
+	pcap = append(pcap, make([]byte, 12)...)
+	pcap = appendUint32(pcap, linkEthernet)
+	pcap = appendUint32(pcap, 1636279200)
+	pcap = appendUint32(pcap, 123456)
+	pcap = appendUint32(pcap, uint32(len(eth)))
+	pcap = appendUint32(pcap, 60)
+	pcap = append(pcap, eth...)
+	os.WriteFile(filepath.Join(dir, "a.pcap"), pcap, 0644)
+
+	// A pcapng file with nanosecond timestamps.
+	block := func(typ uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		b := appendUint32(nil, typ)
+		b = appendUint32(b, uint32(12+len(body)))
+		b = append(b, body...)
+		return appendUint32(b, uint32(12+len(body)))
+	}
+	shb := appendUint32(nil, 0x1a2b3c4d)
+	shb = append(shb, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	idb := []byte{linkRaw, 0, 0, 0, 0, 0, 0, 0, 9, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0}
+	ts := uint64(1636279200)*1e9 + 5000
+	epb := appendUint32(nil, 0)
+	epb = appendUint32(epb, uint32(ts>>32))
+	epb = appendUint32(epb, uint32(ts))
+	epb = appendUint32(epb, uint32(len(ip6)))
+	epb = appendUint32(epb, uint32(len(ip6)))
+	epb = append(epb, ip6...)
+	var ng []byte
+	ng = append(ng, block(0x0a0d0d0a, shb)...)
+	ng = append(ng, block(1, idb)...)
+	ng = append(ng, block(6, epb)...)
+	os.WriteFile(filepath.Join(dir, "b.pcapng"), ng, 0644)
+
+	got := runMain("--preview", filepath.Join(dir, "a.pcap"), filepath.Join(dir, "b.pcapng"))
+	want := "2021-11-07T10:00:00.123456Z Ethernet/IPv4/TCP [S] 10.0.0.1:443 → 10.0.0.2:80 length 60\n" +
+		"2021-11-07T10:00:00.000005Z IPv6/UDP [::1]:53 → [::2]:5353 length 48\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+// appendUint32 appends a little endian uint32, like the AppendUint32
+// of encoding/binary that is newer than the Go version of the module.
+func appendUint32(b []byte, v uint32) []byte {
+	var u [4]byte
+	binary.LittleEndian.PutUint32(u[:], v)
+	return append(b, u[:]...)
+}
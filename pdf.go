@@ -0,0 +1,171 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The layout of the pages of a listing: A4 paper with 9pt Courier,
+// whose characters are 0.6em wide.
+const (
+	pdfWidth    = 595
+	pdfHeight   = 842
+	pdfMargin   = 50
+	pdfFontSize = 9
+	pdfLeading  = 11
+	pdfColumns  = (pdfWidth - 2*pdfMargin) * 10 / (6 * pdfFontSize)
+	pdfRows     = (pdfHeight - 2*pdfMargin - 2*pdfLeading) / pdfLeading
+	pdfNumber   = 7 // columns of the line numbers
+)
+
+// pdfDoc is a printable listing of the inputs, like enscript or a2ps
+// produce. Every input starts on a new page, whose header holds the
+// name of the input and the page number. Long lines are wrapped, and
+// only their first row is numbered.
+type pdfDoc struct {
+	pages [][]byte // the content stream of each page
+}
+
+// listing collects the inputs in the pdf output format.
+var listing *pdfDoc
+
+// add adds the content of r as the next pages of the listing.
+func (d *pdfDoc) add(name string, r io.Reader) error {
+	b, err := readAll(name, "--format=pdf", r)
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSuffix(string(b), "\n")
+
+	type row struct {
+		n    int // line number, or 0 for a wrapped row
+		text string
+	}
+	var rows []row
+	if text != "" {
+		for i, line := range strings.Split(text, "\n") {
+			rs := []rune(expandTabs(strings.TrimSuffix(line, "\r")))
+			n := i + 1
+			for len(rs) > pdfColumns-pdfNumber {
+				rows = append(rows, row{n, string(rs[:pdfColumns-pdfNumber])})
+				rs, n = rs[pdfColumns-pdfNumber:], 0
+			}
+			rows = append(rows, row{n, string(rs)})
+		}
+	}
+
+	pages := (len(rows) + pdfRows - 1) / pdfRows
+	if pages == 0 {
+		pages = 1
+	}
+	for p := 0; p < pages; p++ {
+		var b bytes.Buffer
+		top := pdfHeight - pdfMargin - pdfFontSize
+		page := fmt.Sprintf("Page %d", p+1)
+		fmt.Fprintf(&b, "BT /F2 %d Tf %d %d Td %s Tj ET\n", pdfFontSize, pdfMargin, top, pdfString(name))
+		fmt.Fprintf(&b, "BT /F2 %d Tf %d %d Td %s Tj ET\n", pdfFontSize,
+			pdfWidth-pdfMargin-len(page)*6*pdfFontSize/10, top, pdfString(page))
+		fmt.Fprintf(&b, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, top-2*pdfLeading)
+		end := (p + 1) * pdfRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		for _, r := range rows[p*pdfRows : end] {
+			if r.n > 0 {
+				fmt.Fprintf(&b, "0.5 g %s Tj 0 g ", pdfString(fmt.Sprintf("%*d  ", pdfNumber-2, r.n)))
+			} else {
+				fmt.Fprintf(&b, "%s Tj ", pdfString(strings.Repeat(" ", pdfNumber)))
+			}
+			fmt.Fprintf(&b, "%s Tj T*\n", pdfString(r.text))
+		}
+		b.WriteString("ET\n")
+		d.pages = append(d.pages, b.Bytes())
+	}
+	return nil
+}
+
+// writeTo writes the listing as a PDF document.
+func (d *pdfDoc) writeTo(w io.Writer) error {
+	var b bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\nendobj\n")
+	}
+
+	// Objects 1 to 4 are the catalog, the page tree and the fonts,
+	// followed by a page object and its content for each page.
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, 6+2*i)
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(content)
+		zw.Close()
+		object("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes())
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// pdfString returns s as a PDF string literal. The standard fonts
+// only cover Latin-1, other characters are replaced by a question mark.
+func pdfString(s string) string {
+	b := []byte{'('}
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b = append(b, '\\', byte(r))
+		case r < 0x20 || 0x7f <= r && r < 0xa0 || r > 0xff:
+			b = append(b, '?')
+		default:
+			b = append(b, byte(r))
+		}
+	}
+	return string(append(b, ')'))
+}
+
+// expandTabs replaces tabs by spaces up to the next multiple of eight.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPDFListing(t *testing.T) {
+	d := &pdfDoc{}
+	long := strings.Repeat("x", pdfColumns)
+	var src strings.Builder
+	for i := 0; i < pdfRows; i++ {
+		fmt.Fprintf(&src, "line %d\n", i+1)
+	}
+	src.WriteString(long + "\n")
+	if err := d.add("a (1).txt", strings.NewReader(src.String())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.add("empty", strings.NewReader("")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.pages) != 3 {
+		t.Fatalf("unexpected number of pages: got %d, want 3", len(d.pages))
+	}
+	for _, want := range []string{`(a \(1\).txt) Tj`, "(Page 1) Tj", "(line 1) Tj"} {
+		if !bytes.Contains(d.pages[0], []byte(want)) {
+			t.Errorf("first page lacks %q", want)
+		}
+	}
+	wrapped := fmt.Sprintf("(%5d  ) Tj 0 g (%s) Tj T*\n(       ) Tj (%s) Tj",
+		pdfRows+1, long[:pdfColumns-pdfNumber], long[pdfColumns-pdfNumber:])
+	if !bytes.Contains(d.pages[1], []byte(wrapped)) {
+		t.Errorf("second page lacks the wrapped line:\n%s", d.pages[1])
+	}
+}
+
+func TestMainPDF(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.pdf")
+	if got := runMain("--pdf", out, "testdata/a.txt", "testdata/b.md"); got != "" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read the listing: %v", err)
+	}
+	if !bytes.HasPrefix(b, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(b, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF document:\n%s", b)
+	}
+	if !bytes.Contains(b, []byte("/Count 2")) {
+		t.Fatalf("expect two pages:\n%s", b)
+	}
+
+	// The cross-reference table has to point at the objects.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+	if m == nil {
+		t.Fatalf("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(b[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref does not point at the xref table")
+	}
+	for i, off := range regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(b[xref:], -1) {
+		n, _ := strconv.Atoi(string(off[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(b[n:], []byte(want)) {
+			t.Fatalf("xref entry %d does not point at its object", i+1)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// defaultPeek is the number of lines of --peek without a value.
+const defaultPeek = 10
+
+// peekFlag is the number of lines of --peek, which may be given
+// without a value.
+type peekFlag struct {
+	n   int
+	set bool
+}
+
+func (p *peekFlag) String() string {
+	if !p.set {
+		return ""
+	}
+	return strconv.Itoa(p.n)
+}
+
+func (p *peekFlag) IsBoolFlag() bool { return true }
+
+func (p *peekFlag) Set(v string) error {
+	switch v {
+	case "true":
+		p.n, p.set = defaultPeek, true
+	case "false":
+		p.n, p.set = 0, false
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of lines %q", v)
+		}
+		p.n, p.set = n, true
+	}
+	return nil
+}
+
+// peekReader passes on the first n lines of its input and the last n,
+// with a line in between that tells how many lines it left out. It
+// reads the input once, and keeps only the lines that may be the last
+// ones, in a ring.
+type peekReader struct {
+	r       *bufio.Reader
+	n       int
+	head    int      // the number of lines passed on from the start
+	ring    [][]byte // the last lines read after the first n
+	next    int      // the index in ring of the oldest line once it is full
+	skipped int      // the number of lines dropped from the ring
+	buf     []byte
+	done    bool
+}
+
+func newPeekReader(r io.Reader, n int) *peekReader {
+	return &peekReader{r: bufio.NewReader(r), n: n}
+}
+
+func (p *peekReader) Read(b []byte) (int, error) {
+	for len(p.buf) == 0 {
+		if p.done {
+			return 0, io.EOF
+		}
+		if p.head < p.n {
+			line, err := p.r.ReadBytes('\n')
+			p.head++
+			p.buf = line
+			if err == io.EOF {
+				p.done = true
+			} else if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := p.readTail(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// readTail reads the rest of the input, and then puts the last lines
+// in the buffer.
+func (p *peekReader) readTail() error {
+	for {
+		line, err := p.r.ReadBytes('\n')
+		if len(line) > 0 {
+			if len(p.ring) < p.n {
+				p.ring = append(p.ring, line)
+			} else {
+				p.ring[p.next] = line
+				p.next = (p.next + 1) % p.n
+				p.skipped++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.done = true
+	if p.skipped > 0 {
+		lines := "lines"
+		if p.skipped == 1 {
+			lines = "line"
+		}
+		p.buf = append(p.buf, fmt.Sprintf("... %d %s elided ...\n", p.skipped, lines)...)
+	}
+	for i := range p.ring {
+		p.buf = append(p.buf, p.ring[(p.next+i)%len(p.ring)]...)
+	}
+	return nil
+}
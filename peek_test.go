@@ -0,0 +1,71 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func numberedLines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestPeekReader(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"", 2, ""},
+		{"1\n2\n3", 2, "1\n2\n3"},
+		{numberedLines(4), 2, numberedLines(4)},
+		{numberedLines(5), 2, "1\n2\n... 1 line elided ...\n4\n5\n"},
+		{numberedLines(100), 3, "1\n2\n3\n... 94 lines elided ...\n98\n99\n100\n"},
+		{numberedLines(9) + "10", 1, "1\n... 8 lines elided ...\n10"},
+	}
+	for _, tt := range tests {
+		b, err := io.ReadAll(newPeekReader(strings.NewReader(tt.in), tt.n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("peek %d of %q: got %q, want %q", tt.n, tt.in, b, tt.want)
+		}
+	}
+}
+
+func TestMainPeek(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.WriteFile(a, []byte(numberedLines(30)), 0644)
+	os.WriteFile(b, []byte("x\ny\n"), 0644)
+
+	want := numberedLines(10) + "... 10 lines elided ...\n" + strings.TrimPrefix(numberedLines(30), numberedLines(20)) + "x\ny\n"
+	if out := runMain("--peek", a, b); out != want {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if out := runMain("--peek=1", a); out != "1\n... 28 lines elided ...\n30\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	// The pretty-printed lines are peeked at.
+	j := filepath.Join(dir, "c.json")
+	os.WriteFile(j, []byte(`{"a":[1,2,3]}`), 0644)
+	if out := runMain("--peek=2", "--pretty", j); out != "{\n  \"a\": [\n... 3 lines elided ...\n  ]\n}\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	var p peekFlag
+	if err := p.Set("0"); err == nil {
+		t.Fatal("--peek=0 is accepted")
+	}
+}
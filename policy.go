@@ -0,0 +1,209 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// policy restricts the inputs that may be read, so that scripts can
+// pass arguments of users to cat. Each list that is given restricts
+// its part of the inputs. Without schemes, a policy of paths allows
+// files only and one of hosts allows http and https only, so that no
+// other input gets around the lists; an empty policy allows anything.
+type policy struct {
+	schemes []string // file, fd, http, https, journal, docker, docker-logs or oci
+	hosts   []string // of URLs, where *.example.com allows subdomains
+	paths   []string // prefixes of the paths of files
+}
+
+// inputPolicy is the policy of --policy or $CAT_POLICY, or nil.
+var inputPolicy *policy
+
+// errNotAllowed is the error of inputs that the policy does not allow.
+var errNotAllowed = errors.New("not allowed by the policy")
+
+// loadPolicy reads a policy file, which is a small subset of YAML:
+//
+//	schemes: [file, https]
+//	hosts:
+//	  - example.com
+//	  - "*.example.org"
+//	paths:
+//	  - /srv/data
+func loadPolicy(path string) (*policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	p := &policy{}
+	var list *[]string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if list == nil {
+				return nil, fmt.Errorf("%s:%d: list item without a key", path, n)
+			}
+			*list = append(*list, unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))))
+			continue
+		}
+		i := strings.IndexByte(trimmed, ':')
+		if i < 0 || line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("%s:%d: expected a key of the policy", path, n)
+		}
+		switch key := trimmed[:i]; key {
+		case "schemes":
+			list = &p.schemes
+		case "hosts":
+			list = &p.hosts
+		case "paths":
+			list = &p.paths
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q", path, n, key)
+		}
+		// Lists are given as items on the next lines, [a, b] or a
+		// single value.
+		v := strings.TrimSpace(trimmed[i+1:])
+		if v == "" {
+			continue
+		}
+		if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") {
+			v = strings.TrimSpace(v[1 : len(v)-1])
+			if v == "" {
+				*list = []string{} // allows nothing
+				continue
+			}
+			for _, item := range strings.Split(v, ",") {
+				*list = append(*list, unquote(strings.TrimSpace(item)))
+			}
+			continue
+		}
+		*list = append(*list, unquote(v))
+	}
+	for i, prefix := range p.paths {
+		abs, err := filepath.Abs(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		p.paths[i] = abs
+	}
+	return p, nil
+}
+
+// unquote strips the quotes of a YAML scalar.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// allow returns an error unless the policy allows the input.
+func (p *policy) allow(src string) error {
+	if p == nil {
+		return nil
+	}
+	scheme := "file"
+	if _, ok := fdPath(src); ok {
+		scheme = "fd"
+	}
+	if isJournal(src) {
+		scheme = "journal"
+	}
+	if isDocker(src) || isOCI(src) {
+		scheme = src[:strings.Index(src, ":")]
+	}
+	var u *url.URL
+	if isURL(src) {
+		var err error
+		if u, err = url.Parse(src); err != nil {
+			return fmt.Errorf("%s: %v", src, err)
+		}
+		scheme = u.Scheme
+	}
+	schemes := p.schemes
+	if schemes == nil && (p.paths != nil || p.hosts != nil) {
+		schemes = []string{}
+		if p.paths != nil {
+			schemes = append(schemes, "file")
+		}
+		if p.hosts != nil {
+			schemes = append(schemes, "http", "https")
+		}
+	}
+	if schemes != nil && !contains(schemes, scheme) {
+		return fmt.Errorf("%s: the %s scheme is %w", src, scheme, errNotAllowed)
+	}
+	if u != nil && p.hosts != nil && !p.allowHost(u.Hostname()) {
+		return fmt.Errorf("%s: the host %s is %w", src, u.Hostname(), errNotAllowed)
+	}
+	if scheme == "file" && p.paths != nil {
+		// Symbolic links are resolved, so that they cannot lead out of
+		// the allowed directories. A member of an archive is allowed
+		// by the path of the archive.
+		path := src
+		if archive, _, ok := archiveMember(src); ok {
+			path = archive
+		}
+		path, err := filepath.Abs(path)
+		if err == nil {
+			if real, err := filepath.EvalSymlinks(path); err == nil {
+				path = real
+			}
+		}
+		if err != nil || !p.allowPath(path) {
+			return fmt.Errorf("%s: the path is %w", src, errNotAllowed)
+		}
+	}
+	return nil
+}
+
+func (p *policy) allowHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range p.hosts {
+		h = strings.ToLower(h)
+		if h == host || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *policy) allowPath(path string) bool {
+	for _, prefix := range p.paths {
+		rel, err := filepath.Rel(prefix, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
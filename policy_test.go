@@ -0,0 +1,128 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMainPolicy(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "data")
+	os.Mkdir(allowed, 0755)
+	inside := filepath.Join(allowed, "a.txt")
+	os.WriteFile(inside, []byte("inside\n"), 0644)
+	outside := filepath.Join(dir, "secret.txt")
+	os.WriteFile(outside, []byte("outside\n"), 0644)
+	sibling := filepath.Join(dir, "database.txt")
+	os.WriteFile(sibling, []byte("sibling\n"), 0644)
+
+	policy := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policy, []byte(`# inputs of the report job
+schemes: [file, https]
+hosts:
+  - "*.example.com"
+paths:
+  - `+allowed+`
+`), 0644)
+
+	got := runMain("--policy", policy, inside, outside, sibling, "http://example.com/a", "https://evil.org/a")
+	want := "inside\n" +
+		"cat: " + outside + ": the path is not allowed by the policy\n" +
+		"cat: " + sibling + ": the path is not allowed by the policy\n" +
+		"cat: http://example.com/a: the http scheme is not allowed by the policy\n" +
+		"cat: https://evil.org/a: the host evil.org is not allowed by the policy\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+
+	if err := os.Symlink(outside, filepath.Join(allowed, "link")); err == nil {
+		got = runMain("--policy", policy, filepath.Join(allowed, "link"))
+		want = "cat: " + filepath.Join(allowed, "link") + ": the path is not allowed by the policy\n"
+		if got != want {
+			t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+		}
+	}
+
+	t.Setenv("CAT_POLICY", policy)
+	got = runMain(outside)
+	want = "cat: " + outside + ": the path is not allowed by the policy\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestMainPolicyPathsOnly(t *testing.T) {
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policy, []byte("paths: ["+dir+"]\n"), 0644)
+
+	// Only files are allowed, not any input around the paths.
+	got := runMain("--policy", policy, "/dev/fd/0", "/proc/self/fd/0", "https://example.com/a")
+	want := "cat: /dev/fd/0: the fd scheme is not allowed by the policy\n" +
+		"cat: /proc/self/fd/0: the fd scheme is not allowed by the policy\n" +
+		"cat: https://example.com/a: the https scheme is not allowed by the policy\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestMainPolicyRedirect(t *testing.T) {
+	requireFeature(t, "http")
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			// localhost is the same server under another name.
+			http.Redirect(w, r, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)+"/here", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/back" {
+			http.Redirect(w, r, "/here", http.StatusFound)
+			return
+		}
+		w.Write([]byte("here\n"))
+	}))
+	defer srv.Close()
+
+	policy := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policy, []byte("hosts: [127.0.0.1]\n"), 0644)
+	got := runMain("--policy", policy, srv.URL+"/back", srv.URL+"/away")
+	if want := "here\n"; !strings.HasPrefix(got, want) || !strings.HasSuffix(got, ": the host localhost is not allowed by the policy\n") {
+		t.Fatalf("unexpected output %q", got)
+	}
+}
+
+func TestMainPolicyCIPaths(t *testing.T) {
+	// The policy allows Public, which does not exist, but public does.
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "public"), 0755)
+	os.WriteFile(filepath.Join(dir, "public", "a.txt"), []byte("a\n"), 0644)
+	policy := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policy, []byte("paths:\n  - "+filepath.Join(dir, "Public")+"\n"), 0644)
+
+	src := filepath.Join(dir, "Public", "a.txt")
+	got := runMain("--policy", policy, "--ci-paths", src)
+	if _, err := os.Stat(src); err == nil {
+		t.Skip("the file system is case-insensitive")
+	}
+	want := "cat: " + filepath.Join(dir, "public", "a.txt") + ": the path is not allowed by the policy\n"
+	if got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(policy, []byte("schemes: [file]\nusers: [root]\n"), 0644)
+	_, err := loadPolicy(policy)
+	if err == nil || err.Error() != policy+`:2: unknown key "users"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configPath returns the path of the config file of cat, which is
+// $CAT_CONFIG or cat/config.yaml in the config directory of the user,
+// e.g. ~/.config/cat/config.yaml.
+func configPath() (string, error) {
+	if p := os.Getenv("CAT_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cat", "config.yaml"), nil
+}
+
+// loadPresets reads the presets of a config file, named lists of flags
+// in the same subset of YAML as policies:
+//
+//	log: [--summary, --chop-long-lines]
+//	code:
+//	  - --pager
+//	  - --chop-long-lines
+func loadPresets(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	presets := map[string][]string{}
+	var name string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") {
+			if name == "" {
+				return nil, fmt.Errorf("%s:%d: list item without a preset", path, n)
+			}
+			presets[name] = append(presets[name], unquote(strings.TrimSpace(trimmed[2:])))
+			continue
+		}
+		i := strings.IndexByte(trimmed, ':')
+		if i < 0 || line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("%s:%d: expected the name of a preset", path, n)
+		}
+		name = strings.TrimSpace(trimmed[:i])
+		presets[name] = nil
+		v := strings.TrimSpace(trimmed[i+1:])
+		switch {
+		case v == "":
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			for _, item := range strings.Split(v[1:len(v)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					presets[name] = append(presets[name], unquote(item))
+				}
+			}
+		default:
+			presets[name] = []string{unquote(v)}
+		}
+	}
+	return presets, nil
+}
+
+// presetName returns the name of the preset on a command line, which
+// has to be known before the flags are parsed.
+func presetName(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		switch {
+		case arg == "-preset" || arg == "--preset":
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+		case strings.HasPrefix(arg, "-preset="), strings.HasPrefix(arg, "--preset="):
+			return arg[strings.IndexByte(arg, '=')+1:], true
+		}
+	}
+	return "", false
+}
+
+// applyPreset parses the flags of the named preset, before those of
+// the command line, which thereby override them.
+func applyPreset(fs *flag.FlagSet, name string) error {
+	path, err := configPath()
+	if err != nil {
+		return fmt.Errorf("--preset: %v", err)
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unknown preset %q, there is no config file %s", name, path)
+	}
+	presets, err := loadPresets(path)
+	if err != nil {
+		return err
+	}
+	args, ok := presets[name]
+	if !ok {
+		return fmt.Errorf("unknown preset %q, not in %s", name, path)
+	}
+	for _, arg := range args {
+		flagName := strings.TrimLeft(arg, "-")
+		if i := strings.IndexByte(flagName, '='); i >= 0 {
+			flagName = flagName[:i]
+		}
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("preset %q: %q is no flag", name, arg)
+		case flagName == "preset":
+			return fmt.Errorf("preset %q: presets cannot select presets", name)
+		case fs.Lookup(flagName) == nil:
+			return fmt.Errorf("preset %q: unknown flag %s", name, arg)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("preset %q: %v", name, err)
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("preset %q: %q is no flag", name, fs.Arg(0))
+	}
+	return nil
+}
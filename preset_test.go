@@ -0,0 +1,99 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`# presets
+log: [--summary, "--chop-long-lines"]
+code:
+  - --pager
+  - --chop-long-lines # to scroll
+one: --peek
+none:
+`), 0644)
+	presets, err := loadPresets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"log":  {"--summary", "--chop-long-lines"},
+		"code": {"--pager", "--chop-long-lines"},
+		"one":  {"--peek"},
+		"none": nil,
+	}
+	if !reflect.DeepEqual(presets, want) {
+		t.Fatalf("got %q, want %q", presets, want)
+	}
+
+	os.WriteFile(path, []byte("- --pager\n"), 0644)
+	if _, err := loadPresets(path); err == nil || !strings.Contains(err.Error(), ":1: list item without a preset") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestPresetName(t *testing.T) {
+	tests := []struct {
+		args []string
+		name string
+		ok   bool
+	}{
+		{[]string{"a.txt"}, "", false},
+		{[]string{"--preset", "log", "a.txt"}, "log", true},
+		{[]string{"-o", "out", "-preset=code"}, "code", true},
+		{[]string{"--", "--preset", "log"}, "", false},
+		{[]string{"--preset"}, "", false},
+	}
+	for _, tt := range tests {
+		if name, ok := presetName(tt.args); name != tt.name || ok != tt.ok {
+			t.Errorf("presetName(%q) = %q, %v", tt.args, name, ok)
+		}
+	}
+}
+
+func TestMainPreset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	t.Setenv("CAT_CONFIG", path)
+	input := filepath.Join(dir, "in.txt")
+	os.WriteFile(input, []byte(numberedLines(10)), 0644)
+	os.WriteFile(path, []byte(`short: [--peek=1]
+loop: [--preset=short]
+files: [--peek, in.txt]
+typo: [--peak]
+`), 0644)
+
+	if out := runMain("--preset", "short", input); out != "1\n... 8 lines elided ...\n10\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	// The command line overrides the preset.
+	if out := runMain("--preset=short", "--peek=4", input); !strings.HasPrefix(out, "1\n2\n3\n4\n... 2 lines elided ...\n") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	for name, want := range map[string]string{
+		"nope":  fmt.Sprintf("cat: unknown preset \"nope\", not in %s\n", path),
+		"loop":  "cat: preset \"loop\": presets cannot select presets\n",
+		"files": "cat: preset \"files\": \"in.txt\" is no flag\n",
+		"typo":  "cat: preset \"typo\": unknown flag --peak\n",
+	} {
+		if out, code := runMainCode("--preset", name, input); out != want || code != exitUsage {
+			t.Errorf("%s: unexpected output %q and exit code %d", name, out, code)
+		}
+	}
+
+	os.Remove(path)
+	if out := runMain("--preset", "short", input); out != fmt.Sprintf("cat: unknown preset \"short\", there is no config file %s\n", path) {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
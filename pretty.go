@@ -0,0 +1,299 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// formatters maps languages, as named by the languages table, to
+// pretty-printers that normalize the indentation of their source.
+var formatters = map[string]func(w *bytes.Buffer, src []byte) error{
+	"json": prettyJSON,
+	"xml":  prettyXML,
+	"ini":  prettyINI,
+	"toml": prettyTOML,
+}
+
+// prettify returns the content of the named input pretty-printed as
+// its language, which is taken from --lang or the name of the input.
+// Content without a formatter is returned as it is. If formatting
+// fails, the original content is returned along with the error, so
+// that nothing gets lost.
+func prettify(name string, r io.Reader) (io.Reader, error) {
+	lang := opts.lang
+	if lang == "" {
+		lang = language(name)
+	}
+	format, ok := formatters[lang]
+	if !ok {
+		return r, nil
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := format(&b, src); err != nil {
+		return bytes.NewReader(src), fmt.Errorf("%s: invalid %s: %v", name, lang, err)
+	}
+	return &b, nil
+}
+
+func prettyJSON(w *bytes.Buffer, src []byte) error {
+	if err := json.Indent(w, src, "", "  "); err != nil {
+		return err
+	}
+	w.WriteByte('\n')
+	return nil
+}
+
+// prettyXML indents the elements of an XML document by their depth.
+// Elements that only hold text stay on one line, and empty elements
+// are closed right away as in <br/>.
+func prettyXML(w *bytes.Buffer, src []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.Strict = false
+	depth := 0
+	open := false // whether the > of the last start element is missing
+	text := false // whether the current element holds text
+	newline := func() {
+		if w.Len() > 0 {
+			w.WriteByte('\n')
+		}
+		w.WriteString(strings.Repeat("  ", depth))
+	}
+	closeStart := func() {
+		if open {
+			w.WriteByte('>')
+			open = false
+		}
+	}
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			closeStart()
+			newline()
+			w.WriteString("<" + xmlName(t.Name))
+			for _, a := range t.Attr {
+				w.WriteString(" " + xmlName(a.Name) + `="`)
+				xml.EscapeText(w, []byte(a.Value))
+				w.WriteByte('"')
+			}
+			open, text = true, false
+			depth++
+		case xml.EndElement:
+			if depth == 0 {
+				return fmt.Errorf("unexpected end element </%s>", xmlName(t.Name))
+			}
+			depth--
+			if open {
+				w.WriteString("/>")
+				open = false
+				break
+			}
+			if !text {
+				newline()
+			}
+			w.WriteString("</" + xmlName(t.Name) + ">")
+			text = false
+		case xml.CharData:
+			s := strings.TrimSpace(string(t))
+			if s == "" {
+				break
+			}
+			closeStart()
+			xml.EscapeText(w, []byte(s))
+			text = true
+		case xml.Comment:
+			closeStart()
+			newline()
+			w.WriteString("<!--" + string(t) + "-->")
+		case xml.ProcInst:
+			closeStart()
+			newline()
+			w.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				w.WriteString(" " + string(t.Inst))
+			}
+			w.WriteString("?>")
+		case xml.Directive:
+			closeStart()
+			newline()
+			w.WriteString("<!" + string(t) + ">")
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("unexpected end of document")
+	}
+	w.WriteByte('\n')
+	return nil
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// prettyINI trims every line, puts an empty line before each section
+// and a single space around the = or : of each key.
+func prettyINI(w *bytes.Buffer, src []byte) error {
+	s := bufio.NewScanner(bytes.NewReader(src))
+	s.Buffer(nil, len(src)+1)
+	blank := false
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "":
+			blank = w.Len() > 0
+			continue
+		case strings.HasPrefix(line, "["):
+			if w.Len() > 0 {
+				blank = true
+			}
+		case strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#"):
+		default:
+			if i := strings.IndexAny(line, "=:"); i > 0 {
+				line = strings.TrimSpace(line[:i]) + " " + line[i:i+1] + " " + strings.TrimSpace(line[i+1:])
+				line = strings.TrimRight(line, " ")
+			}
+		}
+		if blank {
+			w.WriteByte('\n')
+			blank = false
+		}
+		w.WriteString(line + "\n")
+	}
+	return s.Err()
+}
+
+// prettyTOML puts an empty line before each table, a single space
+// around the = of each key, and indents the elements of arrays that
+// span lines by their depth. Multi-line strings are kept as they are.
+func prettyTOML(w *bytes.Buffer, src []byte) error {
+	lines := strings.Split(strings.TrimRight(string(src), "\r\n"), "\n")
+	depth := 0  // of the arrays and inline tables open at the start of a line
+	quote := "" // the delimiter of the multi-line string that is open
+	blank := false
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if quote != "" {
+			w.WriteString(line + "\n")
+			quote = tomlScan(line, quote, &depth)
+			continue
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			blank = w.Len() > 0
+			continue
+		case depth == 0 && strings.HasPrefix(line, "["):
+			if w.Len() > 0 {
+				blank = true
+			}
+		case depth == 0 && !strings.HasPrefix(line, "#"):
+			if j := tomlKeyEnd(line); j > 0 {
+				line = strings.TrimSpace(line[:j]) + " = " + strings.TrimSpace(line[j+1:])
+			}
+		}
+		if blank {
+			w.WriteByte('\n')
+			blank = false
+		}
+		indent := depth
+		if strings.HasPrefix(line, "]") || strings.HasPrefix(line, "}") {
+			indent--
+		}
+		if indent > 0 {
+			w.WriteString(strings.Repeat("  ", indent))
+		}
+		w.WriteString(line + "\n")
+		quote = tomlScan(line, "", &depth)
+		if depth < 0 {
+			return fmt.Errorf("line %d: unbalanced brackets", i+1)
+		}
+	}
+	if quote != "" {
+		return fmt.Errorf("unterminated string")
+	}
+	if depth != 0 {
+		return fmt.Errorf("unterminated array")
+	}
+	return nil
+}
+
+// tomlKeyEnd returns the index of the = that ends the key of a line,
+// or -1 if there is none.
+func tomlKeyEnd(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlScan follows the strings, comments and brackets of a line of TOML,
+// starting in the given multi-line string, if any. It updates depth by
+// the brackets outside of strings and returns the delimiter of the
+// multi-line string that is still open at the end of the line.
+func tomlScan(line, quote string, depth *int) string {
+	for i := 0; i < len(line); i++ {
+		rest := line[i:]
+		if quote != "" {
+			switch {
+			case strings.HasPrefix(rest, quote):
+				i += len(quote) - 1
+				quote = ""
+			case rest[0] == '\\' && quote != "'" && quote != "'''":
+				i++
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(rest, `"""`), strings.HasPrefix(rest, "'''"):
+			quote = rest[:3]
+			i += 2
+		case rest[0] == '"' || rest[0] == '\'':
+			quote = rest[:1]
+		case rest[0] == '#':
+			return ""
+		case rest[0] == '[' || rest[0] == '{':
+			*depth++
+		case rest[0] == ']' || rest[0] == '}':
+			*depth--
+		}
+	}
+	if quote == `"` || quote == "'" {
+		return "" // single line strings end with the line anyway
+	}
+	return quote
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		lang, in, want string
+	}{
+		{"json", `{"a":[1,2],"b":{}}`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": {}\n}\n"},
+		{"xml",
+			`<?xml version="1.0"?><a x="1&amp;2"><b>text</b><c/><d:e xmlns:d="urn:d">  <!-- c --></d:e></a>`,
+			"<?xml version=\"1.0\"?>\n<a x=\"1&amp;2\">\n  <b>text</b>\n  <c/>\n  <d:e xmlns:d=\"urn:d\">\n    <!-- c -->\n  </d:e>\n</a>\n"},
+		{"ini", "  ; comment\n[a]\nk=v\n\n\n  x :  y  \nempty=\n[b]\n", "; comment\n\n[a]\nk = v\n\nx : y\nempty =\n\n[b]\n"},
+		{"toml",
+			"title='x=y'\n[server]\nports=[\n8000,\n  [1, 2],\n]\ntext = \"\"\"\n  keep [\n\"\"\"\n[[items]]\n\"a=b\"=1 # c\n",
+			"title = 'x=y'\n\n[server]\nports = [\n  8000,\n  [1, 2],\n]\ntext = \"\"\"\n  keep [\n\"\"\"\n\n[[items]]\n\"a=b\" = 1 # c\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := formatters[tt.lang](&b, []byte(tt.in)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.lang, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.lang, b.String(), tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ lang, in string }{
+		{"json", `{"a":`},
+		{"xml", "<a><b></a>"},
+		{"xml", "</A>"},
+		{"xml", "<a></a></b><c>"},
+		{"toml", "a = [1,\n"},
+		{"toml", "a = ]\n]\n"},
+	} {
+		if err := formatters[tt.lang](new(bytes.Buffer), []byte(tt.in)); err == nil {
+			t.Errorf("%s: expect an error for %q", tt.lang, tt.in)
+		}
+	}
+}
+
+func FuzzFormatters(f *testing.F) {
+	f.Add("xml", []byte("<a><b>text</b></a>"))
+	f.Add("xml", []byte("</A>"))
+	f.Add("json", []byte(`{"a":[1]}`))
+	f.Add("ini", []byte("[a]\nk=v\n"))
+	f.Add("toml", []byte("a = [\n1,\n]\n]\n"))
+	f.Fuzz(func(t *testing.T, lang string, src []byte) {
+		format, ok := formatters[lang]
+		if !ok {
+			return
+		}
+		// Malformed input fails, but must not panic.
+		format(new(bytes.Buffer), src)
+	})
+}
+
+func TestMainPretty(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "conf")
+	os.WriteFile(conf, []byte(`{"a":1}`), 0644)
+	if got, want := runMain("--pretty", "--lang", "json", conf), "{\n  \"a\": 1\n}\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+
+	// Invalid input is written as it is.
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"a":`), 0644)
+	got := runMain("--pretty", bad)
+	if want := `{"a":cat: ` + bad + ": invalid json: unexpected end of JSON input\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
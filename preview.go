@@ -0,0 +1,57 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// previewer summarizes inputs of a binary format as text, e.g. the
+// schema and the first records of a data file.
+type previewer struct {
+	name string
+	// detect reports whether an input is of the format, by the first
+	// bytes of its content and its name.
+	detect  func(head []byte, name string) bool
+	preview func(w io.Writer, r *bufio.Reader) error
+}
+
+// previewers are the formats known to --preview, in the order they
+// are tried.
+var previewers = []previewer{
+	{"avro", isAvro, previewAvro},
+	{"pcap", isPcap, previewPcap},
+	{"ELF", isELF, previewELF},
+	{"Mach-O", isMachO, previewMachO},
+	{"PE", isPE, previewPE},
+	{"notebook", isNotebook, previewNotebook},
+	{"PEM", isPEM, previewPEM},
+}
+
+// previewHead is the number of bytes that previewers can detect their
+// format by.
+const previewHead = 512
+
+// preview writes a preview of the given input in its format.
+func preview(src string, w io.Writer) error {
+	return readInput(src, func(name string, r io.Reader) error {
+		br := bufio.NewReaderSize(r, 64*1024)
+		head, err := br.Peek(previewHead)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return err
+		}
+		for _, p := range previewers {
+			if p.detect(head, name) {
+				if err := p.preview(w, br); err != nil {
+					return fmt.Errorf("%s: invalid %s file: %v", name, p.name, err)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: no preview available for this format", name)
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMainProfile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "a.txt")
+	os.WriteFile(name, []byte("hello\n"), 0644)
+	profile := filepath.Join(dir, "cpu.out")
+
+	if got := runMain("--profile", profile, name); got != "hello\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+	if i, err := os.Stat(profile); err != nil || i.Size() == 0 {
+		t.Fatalf("no profile written: %v", err)
+	}
+
+	requireFeature(t, "http")
+	got := runMain("--profile-http", "127.0.0.1:0", name)
+	if !strings.HasPrefix(got, "cat: serving profiles at http://127.0.0.1:") || !strings.HasSuffix(got, "/debug/pprof/\nhello\n") {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
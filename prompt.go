@@ -0,0 +1,68 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openTTY opens the controlling terminal to read answers from, since
+// stdin usually carries the data to concatenate.
+var openTTY = func() (io.ReadCloser, error) {
+	if runtime.GOOS == "windows" {
+		return os.Open("CONIN$")
+	}
+	return os.Open("/dev/tty")
+}
+
+// confirm asks a yes or no question on the terminal. Without a
+// terminal to ask, e.g. in scripts, the answer is no.
+func confirm(format string, args ...interface{}) bool {
+	tty, err := openTTY()
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(os.Stderr, "cat: "+tr(format)+" [y/N] ", args...)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// readPassword asks for a password on the terminal, which does not echo
+// it where stty can turn that off.
+func readPassword(format string, args ...interface{}) (string, error) {
+	tty, err := openTTY()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	if f, ok := tty.(*os.File); ok && runtime.GOOS != "windows" {
+		stty := func(arg string) {
+			cmd := exec.Command("stty", arg)
+			cmd.Stdin = f
+			cmd.Run()
+		}
+		stty("-echo")
+		defer func() {
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	fmt.Fprintf(os.Stderr, "cat: "+tr(format)+" ", args...)
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && answer == "" {
+		return "", err
+	}
+	return strings.TrimRight(answer, "\r\n"), nil
+}
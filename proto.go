@@ -0,0 +1,475 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func init() { registerFeature("proto") }
+
+// The wire types of the protobuf encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// The field types of a FieldDescriptorProto.
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+
+	protoRepeated = 3 // the label of repeated fields
+)
+
+// protoSchema holds the message and enum types of a descriptor set, as
+// produced by protoc --descriptor_set_out, by their full names.
+type protoSchema struct {
+	messages map[string]*protoMessageType
+	enums    map[string]map[int32]string
+}
+
+type protoMessageType struct {
+	name     string
+	fields   map[uint64]*protoField
+	mapEntry bool
+}
+
+type protoField struct {
+	name     string // as in JSON
+	typ      int
+	typeName string
+	repeated bool
+}
+
+// protoWalk calls fn for each field of an encoded message. For varint
+// and fixed fields v is the value, for length-delimited ones b.
+func protoWalk(msg []byte, fn func(num uint64, wt int, v uint64, b []byte) error) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errors.New("malformed field key")
+		}
+		msg = msg[n:]
+		num, wt := key>>3, int(key&7)
+		var v uint64
+		var b []byte
+		switch wt {
+		case wireVarint:
+			v, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return errors.New("malformed varint")
+			}
+			msg = msg[n:]
+		case wireFixed64:
+			if len(msg) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			v, msg = binary.LittleEndian.Uint64(msg), msg[8:]
+		case wireFixed32:
+			if len(msg) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			v, msg = uint64(binary.LittleEndian.Uint32(msg)), msg[4:]
+		case wireBytes:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || l > uint64(len(msg)-n) {
+				return io.ErrUnexpectedEOF
+			}
+			b, msg = msg[n:n+int(l)], msg[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wt)
+		}
+		if err := fn(num, wt, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadProtoSchema reads a FileDescriptorSet.
+func loadProtoSchema(path string) (*protoSchema, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	s := &protoSchema{
+		messages: map[string]*protoMessageType{},
+		enums:    map[string]map[int32]string{},
+	}
+	err = protoWalk(b, func(num uint64, wt int, _ uint64, file []byte) error {
+		if num != 1 || wt != wireBytes {
+			return nil
+		}
+		var pkg string
+		var messages, enums [][]byte
+		err := protoWalk(file, func(num uint64, wt int, _ uint64, b []byte) error {
+			switch {
+			case wt != wireBytes:
+			case num == 2:
+				pkg = string(b)
+			case num == 4:
+				messages = append(messages, b)
+			case num == 5:
+				enums = append(enums, b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, m := range messages {
+			if err := s.addMessage(pkg, m); err != nil {
+				return err
+			}
+		}
+		for _, e := range enums {
+			if err := s.addEnum(pkg, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid descriptor set: %v", path, err)
+	}
+	return s, nil
+}
+
+// addMessage adds a DescriptorProto and its nested types.
+func (s *protoSchema) addMessage(scope string, desc []byte) error {
+	m := &protoMessageType{fields: map[uint64]*protoField{}}
+	var fields, nested, enums [][]byte
+	err := protoWalk(desc, func(num uint64, wt int, _ uint64, b []byte) error {
+		switch {
+		case wt != wireBytes:
+		case num == 1:
+			m.name = qualify(scope, string(b))
+		case num == 2:
+			fields = append(fields, b)
+		case num == 3:
+			nested = append(nested, b)
+		case num == 4:
+			enums = append(enums, b)
+		case num == 7: // MessageOptions
+			return protoWalk(b, func(num uint64, wt int, v uint64, _ []byte) error {
+				if num == 7 && wt == wireVarint {
+					m.mapEntry = v != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, fd := range fields {
+		f := &protoField{}
+		var number uint64
+		var jsonName string
+		err := protoWalk(fd, func(num uint64, wt int, v uint64, b []byte) error {
+			switch num {
+			case 1:
+				f.name = string(b)
+			case 3:
+				number = v
+			case 4:
+				f.repeated = v == protoRepeated
+			case 5:
+				f.typ = int(v)
+			case 6:
+				f.typeName = strings.TrimPrefix(string(b), ".")
+			case 10:
+				jsonName = string(b)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if jsonName != "" {
+			f.name = jsonName
+		}
+		m.fields[number] = f
+	}
+	s.messages[m.name] = m
+	for _, n := range nested {
+		if err := s.addMessage(m.name, n); err != nil {
+			return err
+		}
+	}
+	for _, e := range enums {
+		if err := s.addEnum(m.name, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addEnum adds an EnumDescriptorProto.
+func (s *protoSchema) addEnum(scope string, desc []byte) error {
+	var name string
+	values := map[int32]string{}
+	err := protoWalk(desc, func(num uint64, wt int, _ uint64, b []byte) error {
+		switch {
+		case wt != wireBytes:
+		case num == 1:
+			name = qualify(scope, string(b))
+		case num == 2:
+			var vname string
+			var v uint64
+			err := protoWalk(b, func(num uint64, _ int, n uint64, b []byte) error {
+				switch num {
+				case 1:
+					vname = string(b)
+				case 2:
+					v = n
+				}
+				return nil
+			})
+			values[int32(v)] = vname
+			return err
+		}
+		return nil
+	})
+	s.enums[name] = values
+	return err
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// decode decodes an encoded message of type t as a JSON object in the
+// canonical JSON mapping of protobuf. Fields that are unknown to the
+// schema are shown by their number.
+func (s *protoSchema) decode(t *protoMessageType, msg []byte) (jsonObject, error) {
+	var obj jsonObject
+	index := map[string]int{}
+	set := func(key string, v interface{}, repeated bool) {
+		i, ok := index[key]
+		if !ok {
+			i = len(obj)
+			index[key] = i
+			obj = append(obj, jsonMember{key: key})
+		}
+		if repeated {
+			list, _ := obj[i].value.([]interface{})
+			obj[i].value = append(list, v)
+			return
+		}
+		obj[i].value = v
+	}
+	err := protoWalk(msg, func(num uint64, wt int, v uint64, b []byte) error {
+		f, ok := t.fields[num]
+		if !ok {
+			set(strconv.FormatUint(num, 10), protoUnknown(wt, v, b), false)
+			return nil
+		}
+		if mt := s.messages[f.typeName]; f.typ == protoMessage && mt != nil && mt.mapEntry {
+			entry, err := s.decode(mt, b)
+			if err != nil {
+				return err
+			}
+			i, ok := index[f.name]
+			if !ok {
+				i = len(obj)
+				index[f.name] = i
+				obj = append(obj, jsonMember{key: f.name, value: jsonObject{}})
+			}
+			var key, val interface{}
+			for _, m := range entry {
+				switch {
+				case mt.fields[1] != nil && m.key == mt.fields[1].name:
+					key = m.value
+				case mt.fields[2] != nil && m.key == mt.fields[2].name:
+					val = m.value
+				}
+			}
+			obj[i].value = append(obj[i].value.(jsonObject), jsonMember{fmt.Sprint(key), val})
+			return nil
+		}
+		if wt == wireBytes && f.typ != protoString && f.typ != protoBytes && f.typ != protoMessage {
+			// A packed repeated scalar.
+			return protoWalkPacked(f.typ, b, func(v uint64) {
+				set(f.name, s.scalar(f, v), true)
+			})
+		}
+		var val interface{}
+		switch f.typ {
+		case protoString:
+			val = string(b)
+		case protoBytes:
+			val = base64.StdEncoding.EncodeToString(b)
+		case protoMessage:
+			mt, ok := s.messages[f.typeName]
+			if !ok {
+				return fmt.Errorf("unknown message type %s", f.typeName)
+			}
+			m, err := s.decode(mt, b)
+			if err != nil {
+				return err
+			}
+			val = m
+		default:
+			val = s.scalar(f, v)
+		}
+		set(f.name, val, f.repeated)
+		return nil
+	})
+	if obj == nil {
+		obj = jsonObject{}
+	}
+	return obj, err
+}
+
+// scalar converts the raw value of a numeric field. As in the JSON
+// mapping of protobuf, 64-bit integers are strings, because they do
+// not fit into the numbers of JavaScript.
+func (s *protoSchema) scalar(f *protoField, v uint64) interface{} {
+	switch f.typ {
+	case protoDouble:
+		return jsonFloat(math.Float64frombits(v))
+	case protoFloat:
+		return jsonFloat(float64(math.Float32frombits(uint32(v))))
+	case protoInt64, protoSfixed64:
+		return strconv.FormatInt(int64(v), 10)
+	case protoUint64, protoFixed64:
+		return strconv.FormatUint(v, 10)
+	case protoSint64:
+		return strconv.FormatInt(int64(v>>1)^-int64(v&1), 10)
+	case protoInt32, protoSfixed32:
+		return int32(v)
+	case protoSint32:
+		return int32(uint32(v)>>1) ^ -int32(v&1)
+	case protoUint32, protoFixed32:
+		return uint32(v)
+	case protoBool:
+		return v != 0
+	case protoEnum:
+		if name, ok := s.enums[f.typeName][int32(v)]; ok {
+			return name
+		}
+		return int32(v)
+	}
+	return v
+}
+
+// protoWalkPacked calls fn for each element of a packed repeated field.
+func protoWalkPacked(typ int, b []byte, fn func(v uint64)) error {
+	for len(b) > 0 {
+		switch typ {
+		case protoDouble, protoFixed64, protoSfixed64:
+			if len(b) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			fn(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		case protoFloat, protoFixed32, protoSfixed32:
+			if len(b) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			fn(uint64(binary.LittleEndian.Uint32(b)))
+			b = b[4:]
+		default:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("malformed varint")
+			}
+			fn(v)
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// protoUnknown shows the value of a field that is not in the schema.
+func protoUnknown(wt int, v uint64, b []byte) interface{} {
+	if wt == wireBytes {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return v
+}
+
+// protoDecoder is the message type of --proto-type, if any.
+var protoDecoder struct {
+	schema    *protoSchema
+	typ       *protoMessageType
+	delimited bool
+}
+
+// setupProto loads the message type that the proto decoder decodes.
+func setupProto(desc, typ string, delimited bool) error {
+	s, err := loadProtoSchema(desc)
+	if err != nil {
+		return err
+	}
+	t, ok := s.messages[strings.TrimPrefix(typ, ".")]
+	if !ok {
+		return fmt.Errorf("%s: no message type %s", desc, typ)
+	}
+	protoDecoder.schema, protoDecoder.typ, protoDecoder.delimited = s, t, delimited
+	return nil
+}
+
+// newProtoReader decodes a message, or a stream of length-delimited
+// messages, of the type given by --proto-type as JSON.
+func newProtoReader(r io.Reader) (io.Reader, error) {
+	s, t := protoDecoder.schema, protoDecoder.typ
+	if t == nil {
+		return nil, errors.New("no message type, use --proto-desc and --proto-type")
+	}
+	decode := func(b []byte) (interface{}, error) { return s.decode(t, b) }
+	if !protoDecoder.delimited {
+		return &jsonReader{name: "proto", next: readOnce(r, decode)}, nil
+	}
+	br := bufio.NewReader(r)
+	return &jsonReader{name: "proto", next: func() (interface{}, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+		b, err := readN(br, n)
+		if err != nil {
+			return nil, err
+		}
+		return decode(b)
+	}}, nil
+}
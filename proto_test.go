@@ -0,0 +1,156 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pb appends protobuf fields: a uint64 is written as a varint, a
+// string or []byte as a length-delimited field.
+func pb(fields ...interface{}) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := uint64(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case uint64:
+			b = appendUvarint(b, num<<3|wireVarint)
+			b = appendUvarint(b, v)
+		case string:
+			b = appendUvarint(b, num<<3|wireBytes)
+			b = appendUvarint(b, uint64(len(v)))
+			b = append(b, v...)
+		case []byte:
+			b = appendUvarint(b, num<<3|wireBytes)
+			b = appendUvarint(b, uint64(len(v)))
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// testDescriptorSet describes
+//
+//	package test;
+//	message Msg {
+//	  enum Color { RED = 0; BLUE = 1; }
+//	  message Inner { bool ok = 1; }
+//	  string name = 1;
+//	  sint64 id = 2;
+//	  repeated int32 vals = 3;
+//	  Color color = 4;
+//	  Inner inner = 5;
+//	  map<string, int32> tags = 6;
+//	}
+func testDescriptorSet() []byte {
+	field := func(name string, num, label, typ uint64, typeName string) []byte {
+		return pb(1, name, 3, num, 4, label, 5, typ, 6, typeName)
+	}
+	msg := pb(
+		1, "Msg",
+		2, field("name", 1, 1, protoString, ""),
+		2, field("id", 2, 1, protoSint64, ""),
+		2, field("vals", 3, protoRepeated, protoInt32, ""),
+		2, field("color", 4, 1, protoEnum, ".test.Msg.Color"),
+		2, field("inner", 5, 1, protoMessage, ".test.Msg.Inner"),
+		2, field("tags", 6, protoRepeated, protoMessage, ".test.Msg.TagsEntry"),
+		3, pb(1, "Inner", 2, field("ok", 1, 1, protoBool, "")),
+		3, pb(1, "TagsEntry",
+			2, field("key", 1, 1, protoString, ""),
+			2, field("value", 2, 1, protoInt32, ""),
+			7, pb(7, uint64(1))),
+		4, pb(1, "Color", 2, pb(1, "RED", 2, uint64(0)), 2, pb(1, "BLUE", 2, uint64(1))),
+	)
+	return pb(1, pb(1, "test.proto", 2, "test", 4, msg))
+}
+
+func testMessage() []byte {
+	return pb(
+		1, "cat",
+		2, uint64(3), // -2 in zigzag
+		3, []byte{1, 2, 0x96, 0x01},
+		4, uint64(1),
+		5, pb(1, uint64(1)),
+		6, pb(1, "a", 2, uint64(7)),
+		6, pb(1, "b", 2, uint64(8)),
+		9, uint64(42),
+	)
+}
+
+func TestProtoDecode(t *testing.T) {
+	dir := t.TempDir()
+	desc := filepath.Join(dir, "set.pb")
+	os.WriteFile(desc, testDescriptorSet(), 0644)
+	defer func() { protoDecoder.schema, protoDecoder.typ = nil, nil }()
+
+	if err := setupProto(desc, "test.Nope", false); err == nil {
+		t.Fatalf("expect an error for an unknown message type")
+	}
+	if err := setupProto(desc, "test.Msg", false); err != nil {
+		t.Fatalf("failed to load the descriptor set: %v", err)
+	}
+	r, err := newProtoReader(bytes.NewReader(testMessage()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{
+  "name": "cat",
+  "id": "-2",
+  "vals": [
+    1,
+    2,
+    150
+  ],
+  "color": "BLUE",
+  "inner": {
+    "ok": true
+  },
+  "tags": {
+    "a": 7,
+    "b": 8
+  },
+  "9": 42
+}
+`
+	if string(got) != want {
+		t.Fatalf("unexpected JSON: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMainProtoDelimited(t *testing.T) {
+	dir := t.TempDir()
+	desc := filepath.Join(dir, "set.pb")
+	os.WriteFile(desc, testDescriptorSet(), 0644)
+	defer func() { protoDecoder.schema, protoDecoder.typ = nil, nil }()
+
+	var stream []byte
+	for _, m := range [][]byte{pb(1, "x"), pb(4, uint64(0))} {
+		stream = appendUvarint(stream, uint64(len(m)))
+		stream = append(stream, m...)
+	}
+	in := filepath.Join(dir, "msgs.bin")
+	os.WriteFile(in, stream, 0644)
+
+	got := runMain("--proto-desc", desc, "--proto-type", "test.Msg", "--proto-delimited", in)
+	if want := "{\n  \"name\": \"x\"\n}\n{\n  \"color\": \"RED\"\n}\n"; got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
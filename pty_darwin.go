@@ -0,0 +1,41 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// openPTY opens a new pseudo-terminal and returns its master side and
+// the path of its slave side.
+func openPTY() (*os.File, string, error) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var name [128]byte
+	for _, req := range []uintptr{syscall.TIOCPTYGRANT, syscall.TIOCPTYUNLK} {
+		if err := ioctl(m, req, nil); err != nil {
+			m.Close()
+			return nil, "", err
+		}
+	}
+	if err := ioctl(m, syscall.TIOCPTYGNAME, unsafe.Pointer(&name)); err != nil {
+		m.Close()
+		return nil, "", err
+	}
+	if i := bytes.IndexByte(name[:], 0); i >= 0 {
+		return m, string(name[:i]), nil
+	}
+	return m, string(name[:]), nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+func bridgePTY(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	return fmt.Errorf("--pty is not supported on %s", runtime.GOOS)
+}
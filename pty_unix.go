@@ -0,0 +1,178 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// bridgePTY is bridge with the command running under a pseudo-terminal,
+// for interactive programs that behave differently, or not at all,
+// when their standard streams are not a terminal.
+//
+// If stdin is a terminal, it is put in raw mode so that every key goes
+// to the command as it is typed, and the size of its window is passed
+// on to the command whenever it changes. Otherwise, the
+// pseudo-terminal does not echo the input, which would end up in the
+// output, and the end of the input is passed on as end-of-file
+// characters.
+func bridgePTY(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	master, slaveName, err := openPTY()
+	if err != nil {
+		return fmt.Errorf("--pty: %v", err)
+	}
+	defer master.Close()
+	slave, err := os.OpenFile(slaveName, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return fmt.Errorf("--pty: %v", err)
+	}
+
+	term, _ := stdin.(*os.File)
+	if term != nil && !isTerminal(term) {
+		term = nil
+	}
+	if term != nil {
+		resizePTY(master, term)
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		go func() {
+			for range winch {
+				resizePTY(master, term)
+			}
+		}()
+		restore, err := makeRaw(term)
+		if err != nil {
+			slave.Close()
+			return fmt.Errorf("--pty: %v", err)
+		}
+		defer restore()
+	} else if err := setEcho(slave, false); err != nil {
+		slave.Close()
+		return fmt.Errorf("--pty: %v", err)
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	// The pseudo-terminal becomes the controlling terminal of the
+	// command in a session of its own.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		buf := make([]byte, 32<<10)
+		last := byte('\n')
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 {
+				last = buf[n-1]
+				if _, err := master.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		if term == nil {
+			// An end-of-file character ends a line that has no
+			// newline, then the next one ends the input.
+			if last != '\n' {
+				master.Write([]byte{4})
+			}
+			master.Write([]byte{4})
+		}
+	}()
+	_, err = io.Copy(stdout, master)
+	if errors.Is(err, syscall.EIO) {
+		err = nil // all processes closed the slave side
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	c, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := c.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+type winsize struct {
+	rows, cols, x, y uint16
+}
+
+// resizePTY sets the size of a pseudo-terminal to the one of a
+// terminal, which tells the processes in it with a SIGWINCH.
+func resizePTY(pty, term *os.File) {
+	var ws winsize
+	if ioctl(term, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)) == nil {
+		ioctl(pty, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+	}
+}
+
+// makeRaw puts a terminal in raw mode like cfmakeraw(3), and returns
+// the function that restores its mode.
+func makeRaw(f *os.File) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(f, ioctlGetTermios, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+	t := old
+	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Oflag &^= syscall.OPOST
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 1, 0
+	if err := ioctl(f, ioctlSetTermios, unsafe.Pointer(&t)); err != nil {
+		return nil, err
+	}
+	return func() { ioctl(f, ioctlSetTermios, unsafe.Pointer(&old)) }, nil
+}
+
+// setEcho turns the echo of a terminal on or off.
+func setEcho(f *os.File, on bool) error {
+	var t syscall.Termios
+	if err := ioctl(f, ioctlGetTermios, unsafe.Pointer(&t)); err != nil {
+		return err
+	}
+	if on {
+		t.Lflag |= syscall.ECHO
+	} else {
+		t.Lflag &^= syscall.ECHO
+	}
+	return ioctl(f, ioctlSetTermios, unsafe.Pointer(&t))
+}
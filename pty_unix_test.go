@@ -0,0 +1,33 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build darwin || linux
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBridgePTY(t *testing.T) {
+	m, _, err := openPTY()
+	if err != nil {
+		t.Skipf("no pseudo-terminals: %v", err)
+	}
+	m.Close()
+
+	// The command runs in a terminal, which does not echo the input and
+	// ends it where it ends, after the last line that has no newline.
+	var out bytes.Buffer
+	script := `test -t 0 && test -t 1 && echo tty; while read l; do echo "got $l"; done; echo end`
+	err = bridgePTY("sh", []string{"-c", script}, strings.NewReader("a\nb"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.ReplaceAll(out.String(), "\r\n", "\n"); got != "tty\ngot a\nend\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
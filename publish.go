@@ -0,0 +1,264 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() { registerFeature("publish") }
+
+// A publisher publishes the lines of the output as messages, for
+// --publish.
+type publisher interface {
+	send(msg []byte) error
+	Close() error
+}
+
+// dialPublisher connects to the NATS server of a nats://host/subject
+// URL, or to the MQTT broker of a mqtt://host/topic one. User names and
+// passwords are given in the URL as well.
+func dialPublisher(rawurl string) (publisher, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	subject := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "nats":
+		if subject == "" || strings.ContainsAny(subject, " \t/") {
+			return nil, fmt.Errorf("%s: expected a NATS subject as in nats://host/subject", rawurl)
+		}
+		return dialNATS(hostPort(u, "4222"), u.User, subject)
+	case "mqtt":
+		if subject == "" || strings.ContainsAny(subject, "+#") {
+			return nil, fmt.Errorf("%s: expected an MQTT topic as in mqtt://host/topic", rawurl)
+		}
+		return dialMQTT(hostPort(u, "1883"), u.User, subject)
+	}
+	return nil, fmt.Errorf("%s: not a nats:// or mqtt:// URL", rawurl)
+}
+
+func hostPort(u *url.URL, port string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// natsConn publishes to a subject of a NATS server in its text protocol,
+// see https://docs.nats.io/reference/reference-protocols/nats-protocol.
+type natsConn struct {
+	conn    net.Conn
+	subject string
+	pong    chan struct{}
+
+	mu  sync.Mutex // serializes the writes
+	err error      // the last -ERR of the server
+}
+
+func dialNATS(addr string, user *url.Userinfo, subject string) (*natsConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	info, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("nats: %s is no NATS server", addr)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	connect := map[string]interface{}{"verbose": false, "pedantic": false, "name": "cat", "lang": "go"}
+	if user != nil {
+		if pass, ok := user.Password(); ok {
+			connect["user"], connect["pass"] = user.Username(), pass
+		} else {
+			connect["auth_token"] = user.Username()
+		}
+	}
+	b, _ := json.Marshal(connect)
+	c := &natsConn{conn: conn, subject: subject, pong: make(chan struct{}, 1)}
+	go c.read(r)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", b); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The server confirms the connection with the PONG to a PING, or
+	// rejects it with an -ERR.
+	if err := c.flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// read answers the PINGs of the server, and passes its PONGs and errors
+// on to the writes.
+func (c *natsConn) read(r *bufio.Reader) {
+	defer close(c.pong)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch line = strings.TrimRight(line, "\r\n"); {
+		case line == "PING":
+			c.mu.Lock()
+			io.WriteString(c.conn, "PONG\r\n")
+			c.mu.Unlock()
+		case line == "PONG":
+			c.pong <- struct{}{}
+		case strings.HasPrefix(line, "-ERR"):
+			c.mu.Lock()
+			c.err = fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+			c.mu.Unlock()
+		}
+	}
+}
+
+// flush waits until the server processed all that was sent.
+func (c *natsConn) flush() error {
+	c.mu.Lock()
+	_, err := io.WriteString(c.conn, "PING\r\n")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	_, ok := <-c.pong
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if !ok {
+		return errors.New("nats: connection closed")
+	}
+	return nil
+}
+
+func (c *natsConn) send(msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	_, err := fmt.Fprintf(c.conn, "PUB %s %d\r\n%s\r\n", c.subject, len(msg), msg)
+	return err
+}
+
+// Close waits for the server to process the messages, and closes the
+// connection.
+func (c *natsConn) Close() error {
+	err := c.flush()
+	c.conn.Close()
+	return err
+}
+
+// mqttConn publishes to a topic of an MQTT broker in version 3.1.1 of
+// the protocol, with QoS 0 and no keep alive.
+type mqttConn struct {
+	conn  net.Conn
+	topic string
+}
+
+func dialMQTT(addr string, user *url.Userinfo, topic string) (*mqttConn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	flags := byte(0x02) // a clean session
+	var payload []byte
+	payload = appendMQTTString(payload, fmt.Sprintf("cat-%d", time.Now().UnixNano()))
+	if user != nil {
+		flags |= 0x80
+		payload = appendMQTTString(payload, user.Username())
+		if pass, ok := user.Password(); ok {
+			flags |= 0x40
+			payload = appendMQTTString(payload, pass)
+		}
+	}
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	body = append(body, 4, flags, 0, 0) // level 4, with keep alive off
+	body = append(body, payload...)
+	if _, err := conn.Write(mqttPacket(0x10, body)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var ack [4]byte
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.ReadFull(conn, ack[:]); err != nil || ack[0] != 0x20 || ack[1] != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt: %s is no MQTT broker", addr)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if rc := ack[3]; rc != 0 {
+		conn.Close()
+		reasons := map[byte]string{
+			1: "unacceptable protocol version",
+			2: "identifier rejected",
+			3: "server unavailable",
+			4: "bad user name or password",
+			5: "not authorized",
+		}
+		if reason, ok := reasons[rc]; ok {
+			return nil, fmt.Errorf("mqtt: connection refused: %s", reason)
+		}
+		return nil, fmt.Errorf("mqtt: connection refused with code %d", rc)
+	}
+	return &mqttConn{conn: conn, topic: topic}, nil
+}
+
+func (c *mqttConn) send(msg []byte) error {
+	body := appendMQTTString(nil, c.topic)
+	_, err := c.conn.Write(mqttPacket(0x30, append(body, msg...)))
+	return err
+}
+
+// Close disconnects from the broker.
+func (c *mqttConn) Close() error {
+	_, err := c.conn.Write([]byte{0xe0, 0})
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// mqttPacket prefixes the body of a packet with its type and the length
+// of the body.
+func mqttPacket(typ byte, body []byte) []byte {
+	b := []byte{typ}
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		if n /= 128; n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	return append(append(b, byte(len(s)>>8), byte(len(s))), s...)
+}
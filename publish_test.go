@@ -0,0 +1,126 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeServer accepts a single connection at a local address, and passes
+// it to serve, whose result it sends on the returned channel.
+func fakeServer(t *testing.T, serve func(conn net.Conn) []string) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	c := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			c <- nil
+			return
+		}
+		defer conn.Close()
+		c <- serve(conn)
+	}()
+	return l.Addr().String(), c
+}
+
+func TestMainPublishNATS(t *testing.T) {
+	addr, got := fakeServer(t, func(conn net.Conn) (msgs []string) {
+		io.WriteString(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return msgs
+			}
+			f := strings.Fields(line)
+			switch {
+			case len(f) == 0:
+			case f[0] == "CONNECT":
+				msgs = append(msgs, strings.TrimSpace(line))
+			case f[0] == "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case f[0] == "PUB" && len(f) == 3:
+				n, _ := strconv.Atoi(f[2])
+				b := make([]byte, n+2)
+				io.ReadFull(r, b)
+				msgs = append(msgs, f[1]+": "+string(b[:n]))
+			}
+		}
+	})
+	if out := runMain("--publish", "nats://alice:secret@"+addr+"/logs.app", "testdata/b.md", "testdata/b.md"); out != "" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	want := []string{
+		`CONNECT {"lang":"go","name":"cat","pass":"secret","pedantic":false,"user":"alice","verbose":false}`,
+		"logs.app: worldworld",
+	}
+	if msgs := <-got; fmt.Sprint(msgs) != fmt.Sprint(want) {
+		t.Fatalf("unexpected messages %q", msgs)
+	}
+}
+
+func TestMainPublishMQTT(t *testing.T) {
+	addr, got := fakeServer(t, func(conn net.Conn) (msgs []string) {
+		r := bufio.NewReader(conn)
+		for {
+			typ, err := r.ReadByte()
+			if err != nil {
+				return msgs
+			}
+			n, shift := 0, 0
+			for {
+				d, _ := r.ReadByte()
+				n |= int(d&0x7f) << shift
+				if shift += 7; d&0x80 == 0 {
+					break
+				}
+			}
+			body := make([]byte, n)
+			io.ReadFull(r, body)
+			switch typ >> 4 {
+			case 1: // CONNECT
+				name := string(body[2:6])
+				user := string(body[len(body)-5:])
+				msgs = append(msgs, fmt.Sprintf("connect %s level %d flags %#x user %s", name, body[6], body[7], user))
+				conn.Write([]byte{0x20, 2, 0, 0})
+			case 3: // PUBLISH
+				l := int(body[0])<<8 | int(body[1])
+				msgs = append(msgs, string(body[2:2+l])+": "+string(body[2+l:]))
+			case 14: // DISCONNECT
+				msgs = append(msgs, "disconnect")
+			}
+		}
+	})
+	if out := runMain("--publish", "mqtt://alice@"+addr+"/sensors/logs", "testdata/b.md"); out != "" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	want := []string{"connect MQTT level 4 flags 0x82 user alice", "sensors/logs: world", "disconnect"}
+	if msgs := <-got; fmt.Sprint(msgs) != fmt.Sprint(want) {
+		t.Fatalf("unexpected messages %q", msgs)
+	}
+}
+
+func TestMQTTPacket(t *testing.T) {
+	// The remaining length is a varint, see the example of MQTT 3.1.1,
+	// section 2.2.3.
+	for n, want := range map[int]string{0: "00", 127: "7f", 128: "8001", 16383: "ff7f", 16384: "808001"} {
+		b := mqttPacket(0x30, make([]byte, n))
+		if got := fmt.Sprintf("%x", b[1:len(b)-n]); got != want {
+			t.Errorf("length %d: got %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// recordMagic starts the header of every record written in the
+// records output format. A record looks like
+//
+//	cat-record 12 "testdata/b.md"
+//	hello world
+//
+// that is, the header line holds the length of the content in bytes
+// and the quoted name of the input, followed by the content and a
+// line feed that is not counted. The content can contain anything,
+// so the output splits back into the inputs unambiguously.
+const recordMagic = "cat-record"
+
+// writeRecord writes the content of r as one record.
+//
+// The length has to be known before the content is written, so the
+// content is buffered unless r is a file with a trustworthy size.
+func writeRecord(w io.Writer, name string, r io.Reader) error {
+	if f, ok := r.(*os.File); ok {
+		if i, err := f.Stat(); err == nil {
+			if size, ok := knownSize(f.Name(), i); ok {
+				if _, err := fmt.Fprintf(w, "%s %d %s\n", recordMagic, size, strconv.Quote(name)); err != nil {
+					return err
+				}
+				// A file that shrank meanwhile cannot be framed
+				// correctly any more, one that grew is cut off.
+				if _, err := io.CopyN(w, f, size); err != nil {
+					return fmt.Errorf("%s: file changed while reading: %v", name, err)
+				}
+				_, err := io.WriteString(w, "\n")
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%s %d %s\n", recordMagic, buf.Len(), strconv.Quote(name)); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteRecord(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeRecord(&buf, "line\nbreak", strings.NewReader("a\nb")); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	if want := "cat-record 3 \"line\\nbreak\"\na\nb\n"; buf.String() != want {
+		t.Fatalf("unexpected record: got %q, want %q", buf.String(), want)
+	}
+
+	f, err := os.Open("testdata/b.md")
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+	buf.Reset()
+	if err := writeRecord(&buf, "b.md", f); err != nil {
+		t.Fatalf("failed to write record: %v", err)
+	}
+	if want := "cat-record 5 \"b.md\"\nworld\n"; buf.String() != want {
+		t.Fatalf("unexpected record: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMainRecordsPseudoFile(t *testing.T) {
+	// Files of sysfs report the size of a page, whatever they hold.
+	const src = "/sys/class/net/lo/mtu"
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Skipf("sysfs is not mounted: %v", err)
+	}
+	want := "cat-record " + strconv.Itoa(len(b)) + " " + strconv.Quote(src) + "\n" + string(b) + "\n"
+	if got := runMain("--format=records", src); got != want {
+		t.Fatalf("unexpected output: got %q, want %q", got, want)
+	}
+}
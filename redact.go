@@ -0,0 +1,243 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces what --redact finds.
+const redacted = "[REDACTED]"
+
+// redactProfiles are the sets of patterns that --redact can be given,
+// in the order they are applied. Of patterns with groups, only the
+// first group is masked, so that the names of secrets stay readable.
+var redactProfiles = []struct {
+	name     string
+	patterns []string
+	valid    func(match string) bool // if set, filters the matches
+}{
+	{"secrets", []string{
+		`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`,                                                    // AWS access key IDs
+		`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40,})`,                 // AWS secret keys
+		`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`,                                             // bearer tokens
+		`\b(gh[pousr]_[A-Za-z0-9]{36,})\b`,                                                   // GitHub tokens
+		`\b(xox[abprs]-[A-Za-z0-9-]{10,})\b`,                                                 // Slack tokens
+		`(?i)\b(?:password|passwd|secret|api[_-]?key|token)["']?\s*[=:]\s*["']?([^\s"',;]+)`, // assignments
+	}, nil},
+	{"emails", []string{`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`}, nil},
+	{"ipv4", []string{`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`}, nil},
+	{"ipv6", []string{`(?i)(?:\b[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|(?:\b[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}\b(?::[0-9a-f]{1,4}\b){0,6})?|::(?:[0-9a-f]{1,4}\b(?::[0-9a-f]{1,4}\b){0,6})`}, nil},
+	{"credit-cards", []string{`\b\d(?:[ -]?\d){12,18}\b`}, luhn},
+}
+
+// redactFlag is the list of profiles of --redact, which may be given
+// without a value for the secrets profile.
+type redactFlag struct {
+	profiles []string
+	set      bool
+}
+
+func (f *redactFlag) String() string { return strings.Join(f.profiles, ",") }
+
+func (f *redactFlag) IsBoolFlag() bool { return true }
+
+func (f *redactFlag) Set(v string) error {
+	switch v {
+	case "true":
+		f.profiles, f.set = []string{"secrets"}, true
+		return nil
+	case "false":
+		f.profiles, f.set = nil, false
+		return nil
+	}
+	f.profiles, f.set = nil, true
+	for _, p := range strings.Split(v, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !isRedactProfile(p) {
+			return fmt.Errorf("unknown profile %q", p)
+		}
+		f.profiles = append(f.profiles, p)
+	}
+	return nil
+}
+
+func isRedactProfile(name string) bool {
+	for _, p := range redactProfiles {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// redactRule is a pattern of a profile.
+type redactRule struct {
+	profile string
+	re      *regexp.Regexp
+	valid   func(string) bool
+}
+
+// redactRules are the patterns of --redact, those of the selected
+// profiles and those of --redact-config, which count as the custom
+// profile.
+var redactRules []redactRule
+
+// setupRedact compiles the patterns of the given profiles and of the
+// config file, which holds a regexp per line and # comments.
+func setupRedact(profiles []string, config string) error {
+	redactRules, redactions = nil, nil
+	for _, p := range redactProfiles {
+		for _, name := range profiles {
+			if name != p.name {
+				continue
+			}
+			for _, pattern := range p.patterns {
+				redactRules = append(redactRules, redactRule{p.name, regexp.MustCompile(pattern), p.valid})
+			}
+			break
+		}
+	}
+	if config == "" {
+		return nil
+	}
+	b, err := os.ReadFile(config)
+	if err != nil {
+		return fmt.Errorf("%s: %v", config, unwrapPathError(err))
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid pattern: %v", config, n, err)
+		}
+		redactRules = append(redactRules, redactRule{"custom", re, nil})
+	}
+	return nil
+}
+
+// redaction counts the replacements in an input by profile.
+type redaction struct {
+	name   string
+	counts map[string]int
+}
+
+// redactions are the inputs that --redact masked something in.
+var redactions []*redaction
+
+// newRedactor returns a line transformer that masks the matches of the
+// rules in the named input. The body of private key blocks is masked
+// as a whole, as a secret.
+func newRedactor(name string) func(line []byte) []byte {
+	r := &redaction{name: name, counts: map[string]int{}}
+	redactions = append(redactions, r)
+	secrets := false
+	for _, rule := range redactRules {
+		secrets = secrets || rule.profile == "secrets"
+	}
+	inKey := false
+	return func(line []byte) []byte {
+		if inKey {
+			if !bytes.Contains(line, []byte("-----END ")) {
+				return nil
+			}
+			inKey = false
+			return line
+		}
+		if secrets && bytes.Contains(line, []byte("-----BEGIN ")) && bytes.Contains(line, []byte("PRIVATE KEY-----")) {
+			inKey = true
+			r.counts["secrets"]++
+			eol := line[len(bytes.TrimRight(line, "\r\n")):]
+			return append(append(line, redacted...), eol...)
+		}
+		for _, rule := range redactRules {
+			var n int
+			line, n = redactLine(rule, line)
+			r.counts[rule.profile] += n
+		}
+		return line
+	}
+}
+
+// redactLine masks the matches of a rule in line, or only their first
+// group if its pattern has groups, and returns how many it masked.
+func redactLine(rule redactRule, line []byte) ([]byte, int) {
+	matches := rule.re.FindAllSubmatchIndex(line, -1)
+	if matches == nil {
+		return line, 0
+	}
+	var b bytes.Buffer
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		if rule.valid != nil && !rule.valid(string(line[start:end])) {
+			continue
+		}
+		b.Write(line[last:start])
+		b.WriteString(redacted)
+		last = end
+		n++
+	}
+	b.Write(line[last:])
+	return b.Bytes(), n
+}
+
+// reportRedactions writes how many replacements --redact made in each
+// input, by profile.
+func reportRedactions(w io.Writer) {
+	for _, r := range redactions {
+		var counts []string
+		for _, p := range append(redactProfileNames(), "custom") {
+			if n := r.counts[p]; n > 0 {
+				counts = append(counts, fmt.Sprintf("%d %s", n, p))
+			}
+		}
+		if len(counts) > 0 {
+			fmt.Fprintf(w, "cat: %s: redacted %s\n", r.name, strings.Join(counts, ", "))
+		}
+	}
+}
+
+func redactProfileNames() []string {
+	var names []string
+	for _, p := range redactProfiles {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// luhn reports whether the digits of s pass the Luhn check of card
+// numbers, which most other long numbers fail.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
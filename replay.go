@@ -0,0 +1,99 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replayTTYRec plays a ttyrec recording, a sequence of records of the
+// time they were written, their size and the output of the terminal.
+func replayTTYRec(name string, r io.Reader) io.Reader {
+	var last time.Time
+	return newPacedReader(func() ([]byte, time.Duration, error) {
+		var h [12]byte
+		if _, err := io.ReadFull(r, h[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = fmt.Errorf("%s: truncated ttyrec record", name)
+			}
+			return nil, 0, err
+		}
+		sec, usec, n := binary.LittleEndian.Uint32(h[:]), binary.LittleEndian.Uint32(h[4:]), binary.LittleEndian.Uint32(h[8:])
+		if usec >= 1e6 || n > 16<<20 {
+			return nil, 0, fmt.Errorf("%s: not a ttyrec recording", name)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, 0, fmt.Errorf("%s: truncated ttyrec record", name)
+		}
+		t := time.Unix(int64(sec), int64(usec)*1e3)
+		var delay time.Duration
+		if !last.IsZero() && t.After(last) {
+			delay = t.Sub(last)
+		}
+		last = t
+		return data, delay, nil
+	})
+}
+
+// replayTypescript plays a typescript of script(1) with the timing that
+// script -t wrote, lines of the delay in seconds before a number of
+// bytes. The timing of script --log-timing starts its lines with the
+// stream, of which only the output, O, is played.
+func replayTypescript(name string, r io.Reader, timing string) (io.Reader, error) {
+	f, err := os.Open(timing)
+	if err != nil {
+		return nil, fmt.Errorf("--replay-timing: %v", unwrapPathError(err))
+	}
+	br := bufio.NewReader(r)
+	// The first line is the header "Script started on ...".
+	if _, err := br.ReadBytes('\n'); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: not a typescript", name)
+	}
+	s := bufio.NewScanner(f)
+	line := 0
+	return newPacedReader(func() ([]byte, time.Duration, error) {
+		for s.Scan() {
+			line++
+			fields := strings.Fields(s.Text())
+			if len(fields) > 0 && (fields[0][0] < '0' || fields[0][0] > '9') {
+				if fields[0] != "O" {
+					continue // input, signals and other information
+				}
+				fields = fields[1:]
+			}
+			if len(fields) != 2 {
+				f.Close()
+				return nil, 0, fmt.Errorf("%s:%d: invalid timing", timing, line)
+			}
+			secs, err1 := strconv.ParseFloat(fields[0], 64)
+			n, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil || secs < 0 || n < 0 {
+				f.Close()
+				return nil, 0, fmt.Errorf("%s:%d: invalid timing", timing, line)
+			}
+			data := make([]byte, n)
+			n, err := io.ReadFull(br, data)
+			if err == io.ErrUnexpectedEOF {
+				err = nil // the footer of the typescript may be missing
+			}
+			return data[:n], time.Duration(secs * float64(time.Second)), err
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, 0, err
+		}
+		// The rest is the footer "Script done on ...".
+		return nil, 0, io.EOF
+	}), nil
+}
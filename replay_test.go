@@ -0,0 +1,100 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recordSleeps replaces sleep by one that records the delays.
+func recordSleeps(t *testing.T) *[]time.Duration {
+	var delays []time.Duration
+	old := sleep
+	t.Cleanup(func() { sleep = old })
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	return &delays
+}
+
+func ttyrecRecord(sec, usec uint32, data string) []byte {
+	b := make([]byte, 12)
+	binary.LittleEndian.PutUint32(b, sec)
+	binary.LittleEndian.PutUint32(b[4:], usec)
+	binary.LittleEndian.PutUint32(b[8:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestMainReplay(t *testing.T) {
+	dir := t.TempDir()
+	rec := filepath.Join(dir, "demo.ttyrec")
+	var b []byte
+	b = append(b, ttyrecRecord(100, 0, "$ ")...)
+	b = append(b, ttyrecRecord(100, 500000, "ls\r\n")...)
+	b = append(b, ttyrecRecord(102, 0, "a b\r\n")...)
+	os.WriteFile(rec, b, 0644)
+
+	delays := recordSleeps(t)
+	if out := runMain("--replay", rec); out != "$ ls\r\na b\r\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if fmt.Sprint(*delays) != "[500ms 1.5s]" {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+
+	*delays = nil
+	runMain("--replay", "--replay-speed=2", rec)
+	if fmt.Sprint(*delays) != "[250ms 750ms]" {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+
+	os.WriteFile(rec, b[:20], 0644)
+	if out, want := runMain("--replay", rec), "$ cat: "+rec+": truncated ttyrec record\n"; out != want {
+		t.Fatalf("unexpected output %q, want %q", out, want)
+	}
+}
+
+func TestMainReplayTiming(t *testing.T) {
+	dir := t.TempDir()
+	typescript := filepath.Join(dir, "typescript")
+	timing := filepath.Join(dir, "timing")
+	os.WriteFile(typescript, []byte("Script started on 2021-01-01 00:00:00+00:00\n$ ls\r\na b\r\nScript done on 2021-01-01 00:00:03+00:00\n"), 0644)
+	os.WriteFile(timing, []byte("0.25 2\n1.5 4\n0.001 5\n"), 0644)
+
+	delays := recordSleeps(t)
+	if out := runMain("--replay-timing", timing, typescript); out != "$ ls\r\na b\r\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if fmt.Sprint(*delays) != "[250ms 1.5s 1ms]" {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+
+	// The advanced format of script --log-timing has input too.
+	os.WriteFile(timing, []byte("O 0.25 2\nI 0.5 3\nO 1.5 4\nH 0 COLUMNS 80\n"), 0644)
+	*delays = nil
+	if out := runMain("--replay-timing", timing, typescript); out != "$ ls\r\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestMainDelayPerLine(t *testing.T) {
+	delays := recordSleeps(t)
+	if out := runMain("--delay-per-line=200ms", "testdata/a.txt"); out == "" {
+		t.Fatal("no output")
+	}
+	b, _ := os.ReadFile("testdata/a.txt")
+	lines := 0
+	for _, c := range b {
+		if c == '\n' {
+			lines++
+		}
+	}
+	if len(*delays) != lines-1 || (*delays)[0] != 200*time.Millisecond {
+		t.Fatalf("unexpected delays %v for %d lines", *delays, lines)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+var (
+	// osOpen opens a file, it is replaced by tests to inject failures.
+	osOpen = os.Open
+	// openBackoff is the longest wait before openRetry gives up.
+	openBackoff = time.Second
+	// writeBackoff is the longest wait of retryWriter between retries.
+	writeBackoff = 100 * time.Millisecond
+)
+
+// openRetry opens a file for reading and retries with an exponential
+// backoff if the process or the system ran out of file descriptors.
+//
+// cat holds at most one input open at a time, so running out usually
+// means that descriptors are used up by something else, e.g. inherited
+// from the parent process or by other processes under a low ulimit,
+// and may free up shortly. Giving up after about a second keeps a
+// hopeless case from hanging.
+func openRetry(name string) (*os.File, error) {
+	var f *os.File
+	err := retryOpen(func() (err error) {
+		f, err = osOpen(name)
+		return err
+	})
+	return f, err
+}
+
+// retryOpen calls open until it succeeds or fails for another reason
+// than running out of file descriptors, as openRetry does.
+func retryOpen(open func() error) error {
+	delay := time.Millisecond
+	for {
+		err := open()
+		if err == nil || !tooManyFiles(err) || delay > openBackoff {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func tooManyFiles(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// retryWriter writes all that is written to it, and retries writes that
+// were interrupted or would block, or that were short.
+//
+// Writes to a file that some other process switched to non-blocking
+// mode, e.g. a terminal or a pipe shared with the shell, fail with
+// EAGAIN as soon as its buffer is full. Waiting for the reader to catch
+// up is what a blocking write would have done.
+type retryWriter struct {
+	w io.Writer
+}
+
+func (w *retryWriter) Write(p []byte) (int, error) {
+	written := 0
+	delay := time.Millisecond
+	for written < len(p) {
+		n, err := w.w.Write(p[written:])
+		written += n
+		switch {
+		case err == nil && n == 0:
+			return written, io.ErrShortWrite
+		case err == nil || errors.Is(err, syscall.EINTR):
+		case errors.Is(err, syscall.EAGAIN):
+			if n > 0 {
+				delay = time.Millisecond
+				continue
+			}
+			time.Sleep(delay)
+			if delay *= 2; delay > writeBackoff {
+				delay = writeBackoff
+			}
+		default:
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestOpenRetry(t *testing.T) {
+	defer func() { osOpen, openBackoff = os.Open, time.Second }()
+	openBackoff = 10 * time.Millisecond
+
+	failures := 3
+	osOpen = func(name string) (*os.File, error) {
+		if failures > 0 {
+			failures--
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EMFILE}
+		}
+		return os.Open(name)
+	}
+	w := newCompleteWriter()
+	if err := cat("testdata/b.md", w); err != nil {
+		t.Fatalf("expect cat to recover from EMFILE: %v", err)
+	}
+	if w.String() != "world" || failures != 0 {
+		t.Fatalf("unexpected content %q after %d failures left", w.String(), failures)
+	}
+
+	osOpen = func(name string) (*os.File, error) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.ENFILE}
+	}
+	if _, err := openRetry("testdata/b.md"); !tooManyFiles(err) {
+		t.Fatalf("expect persistent ENFILE to be reported, got %v", err)
+	}
+}
+
+// flakyWriter writes at most 3 bytes at a time, and fails every other
+// write with one of errs in turn.
+type flakyWriter struct {
+	bytes.Buffer
+	errs []error
+	n    int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.n++
+	if w.n%2 == 0 && len(w.errs) > 0 {
+		err := w.errs[0]
+		w.errs = w.errs[1:]
+		return 0, err
+	}
+	if len(p) > 3 {
+		p = p[:3]
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestRetryWriter(t *testing.T) {
+	defer func() { writeBackoff = 100 * time.Millisecond }()
+	writeBackoff = time.Millisecond
+
+	f := &flakyWriter{errs: []error{syscall.EINTR, syscall.EAGAIN, &fs.PathError{Op: "write", Path: "/dev/stdout", Err: syscall.EAGAIN}}}
+	w := &retryWriter{w: f}
+	if n, err := w.Write([]byte("hello, world")); n != 12 || err != nil || f.String() != "hello, world" {
+		t.Fatalf("unexpected write of %d bytes %q: %v", n, f.String(), err)
+	}
+
+	f = &flakyWriter{errs: []error{syscall.EPIPE}}
+	w = &retryWriter{w: f}
+	if n, err := w.Write([]byte("hello, world")); n != 3 || !errors.Is(err, syscall.EPIPE) {
+		t.Fatalf("expect EPIPE after 3 bytes, got %d bytes: %v", n, err)
+	}
+}
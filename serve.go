@@ -0,0 +1,240 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"sync"
+)
+
+// A subscriber is a client of --serve that receives the lines of the
+// output as messages.
+type subscriber interface {
+	send(msg []byte) error
+	hangUp()
+}
+
+// hub serves the output to its clients: as Server-Sent Events at
+// /events, to WebSocket clients on any path, and as a page that tails
+// the events at /.
+//
+// Clients receive the lines written after they connected, as in a live
+// view. Nothing is written before the first client connected, so that
+// it sees the output from its start.
+type hub struct {
+	srv   *http.Server
+	ready chan struct{}
+	once  sync.Once
+
+	mu   sync.Mutex
+	subs map[subscriber]bool
+}
+
+// serve accepts the clients of the output at the given address.
+func serve(addr string) (*hub, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	h := newHub()
+	h.srv = &http.Server{Handler: h}
+	go h.srv.Serve(l)
+	fmt.Fprintf(os.Stderr, "cat: serving the output at http://%s/\n", l.Addr())
+	return h, nil
+}
+
+func newHub() *hub {
+	return &hub{ready: make(chan struct{}), subs: map[subscriber]bool{}}
+}
+
+func (h *hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case isWSUpgrade(r):
+		c, err := upgradeWS(w, r)
+		if err != nil {
+			return
+		}
+		h.add(c)
+		go func() {
+			c.serveClient()
+			h.drop(c)
+		}()
+	case r.URL.Path == "/events":
+		h.serveEvents(w, r)
+	case r.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, tailPage)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *hub) add(s subscriber) {
+	h.mu.Lock()
+	h.subs[s] = true
+	h.mu.Unlock()
+	h.once.Do(func() { close(h.ready) })
+}
+
+func (h *hub) drop(s subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[s] {
+		delete(h.subs, s)
+		s.hangUp()
+	}
+}
+
+// send sends a message to all clients, after the first one connected.
+// Clients that fail are dropped.
+func (h *hub) send(msg []byte) error {
+	<-h.ready
+	h.mu.Lock()
+	subs := make([]subscriber, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mu.Unlock()
+	for _, s := range subs {
+		if err := s.send(msg); err != nil {
+			h.drop(s)
+		}
+	}
+	return nil
+}
+
+// Close hangs up on all clients and stops the server.
+func (h *hub) Close() error {
+	h.mu.Lock()
+	subs := h.subs
+	h.subs = map[subscriber]bool{}
+	h.mu.Unlock()
+	for s := range subs {
+		s.hangUp()
+	}
+	if h.srv == nil {
+		return nil
+	}
+	return h.srv.Close()
+}
+
+// eventStream is a client of Server-Sent Events.
+type eventStream struct {
+	w    http.ResponseWriter
+	f    http.Flusher
+	done chan struct{}
+	once sync.Once
+
+	mu sync.Mutex // serializes the writes of events
+}
+
+// serveEvents streams the lines of the output as the data of events
+// until the client goes away or the output ends.
+func (h *hub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	// The client is added before it gets the headers, so that it gets
+	// all lines after that as well.
+	e := &eventStream{w: w, f: f, done: make(chan struct{})}
+	h.add(e)
+	e.mu.Lock()
+	f.Flush()
+	e.mu.Unlock()
+	select {
+	case <-e.done:
+	case <-r.Context().Done():
+		h.drop(e)
+	}
+}
+
+// send writes a line as an event. A CR would end the data line early,
+// so that the parts of a line it separates become lines of the data.
+func (e *eventStream) send(msg []byte) error {
+	var b bytes.Buffer
+	for _, part := range bytes.Split(msg, []byte("\r")) {
+		b.WriteString("data: ")
+		b.Write(part)
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.done:
+		// The handler returned, and the response is done with.
+		return io.ErrClosedPipe
+	default:
+	}
+	if _, err := e.w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	e.f.Flush()
+	return nil
+}
+
+func (e *eventStream) hangUp() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.once.Do(func() { close(e.done) })
+}
+
+// tailPage shows the events of the output as they arrive.
+const tailPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cat</title>
+<style>body { margin: 0; } pre { margin: 0; padding: 1em; white-space: pre-wrap; }</style>
+</head>
+<body>
+<pre id="out"></pre>
+<script>
+const out = document.getElementById("out");
+const events = new EventSource("/events");
+events.onmessage = e => {
+	const follow = window.innerHeight + window.scrollY >= document.body.offsetHeight - 2;
+	out.append(e.data + "\n");
+	if (follow) window.scrollTo(0, document.body.scrollHeight);
+};
+events.onerror = () => events.close();
+</script>
+</body>
+</html>
+`
+
+// serveProfiles serves the profiles of the running process over HTTP
+// at the given address, under /debug/pprof/ as net/http/pprof does.
+// The handlers are registered on a mux of their own rather than the
+// default one.
+func serveProfiles(addr string) (stop func(), err error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	fmt.Fprintf(os.Stderr, "cat: serving profiles at http://%s/debug/pprof/\n", l.Addr())
+	return func() { srv.Close() }, nil
+}
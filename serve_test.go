@@ -0,0 +1,72 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	h := newHub()
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	c, err := dialWS("ws" + strings.TrimPrefix(srv.URL, "http") + "/any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get(srv.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	w := &messageWriter{send: h.send}
+	w.Write([]byte("first line\r\nsecond "))
+	w.Write([]byte("line\nno line ending"))
+	w.Close()
+	h.Close()
+
+	var got []string
+	for {
+		op, payload, err := readWSFrame(c.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if op == wsClose {
+			break
+		}
+		got = append(got, string(payload))
+	}
+	if strings.Join(got, "|") != "first line|second line|no line ending" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+
+	events, _ := io.ReadAll(bufio.NewReader(resp.Body))
+	want := "data: first line\n\ndata: second line\n\ndata: no line ending\n\n"
+	if string(events) != want {
+		t.Fatalf("unexpected events:\n%s", events)
+	}
+
+	page, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(page.Body)
+	page.Body.Close()
+	if !strings.Contains(string(b), `new EventSource("/events")`) {
+		t.Fatalf("unexpected page:\n%s", b)
+	}
+}
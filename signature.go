@@ -0,0 +1,314 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+)
+
+func init() { registerFeature("signature") }
+
+// verifySignature checks the content of an input against its detached
+// signature before any of it is written: the minisign signature at
+// src.minisig with --minisign-key, or the SSH signature at src.sig
+// with --ssh-key. For URL inputs, the signature is fetched from the
+// same place. It returns the verified content to read instead of r.
+func verifySignature(name, src string, r io.Reader) (io.Reader, error) {
+	if opts.minisignKey == "" && opts.sshKey == "" {
+		return r, nil
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if opts.minisignKey != "" {
+		sig, err := readSignature(src + ".minisig")
+		if err == nil {
+			err = verifyMinisign(opts.minisignKey, sig, content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: minisign: %v", name, err)
+		}
+	}
+	if opts.sshKey != "" {
+		sig, err := readSignature(src + ".sig")
+		if err == nil {
+			err = verifySSHSig(opts.sshKey, opts.sshNamespace, sig, content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: ssh signature: %v", name, err)
+		}
+	}
+	return bytes.NewReader(content), nil
+}
+
+func readSignature(src string) ([]byte, error) {
+	f, err := open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// readKey returns the content of a key file, or the key itself if it
+// names no file.
+func readKey(key string) []byte {
+	if b, err := os.ReadFile(key); err == nil {
+		return b
+	}
+	return []byte(key)
+}
+
+// verifyMinisign verifies a minisign signature, see
+// https://jedisct1.github.io/minisign/. Both the legacy signatures of
+// the content and the prehashed ones of its BLAKE2b-512 digest are
+// known, as is the signature of the trusted comment.
+func verifyMinisign(key string, sig, content []byte) error {
+	// A key file has an untrusted comment line before the key.
+	var pub []byte
+	for _, line := range strings.Split(string(readKey(key)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "untrusted comment:") {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(b) != 42 || string(b[:2]) != "Ed" {
+			return errors.New("invalid public key")
+		}
+		pub = b
+		break
+	}
+	if pub == nil {
+		return errors.New("invalid public key")
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(sig), "\n") {
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("invalid signature file")
+	}
+	s, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(s) != 74 {
+		return errors.New("invalid signature")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.New("invalid trusted comment signature")
+	}
+	if !bytes.Equal(s[2:10], pub[2:10]) {
+		return fmt.Errorf("signed by key %X, not by key %X", reverse(s[2:10]), reverse(pub[2:10]))
+	}
+
+	msg := content
+	switch string(s[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b512(content)
+		msg = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", s[:2])
+	}
+	pk := ed25519.PublicKey(pub[10:])
+	if !ed25519.Verify(pk, msg, s[10:]) {
+		return errors.New("signature mismatch")
+	}
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(pk, append(append([]byte(nil), s[10:]...), comment...), global) {
+		return errors.New("trusted comment signature mismatch")
+	}
+	return nil
+}
+
+// reverse returns b in reverse, as minisign shows key IDs in little
+// endian.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, c := range b {
+		r[len(b)-1-i] = c
+	}
+	return r
+}
+
+// verifySSHSig verifies an SSH signature as made by ssh-keygen -Y sign,
+// see PROTOCOL.sshsig of OpenSSH, by one of the allowed public keys in
+// the format of authorized_keys, and for the given namespace.
+func verifySSHSig(keys, namespace string, sig, content []byte) error {
+	armored := strings.TrimSpace(string(sig))
+	if !strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----") || !strings.HasSuffix(armored, "-----END SSH SIGNATURE-----") {
+		return errors.New("invalid signature file")
+	}
+	armored = strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----")
+	armored = strings.TrimSuffix(armored, "-----END SSH SIGNATURE-----")
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(armored), ""))
+	if err != nil {
+		return errors.New("invalid signature file")
+	}
+
+	r := newSSHReader(blob)
+	if string(r.next(6)) != "SSHSIG" || binary.BigEndian.Uint32(r.next(4)) != 1 {
+		return errors.New("invalid signature")
+	}
+	pub, ns, _, hashAlg, signature := r.string(), r.string(), r.string(), r.string(), r.string()
+	if r.err != nil {
+		return errors.New("invalid signature")
+	}
+	if string(ns) != namespace {
+		return fmt.Errorf("signed for namespace %q, not %q", ns, namespace)
+	}
+
+	allowed := false
+	for _, line := range strings.Split(string(readKey(keys)), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if k, err := base64.StdEncoding.DecodeString(f[1]); err == nil && bytes.Equal(k, pub) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return errors.New("not signed by an allowed key")
+	}
+
+	var h []byte
+	switch string(hashAlg) {
+	case "sha256":
+		s := sha256.Sum256(content)
+		h = s[:]
+	case "sha512":
+		s := sha512.Sum512(content)
+		h = s[:]
+	default:
+		return fmt.Errorf("unsupported hash algorithm %q", hashAlg)
+	}
+	var signed []byte
+	signed = append(signed, "SSHSIG"...)
+	signed = appendSSHString(signed, ns)
+	signed = appendSSHString(signed, nil)
+	signed = appendSSHString(signed, hashAlg)
+	signed = appendSSHString(signed, h)
+
+	s := newSSHReader(signature)
+	sigType, sigBytes := s.string(), s.string()
+	if s.err != nil {
+		return errors.New("invalid signature")
+	}
+	if !verifySSHKeySig(pub, string(sigType), sigBytes, signed) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// verifySSHKeySig verifies a signature of data by an SSH public key in
+// its wire format.
+func verifySSHKeySig(pub []byte, sigType string, sig, data []byte) bool {
+	k := newSSHReader(pub)
+	switch keyType := string(k.string()); keyType {
+	case "ssh-ed25519":
+		pk := k.string()
+		return k.err == nil && sigType == keyType && len(pk) == ed25519.PublicKeySize &&
+			ed25519.Verify(ed25519.PublicKey(pk), data, sig)
+	case "ssh-rsa":
+		e, n := new(big.Int).SetBytes(k.string()), new(big.Int).SetBytes(k.string())
+		if k.err != nil || !e.IsInt64() {
+			return false
+		}
+		pk := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		switch sigType {
+		case "rsa-sha2-256":
+			h := sha256.Sum256(data)
+			return rsa.VerifyPKCS1v15(pk, crypto.SHA256, h[:], sig) == nil
+		case "rsa-sha2-512":
+			h := sha512.Sum512(data)
+			return rsa.VerifyPKCS1v15(pk, crypto.SHA512, h[:], sig) == nil
+		}
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521":
+		k.string() // the curve name, again
+		q := k.string()
+		s := newSSHReader(sig)
+		r, ss := new(big.Int).SetBytes(s.string()), new(big.Int).SetBytes(s.string())
+		if k.err != nil || s.err != nil || sigType != keyType {
+			return false
+		}
+		var curve elliptic.Curve
+		var h []byte
+		switch keyType {
+		case "ecdsa-sha2-nistp256":
+			curve = elliptic.P256()
+			sum := sha256.Sum256(data)
+			h = sum[:]
+		case "ecdsa-sha2-nistp384":
+			curve = elliptic.P384()
+			sum := sha512.Sum384(data)
+			h = sum[:]
+		default:
+			curve = elliptic.P521()
+			sum := sha512.Sum512(data)
+			h = sum[:]
+		}
+		x, y := elliptic.Unmarshal(curve, q)
+		if x == nil {
+			return false
+		}
+		return ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, h, r, ss)
+	}
+	return false
+}
+
+// sshReader reads the fields of the SSH wire format. After the first
+// error, all fields are empty.
+type sshReader struct {
+	b   []byte
+	err error
+}
+
+func newSSHReader(b []byte) *sshReader { return &sshReader{b: b} }
+
+func (s *sshReader) next(n int) []byte {
+	if s.err != nil || n > len(s.b) {
+		s.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	b := s.b[:n]
+	s.b = s.b[n:]
+	return b
+}
+
+// string reads a string, which is prefixed by its length.
+func (s *sshReader) string() []byte {
+	n := binary.BigEndian.Uint32(s.next(4))
+	if uint64(n) > uint64(len(s.b)) {
+		s.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	return s.next(int(n))
+}
+
+func appendSSHString(b, s []byte) []byte {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(s)))
+	return append(append(b, n[:]...), s...)
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBlake2b512(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{"abc", "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+		{strings.Repeat("\x00", 300), "104b2a75c9b7062f1e945d3d366fd4e451957579ea7ef16575578202532b5368ba7c41e39ef11c54258c7104bae569474adc0374a0ba26debe286490807f42d2"},
+	}
+	for _, tt := range tests {
+		if sum := blake2b512([]byte(tt.in)); hex.EncodeToString(sum[:]) != tt.want {
+			t.Errorf("blake2b512(%q) = %x, want %s", tt.in, sum, tt.want)
+		}
+	}
+}
+
+// minisign signs content like minisign -S, prehashed or not.
+func minisign(priv ed25519.PrivateKey, keyID []byte, content []byte, prehash bool) string {
+	alg, msg := "Ed", content
+	if prehash {
+		sum := blake2b512(content)
+		alg, msg = "ED", sum[:]
+	}
+	sig := append(append([]byte(alg), keyID...), ed25519.Sign(priv, msg)...)
+	comment := "timestamp:1636279200"
+	global := ed25519.Sign(priv, append(append([]byte(nil), sig[10:]...), comment...))
+	return "untrusted comment: signature\n" + base64.StdEncoding.EncodeToString(sig) + "\n" +
+		"trusted comment: " + comment + "\n" + base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+// sshsig signs content like ssh-keygen -Y sign with an Ed25519 key.
+func sshsig(priv ed25519.PrivateKey, pub []byte, namespace string, content []byte) string {
+	h := sha512.Sum512(content)
+	signed := []byte("SSHSIG")
+	signed = appendSSHString(signed, []byte(namespace))
+	signed = appendSSHString(signed, nil)
+	signed = appendSSHString(signed, []byte("sha512"))
+	signed = appendSSHString(signed, h[:])
+	sig := appendSSHString(nil, []byte("ssh-ed25519"))
+	sig = appendSSHString(sig, ed25519.Sign(priv, signed))
+
+	blob := append([]byte("SSHSIG"), 0, 0, 0, 1)
+	blob = appendSSHString(blob, pub)
+	blob = appendSSHString(blob, []byte(namespace))
+	blob = appendSSHString(blob, nil)
+	blob = appendSSHString(blob, []byte("sha512"))
+	blob = appendSSHString(blob, sig)
+	return "-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString(blob) + "\n-----END SSH SIGNATURE-----\n"
+}
+
+func TestMainSignatures(t *testing.T) {
+	requireFeature(t, "http")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	minisignKey := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+	sshPub := appendSSHString(appendSSHString(nil, []byte("ssh-ed25519")), pub)
+	sshKey := "ssh-ed25519 " + base64.StdEncoding.EncodeToString(sshPub) + " test"
+
+	content := []byte("release notes\n")
+	files := map[string]string{
+		"/a.txt":         string(content),
+		"/a.txt.minisig": minisign(priv, keyID, content, false),
+		"/a.txt.sig":     sshsig(priv, sshPub, "file", content),
+		"/b.txt":         "tampered\n",
+		"/b.txt.minisig": minisign(priv, keyID, content, true),
+		"/b.txt.sig":     sshsig(priv, sshPub, "file", content),
+		"/c.txt":         string(content),
+		"/c.txt.minisig": minisign(priv, keyID, content, true),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(b))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"--minisign-key", minisignKey, srv.URL + "/a.txt", srv.URL + "/b.txt", srv.URL + "/c.txt"},
+			"release notes\nrelease notes\ncat: " + srv.URL + "/b.txt: minisign: signature mismatch\n",
+		},
+		{
+			[]string{"--ssh-key", sshKey, srv.URL + "/a.txt", srv.URL + "/b.txt", srv.URL + "/c.txt"},
+			"release notes\ncat: " + srv.URL + "/b.txt: ssh signature: signature mismatch\n" +
+				"cat: " + srv.URL + "/c.txt: ssh signature: " + srv.URL + "/c.txt.sig: 404 Not Found\n",
+		},
+		{
+			[]string{"--ssh-key", sshKey, "--ssh-namespace", "git", srv.URL + "/a.txt"},
+			"cat: " + srv.URL + "/a.txt: ssh signature: signed for namespace \"file\", not \"git\"\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := runMain(tt.args...); got != tt.want {
+			t.Errorf("cat %v:\ngot  %q\nwant %q", tt.args, got, tt.want)
+		}
+	}
+}
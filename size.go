@@ -0,0 +1,48 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pseudoRoots are the mount points of the usual pseudo file systems.
+var pseudoRoots = []string{"/proc", "/sys"}
+
+// knownSize returns the size of the file of the given path, if the size
+// can be trusted.
+//
+// Only regular files have a meaningful size, and even those are not
+// reliable on pseudo file systems: files under /proc and /sys report a
+// size of 0 or of a page while producing content when read, and may
+// fail to seek. Anything that wants to rely on the size, e.g. to
+// preallocate or to announce a length upfront, must fall back to plain
+// streaming if the size is not known.
+func knownSize(path string, i os.FileInfo) (int64, bool) {
+	if !i.Mode().IsRegular() || i.Size() == 0 || isPseudo(path) {
+		return 0, false
+	}
+	return i.Size(), true
+}
+
+// isPseudo reports whether the path is on a pseudo file system.
+func isPseudo(path string) bool {
+	if path == "" {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	abs = filepath.ToSlash(abs)
+	for _, root := range pseudoRoots {
+		if abs == root || strings.HasPrefix(abs, root+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeInfo is a synthetic file info, e.g. of a pseudo file that
+// reports a size of zero.
+type fakeInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+}
+
+func (f fakeInfo) Name() string       { return f.name }
+func (f fakeInfo) Size() int64        { return f.size }
+func (f fakeInfo) Mode() fs.FileMode  { return f.mode }
+func (f fakeInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeInfo) Sys() interface{}   { return nil }
+
+func TestKnownSize(t *testing.T) {
+	page := int64(os.Getpagesize())
+	tests := []struct {
+		path string
+		info os.FileInfo
+		size int64
+		ok   bool
+	}{
+		{"testdata/a.txt", fakeInfo{"a.txt", 108, 0644}, 108, true},
+		{"", fakeInfo{"a.txt", 108, 0644}, 108, true},
+		{"/proc/self/status", fakeInfo{"status", 0, 0444}, 0, false},
+		{"/sys/class/net/lo/mtu", fakeInfo{"mtu", page, 0644}, 0, false},
+		{"/sys/kernel/x", fakeInfo{"x", 10, 0644}, 0, false},
+		{"testdata/page", fakeInfo{"page", page, 0644}, page, true},
+		{"/system/a.txt", fakeInfo{"a.txt", 108, 0644}, 108, true},
+		{"fifo", fakeInfo{"fifo", 0, fs.ModeNamedPipe}, 0, false},
+		{"tty", fakeInfo{"tty", 0, fs.ModeDevice | fs.ModeCharDevice}, 0, false},
+	}
+	for _, tt := range tests {
+		size, ok := knownSize(tt.path, tt.info)
+		if size != tt.size || ok != tt.ok {
+			t.Errorf("knownSize(%q, %s): got %d %v, want %d %v", tt.path, tt.info.Name(), size, ok, tt.size, tt.ok)
+		}
+	}
+}
+
+func TestCatPseudoFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("procfs is only available on Linux")
+	}
+
+	const src = "/proc/self/status"
+	i, err := os.Stat(src)
+	if err != nil {
+		t.Skipf("procfs is not mounted: %v", err)
+	}
+	if _, ok := knownSize(src, i); ok {
+		t.Fatalf("expect size of %s to be unknown", src)
+	}
+
+	w := newCompleteWriter()
+	if err := cat(src, w); err != nil {
+		t.Fatalf("failed to cat %s: %v", src, err)
+	}
+	if len(w.Bytes()) == 0 {
+		t.Fatalf("expect content from %s, got nothing", src)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// splitRecords reads a stream in the records output format and writes
+// the content of every record to a file below dir that is named after
+// the input the record came from.
+func splitRecords(r io.Reader, dir string) error {
+	br := bufio.NewReader(r)
+	for {
+		header, err := br.ReadString('\n')
+		if err == io.EOF && header == "" {
+			return nil
+		}
+		if err != nil {
+			return errors.New("truncated record header")
+		}
+		size, name, err := parseRecordHeader(header)
+		if err != nil {
+			return err
+		}
+		path, err := recordPath(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(f, br, size)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == io.EOF {
+			return fmt.Errorf("%s: truncated record", name)
+		}
+		if err != nil {
+			return err
+		}
+		if c, err := br.ReadByte(); err != nil || c != '\n' {
+			return fmt.Errorf("%s: record is longer than announced", name)
+		}
+	}
+}
+
+// parseRecordHeader parses a header line as written by writeRecord.
+func parseRecordHeader(header string) (size int64, name string, err error) {
+	fields := strings.SplitN(strings.TrimSuffix(header, "\n"), " ", 3)
+	if len(fields) != 3 || fields[0] != recordMagic {
+		return 0, "", fmt.Errorf("invalid record header %q", header)
+	}
+	size, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, "", fmt.Errorf("invalid record length %q", fields[1])
+	}
+	name, err = strconv.Unquote(fields[2])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid record name %s", fields[2])
+	}
+	return size, name, nil
+}
+
+// recordPath returns where the record of the given input is written.
+// Absolute names are placed below dir as well, and names that would
+// escape dir, e.g. ../etc/passwd, are refused because the stream may
+// come from anywhere.
+func recordPath(dir, name string) (string, error) {
+	p := filepath.FromSlash(name)
+	p = strings.TrimPrefix(p, filepath.VolumeName(p))
+	p = strings.TrimLeft(p, `/\`)
+	for _, elem := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if elem == ".." {
+			return "", fmt.Errorf("%s: refuse to write outside of %s", name, dir)
+		}
+	}
+	if p == "" || filepath.Clean(p) == "." {
+		return "", fmt.Errorf("invalid record name %q", name)
+	}
+	return filepath.Join(dir, p), nil
+}
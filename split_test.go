@@ -0,0 +1,68 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitRecords(t *testing.T) {
+	defer func() { opts = options{} }()
+	opts.format = "records"
+
+	// Records written by cat split back into the original files.
+	var buf bytes.Buffer
+	for _, src := range []string{"testdata/a.txt", "testdata/x.png"} {
+		if err := cat(src, &buf); err != nil {
+			t.Fatalf("failed to cat %s: %v", src, err)
+		}
+	}
+	dir := t.TempDir()
+	if err := splitRecords(&buf, dir); err != nil {
+		t.Fatalf("failed to split records: %v", err)
+	}
+	for _, src := range []string{"testdata/a.txt", "testdata/x.png"} {
+		want, _ := os.ReadFile(src)
+		got, err := os.ReadFile(filepath.Join(dir, src))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("%s: content differs after split: %v", src, err)
+		}
+	}
+
+	for _, in := range []string{
+		"cat-record 3 \"../escape\"\nabc\n",
+		"cat-record 10 \"short\"\nabc\n",
+		"cat-record 1 \"long\"\nabc\n",
+		"not a record\n",
+	} {
+		if err := splitRecords(strings.NewReader(in), t.TempDir()); err == nil {
+			t.Errorf("expect splitting %q to fail", in)
+		}
+	}
+}
+
+func TestRecordPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"a.txt", "out/a.txt", true},
+		{"/etc/hosts", "out/etc/hosts", true},
+		{"a/../../b", "", false},
+		{"..", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := recordPath("out", tt.name)
+		if (err == nil) != tt.ok || got != filepath.FromSlash(tt.want) {
+			t.Errorf("recordPath(%q): got %q %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
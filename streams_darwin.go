@@ -0,0 +1,30 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// listStreams writes the size and name of the data fork and, if there
+// is one, the resource fork of the given file. The resource fork is
+// named file/..namedfork/rsrc and can be read by cat under that name.
+func listStreams(src string, w io.Writer) error {
+	src = filepath.Clean(src)
+	i, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("%s: No such file or directory", src)
+	}
+	fmt.Fprintf(w, "%d\t%s\n", i.Size(), src)
+
+	rsrc := src + "/..namedfork/rsrc"
+	if i, err := os.Stat(rsrc); err == nil && i.Size() > 0 {
+		fmt.Fprintf(w, "%d\t%s\n", i.Size(), rsrc)
+	}
+	return nil
+}
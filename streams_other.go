@@ -0,0 +1,18 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !darwin
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// listStreams lists the data streams of a file, which only exist as
+// NTFS streams on Windows and resource forks on macOS.
+func listStreams(src string, w io.Writer) error {
+	return fmt.Errorf("%s: data streams are only supported on Windows and macOS", src)
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procFindFirstStreamW = kernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW  = kernel32.NewProc("FindNextStreamW")
+)
+
+// win32FindStreamData is WIN32_FIND_STREAM_DATA.
+type win32FindStreamData struct {
+	size int64
+	name [syscall.MAX_PATH + 36]uint16
+}
+
+// listStreams writes the size and name of every NTFS data stream of
+// the given file, one per line. Alternate streams are named like
+// file.txt:Zone.Identifier and can be read by cat under that name.
+func listStreams(src string, w io.Writer) error {
+	src = filepath.Clean(src)
+	p, err := syscall.UTF16PtrFromString(longPath(src))
+	if err != nil {
+		return fmt.Errorf("%s: %v", src, err)
+	}
+
+	var data win32FindStreamData
+	h, _, e := procFindFirstStreamW.Call(uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&data)), 0)
+	if syscall.Handle(h) == syscall.InvalidHandle {
+		if e == syscall.ERROR_HANDLE_EOF {
+			return nil // e.g. a directory without any data stream
+		}
+		return fmt.Errorf("%s: %v", src, e)
+	}
+	defer syscall.FindClose(syscall.Handle(h))
+
+	for {
+		// The default stream is called ::$DATA, the others :name:$DATA.
+		name := strings.TrimSuffix(syscall.UTF16ToString(data.name[:]), ":$DATA")
+		if name == ":" {
+			name = ""
+		}
+		fmt.Fprintf(w, "%d\t%s%s\n", data.size, src, name)
+
+		ok, _, e := procFindNextStreamW.Call(h, uintptr(unsafe.Pointer(&data)))
+		if ok == 0 {
+			if e == syscall.ERROR_HANDLE_EOF {
+				return nil
+			}
+			return fmt.Errorf("%s: %v", src, e)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build windows
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAlternateDataStreams(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(src, []byte("main"), 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := os.WriteFile(src+":extra", []byte("hidden"), 0644); err != nil {
+		t.Skipf("file system does not support alternate data streams: %v", err)
+	}
+
+	w := newCompleteWriter()
+	if err := cat(src+":extra", w); err != nil {
+		t.Fatalf("failed to cat stream: %v", err)
+	}
+	if w.String() != "hidden" {
+		t.Fatalf("unexpected stream content: %q", w.String())
+	}
+
+	var buf bytes.Buffer
+	if err := listStreams(src, &buf); err != nil {
+		t.Fatalf("failed to list streams: %v", err)
+	}
+	want := fmt.Sprintf("4\t%s\n6\t%s:extra\n", src, src)
+	if buf.String() != want {
+		t.Fatalf("unexpected streams: got %q, want %q", buf.String(), want)
+	}
+}
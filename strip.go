@@ -0,0 +1,94 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// stripComments removes the comments of the named input, in the syntax
+// of its language as told by its extension or --lang, and the lines
+// that only held comments. Inputs of languages without a known syntax
+// are left as they are. With --strip-blank-lines, blank lines are
+// removed as well.
+func stripComments(name string, r io.Reader) (io.Reader, error) {
+	b, err := readAll(name, "--strip-comments", r)
+	if err != nil {
+		return nil, err
+	}
+	src := string(b)
+	lang := opts.lang
+	if lang == "" {
+		lang = language(name)
+	}
+	syn := syntaxes[lang]
+	if !opts.stripComments {
+		syn = nil
+	}
+
+	// The lines that lost a comment, by their index in the output.
+	stripped := map[int]bool{}
+	var out strings.Builder
+	line := 0
+	var strip func(off int)
+	strip = func(off int) {
+		for _, s := range highlight(src[off:], syn) {
+			start := off
+			off += len(s.text)
+			switch {
+			case s.class != "c":
+				out.WriteString(s.text)
+				line += strings.Count(s.text, "\n")
+			case start == 0 && strings.HasPrefix(s.text, "#!"):
+				out.WriteString(s.text)
+			case notComment(src, start, s.text):
+				// The rest of the line may still hold a comment.
+				out.WriteByte(s.text[0])
+				strip(start + 1)
+				return
+			default:
+				stripped[line] = true
+				if strings.HasSuffix(s.text, "\r") {
+					out.WriteByte('\r') // of a line that ends with CRLF
+				}
+			}
+		}
+	}
+	strip(0)
+
+	var buf bytes.Buffer
+	lines := strings.SplitAfter(out.String(), "\n")
+	for i, l := range lines {
+		text := strings.TrimRight(l, "\r\n")
+		if stripped[i] {
+			text = strings.TrimRight(text, " \t")
+			if text == "" {
+				continue
+			}
+			l = text + l[len(strings.TrimRight(l, "\r\n")):]
+		}
+		if opts.stripBlankLines && strings.TrimSpace(text) == "" {
+			continue
+		}
+		buf.WriteString(l)
+	}
+	return &buf, nil
+}
+
+// notComment reports whether what looks like a comment at src[i:] is
+// none: a # or ; that does not follow a space, as in ${#var} in shell
+// scripts or an URL in YAML.
+func notComment(src string, i int, text string) bool {
+	if text[0] != '#' && text[0] != ';' || i == 0 {
+		return false
+	}
+	switch src[i-1] {
+	case ' ', '\t', '\n', '\r':
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainStripComments(t *testing.T) {
+	requireFeature(t, "highlight")
+	dir := t.TempDir()
+	sh := filepath.Join(dir, "a.sh")
+	os.WriteFile(sh, []byte("#!/bin/sh\n# setup\necho \"# not\" ${#x} # trailing\n\n  # indented\nx=1\n"), 0644)
+	c := filepath.Join(dir, "b.c")
+	os.WriteFile(c, []byte("int a; /* multi\n line */\n// c\nint b; // x\r\nchar *s = \"//\";\n"), 0644)
+	txt := filepath.Join(dir, "c.txt")
+	os.WriteFile(txt, []byte("# kept\n\nend\n"), 0644)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{
+			[]string{"--strip-comments", sh, c, txt},
+			"#!/bin/sh\necho \"# not\" ${#x}\n\nx=1\n" +
+				"int a;\nint b;\r\nchar *s = \"//\";\n" +
+				"# kept\n\nend\n",
+		},
+		{
+			[]string{"--strip-comments", "--strip-blank-lines", sh, txt},
+			"#!/bin/sh\necho \"# not\" ${#x}\nx=1\n# kept\nend\n",
+		},
+		{
+			[]string{"--strip-comments", "--lang", "sh", txt},
+			"\nend\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := runMain(tt.args...); got != tt.want {
+			t.Errorf("cat %v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
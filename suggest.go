@@ -0,0 +1,85 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// suggestPath returns the entry of the parent directory of a missing
+// path whose name is the closest to it, if it is close enough to be a
+// likely typo, e.g. testdata/a.txt for testdata/a.tx.
+func suggestPath(src string) (string, bool) {
+	dir, name := filepath.Split(src)
+	if name == "" {
+		return "", false
+	}
+	parent := filepath.Clean(dir)
+	if !filepath.IsAbs(dir) {
+		parent = filepath.Clean("./" + dir)
+	}
+	entries, err := os.ReadDir(longPath(parent))
+	if err != nil {
+		return "", false
+	}
+
+	// Allow about one typo for every three characters.
+	best, bestDist := "", len([]rune(name))/3
+	if bestDist < 1 {
+		bestDist = 1
+	}
+	bestDist++
+	lower := strings.ToLower(name)
+	for _, e := range entries {
+		d := editDistance(lower, strings.ToLower(e.Name()))
+		if d < bestDist {
+			best, bestDist = e.Name(), d
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return dir + best, true
+}
+
+// editDistance returns the optimal string alignment distance between
+// two strings, i.e. the Levenshtein distance that also counts swapping
+// two adjacent characters as a single edit, a frequent typo.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	prev2 := make([]int, len(t)+1)
+	prev := make([]int, len(t)+1)
+	curr := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		curr[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && prev2[j-2]+1 < curr[j] {
+				curr[j] = prev2[j-2] + 1
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"a.txt", "a.txt", 0},
+		{"a.tx", "a.txt", 1},
+		{"kitten", "sitting", 3},
+		{"世界", "世间", 1},
+		{"x.pgn", "x.png", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q): got %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestPath(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		ok   bool
+	}{
+		{"testdata/a.tx", "testdata/a.txt", true},
+		{"testdata/B.MD", "testdata/b.md", true},
+		{"testdata/x.pgn", "testdata/x.png", true},
+		{"testdata/unrelated.go", "", false},
+		{"missing/a.txt", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := suggestPath(filepath.FromSlash(tt.src))
+		if got != filepath.FromSlash(tt.want) || ok != tt.ok {
+			t.Errorf("suggestPath(%s): got %q %v, want %q %v", tt.src, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "a.tx")
+	if got, ok := suggestPath(src); got != src+"t" || !ok {
+		t.Errorf("suggestPath(%s): got %q %v, want %q", src, got, ok, src+"t")
+	}
+
+	want := errors.New("testdata/a.tx: No such file or directory (did you mean testdata/a.txt?)")
+	if err := cat("testdata/a.tx", newCompleteWriter()); err == nil || err.Error() != filepath.FromSlash(want.Error()) {
+		t.Fatalf("unexpected error: got %v, want %v", err, want)
+	}
+}
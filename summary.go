@@ -0,0 +1,184 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// summarizeAbove is the size above which files are summarized rather
+// than written in full, or 0 to write all files in full. It is set for
+// output to a terminal, which large files would flood.
+var summarizeAbove int64
+
+// guardInputs is whether to ask before writing large and binary files.
+// It is set for output to a terminal, like summarizeAbove.
+var guardInputs bool
+
+const (
+	defaultSummarizeAbove = 1 << 20
+	summaryLines          = 5        // the number of first and last lines
+	summaryTail           = 64 << 10 // the bytes at the end that the last lines are looked for in
+	summaryLineMax        = 4 << 10  // the bytes of a line that are kept
+)
+
+// largeFile returns the size of r if it is a file larger than
+// summarizeAbove.
+func largeFile(r io.Reader) (int64, bool) {
+	if summarizeAbove <= 0 {
+		return 0, false
+	}
+	f, ok := r.(interface{ Stat() (os.FileInfo, error) })
+	if !ok {
+		return 0, false
+	}
+	i, err := f.Stat()
+	if err != nil {
+		return 0, false
+	}
+	var name string
+	if f, ok := r.(interface{ Name() string }); ok {
+		name = f.Name()
+	}
+	size, ok := knownSize(name, i)
+	return size, ok && size > summarizeAbove
+}
+
+// guard asks before a large or a binary file is written to the
+// terminal. It returns the reader to write the file from, and nil if
+// the file is to be skipped. A large file that is not to be written in
+// full is summarized.
+func guard(w io.Writer, name string, r io.Reader) (io.Reader, error) {
+	if size, ok := largeFile(r); ok {
+		if !guardInputs || !confirm("%s is %s, print all of it?", name, formatSize(size)) {
+			return nil, writeSummary(w, name, size, r)
+		}
+		return r, nil
+	}
+	if !guardInputs {
+		return r, nil
+	}
+	br := bufio.NewReader(r)
+	head, err := br.Peek(previewHead)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if isBinary(head) && !confirm("%s looks like binary data, print it anyway?", name) {
+		fmt.Fprintf(os.Stderr, tr("cat: skipped %s, use --interactive-guard=false to print binary data without asking\n"), name)
+		return nil, nil
+	}
+	return br, nil
+}
+
+// writeSummary writes a summary of a large file, its size, the type of
+// its content and, if it is text, its first and last lines.
+func writeSummary(w io.Writer, name string, size int64, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	head, err := br.Peek(previewHead)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	kind := "text"
+	for _, p := range previewers {
+		if p.detect(head, name) {
+			kind = p.name + " data"
+			break
+		}
+	}
+	if kind == "text" && isBinary(head) {
+		kind = "binary data"
+	}
+	fmt.Fprintf(os.Stderr, tr("cat: %s is larger than %s, summarized it, use --full to print all of it\n"), name, formatSize(summarizeAbove))
+	if _, err := fmt.Fprintf(w, "%s: %s of %s\n", paint(name, "1"), formatSize(size), kind); err != nil || kind != "text" {
+		return err
+	}
+
+	cols := terminalWidth()
+	var first []string
+	for len(first) < summaryLines {
+		line, err := readLinePrefix(br)
+		if line != "" || err == nil {
+			first = append(first, line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	// The last lines are read from the end of the file where it can
+	// seek, and else from the rest of it.
+	rest := br
+	if s, ok := r.(io.Seeker); ok && size > summaryTail {
+		if _, err := s.Seek(size-summaryTail, io.SeekStart); err == nil {
+			rest = bufio.NewReader(r)
+			readLinePrefix(rest) // a part of a line
+		}
+	}
+	var last []string
+	for {
+		line, err := readLinePrefix(rest)
+		if line != "" || err == nil {
+			if last = append(last, line); len(last) > summaryLines {
+				last = last[1:]
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, line := range first {
+		fmt.Fprintln(bw, chopLine(line, 0, cols))
+	}
+	if accessible {
+		bw.WriteString("Skipped to the last lines.\n")
+	} else {
+		bw.WriteString(paint("...", "2") + "\n")
+	}
+	for _, line := range last {
+		fmt.Fprintln(bw, chopLine(line, 0, cols))
+	}
+	return bw.Flush()
+}
+
+// readLinePrefix reads a line, without its line break, of which it
+// keeps at most summaryLineMax bytes.
+func readLinePrefix(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := r.ReadSlice('\n')
+		if len(line) < summaryLineMax {
+			line = append(line, b...)
+		}
+		if err != bufio.ErrBufferFull {
+			if len(line) > summaryLineMax {
+				line = line[:summaryLineMax]
+			}
+			return string(bytes.TrimRight(line, "\r\n")), err
+		}
+	}
+}
+
+// isBinary reports whether the beginning of a content is binary rather
+// than text, because it has NUL bytes or is not UTF-8. A character that
+// the beginning cuts off at its end does not count.
+func isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 {
+			return len(head) >= utf8.UTFMax || utf8.FullRune(head)
+		}
+		head = head[size:]
+	}
+	return false
+}
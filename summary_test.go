@@ -0,0 +1,127 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	old := summarizeAbove
+	t.Cleanup(func() { summarizeAbove = old })
+	t.Setenv("COLUMNS", "20")
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	os.WriteFile(small, []byte("a\nb\n"), 0644)
+	text := filepath.Join(dir, "big.txt")
+	content := numberedLines(30000) + strings.Repeat("x", 100) + "\n"
+	os.WriteFile(text, []byte(content), 0644)
+	bin := filepath.Join(dir, "big.bin")
+	os.WriteFile(bin, make([]byte, 300<<10), 0644)
+
+	summarizeAbove = 100 << 10
+	out := captureOutput(func() {
+		for _, f := range []string{small, text, bin} {
+			if err := cat(f, os.Stdout); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	want := "a\nb\n" +
+		"cat: " + text + " is larger than 100K, summarized it, use --full to print all of it\n" +
+		text + ": 165K of text\n" +
+		"1\n2\n3\n4\n5\n...\n29997\n29998\n29999\n30000\nxxxxxxxxxxxxxxxxxxx>\n" +
+		"cat: " + bin + " is larger than 100K, summarized it, use --full to print all of it\n" +
+		bin + ": 300K of binary data\n"
+	if out != want {
+		t.Fatalf("unexpected output\n%q\nwant\n%q", out, want)
+	}
+
+	summarizeAbove = 0
+	if out := captureOutput(func() { cat(text, os.Stdout) }); out != content {
+		t.Fatal("summarized with summarizeAbove = 0")
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		head string
+		want bool
+	}{
+		{"", false},
+		{"hello\n", false},
+		{"héllo", false},
+		{"h\x00llo", true},
+		{"h\xffllo", true},
+		{"hell\xc3", false}, // cut off
+		{"hell\xe4\xbd", false},
+		{"hell\xc3x", true},
+	}
+	for _, tt := range tests {
+		if got := isBinary([]byte(tt.head)); got != tt.want {
+			t.Errorf("isBinary(%q) = %v, want %v", tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:               "0B",
+		1023:            "1023B",
+		1024:            "1K",
+		1536:            "1.5K",
+		1 << 20:         "1M",
+		5<<30 + 100<<20: "5.1G",
+		3 << 40:         "3T",
+		2 << 50:         "2048T",
+	} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	oldAbove, oldGuard, oldTTY := summarizeAbove, guardInputs, openTTY
+	t.Cleanup(func() { summarizeAbove, guardInputs, openTTY = oldAbove, oldGuard, oldTTY })
+	answer := func(s string) {
+		openTTY = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(s)), nil }
+	}
+
+	dir := t.TempDir()
+	text := filepath.Join(dir, "big.txt")
+	content := numberedLines(30000)
+	os.WriteFile(text, []byte(content), 0644)
+	bin := filepath.Join(dir, "small.bin")
+	os.WriteFile(bin, []byte("\x00\x01\x02"), 0644)
+
+	summarizeAbove, guardInputs = 100<<10, true
+	answer("y\n")
+	want := "cat: " + text + " is 164.9K, print all of it? [y/N] " + content +
+		"cat: " + bin + " looks like binary data, print it anyway? [y/N] \x00\x01\x02"
+	if out := captureOutput(func() { cat(text, os.Stdout); cat(bin, os.Stdout) }); out != want {
+		t.Fatalf("unexpected output of %d bytes, want %d", len(out), len(want))
+	}
+
+	answer("n\n")
+	out := captureOutput(func() { cat(text, os.Stdout); cat(bin, os.Stdout) })
+	if !strings.Contains(out, text+": 164.9K of text\n1\n") || strings.Contains(out, "\x00") ||
+		!strings.HasSuffix(out, "cat: skipped "+bin+", use --interactive-guard=false to print binary data without asking\n") {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	// Without the guard, large files are summarized and binary ones
+	// printed.
+	guardInputs = false
+	out = captureOutput(func() { cat(text, os.Stdout); cat(bin, os.Stdout) })
+	if strings.Contains(out, "[y/N]") || !strings.Contains(out, text+": 164.9K of text\n") || !strings.HasSuffix(out, "\x00\x01\x02") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+func init() { registerFeature("syslog") }
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// syslogSockets are where syslog daemons listen locally, on Linux, macOS
+// and the BSDs.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// syslogWriter forwards lines to syslog. Remote servers get messages in
+// the format of RFC 5424, over TCP framed by their length as in RFC
+// 6587. The local daemon gets them in the traditional format that all
+// of them read.
+type syslogWriter struct {
+	conn     net.Conn
+	network  string
+	local    bool
+	pri      int
+	hostname string
+	tag      string
+}
+
+// dialSyslog connects to the syslog server at the given address, as
+// udp://host:port, tcp://host:port, host:port for UDP or the path of a
+// Unix socket, or to the local daemon if there is no address.
+func dialSyslog(addr, facility, severity, tag string) (*syslogWriter, error) {
+	f, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("syslog: unknown facility %q", facility)
+	}
+	s, ok := syslogSeverities[strings.ToLower(severity)]
+	if !ok {
+		return nil, fmt.Errorf("syslog: unknown severity %q", severity)
+	}
+	w := &syslogWriter{pri: f*8 + s, tag: tag}
+	w.hostname, _ = os.Hostname()
+	if w.hostname == "" {
+		w.hostname = "-"
+	}
+
+	var err error
+	switch {
+	case addr == "":
+		w.local = true
+		err = errors.New("syslog: no local syslog daemon, give the address of one")
+		for _, path := range syslogSockets {
+			if w.conn, w.network, err = dialUnixSyslog(path); err == nil {
+				break
+			}
+		}
+	case strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "unix://"):
+		w.local = true
+		w.conn, w.network, err = dialUnixSyslog(strings.TrimPrefix(addr, "unix://"))
+	case strings.HasPrefix(addr, "tcp://"):
+		w.network = "tcp"
+		w.conn, err = net.Dial("tcp", withPort(strings.TrimPrefix(addr, "tcp://"), "514"))
+	default:
+		w.network = "udp"
+		w.conn, err = net.Dial("udp", withPort(strings.TrimPrefix(addr, "udp://"), "514"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func dialUnixSyslog(path string) (net.Conn, string, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		if c, err := net.Dial(network, path); err == nil {
+			return c, network, nil
+		}
+	}
+	return nil, "", fmt.Errorf("syslog: cannot connect to %s", path)
+}
+
+// withPort adds the default port to an address without one.
+func withPort(addr, port string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, port)
+	}
+	return addr
+}
+
+// format formats a line as a message.
+func (w *syslogWriter) format(line []byte, now time.Time) []byte {
+	if w.local {
+		return []byte(fmt.Sprintf("<%d>%s %s[%d]: %s", w.pri, now.Format(time.Stamp), w.tag, os.Getpid(), line))
+	}
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %d - - %s", w.pri, now.Format("2006-01-02T15:04:05.000000Z07:00"), w.hostname, w.tag, os.Getpid(), line))
+}
+
+// send sends a line as a message.
+func (w *syslogWriter) send(line []byte) error {
+	msg := w.format(line, time.Now())
+	switch w.network {
+	case "tcp":
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	case "unix":
+		msg = append(msg, '\n')
+	}
+	_, err := w.conn.Write(msg)
+	return err
+}
+
+func (w *syslogWriter) Close() error { return w.conn.Close() }
@@ -0,0 +1,82 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestMainSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	name := filepath.Join(t.TempDir(), "log")
+	os.WriteFile(name, []byte("first\nsecond\n"), 0644)
+
+	out := runMain("--syslog=udp://"+conn.LocalAddr().String(), "--syslog-facility=local0", "--syslog-severity=notice", name)
+	if out != "" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, line := range []string{"first", "second"} {
+		b := make([]byte, 1024)
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`^<133>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ \S+ cat %d - - %s$`, os.Getpid(), line)
+		if !regexp.MustCompile(want).Match(b[:n]) {
+			t.Fatalf("unexpected message %q", b[:n])
+		}
+	}
+}
+
+func TestMainSyslogTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			got <- ""
+			return
+		}
+		b, _ := io.ReadAll(conn)
+		got <- string(b)
+	}()
+
+	if out := runMain("--syslog=tcp://"+l.Addr().String(), "--syslog-tag=app", "testdata/b.md"); out != "" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	// Messages are framed by their length.
+	msg := <-got
+	m := regexp.MustCompile(`^(\d+) (<14>1 .* app \d+ - - world)$`).FindStringSubmatch(msg)
+	if m == nil || m[1] != fmt.Sprint(len(m[2])) {
+		t.Fatalf("unexpected message %q", msg)
+	}
+}
+
+func TestDialSyslog(t *testing.T) {
+	if _, err := dialSyslog("udp://127.0.0.1:514", "nope", "info", "cat"); err == nil || err.Error() != `syslog: unknown facility "nope"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := dialSyslog("udp://127.0.0.1:514", "user", "loud", "cat"); err == nil || err.Error() != `syslog: unknown severity "loud"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// tableWindow is the number of lines a tableWriter looks ahead.
+const tableWindow = 1000
+
+// tableFlag is the delimiter of --table, which may be given without a
+// value to split columns at runs of whitespace.
+type tableFlag struct {
+	delim string
+	set   bool
+}
+
+func (t *tableFlag) String() string { return t.delim }
+
+func (t *tableFlag) IsBoolFlag() bool { return true }
+
+func (t *tableFlag) Set(v string) error {
+	switch v {
+	case "true":
+		t.delim, t.set = "", true
+	case "false":
+		t.delim, t.set = "", false
+	case `\t`:
+		t.delim, t.set = "\t", true
+	default:
+		t.delim, t.set = v, true
+	}
+	return nil
+}
+
+// tableWriter aligns delimited lines into padded columns, like
+// column -t. To stream, it computes the column widths from a window
+// of lines at a time. The widths only grow from one window to the
+// next, so a later line that is wider than any line before shifts its
+// columns, but the columns stay aligned within each window.
+type tableWriter struct {
+	w      io.Writer
+	delim  string // or "" to split at runs of whitespace
+	line   []byte // the incomplete last line
+	rows   [][]string
+	widths []int
+}
+
+func (t *tableWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			t.line = append(t.line, p...)
+			break
+		}
+		t.line = append(t.line, p[:i]...)
+		t.add(string(t.line))
+		t.line, p = t.line[:0], p[i+1:]
+		if len(t.rows) >= tableWindow {
+			if err := t.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (t *tableWriter) add(line string) {
+	line = strings.TrimSuffix(line, "\r")
+	var cells []string
+	if t.delim == "" {
+		cells = strings.Fields(line)
+	} else {
+		cells = strings.Split(line, t.delim)
+	}
+	t.rows = append(t.rows, cells)
+}
+
+// flush writes the lines of the window.
+func (t *tableWriter) flush() error {
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if i == len(t.widths) {
+				t.widths = append(t.widths, 0)
+			}
+			if n := stringWidth(cell); n > t.widths[i] {
+				t.widths[i] = n
+			}
+		}
+	}
+	var b strings.Builder
+	for _, row := range t.rows {
+		for i, cell := range row {
+			b.WriteString(cell)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", t.widths[i]-stringWidth(cell)+2))
+			}
+		}
+		b.WriteByte('\n')
+	}
+	t.rows = t.rows[:0]
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// Close writes what is left, including an unterminated last line.
+func (t *tableWriter) Close() error {
+	if len(t.line) > 0 {
+		t.add(string(t.line))
+		t.line = t.line[:0]
+	}
+	return t.flush()
+}
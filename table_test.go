@@ -0,0 +1,78 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTableWriter(t *testing.T) {
+	tests := []struct {
+		delim, in, want string
+	}{
+		{"", "a  bb c\nddd e\n", "a    bb  c\nddd  e\n"},
+		{",", "name,size\nb.md,5\r\n,\nx", "name  size\nb.md  5\n      \nx\n"},
+		{",", "日本,1\nab,2\n", "日本  1\nab    2\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		tw := &tableWriter{w: &b, delim: tt.delim}
+		if _, err := io.Copy(tw, iotest.OneByteReader(strings.NewReader(tt.in))); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.in, b.String(), tt.want)
+		}
+	}
+}
+
+func TestTableWriterWindow(t *testing.T) {
+	var in strings.Builder
+	for i := 0; i < tableWindow; i++ {
+		fmt.Fprintf(&in, "a b\n")
+	}
+	in.WriteString("ccc d\n")
+	var b strings.Builder
+	tw := &tableWriter{w: &b}
+	io.WriteString(tw, in.String())
+	if got, want := b.String(), strings.Repeat("a  b\n", tableWindow); got != want {
+		t.Fatalf("expect the first window to be written once full, got %d bytes", len(got))
+	}
+	tw.Close()
+	if !strings.HasSuffix(b.String(), "a  b\nccc  d\n") {
+		t.Fatalf("unexpected end of output: %q", b.String()[b.Len()-20:])
+	}
+}
+
+func TestTableFlag(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		delim string
+		set   bool
+	}{
+		{nil, "", false},
+		{[]string{"--table"}, "", true},
+		{[]string{"--table=,"}, ",", true},
+		{[]string{`--table=\t`}, "\t", true},
+	} {
+		var tf tableFlag
+		fs := flag.NewFlagSet("cat", flag.ContinueOnError)
+		fs.Var(&tf, "table", "")
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if tf.delim != tt.delim || tf.set != tt.set {
+			t.Errorf("%v: got %q %v, want %q %v", tt.args, tf.delim, tf.set, tt.delim, tt.set)
+		}
+	}
+}
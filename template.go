@@ -0,0 +1,59 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/template"
+)
+
+func init() { registerFeature("template") }
+
+// templateData is the data that inputs are rendered with as templates,
+// as loaded from --data.
+var templateData interface{}
+
+// loadTemplateData reads the JSON data file for --template.
+func loadTemplateData(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	// Numbers are kept as they are written, so that large ones are not
+	// printed in exponent notation.
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	templateData = v
+	return nil
+}
+
+// renderTemplate executes the content of the named input as a Go
+// template with the data of --data. Keys missing from the data are
+// errors rather than silently empty.
+func renderTemplate(name string, r io.Reader) (io.Reader, error) {
+	b, err := readAll(name, "--template", r)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, templateData); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainTemplate(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "values.json")
+	os.WriteFile(data, []byte(`{"host": "example.com", "size": 1000000, "users": ["ann", "bob"]}`), 0644)
+	tmpl := filepath.Join(dir, "a.tmpl")
+	os.WriteFile(tmpl, []byte("host={{.host}} size={{.size}}\n{{range .users}}user {{.}}\n{{end}}"), 0644)
+	missing := filepath.Join(dir, "b.tmpl")
+	os.WriteFile(missing, []byte("{{.port}}\n"), 0644)
+
+	got := runMain("--template", "--data", data, tmpl, missing)
+	want := "host=example.com size=1000000\nuser ann\nuser bob\n" +
+		"cat: template: " + missing + ":1:2: executing \"" + missing + "\" at <.port>: map has no entry for key \"port\"\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+
+	got = runMain("--data", data, tmpl)
+	want = "cat: --data can only be used with --template\n"
+	if got != want {
+		t.Fatalf("unexpected output:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,11 @@
+// Package demo says hello.
+package demo
+
+import "fmt"
+
+// Greet writes a greeting.
+func Greet(name string) {
+	/* to standard output,
+	   with a name */
+	fmt.Println("hello, " + name) // <b>&
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"time"
+)
+
+// sleep is time.Sleep, it is replaced by tests.
+var sleep = time.Sleep
+
+// pacedReader delivers a stream in pieces, each after its delay, so
+// that what writes them out as they come plays the stream at a pace.
+type pacedReader struct {
+	next  func() ([]byte, time.Duration, error) // the next piece
+	speed float64                               // divides the delays
+	buf   []byte
+	err   error
+}
+
+func newPacedReader(next func() ([]byte, time.Duration, error)) *pacedReader {
+	speed := opts.replaySpeed
+	if speed <= 0 {
+		speed = 1
+	}
+	return &pacedReader{next: next, speed: speed}
+}
+
+func (p *pacedReader) Read(b []byte) (int, error) {
+	for len(p.buf) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		var delay time.Duration
+		p.buf, delay, p.err = p.next()
+		if delay > 0 && len(p.buf) > 0 {
+			sleep(time.Duration(float64(delay) / p.speed))
+		}
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+// pacedLines delivers the lines of a stream, each after the delay.
+func pacedLines(r *bufio.Reader, delay time.Duration) *pacedReader {
+	first := true
+	return newPacedReader(func() ([]byte, time.Duration, error) {
+		line, err := r.ReadBytes('\n')
+		d := delay
+		if first {
+			d, first = 0, false
+		}
+		return line, d, err
+	})
+}
+
+// pacedChars delivers the characters of a stream at a rate per second,
+// like a typewriter. Escape sequences of terminals are no characters
+// and come with the one after them.
+func pacedChars(r *bufio.Reader, cps float64) *pacedReader {
+	delay := time.Duration(float64(time.Second) / cps)
+	first := true
+	return &pacedReader{speed: 1, next: func() ([]byte, time.Duration, error) {
+		var b []byte
+		for {
+			c, size, err := r.ReadRune()
+			if err != nil {
+				return b, delay, err
+			}
+			if c != 0x1b {
+				// The bytes as they are, also if they are no UTF-8.
+				r.UnreadRune()
+				b = append(b, make([]byte, size)...)
+				io.ReadFull(r, b[len(b)-size:])
+				break
+			}
+			seq, err := readEscapeSeq(r)
+			b = append(append(b, 0x1b), seq...)
+			if err != nil {
+				return b, delay, err
+			}
+		}
+		d := delay
+		if first {
+			d, first = 0, false
+		}
+		return b, d, nil
+	}}
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPacedChars(t *testing.T) {
+	delays := recordSleeps(t)
+
+	// Characters come one at a time, and escape sequences with the
+	// character after them.
+	in := "a\x1b[1mé\x1b]8;;http://x\x07\xff\n"
+	p := pacedChars(bufio.NewReader(strings.NewReader(in)), 4)
+	var pieces []string
+	buf := make([]byte, 64)
+	for {
+		n, err := p.Read(buf)
+		if n > 0 {
+			pieces = append(pieces, string(buf[:n]))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	want := []string{"a", "\x1b[1mé", "\x1b]8;;http://x\x07\xff", "\n"}
+	if fmt.Sprintf("%q", pieces) != fmt.Sprintf("%q", want) {
+		t.Fatalf("unexpected pieces %q, want %q", pieces, want)
+	}
+	if fmt.Sprint(*delays) != "[250ms 250ms 250ms]" {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+}
+
+func TestMainTypewriter(t *testing.T) {
+	delays := recordSleeps(t)
+	if out := runMain("--typewriter=100", "--replay-speed=2", "testdata/a.txt"); !strings.HasPrefix(out, "hello\n") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if len(*delays) == 0 || (*delays)[0] != 10*time.Millisecond {
+		t.Fatalf("unexpected delays %v", *delays)
+	}
+}
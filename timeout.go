@@ -0,0 +1,147 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// errTimeout is returned for inputs that took longer than
+// --per-file-timeout, or were read when the --timeout was over.
+var errTimeout = errors.New("timed out")
+
+// runCtx is done when the --timeout of the whole run is over.
+var runCtx = context.Background()
+
+// runTimedOut reports whether the --timeout of the run is over.
+func runTimedOut() bool {
+	return runCtx.Err() == context.DeadlineExceeded
+}
+
+// openDeadline opens an input like open, but gives up on it after the
+// timeout, if not 0, or the --timeout of the run, whether it hangs in
+// opening or in reading.
+//
+// Reads of a hung NFS mount cannot be canceled, hence they are made in
+// the background and left behind if they do not return in time.
+func openDeadline(src, name string, timeout time.Duration) (io.ReadCloser, error) {
+	ctx, cancel := runCtx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(runCtx, timeout)
+	}
+	d := &deadlineReader{name: name, ctx: ctx, cancel: cancel, timeout: timeout}
+	type result struct {
+		rc  io.ReadCloser
+		err error
+	}
+	c := make(chan result, 1)
+	go func() {
+		rc, err := open(src)
+		c <- result{rc, err}
+	}()
+	select {
+	case r := <-c:
+		if r.err != nil {
+			cancel()
+			return nil, r.err
+		}
+		d.rc = r.rc
+		return d, nil
+	case <-ctx.Done():
+		cancel()
+		go func() {
+			if r := <-c; r.err == nil {
+				r.rc.Close()
+			}
+		}()
+		return nil, d.timedOut()
+	}
+}
+
+// deadlineReader fails the reads of an input once its context is done.
+type deadlineReader struct {
+	rc      io.ReadCloser
+	name    string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration // of the input, or 0 for the --timeout of the run
+
+	// mu guards the rest against a Close while a deadlineReader above
+	// this one reads it in the background.
+	mu      sync.Mutex
+	buf     []byte
+	pending chan readResult // of the read in the background, if any
+	err     error
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return 0, d.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// The read fills a buffer of its own, as it may outlive the call.
+	if cap(d.buf) < len(p) {
+		d.buf = make([]byte, len(p))
+	}
+	buf := d.buf[:len(p)]
+	c := make(chan readResult, 1)
+	go func() {
+		n, err := d.rc.Read(buf)
+		c <- readResult{n, err}
+	}()
+	select {
+	case r := <-c:
+		return copy(p, buf[:r.n]), r.err
+	case <-d.ctx.Done():
+		d.pending, d.err = c, d.timedOut()
+		return 0, d.err
+	}
+}
+
+func (d *deadlineReader) timedOut() error {
+	timeout := d.timeout
+	if runTimedOut() {
+		timeout = opts.timeout
+	}
+	return fmt.Errorf("%s: %w after %v", d.name, errTimeout, timeout)
+}
+
+// Close closes the input, once the read left behind returned.
+func (d *deadlineReader) Close() error {
+	d.cancel()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pending != nil {
+		go func() {
+			<-d.pending
+			d.rc.Close()
+		}()
+		return nil
+	}
+	return d.rc.Close()
+}
+
+// runDeadline makes the reads of r fail when the --timeout of the run
+// is over, also the reads that wait for stdin or pace the output.
+func runDeadline(name string, r io.Reader) io.Reader {
+	if runCtx.Done() == nil {
+		return r
+	}
+	return &deadlineReader{rc: io.NopCloser(r), name: name, ctx: runCtx, cancel: func() {}}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMainPerFileTimeout(t *testing.T) {
+	requireFeature(t, "http")
+	// The server sends the start of its response, and then stalls.
+	stall := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello, "))
+		w.(http.Flusher).Flush()
+		<-stall
+	}))
+	defer srv.Close()
+	defer close(stall)
+
+	url := srv.URL + "/stalled"
+	got := runMain("--per-file-timeout=100ms", url, "testdata/b.md")
+	want := "hello, world" + "cat: " + url + ": timed out after 100ms\n"
+	if got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	got = runMain("--per-file-timeout=100ms", "--on-timeout=abort", url, "testdata/b.md")
+	want = "hello, cat: " + url + ": timed out after 100ms\n"
+	if got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+func TestMainTimeout(t *testing.T) {
+	requireFeature(t, "http")
+	stall := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello, "))
+		w.(http.Flusher).Flush()
+		<-stall
+	}))
+	defer srv.Close()
+	defer close(stall)
+
+	// The partial output is written, and no more inputs are read.
+	url := srv.URL + "/stalled"
+	got, code := runMainCode("--timeout=100ms", "testdata/b.md", url, "testdata/b.md")
+	want := "world" + "hello, " + "cat: " + url + ": timed out after 100ms\n"
+	if got != want || code != exitTimeout {
+		t.Fatalf("unexpected output %q and exit code %d", got, code)
+	}
+	// Also per input, the timeout is that of the whole run.
+	got, code = runMainCode("--timeout=100ms", "--per-file-timeout=1h", url)
+	want = "hello, " + "cat: " + url + ": timed out after 100ms\n"
+	if got != want || code != exitTimeout {
+		t.Fatalf("unexpected output %q and exit code %d", got, code)
+	}
+	if _, code := runMainCode("--timeout=1h", "testdata/b.md"); code != exitOK {
+		t.Fatalf("unexpected exit code %d in time", code)
+	}
+	if _, code := runMainCode("--timeout=-1s", "testdata/b.md"); code != exitUsage {
+		t.Fatalf("unexpected exit code %d for a negative timeout", code)
+	}
+}
+
+func TestOpenDeadline(t *testing.T) {
+	opened, block := make(chan struct{}), make(chan struct{})
+	defer func() { osOpen = os.Open }()
+	osOpen = func(name string) (*os.File, error) {
+		close(opened)
+		<-block
+		return os.Open(name)
+	}
+	_, err := openDeadline("testdata/b.md", "testdata/b.md", 50*time.Millisecond)
+	<-opened
+	close(block)
+	if err == nil || err.Error() != "testdata/b.md: timed out after 50ms" {
+		t.Fatalf("expect the open to time out, got %v", err)
+	}
+}
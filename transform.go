@@ -0,0 +1,99 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// transform applies the transformations that options ask for to the
+// content of the named input, after its decoders.
+func transform(name string, r io.Reader) (io.Reader, error) {
+	var err error
+	// Recordings are played from the start, the rest applies to what
+	// they show.
+	if opts.replay && opts.replayTiming != "" {
+		if r, err = replayTypescript(name, r, opts.replayTiming); err != nil {
+			return nil, err
+		}
+	} else if opts.replay {
+		r = replayTTYRec(name, r)
+	}
+	// Patches apply to the content as it is in the file.
+	if p := patchFor(name); p != nil {
+		r = applyPatch(name, r, p)
+	}
+	if opts.processIncludes {
+		if r, err = processIncludes(name, r); err != nil {
+			return nil, err
+		}
+	}
+	if opts.template {
+		if r, err = renderTemplate(name, r); err != nil {
+			return nil, err
+		}
+	}
+	if opts.envsubst {
+		r = newLineTransformer(r, expandEnv)
+	}
+	if opts.stripComments || opts.stripBlankLines {
+		if r, err = stripComments(name, r); err != nil {
+			return nil, err
+		}
+	}
+	// Secrets are masked last, so that nothing brings them back.
+	if opts.redact.set {
+		r = newLineTransformer(r, newRedactor(name))
+	}
+	return r, nil
+}
+
+// layout applies what options ask of the lines as they are written,
+// after transform and --pretty: it numbers them, peeks at them and
+// paces them.
+func layout(r io.Reader) io.Reader {
+	// HTML and PDF number their lines already.
+	if opts.number && opts.format != "html" && opts.format != "pdf" {
+		r = newLineTransformer(r, numbers.number)
+	}
+	if opts.peek.set {
+		r = newPeekReader(r, opts.peek.n)
+	}
+	if opts.delayPerLine > 0 {
+		r = pacedLines(bufio.NewReader(r), opts.delayPerLine)
+	}
+	if opts.typewriter > 0 {
+		r = pacedChars(bufio.NewReader(r), opts.typewriter)
+	}
+	return r
+}
+
+// lineTransformer rewrites its input a line at a time.
+type lineTransformer struct {
+	r   *bufio.Reader
+	fn  func(line []byte) []byte
+	buf bytes.Buffer
+	err error
+}
+
+func newLineTransformer(r io.Reader, fn func(line []byte) []byte) *lineTransformer {
+	return &lineTransformer{r: bufio.NewReader(r), fn: fn}
+}
+
+func (t *lineTransformer) Read(p []byte) (int, error) {
+	for t.buf.Len() == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		line, err := t.r.ReadBytes('\n')
+		t.err = err
+		if len(line) > 0 {
+			t.buf.Write(t.fn(line))
+		}
+	}
+	return t.buf.Read(p)
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hashState is the state of --skip-if-unchanged, the SHA-256 hashes of
+// the contents of the inputs by their names, as of the runs before.
+// Its file has a line for each input of the hash and the quoted name.
+type hashState struct {
+	path    string
+	hashes  map[string]string
+	changed bool
+}
+
+// unchangedState is the state of --skip-if-unchanged, or nil.
+var unchangedState *hashState
+
+// loadHashState reads the state file, which the first run creates.
+func loadHashState(path string) (*hashState, error) {
+	s := &hashState{path: path, hashes: map[string]string{}}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, unwrapPathError(err))
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		sum, quoted, _ := strings.Cut(sc.Text(), " ")
+		name, err := strconv.Unquote(quoted)
+		if _, herr := hex.DecodeString(sum); err != nil || herr != nil || len(sum) != 2*sha256.Size {
+			return nil, fmt.Errorf("%s:%d: invalid state, expect a SHA-256 hash and a quoted name", path, line)
+		}
+		s.hashes[name] = sum
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// check hashes the content of the named input, and returns it unless
+// it is the same as in the runs before, kept in a temporary file beyond
+// --max-memory, with its hash for record once it was written.
+func (s *hashState) check(name string, r io.Reader) (*spillBuffer, string, error) {
+	buf := &spillBuffer{}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(buf, h), r); err != nil {
+		buf.Close()
+		return nil, "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if s.hashes[name] == sum {
+		buf.Close()
+		return nil, sum, nil
+	}
+	return buf, sum, nil
+}
+
+// record records the hash of the named input that was written.
+func (s *hashState) record(name, sum string) {
+	if s.hashes[name] != sum {
+		s.hashes[name] = sum
+		s.changed = true
+	}
+}
+
+// save writes the state file, if any hash changed, atomically so that
+// an interrupted run leaves the state of the one before.
+func (s *hashState) save() error {
+	if !s.changed {
+		return nil
+	}
+	names := make([]string, 0, len(s.hashes))
+	for name := range s.hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s %s\n", s.hashes[name], strconv.Quote(name))
+	}
+
+	f, tmp, err := createTemp(s.path, 0644)
+	if err != nil {
+		return err
+	}
+	defer removeTemp(tmp)
+	if _, err := io.WriteString(f, b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	if err := os.Rename(longPath(tmp), longPath(s.path)); err != nil {
+		return fmt.Errorf("%s: %v", s.path, unwrapPathError(err))
+	}
+	untrackTemp(tmp)
+	return nil
+}
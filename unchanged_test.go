@@ -0,0 +1,55 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMainSkipIfUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("a\n"), 0644)
+	os.WriteFile(b, []byte("b\n"), 0644)
+
+	if got := runMain("--skip-if-unchanged", state, a, b); got != "a\nb\n" {
+		t.Fatalf("unexpected output %q of the first run", got)
+	}
+	if got := runMain("--skip-if-unchanged", state, a, b); got != "" {
+		t.Fatalf("unexpected output %q of unchanged inputs", got)
+	}
+	os.WriteFile(b, []byte("B\n"), 0644)
+	if got := runMain("--skip-if-unchanged", state, a, b); got != "B\n" {
+		t.Fatalf("unexpected output %q of a changed input", got)
+	}
+	// Inputs that are not read in a run keep their hashes.
+	runMain("--skip-if-unchanged", state, b)
+	if got := runMain("--skip-if-unchanged", state, a); got != "" {
+		t.Fatalf("unexpected output %q of an unchanged input", got)
+	}
+
+	// Inputs that fail are not recorded.
+	runMain("--skip-if-unchanged", state, filepath.Join(dir, "c.txt"))
+	s, err := loadHashState(state)
+	if err != nil || len(s.hashes) != 2 {
+		t.Fatalf("unexpected state %v, %v", s, err)
+	}
+}
+
+func TestLoadHashState(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state")
+	os.WriteFile(state, []byte("abc a.txt\n"), 0644)
+	_, err := loadHashState(state)
+	if err == nil || !strings.Contains(err.Error(), state+":1: invalid state") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if s, err := loadHashState(state + ".missing"); err != nil || len(s.hashes) != 0 {
+		t.Fatalf("unexpected state %v, %v of a missing file", s, err)
+	}
+}
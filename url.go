@@ -0,0 +1,41 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isURL reports whether an input is to be fetched over HTTP.
+func isURL(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// isDocker reports whether an input is a file in a container, as in
+// docker://container:/path, or its logs, as in docker-logs://container.
+func isDocker(src string) bool {
+	return strings.HasPrefix(src, "docker://") || strings.HasPrefix(src, "docker-logs://")
+}
+
+// isOCI reports whether an input is a file in a container image, as in
+// oci://alpine:3.19:/etc/os-release.
+func isOCI(src string) bool {
+	return strings.HasPrefix(src, "oci://")
+}
+
+// isJournal reports whether an input is a query of the systemd journal,
+// as in journal://unit=nginx.service.
+func isJournal(src string) bool {
+	return strings.HasPrefix(src, "journal://")
+}
+
+// inputName is the name of an input in the output and in errors.
+func inputName(src string) string {
+	if isURL(src) || isJournal(src) || isDocker(src) || isOCI(src) || isArchiveMember(src) {
+		return src
+	}
+	return filepath.Clean(src)
+}
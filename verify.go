@@ -0,0 +1,98 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// digestList is the list of --expect-sha256, the digests that the
+// inputs must have, in order.
+type digestList struct {
+	digests []string
+	next    int // the index of the digest of the next input
+}
+
+func (d *digestList) String() string { return strings.Join(d.digests, ",") }
+
+func (d *digestList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid SHA-256 digest %q", s)
+		}
+		d.digests = append(d.digests, s)
+	}
+	return nil
+}
+
+// verify checks the raw content of the named input against the next
+// digest of --expect-sha256. It returns the content to read instead
+// of r, and a check to call after reading it.
+//
+// With --verify-mode=before, the content is hashed before anything
+// of it is written, which buffers inputs that cannot be read twice.
+// With --verify-mode=after, it is hashed as it is read, and the check
+// reports a mismatch once the content was written.
+func verify(name string, r io.Reader) (io.Reader, func() error, error) {
+	none := func() error { return nil }
+	d := &opts.expectSHA256
+	if len(d.digests) == 0 {
+		return r, none, nil
+	}
+	if d.next >= len(d.digests) {
+		return nil, none, fmt.Errorf("%s: no digest given by --expect-sha256", name)
+	}
+	want := d.digests[d.next]
+	d.next++
+	h := sha256.New()
+	compare := func() error {
+		if got := hex.EncodeToString(h.Sum(nil)); got != want {
+			return fmt.Errorf("%s: SHA-256 mismatch: got %s, want %s", name, got, want)
+		}
+		return nil
+	}
+
+	if opts.verifyMode == "after" {
+		tr := io.TeeReader(r, h)
+		return tr, func() error {
+			// What the output did not need, e.g. after the end of a
+			// compressed stream, still counts.
+			if _, err := io.Copy(io.Discard, tr); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			return compare()
+		}, nil
+	}
+
+	// Regular files are read twice rather than held in memory.
+	if s, ok := r.(io.Seeker); ok {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			if _, err := io.Copy(h, r); err != nil {
+				return nil, none, fmt.Errorf("%s: %v", name, err)
+			}
+			if err := compare(); err != nil {
+				return nil, none, err
+			}
+			if _, err := s.Seek(start, io.SeekStart); err != nil {
+				return nil, none, fmt.Errorf("%s: %v", name, err)
+			}
+			return r, none, nil
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(&buf, h), r); err != nil {
+		return nil, none, fmt.Errorf("%s: %v", name, err)
+	}
+	if err := compare(); err != nil {
+		return nil, none, err
+	}
+	return &buf, none, nil
+}
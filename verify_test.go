@@ -0,0 +1,42 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainExpectSHA256(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	os.WriteFile(a, []byte("hello\n"), 0644)
+	os.WriteFile(b, []byte("world\n"), 0644)
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(h[:])
+	}
+	good := sum("hello\n") + "," + sum("world\n")
+	bad := sum("hello\n") + "," + sum("hello\n")
+	mismatch := "cat: " + b + ": SHA-256 mismatch: got " + sum("world\n") + ", want " + sum("hello\n") + "\n"
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--expect-sha256", good, a, b}, "hello\nworld\n"},
+		{[]string{"--expect-sha256", bad, a, b}, "hello\n" + mismatch},
+		{[]string{"--expect-sha256", bad, "--verify-mode", "after", a, b}, "hello\nworld\n" + mismatch},
+		{[]string{"--expect-sha256", sum("hello\n"), a, b}, "hello\ncat: " + b + ": no digest given by --expect-sha256\n"},
+	}
+	for _, tt := range tests {
+		if got := runMain(tt.args...); got != tt.want {
+			t.Errorf("cat %v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// virtualFS holds the input files, if not nil, rather than the file
+// system of the host, e.g. where cat runs in a browser.
+var virtualFS fs.FS
+
+// memFS is a file system of files in memory by their names.
+type memFS map[string][]byte
+
+func (m memFS) Open(name string) (fs.File, error) {
+	b, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memFile{Reader: bytes.NewReader(b), name: path.Base(name)}, nil
+}
+
+// memFile is a file of a memFS, which is its own fs.FileInfo.
+type memFile struct {
+	*bytes.Reader
+	name string
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *memFile) Close() error               { return nil }
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Mode() fs.FileMode  { return 0444 }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return false }
+func (f *memFile) Sys() interface{}   { return nil }
+
+// openVirtual opens an input of the virtual file system, and retries
+// like openRetry.
+func openVirtual(src string) (io.ReadCloser, error) {
+	var f fs.File
+	err := retryOpen(func() (err error) {
+		f, err = virtualFS.Open(src)
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", src, errNotExist)
+	}
+	return f, err
+}
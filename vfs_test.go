@@ -0,0 +1,20 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestMainVirtualFS(t *testing.T) {
+	virtualFS = memFS{"a.go": []byte("package a\n")}
+	defer func() { virtualFS = nil }()
+
+	if got, want := runMain("--fence", "a.go"), "```go a.go\npackage a\n```\n"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+	// The files of the host are not visible.
+	if got, want := runMain("testdata/a.txt"), "cat: testdata/a.txt: No such file or directory\n"; got != want {
+		t.Fatalf("unexpected output %q, want %q", got, want)
+	}
+}
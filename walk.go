@@ -0,0 +1,156 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expand replaces the directories among the given inputs with all
+// files below them in lexical order, as requested by -r. Archives are
+// walked like directories, their members become archive!/member
+// inputs. Other inputs are kept as they are.
+//
+// Like ripgrep, hidden files and directories are left out unless
+// asked for with --hidden, and so are the ones excluded by the
+// .gitignore and .catignore files met on the way, unless --no-ignore.
+func expand(args []string) (files []string, errs []error) {
+	w := &walker{seen: map[fileKey]bool{}}
+	for _, arg := range args {
+		if _, path := splitDecoders(arg); path != arg {
+			w.files = append(w.files, arg) // annotated inputs are never directories
+			continue
+		}
+		i, err := os.Stat(longPath(filepath.Clean(arg)))
+		if err == nil && !i.IsDir() {
+			if format, _ := archiveFormat(arg); format != "" {
+				w.walkArchive(filepath.Clean(arg))
+				continue
+			}
+		}
+		if err != nil || !i.IsDir() {
+			w.files = append(w.files, arg)
+			continue
+		}
+		if w.visited(i) {
+			continue
+		}
+		w.walk(filepath.Clean(arg), []os.FileInfo{i}, nil)
+	}
+	return w.files, w.errs
+}
+
+// walker collects the files below directories.
+//
+// The same directory can be reached more than once through symbolic
+// links, which are only followed with --follow-dir-symlinks, and bind
+// mounts. A directory that is its own ancestor is reported and skipped
+// to not loop forever. Where the system exposes device and inode
+// numbers, directories and files seen before, e.g. hard links, are
+// skipped as well to not emit the same content twice.
+type walker struct {
+	files []string
+	errs  []error
+	seen  map[fileKey]bool
+}
+
+func (w *walker) walk(dir string, ancestors []os.FileInfo, rules []ignoreRule) {
+	entries, err := os.ReadDir(longPath(dir))
+	if err != nil {
+		w.errs = append(w.errs, fmt.Errorf("%s: %v", dir, unwrapPathError(err)))
+		return
+	}
+	if !opts.noIgnore {
+		// Limit the capacity to not share the appended rules between
+		// sibling directories.
+		rules = append(rules[:len(rules):len(rules)], readIgnoreFiles(dir)...)
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !opts.hidden && strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		i, err := os.Stat(longPath(path))
+		if err != nil {
+			if e.Type()&fs.ModeSymlink != 0 {
+				w.files = append(w.files, path) // report the dangling link when read
+				continue
+			}
+			w.errs = append(w.errs, fmt.Errorf("%s: %v", path, unwrapPathError(err)))
+			continue
+		}
+		if ignored(path, i.IsDir(), rules) {
+			continue
+		}
+
+		if i.IsDir() {
+			if e.Type()&fs.ModeSymlink != 0 && !opts.followDirSymlinks {
+				continue
+			}
+			if loops(i, ancestors) {
+				w.errs = append(w.errs, fmt.Errorf("%s: recursive directory loop", path))
+				continue
+			}
+			if w.visited(i) {
+				continue // reached before through another link
+			}
+			w.walk(path, append(ancestors, i), rules)
+			continue
+		}
+
+		if skipFile(e.Name()) || !keepFile(i) || w.visited(i) {
+			continue
+		}
+		w.files = append(w.files, path)
+	}
+}
+
+// visited reports whether the file was seen before and marks it seen.
+func (w *walker) visited(i os.FileInfo) bool {
+	key, ok := fileKeyOf(i)
+	if !ok {
+		return false
+	}
+	if w.seen[key] {
+		return true
+	}
+	w.seen[key] = true
+	return false
+}
+
+// loops reports whether a directory is one of its ancestors.
+func loops(dir os.FileInfo, ancestors []os.FileInfo) bool {
+	for _, a := range ancestors {
+		if os.SameFile(dir, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipFile reports whether a file found in a directory is left out.
+//
+// macOS stores resource forks and Finder metadata in AppleDouble ._*
+// files and .DS_Store when writing to file systems that lack support
+// for them, e.g. network shares and USB drives. They are never what
+// one wants to see, unless asked for with --apple-double.
+func skipFile(name string) bool {
+	return !opts.appleDouble && (strings.HasPrefix(name, "._") || name == ".DS_Store")
+}
+
+// unwrapPathError strips the operation and path from an error, as
+// they are reported separately.
+func unwrapPathError(err error) error {
+	if e, ok := err.(*fs.PathError); ok {
+		return e.Err
+	}
+	return err
+}
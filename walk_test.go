@@ -0,0 +1,161 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// mkfiles creates the given files, and their parent directories, below
+// dir with their names as content.
+func mkfiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to create file: %v", err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	defer func() { opts = options{} }()
+
+	dir := t.TempDir()
+	mkfiles(t, dir, "b.txt", "._b.txt", ".DS_Store", "a/z.txt", "a/._z.txt", "c/d/e.txt")
+	join := func(names ...string) (paths []string) {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		hidden      bool
+		appleDouble bool
+		want        []string
+	}{
+		{false, false, append(join("a/z.txt", "b.txt", "c/d/e.txt"), "testdata/b.md")},
+		{false, true, append(join("a/z.txt", "b.txt", "c/d/e.txt"), "testdata/b.md")},
+		{true, false, append(join("a/z.txt", "b.txt", "c/d/e.txt"), "testdata/b.md")},
+		{true, true, append(join(".DS_Store", "._b.txt", "a/._z.txt", "a/z.txt", "b.txt", "c/d/e.txt"), "testdata/b.md")},
+	}
+	for _, tt := range tests {
+		opts.hidden, opts.appleDouble = tt.hidden, tt.appleDouble
+		got, errs := expand([]string{dir, "testdata/b.md"})
+		if len(errs) != 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hidden=%v apple-double=%v: got %v, want %v", tt.hidden, tt.appleDouble, got, tt.want)
+		}
+	}
+}
+
+func TestExpandLinks(t *testing.T) {
+	defer func() { opts = options{} }()
+
+	dir := t.TempDir()
+	mkfiles(t, dir, "a/x.txt", "b/y.txt")
+	// a/loop points back to the root, b/a points to a sibling, and
+	// b/z.txt is a hard link to a/x.txt.
+	symlink(t, dir, filepath.Join(dir, "a", "loop"))
+	symlink(t, filepath.Join(dir, "a"), filepath.Join(dir, "b", "a"))
+	if err := os.Link(filepath.Join(dir, "a", "x.txt"), filepath.Join(dir, "b", "z.txt")); err != nil {
+		t.Fatalf("failed to create hard link: %v", err)
+	}
+
+	opts = options{}
+	got, errs := expand([]string{dir})
+	want := []string{filepath.Join(dir, "a", "x.txt"), filepath.Join(dir, "b", "y.txt")}
+	if len(errs) != 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files: got %v, %v, want %v", got, errs, want)
+	}
+
+	opts = options{followDirSymlinks: true}
+	got, errs = expand([]string{dir})
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected files following links: got %v, want %v", got, want)
+	}
+	if len(errs) != 1 || errs[0].Error() != filepath.Join(dir, "a", "loop")+": recursive directory loop" {
+		t.Fatalf("expect the loop to be reported, got %v", errs)
+	}
+}
+
+func TestExpandIgnore(t *testing.T) {
+	defer func() { opts = options{} }()
+
+	dir := t.TempDir()
+	mkfiles(t, dir, ".env", "main.go", "main.log", "keep.log", "build/out.bin",
+		"src/gen/x.go", "src/app/y.go", "src/app/z.tmp", "docs/a.md", "docs/drafts/b.md")
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("# logs\n*.log\n!keep.log\nbuild/\n/src/gen\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "src", ".catignore"), []byte("*.tmp\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", ".gitignore"), []byte("**/drafts/**\n"), 0644)
+	join := func(names ...string) (paths []string) {
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		opts options
+		want []string
+	}{
+		{options{}, join("docs/a.md", "keep.log", "main.go", "src/app/y.go")},
+		{options{hidden: true}, join(".env", ".gitignore", "docs/.gitignore", "docs/a.md", "keep.log", "main.go", "src/.catignore", "src/app/y.go")},
+		{options{noIgnore: true}, join("build/out.bin", "docs/a.md", "docs/drafts/b.md", "keep.log", "main.go", "main.log", "src/app/y.go", "src/app/z.tmp", "src/gen/x.go")},
+	}
+	for _, tt := range tests {
+		opts = tt.opts
+		got, errs := expand([]string{dir})
+		if len(errs) != 0 || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, %v, want %v", tt.opts, got, errs, tt.want)
+		}
+	}
+}
+
+func TestIgnoreRule(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "x/y/a.log", false, true},
+		{"build/", "x/build", true, true},
+		{"build/", "x/build", false, false},
+		{"/a.txt", "x/a.txt", false, false},
+		{"/a.txt", "a.txt", false, true},
+		{"x/*.go", "x/a.go", false, true},
+		{"x/*.go", "y/x/a.go", false, false},
+		{"**/x/a.go", "y/z/x/a.go", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**", "a/x/y", false, true},
+		{`\#hash`, "#hash", false, true},
+	}
+	for _, tt := range tests {
+		r, ok := parseIgnoreRule("base", tt.pattern)
+		if !ok {
+			t.Fatalf("failed to parse %q", tt.pattern)
+		}
+		if got := r.match(filepath.Join("base", filepath.FromSlash(tt.path)), tt.isDir); got != tt.want {
+			t.Errorf("%q matches %q: got %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+	for _, line := range []string{"", "# comment", "   ", "/"} {
+		if _, ok := parseIgnoreRule("base", line); ok {
+			t.Errorf("expect %q to be no rule", line)
+		}
+	}
+}
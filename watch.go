@@ -0,0 +1,92 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often --watch looks for changes of the inputs.
+const watchInterval = 500 * time.Millisecond
+
+// watching tells whether a run is one of --watch, which does not watch
+// again.
+var watching bool
+
+// fileStamp is what tells that a file changed.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// stampInputs returns the stamps of the input files, and of the files
+// below the input directories. Inputs that do not exist have none, and
+// URLs are not watched.
+func stampInputs(args []string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	for _, arg := range args {
+		_, src := splitDecoders(arg)
+		if isURL(src) {
+			continue
+		}
+		filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if i, err := d.Info(); err == nil {
+				stamps[path] = fileStamp{i.Size(), i.ModTime(), i.Mode()}
+			}
+			return nil
+		})
+	}
+	return stamps
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, s := range a {
+		if t, ok := b[path]; !ok || t != s {
+			return false
+		}
+	}
+	return true
+}
+
+// watchInputs runs build, which concatenates the inputs, and again
+// whenever they change, until stop is closed. Changes are built once
+// the inputs stay the same for an interval, so that a file is not read
+// while an editor writes it.
+func watchInputs(args []string, output string, build func() int, stop <-chan struct{}) int {
+	watching = true
+	defer func() { watching = false }()
+	stamps := stampInputs(args)
+	for {
+		if build() == exitOK {
+			fmt.Fprintf(os.Stderr, tr("cat: wrote %s, waiting for changes\n"), output)
+		}
+		for changed := false; ; {
+			select {
+			case <-stop:
+				return exitOK
+			default:
+			}
+			sleep(watchInterval)
+			now := stampInputs(args)
+			same := sameStamps(stamps, now)
+			stamps = now
+			if same && changed {
+				break
+			}
+			changed = changed || !same
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchInputs(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.js")
+	os.WriteFile(a, []byte("a\n"), 0644)
+	os.Mkdir(filepath.Join(dir, "lib"), 0755)
+
+	// The sleeps between the looks for changes change the inputs.
+	stop := make(chan struct{})
+	sleeps := 0
+	old := sleep
+	defer func() { sleep = old }()
+	sleep = func(time.Duration) {
+		switch sleeps++; sleeps {
+		case 1:
+			os.WriteFile(a, []byte("a, changed\n"), 0644)
+		case 3:
+			os.WriteFile(filepath.Join(dir, "lib", "b.js"), []byte("b\n"), 0644)
+		case 4:
+			os.WriteFile(filepath.Join(dir, "lib", "b.js"), []byte("b, changed while written\n"), 0644)
+		case 6:
+			close(stop)
+		}
+	}
+	builds := 0
+	captureOutput(func() {
+		watchInputs([]string{a, filepath.Join(dir, "lib")}, "out.js", func() int {
+			builds++
+			return exitOK
+		}, stop)
+	})
+	if builds != 3 {
+		t.Fatalf("unexpected %d builds after %d sleeps", builds, sleeps)
+	}
+}
+
+func TestMainWatchUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"--watch", "testdata/a.txt"},
+		{"--watch", "-o", filepath.Join(t.TempDir(), "out")},
+		{"--watch", "-o", filepath.Join(t.TempDir(), "out"), "-"},
+	} {
+		if _, code := runMainCode(args...); code != exitUsage {
+			t.Errorf("unexpected exit code %d for %q", code, args)
+		}
+	}
+}
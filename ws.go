@@ -0,0 +1,259 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The opcodes of WebSocket frames, see RFC 6455.
+const (
+	wsText   = 0x1
+	wsBinary = 0x2
+	wsClose  = 0x8
+	wsPing   = 0x9
+	wsPong   = 0xa
+)
+
+// wsMaxPayload limits the frames read, of which cat expects no more
+// than control frames.
+const wsMaxPayload = 1 << 20
+
+// websocketAccept returns the accept key of the opening handshake for
+// the key of a client.
+func websocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// writeWSFrame writes a frame that is not fragmented. Clients have to
+// mask their frames, servers must not.
+func writeWSFrame(w io.Writer, op byte, payload []byte, mask bool) error {
+	b := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xffff:
+		b[1] = 126
+		b = append(b, byte(n>>8), byte(n))
+	default:
+		b[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		b = append(b, ext[:]...)
+	}
+	if mask {
+		b[1] |= 0x80
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		b = append(b, key[:]...)
+		for i, c := range payload {
+			b = append(b, c^key[i%4])
+		}
+	} else {
+		b = append(b, payload...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// readWSFrame reads a frame and unmasks its payload.
+func readWSFrame(r *bufio.Reader) (op byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	op = h[0] & 0xf
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxPayload {
+		return 0, nil, fmt.Errorf("websocket frame of %d bytes is too large", n)
+	}
+	var key [4]byte
+	masked := h[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return op, payload, nil
+}
+
+// wsConn is a WebSocket connection that cat writes messages to.
+type wsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	client bool
+
+	mu sync.Mutex // serializes the writes of frames
+}
+
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return writeWSFrame(c.conn, op, payload, c.client)
+}
+
+// send sends a message, as text if it is UTF-8 and as binary if not.
+func (c *wsConn) send(msg []byte) error {
+	if utf8.Valid(msg) {
+		return c.writeFrame(wsText, msg)
+	}
+	return c.writeFrame(wsBinary, msg)
+}
+
+// Close sends a close frame with the normal closure status, and waits
+// a moment for the other side to confirm it before closing.
+func (c *wsConn) Close() error {
+	err := c.writeFrame(wsClose, []byte{0x03, 0xe8})
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	for err == nil {
+		var op byte
+		if op, _, err = readWSFrame(c.r); op == wsClose {
+			break
+		}
+	}
+	return c.conn.Close()
+}
+
+// dialWS opens a WebSocket connection to a ws:// or wss:// URL.
+func dialWS(rawurl string) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "ws":
+			host = net.JoinHostPort(u.Hostname(), "80")
+		case "wss":
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("%s: not a ws:// or wss:// URL", rawurl)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", u.RequestURI(), u.Host, key)
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %v", rawurl, err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%s: no WebSocket handshake: %s", rawurl, resp.Status)
+	}
+	return &wsConn{conn: conn, r: r, client: true}, nil
+}
+
+// upgradeWS answers the opening handshake of a WebSocket client and
+// takes over its connection.
+func upgradeWS(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported WebSocket version")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot upgrade the connection", http.StatusInternalServerError)
+		return nil, errors.New("cannot upgrade the connection")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(key))
+	return &wsConn{conn: conn, r: rw.Reader}, nil
+}
+
+// isWSUpgrade reports whether a request opens a WebSocket connection.
+func isWSUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// serveClient answers the frames of a client until it closes the
+// connection. Clients of cat only close the connection or ping it.
+func (c *wsConn) serveClient() {
+	for {
+		op, payload, err := readWSFrame(c.r)
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsClose:
+			c.writeFrame(wsClose, payload)
+			return
+		case wsPing:
+			c.writeFrame(wsPong, payload)
+		}
+	}
+}
+
+// hangUp closes the connection of a client right away.
+func (c *wsConn) hangUp() {
+	c.writeFrame(wsClose, []byte{0x03, 0xe8})
+	c.conn.Close()
+}
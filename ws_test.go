@@ -0,0 +1,58 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebsocketAccept(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	if got := websocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+}
+
+func TestMainWS(t *testing.T) {
+	// The server collects the messages of cat until it closes.
+	msgs := make(chan []string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + websocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"))
+		var got []string
+		for {
+			op, payload, err := readWSFrame(rw.Reader)
+			if err != nil || op == wsClose {
+				writeWSFrame(conn, wsClose, payload, false)
+				break
+			}
+			got = append(got, string(payload))
+		}
+		msgs <- got
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "log")
+	os.WriteFile(name, []byte("hello\n"), 0644)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/logs"
+	if got := runMain("--ws", url, name, "testdata/b.md"); got != "" {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if got := strings.Join(<-msgs, "|"); got != "hello|world" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+}
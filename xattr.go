@@ -0,0 +1,53 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+)
+
+// printXattrs writes the extended attributes of a file in the format
+// of getfattr -d, e.g. the com.apple.quarantine flag on macOS.
+func printXattrs(src string, w io.Writer) error {
+	src = filepath.Clean(src)
+
+	names, err := readXattr(func(buf []byte) (int, error) { return listxattr(src, buf) })
+	if err != nil {
+		return fmt.Errorf("%s: %v", src, err)
+	}
+
+	fmt.Fprintf(w, "# file: %s\n", src)
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		v, err := readXattr(func(buf []byte) (int, error) { return getxattr(src, string(name), buf) })
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", src, name, err)
+		}
+		fmt.Fprintf(w, "%s=%s\n", name, strconv.Quote(string(v)))
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// readXattr calls an xattr system call first to learn the size of the
+// result and then again to fetch it.
+func readXattr(call func(buf []byte) (int, error)) ([]byte, error) {
+	n, err := call(nil)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	n, err = call(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
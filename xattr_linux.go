@@ -0,0 +1,15 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import "syscall"
+
+func listxattr(path string, buf []byte) (int, error) {
+	return syscall.Listxattr(path, buf)
+}
+
+func getxattr(path, name string, buf []byte) (int, error) {
+	return syscall.Getxattr(path, name, buf)
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestPrintXattrs(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(src, nil, 0644); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	if err := syscall.Setxattr(src, "user.origin", []byte("https://changkun.de\n"), 0); err != nil {
+		t.Skipf("file system does not support user attributes: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := printXattrs(src, &buf); err != nil {
+		t.Fatalf("failed to print attributes: %v", err)
+	}
+	want := "# file: " + src + "\nuser.origin=\"https://changkun.de\\n\"\n\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output: got %q, want %q", buf.String(), want)
+	}
+}
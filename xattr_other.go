@@ -0,0 +1,14 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+var errNoXattr = errors.New("extended attributes are not supported on this system")
+
+func listxattr(path string, buf []byte) (int, error)      { return 0, errNoXattr }
+func getxattr(path, name string, buf []byte) (int, error) { return 0, errNoXattr }
@@ -0,0 +1,290 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+var errPassword = errors.New("incorrect password")
+
+// zipFS is a zip archive as a file system that also reads the members
+// encrypted with the traditional encryption of PKWARE or the AES
+// encryption of WinZip, see APPNOTE.TXT and
+// https://www.winzip.com/en/support/aes-encryption/.
+type zipFS struct {
+	*zip.Reader
+	name  string // of the archive
+	files map[string]*zip.File
+}
+
+func newZipFS(name string, r *zip.Reader) *zipFS {
+	z := &zipFS{Reader: r, name: name, files: map[string]*zip.File{}}
+	for _, f := range r.File {
+		if p, ok := memberPath(strings.ReplaceAll(f.Name, `\`, "/")); ok {
+			z.files[p] = f
+		}
+	}
+	return z
+}
+
+func (z *zipFS) Open(name string) (fs.File, error) {
+	f, ok := z.files[name]
+	if !ok || f.Flags&0x1 == 0 {
+		return z.Reader.Open(name)
+	}
+	password, err := archivePassword(z.name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	r, err := openEncrypted(f, password)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &archiveFile{info: f.FileInfo(), Reader: r, Closer: io.NopCloser(nil)}, nil
+}
+
+// archivePassword returns the password of --archive-password, or asks
+// for it on the terminal once.
+func archivePassword(archive string) (string, error) {
+	if opts.archivePassword != "" {
+		return opts.archivePassword, nil
+	}
+	password, err := readPassword("password for %s:", archive)
+	if err != nil || password == "" {
+		return "", errors.New("encrypted, give the password with --archive-password")
+	}
+	opts.archivePassword = password
+	return password, nil
+}
+
+// openEncrypted decrypts and decompresses an encrypted member.
+func openEncrypted(f *zip.File, password string) (io.Reader, error) {
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	method, checkCRC := f.Method, true
+	if f.Method == 99 {
+		var version uint16
+		var strength byte
+		version, strength, method, err = aesExtra(f.Extra)
+		if err != nil {
+			return nil, err
+		}
+		// AE-2 leaves out the CRC, which would tell about the content
+		// what the authentication code does not.
+		checkCRC = version == 1
+		r, err = newAESReader(raw, int64(f.CompressedSize64), password, strength)
+	} else {
+		check := byte(f.CRC32 >> 24)
+		if f.Flags&0x8 != 0 {
+			check = byte(f.ModifiedTime >> 8) // the CRC follows the data
+		}
+		r, err = newZipCryptoReader(raw, password, check)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case zip.Store:
+	case zip.Deflate:
+		r = flate.NewReader(r)
+	default:
+		return nil, zip.ErrAlgorithm
+	}
+	if checkCRC {
+		r = &crcReader{r: r, h: crc32.NewIEEE(), want: f.CRC32}
+	}
+	return r, nil
+}
+
+// crcReader checks the CRC-32 of what it read at the end.
+type crcReader struct {
+	r    io.Reader
+	h    hash.Hash32
+	want uint32
+}
+
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	if err == io.EOF && c.h.Sum32() != c.want {
+		err = zip.ErrChecksum
+	}
+	return n, err
+}
+
+// zipCrypto is the state of the traditional encryption of PKWARE.
+type zipCrypto struct {
+	r          io.Reader
+	k0, k1, k2 uint32
+}
+
+func newZipCryptoReader(r io.Reader, password string, check byte) (io.Reader, error) {
+	z := &zipCrypto{r: r, k0: 0x12345678, k1: 0x23456789, k2: 0x34567890}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	// The encryption header ends with a byte known in advance, which
+	// tells most incorrect passwords.
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	z.decrypt(header[:])
+	if header[11] != check {
+		return nil, errPassword
+	}
+	return z, nil
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.k0 = crc32.IEEETable[byte(z.k0)^b] ^ z.k0>>8
+	z.k1 = (z.k1+z.k0&0xff)*134775813 + 1
+	z.k2 = crc32.IEEETable[byte(z.k2)^byte(z.k1>>24)] ^ z.k2>>8
+}
+
+func (z *zipCrypto) decrypt(p []byte) {
+	for i, c := range p {
+		t := (z.k2 | 2) & 0xffff
+		p[i] = c ^ byte(t*(t^1)>>8)
+		z.update(p[i])
+	}
+}
+
+func (z *zipCrypto) Read(p []byte) (int, error) {
+	n, err := z.r.Read(p)
+	z.decrypt(p[:n])
+	return n, err
+}
+
+// aesExtra reads the version, the key strength and the compression
+// method from the extra field of AES encrypted members.
+func aesExtra(extra []byte) (version uint16, strength byte, method uint16, err error) {
+	for len(extra) >= 4 {
+		tag, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			break
+		}
+		if tag == 0x9901 && size >= 7 && string(extra[2:4]) == "AE" {
+			return binary.LittleEndian.Uint16(extra), extra[4], binary.LittleEndian.Uint16(extra[5:]), nil
+		}
+		extra = extra[size:]
+	}
+	return 0, 0, 0, zip.ErrAlgorithm
+}
+
+// aesReader decrypts the AES encryption of WinZip, which is AES in
+// counter mode with a little endian counter and an HMAC-SHA1 of the
+// encrypted data after it.
+type aesReader struct {
+	r       io.Reader // the encrypted data
+	raw     io.Reader // the authentication code
+	block   cipher.Block
+	mac     hash.Hash
+	counter uint64
+	stream  [aes.BlockSize]byte
+	used    int
+}
+
+func newAESReader(r io.Reader, size int64, password string, strength byte) (io.Reader, error) {
+	if strength < 1 || strength > 3 {
+		return nil, fmt.Errorf("unknown AES strength %d", strength)
+	}
+	keyLen := 8 + 8*int(strength)
+	salt := make([]byte, keyLen/2+2)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, err
+	}
+	salt, verifier := salt[:keyLen/2], salt[keyLen/2:]
+	key := pbkdf2SHA1([]byte(password), salt, 1000, 2*keyLen+2)
+	if !bytes.Equal(key[2*keyLen:], verifier) {
+		return nil, errPassword
+	}
+	block, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	size -= int64(len(salt) + len(verifier) + 10)
+	if size < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &aesReader{
+		r:     io.LimitReader(r, size),
+		raw:   r,
+		block: block,
+		mac:   hmac.New(sha1.New, key[keyLen:2*keyLen]),
+		used:  aes.BlockSize,
+	}, nil
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	a.mac.Write(p[:n])
+	for i := range p[:n] {
+		if a.used == aes.BlockSize {
+			a.counter++
+			var c [aes.BlockSize]byte
+			binary.LittleEndian.PutUint64(c[:], a.counter)
+			a.block.Encrypt(a.stream[:], c[:])
+			a.used = 0
+		}
+		p[i] ^= a.stream[a.used]
+		a.used++
+	}
+	if err == io.EOF {
+		var code [10]byte
+		if _, err := io.ReadFull(a.raw, code[:]); err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		if !hmac.Equal(code[:], a.mac.Sum(nil)[:10]) {
+			return n, errors.New("authentication failed")
+		}
+	}
+	return n, err
+}
+
+// pbkdf2SHA1 derives a key from a password as in RFC 8018.
+func pbkdf2SHA1(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha1.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
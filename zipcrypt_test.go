@@ -0,0 +1,71 @@
+// Copyright 2021 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build !minimal
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA1(t *testing.T) {
+	// The test vectors of RFC 6070.
+	tests := []struct {
+		password, salt string
+		iter, keyLen   int
+		want           string
+	}{
+		{"password", "salt", 1, 20, "0c60c80f961f0e71f3a9b524af6012062fe037a6"},
+		{"password", "salt", 4096, 20, "4b007901b765489abead49d926f721d065a429c1"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 25, "3d2eec4fe41c849b80c8d83662c0e44a8b291a964cf2f07038"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA1([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iter, got, tt.want)
+		}
+	}
+}
+
+// The archives in testdata were made by zip -P hunter2, with the
+// traditional encryption, and by bsdtar --options
+// zip:encryption=aes256 --passphrase hunter2, which uses AE-2 for the
+// small file and AE-1 for the large one.
+func TestMainZipPassword(t *testing.T) {
+	defer func() { opts = options{} }()
+	oldTTY := openTTY
+	defer func() { openTTY = oldTTY }()
+
+	big := strings.Repeat("compressible line\n", 50)
+	for _, name := range []string{"testdata/zipcrypto.zip", "testdata/aes.zip"} {
+		if out := runMain("--archive-password=hunter2", name+"!/notes.txt", name+"!/big.txt"); out != "secret notes\n"+big {
+			t.Errorf("%s: unexpected output %q", name, out)
+		}
+		want := "cat: " + name + "!/notes.txt: incorrect password\n"
+		if out := runMain("--archive-password=wrong", name+"!/notes.txt"); out != want {
+			t.Errorf("%s: got %q, want %q", name, out, want)
+		}
+
+		// The password is asked for once, if there is a terminal.
+		asked := 0
+		openTTY = func() (io.ReadCloser, error) {
+			asked++
+			return io.NopCloser(strings.NewReader("hunter2\n")), nil
+		}
+		want = "cat: password for " + name + ": " + big + "secret notes\n"
+		if out := runMain("-r", name); out != want || asked != 1 {
+			t.Errorf("%s: unexpected output %q, asked %d times", name, out, asked)
+		}
+		openTTY = func() (io.ReadCloser, error) { return nil, errors.New("no terminal") }
+		want = "cat: " + name + "!/notes.txt: encrypted, give the password with --archive-password\n"
+		if out := runMain(name + "!/notes.txt"); out != want {
+			t.Errorf("%s: got %q, want %q", name, out, want)
+		}
+	}
+}